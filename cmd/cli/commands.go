@@ -1,19 +1,31 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ryan/ralph-o-matic/internal/cli"
+	"github.com/ryan/ralph-o-matic/internal/git"
 	"github.com/ryan/ralph-o-matic/internal/models"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 func submitCmd() *cobra.Command {
-	var prompt, priority, workingDir string
-	var maxIterations int
+	var prompt, priority, workingDir, executorName, verifyCommand, stallPolicy, modelPlan string
+	var largeModel, smallModel, ollamaHost string
+	var endpointTags, secrets []string
+	var maxIterations, stallWindow int
+	var maxDuration, iterationTimeout time.Duration
+	var maxTokens int64
 	var openEnded bool
 
 	cmd := &cobra.Command{
@@ -34,6 +46,11 @@ func submitCmd() *cobra.Command {
 				}
 			}
 
+			plan, err := cli.ParseModelPlan(modelPlan)
+			if err != nil {
+				return fmt.Errorf("invalid --model-plan: %w", err)
+			}
+
 			if priority == "" {
 				priority = cfg.DefaultPriority
 			}
@@ -48,6 +65,26 @@ func submitCmd() *cobra.Command {
 				MaxIterations: maxIterations,
 				Priority:      priority,
 				WorkingDir:    workingDir,
+				Executor:      executorName,
+				VerifyCommand: verifyCommand,
+				StallWindow:   stallWindow,
+				StallPolicy:   stallPolicy,
+				ModelPlan:     plan,
+				LargeModel:    largeModel,
+				SmallModel:    smallModel,
+				OllamaHost:    ollamaHost,
+				EndpointTags:  endpointTags,
+				Secrets:       secrets,
+				MaxTokens:     maxTokens,
+			}
+			if email, err := git.New().UserEmail(cmd.Context(), ""); err == nil {
+				req.Owner = email
+			}
+			if maxDuration > 0 {
+				req.MaxDuration = maxDuration.String()
+			}
+			if iterationTimeout > 0 {
+				req.PerIterationTimeout = iterationTimeout.String()
 			}
 
 			fmt.Println("Submitting job...")
@@ -55,6 +92,30 @@ func submitCmd() *cobra.Command {
 			fmt.Printf("  Branch:        %s\n", branch)
 			fmt.Printf("  Max iterations: %d\n", maxIterations)
 			fmt.Printf("  Priority:      %s\n", priority)
+			if executorName != "" {
+				fmt.Printf("  Executor:      %s\n", executorName)
+			}
+			if verifyCommand != "" {
+				fmt.Printf("  Verify:        %s\n", verifyCommand)
+			}
+			if largeModel != "" || smallModel != "" {
+				fmt.Printf("  Models:        %s\n", formatModels(largeModel, smallModel))
+			}
+			if modelPlan != "" {
+				fmt.Printf("  Model plan:    %s\n", modelPlan)
+			}
+			if ollamaHost != "" {
+				fmt.Printf("  Ollama:        %s\n", ollamaHost)
+			}
+			if len(endpointTags) > 0 {
+				fmt.Printf("  Endpoint tags: %s\n", strings.Join(endpointTags, ", "))
+			}
+			if len(secrets) > 0 {
+				fmt.Printf("  Secrets:       %s\n", strings.Join(secrets, ", "))
+			}
+			if budget := formatBudget(maxDuration, iterationTimeout, maxTokens); budget != "" {
+				fmt.Printf("  Budget:        %s\n", budget)
+			}
 
 			job, err := client.CreateJob(req)
 			if err != nil {
@@ -72,12 +133,28 @@ func submitCmd() *cobra.Command {
 	cmd.Flags().IntVar(&maxIterations, "max-iterations", 0, "Max iterations")
 	cmd.Flags().StringVar(&workingDir, "working-dir", "", "Working directory")
 	cmd.Flags().BoolVar(&openEnded, "open-ended", false, "Use open-ended prompt")
+	cmd.Flags().StringVar(&verifyCommand, "verify", "", "Command that must pass before the job counts as complete (e.g. \"go test ./...\")")
+	cmd.Flags().StringVar(&executorName, "executor", "", "Agent backend: claude, aider, opencode, ollama (default: server setting)")
+	cmd.Flags().IntVar(&stallWindow, "stall-window", 0, "Iterations without progress before the job counts as stalled (default 4)")
+	cmd.Flags().StringVar(&stallPolicy, "stall-policy", "", "When stalled: hint, fail, escalate, off (default: hint)")
+	cmd.Flags().StringVar(&largeModel, "large-model", "", "Large model for this job (default: server setting)")
+	cmd.Flags().StringVar(&smallModel, "small-model", "", "Small model for this job (default: server setting)")
+	cmd.Flags().StringVar(&ollamaHost, "ollama-host", "", "One of the server's Ollama hosts to run this job on, e.g. http://gpu-box:11434 (default: server setting)")
+	cmd.Flags().StringSliceVar(&endpointTags, "endpoint-tag", nil, "Only run on Ollama endpoints with this tag (repeatable)")
+	cmd.Flags().StringSliceVar(&secrets, "secret", nil, "Server secret to set as an env var of the same name (repeatable)")
+	cmd.Flags().StringVar(&modelPlan, "model-plan", "", "Models to escalate through, e.g. \"qwen2.5-coder:14b@10+stall,qwen3-coder:70b\"")
+	cmd.Flags().DurationVar(&maxDuration, "max-duration", 0, "Fail the job after this much running time (e.g. 4h)")
+	cmd.Flags().DurationVar(&iterationTimeout, "iteration-timeout", 0, "Stop any single iteration that runs longer than this (e.g. 45m)")
+	cmd.Flags().Int64Var(&maxTokens, "max-tokens", 0, "Fail the job once it has used this many tokens")
 
 	return cmd
 }
 
 func statusCmd() *cobra.Command {
-	return &cobra.Command{
+	var owner string
+	var mine bool
+
+	cmd := &cobra.Command{
 		Use:   "status [job-id]",
 		Short: "Show queue status or specific job details",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -97,8 +174,16 @@ func statusCmd() *cobra.Command {
 				return nil
 			}
 
+			if mine {
+				me, err := currentUser(cmd.Context())
+				if err != nil {
+					return err
+				}
+				owner = me
+			}
+
 			// Show queue overview
-			jobs, _, err := client.GetJobs(nil)
+			jobs, _, err := client.GetJobs(nil, owner)
 			if err != nil {
 				return err
 			}
@@ -107,10 +192,30 @@ func statusCmd() *cobra.Command {
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&mine, "mine", false, "Only show jobs you submitted")
+	cmd.Flags().StringVar(&owner, "owner", "", "Only show jobs submitted by this user")
+	return cmd
+}
+
+// currentUser returns who the server records as the owner of jobs this CLI
+// submits: the token's name if the server authenticates, otherwise the git
+// user.email
+func currentUser(ctx context.Context) (string, error) {
+	if me, err := client.WhoAmI(); err == nil && me.ID != 0 {
+		return me.Name, nil
+	}
+	email, err := git.New().UserEmail(ctx, "")
+	if err != nil || email == "" {
+		return "", fmt.Errorf("cannot tell who you are: log in with `ralph-o-matic login` or set git user.email")
+	}
+	return email, nil
 }
 
 func logsCmd() *cobra.Command {
 	var follow bool
+	var iteration, tail int
+	var since string
 
 	cmd := &cobra.Command{
 		Use:   "logs <job-id>",
@@ -122,22 +227,494 @@ func logsCmd() *cobra.Command {
 				return fmt.Errorf("invalid job ID")
 			}
 
-			logs, err := client.GetLogs(id)
+			opts := cli.LogOptions{Iteration: iteration, Tail: tail}
+			if since != "" {
+				opts.Since, err = cli.ParseSince(since, time.Now())
+				if err != nil {
+					return err
+				}
+			}
+
+			if !follow {
+				logs, err := client.GetLogs(id, opts)
+				if err != nil {
+					return err
+				}
+
+				for _, entry := range logs {
+					printLogEntry(entry)
+				}
+				return nil
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			job, err := client.FollowLogs(ctx, id, opts, printLogEntry, func(err error) {
+				fmt.Fprintf(os.Stderr, "Lost connection (%v), reconnecting...\n", err)
+			})
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil // Interrupted by the user
+				}
+				return err
+			}
+
+			fmt.Printf("\nJob #%d %s\n", job.ID, job.Status)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Stream logs in real-time until the job finishes")
+	cmd.Flags().IntVar(&iteration, "iteration", 0, "Only show logs from this iteration")
+	cmd.Flags().StringVar(&since, "since", "", "Only show logs newer than a duration (e.g. 10m) or RFC3339 time")
+	cmd.Flags().IntVar(&tail, "tail", 0, "Only show the last N lines")
+	return cmd
+}
+
+func timelineCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "timeline <job-id>",
+		Short: "Show a job's iterations with their commits, diffstats and token usage",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid job ID")
+			}
+
+			iterations, err := client.GetIterations(id)
+			if err != nil {
+				return err
+			}
+
+			if len(iterations) == 0 {
+				fmt.Printf("Job #%d has not run any iterations\n", id)
+				return nil
+			}
+
+			printTimeline(iterations)
+			return nil
+		},
+	}
+}
+
+func endpointsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "endpoints",
+		Short: "Show the health and load of the server's Ollama endpoints",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			endpoints, err := client.GetEndpoints()
+			if err != nil {
+				return err
+			}
+
+			if len(endpoints) == 0 {
+				fmt.Println("No endpoint pool configured; jobs use the server's Ollama host")
+				return nil
+			}
+
+			fmt.Printf("%-16s %-32s %-8s %-6s %-16s %s\n", "NAME", "HOST", "HEALTH", "JOBS", "TAGS", "MODELS")
+			for _, e := range endpoints {
+				health := "up"
+				if !e.Healthy {
+					health = "down"
+				}
+				tags := strings.Join(e.Tags, ",")
+				if tags == "" {
+					tags = "-"
+				}
+				fmt.Printf("%-16s %-32s %-8s %-6s %-16s %d\n",
+					e.Name, e.Host, health, fmt.Sprintf("%d/%d", e.Active, e.Capacity), tags, len(e.Models))
+				if e.Error != "" {
+					fmt.Printf("  %s\n", e.Error)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func doctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the server's environment and suggest fixes",
+		Long: `Check that the server can run jobs: git, gh and claude installed and
+logged in, Ollama reachable with its models, a writable workspace with free
+disk, an up-to-date database and detectable hardware.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.Ping(); err != nil {
+				printCheck(cli.DoctorCheck{
+					Name:   "server",
+					Status: "fail",
+					Detail: err.Error(),
+					Fix:    fmt.Sprintf("Start ralph-o-matic-server, or point the CLI at it with `ralph-o-matic config set server <url>` (now %s)", cfg.Server),
+				})
+				return fmt.Errorf("server is not reachable")
+			}
+			printCheck(cli.DoctorCheck{Name: "server", Status: "ok", Detail: cfg.Server})
+
+			report, err := client.Doctor()
+			if err != nil {
+				return err
+			}
+			for _, c := range report.Checks {
+				printCheck(c)
+			}
+
+			if !report.Healthy {
+				return fmt.Errorf("jobs cannot run until the failed checks are fixed")
+			}
+			return nil
+		},
+	}
+}
+
+// printCheck prints one diagnostic, followed by its fix if it has one
+func printCheck(c cli.DoctorCheck) {
+	fmt.Printf("%-5s %-28s %s\n", strings.ToUpper(c.Status), c.Name, c.Detail)
+	if c.Fix != "" {
+		fmt.Printf("      fix: %s\n", c.Fix)
+	}
+}
+
+func loginCmd() *cobra.Command {
+	var token string
+
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Store the API token the CLI authenticates with",
+		Long: `Check an API token against the server and store it in the CLI config.
+The server prints an admin token the first time it starts; create more with
+` + "`ralph-o-matic tokens create`" + `.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if token == "" {
+				fmt.Print("Token: ")
+				line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+				if err != nil && line == "" {
+					return fmt.Errorf("failed to read token: %w", err)
+				}
+				token = strings.TrimSpace(line)
+			}
+			if token == "" {
+				return fmt.Errorf("no token given")
+			}
+
+			client.SetToken(token)
+			me, err := client.WhoAmI()
+			if err != nil {
+				return err
+			}
+
+			cfg.Token = token
+			if err := cli.SaveConfig(cli.ConfigPath(), cfg); err != nil {
+				return err
+			}
+			fmt.Printf("Logged in to %s as %s (%s)\n", cfg.Server, me.Name, formatScopes(me.Scopes))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&token, "token", "", "API token (prompted for if not given)")
+	return cmd
+}
+
+func tokensCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tokens",
+		Short: "Manage the server's API tokens (needs the admin scope)",
+	}
+
+	var scopes string
+	create := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a token and print its secret",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			parsed, err := models.ParseScopes(scopes)
+			if err != nil {
+				return err
+			}
+			token, err := client.CreateToken(args[0], parsed)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Token #%d for %s (%s):\n%s\n", token.ID, token.Name, formatScopes(token.Scopes), token.Secret)
+			fmt.Println("Store it now; it is not shown again.")
+			return nil
+		},
+	}
+	create.Flags().StringVar(&scopes, "scopes", "submit", "comma-separated scopes: submit, control, admin")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List tokens",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tokens, err := client.ListTokens()
+			if err != nil {
+				return err
+			}
+			if len(tokens) == 0 {
+				fmt.Println("No tokens")
+				return nil
+			}
+			for _, t := range tokens {
+				lastUsed := "never used"
+				if t.LastUsedAt != nil {
+					lastUsed = "last used " + t.LastUsedAt.Local().Format("2006-01-02 15:04")
+				}
+				fmt.Printf("#%-4d %-20s %-22s %s\n", t.ID, t.Name, formatScopes(t.Scopes), lastUsed)
+			}
+			return nil
+		},
+	}
+
+	rm := &cobra.Command{
+		Use:   "rm <token-id>",
+		Short: "Revoke a token",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid token ID")
+			}
+			if err := client.DeleteToken(id); err != nil {
+				return err
+			}
+			fmt.Printf("Token #%d revoked\n", id)
+			return nil
+		},
+	}
+
+	cmd.AddCommand(create, list, rm)
+	return cmd
+}
+
+func secretsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Manage the secrets jobs can use (needs the admin scope)",
+		Long: `Store values such as API keys encrypted on the server. A job submitted
+with --secret NAME gets the value as the environment variable NAME, and it is
+replaced with [REDACTED] wherever it shows up in logs or job details. Only
+admins and the users a secret lists may submit jobs using it.`,
+	}
+
+	var users []string
+	set := &cobra.Command{
+		Use:   "set <name>",
+		Short: "Store a secret, reading the value from stdin",
+		Long: `Store a secret. The value is read from stdin, or prompted for without
+echoing it, so that it stays out of shell history and process listings. Piped
+values are kept whole but for a final newline, so keys spanning several lines
+survive:
+
+  ralph-o-matic secrets set NPM_TOKEN --users alice,ci < token.txt
+  ralph-o-matic secrets set DEPLOY_KEY < id_ed25519`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if err := models.ValidateSecretName(name); err != nil {
+				return err
+			}
+			value, err := readSecret(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read value: %w", err)
+			}
+			if value == "" {
+				return fmt.Errorf("no value given")
+			}
+
+			if err := client.SetSecret(name, value, users); err != nil {
+				return err
+			}
+			fmt.Printf("Secret %s stored\n", name)
+			return nil
+		},
+	}
+	set.Flags().StringSliceVar(&users, "users", nil, "Token names, besides admins, allowed to submit jobs using the secret")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List secret names",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			secrets, err := client.ListSecrets()
 			if err != nil {
 				return err
 			}
+			if len(secrets) == 0 {
+				fmt.Println("No secrets")
+				return nil
+			}
+			for _, s := range secrets {
+				users := "admins only"
+				if len(s.Users) > 0 {
+					users = "admins, " + strings.Join(s.Users, ", ")
+				}
+				fmt.Printf("%-30s %-30s updated %s\n", s.Name, users, s.UpdatedAt.Local().Format("2006-01-02 15:04"))
+			}
+			return nil
+		},
+	}
 
-			for _, log := range logs {
-				fmt.Printf("[iter %v] %v\n", log["iteration"], log["message"])
+	rm := &cobra.Command{
+		Use:   "rm <name>",
+		Short: "Delete a secret",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := client.DeleteSecret(args[0]); err != nil {
+				return err
 			}
+			fmt.Printf("Secret %s deleted\n", args[0])
 			return nil
 		},
 	}
 
-	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "Stream logs in real-time")
+	cmd.AddCommand(set, list, rm)
 	return cmd
 }
 
+// readSecret reads a secret's value from in: prompted for without echo when
+// in is a terminal, else everything piped in less a final newline
+func readSecret(in *os.File) (string, error) {
+	fd := int(in.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Print("Value: ")
+		value, err := term.ReadPassword(fd)
+		fmt.Println()
+		return string(value), err
+	}
+
+	value, err := io.ReadAll(in)
+	if err != nil {
+		return "", err
+	}
+	s := strings.TrimSuffix(string(value), "\n")
+	return strings.TrimSuffix(s, "\r"), nil
+}
+
+// formatScopes describes what a token may do besides reading
+func formatScopes(scopes []models.Scope) string {
+	if len(scopes) == 0 {
+		return "read only"
+	}
+	parts := make([]string, len(scopes))
+	for i, s := range scopes {
+		parts[i] = string(s)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func modelsCmd() *cobra.Command {
+	var host string
+
+	cmd := &cobra.Command{
+		Use:   "models",
+		Short: "List, pull and remove models on the server's Ollama hosts",
+	}
+	cmd.PersistentFlags().StringVar(&host, "host", "", "Only this Ollama host (default: every host the server uses)")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List installed models",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hosts, err := client.ListModels(host)
+			if err != nil {
+				return err
+			}
+
+			for i, h := range hosts {
+				if i > 0 {
+					fmt.Println()
+				}
+				fmt.Println(h.Host)
+				if h.Error != "" {
+					fmt.Printf("  %s\n", h.Error)
+					continue
+				}
+				if len(h.Models) == 0 {
+					fmt.Println("  No models installed")
+					continue
+				}
+				for _, m := range h.Models {
+					fmt.Printf("  %-40s %6.1f GB\n", m.Name, m.SizeGB)
+				}
+			}
+			return nil
+		},
+	}
+
+	pullCmd := &cobra.Command{
+		Use:   "pull <model>",
+		Short: "Pull a model, showing download progress",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			var progress pullPrinter
+			err := client.PullModel(ctx, args[0], host, progress.print)
+			progress.end()
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Pulled %s\n", args[0])
+			return nil
+		},
+	}
+
+	rmCmd := &cobra.Command{
+		Use:     "rm <model>",
+		Aliases: []string{"remove"},
+		Short:   "Remove a model",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hosts, err := client.DeleteModel(args[0], host)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Removed %s from %s\n", args[0], strings.Join(hosts, ", "))
+			return nil
+		},
+	}
+
+	cmd.AddCommand(listCmd, pullCmd, rmCmd)
+	return cmd
+}
+
+// pullPrinter shows a model pull's progress: one line per status, with
+// downloads updating their line in place
+type pullPrinter struct {
+	status string
+	inline bool // the current line is a download still being redrawn
+}
+
+func (p *pullPrinter) print(e *cli.PullEvent) {
+	if e.Status != p.status {
+		p.end()
+		p.status = e.Status
+		if e.Total == 0 {
+			fmt.Printf("%s: %s\n", e.Host, e.Status)
+			return
+		}
+	}
+	if e.Total > 0 {
+		fmt.Printf("\r%s: %s %3d%% of %.1f GB", e.Host, e.Status, e.Completed*100/e.Total, float64(e.Total)/(1<<30))
+		p.inline = true
+	}
+}
+
+// end finishes a download line left open by print
+func (p *pullPrinter) end() {
+	if p.inline {
+		fmt.Println()
+		p.inline = false
+	}
+}
+
 func cancelCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "cancel <job-id>",
@@ -220,7 +797,7 @@ func moveCmd() *cobra.Command {
 			}
 
 			// Get current queue
-			jobs, _, err := client.GetJobs([]string{"queued"})
+			jobs, _, err := client.GetJobs([]string{"queued"}, "")
 			if err != nil {
 				return err
 			}
@@ -271,6 +848,9 @@ func configCmd() *cobra.Command {
 				fmt.Printf("server: %s\n", cfg.Server)
 				fmt.Printf("default_priority: %s\n", cfg.DefaultPriority)
 				fmt.Printf("default_max_iterations: %d\n", cfg.DefaultMaxIterations)
+				if cfg.Token != "" {
+					fmt.Println("token: (set; see `ralph-o-matic login`)")
+				}
 				return nil
 			}
 
@@ -313,6 +893,7 @@ func serverConfigCmd() *cobra.Command {
 				fmt.Printf("ollama_host: %s (remote: %v)\n", serverCfg.Ollama.Host, serverCfg.Ollama.IsRemote)
 				fmt.Printf("large_model: %s (device: %s, %.1fGB)\n", serverCfg.LargeModel.Name, serverCfg.LargeModel.Device, serverCfg.LargeModel.MemoryGB)
 				fmt.Printf("small_model: %s (device: %s, %.1fGB)\n", serverCfg.SmallModel.Name, serverCfg.SmallModel.Device, serverCfg.SmallModel.MemoryGB)
+				fmt.Printf("executor: %s\n", serverCfg.Executor)
 				fmt.Printf("default_max_iterations: %d\n", serverCfg.DefaultMaxIterations)
 				fmt.Printf("concurrent_jobs: %d\n", serverCfg.ConcurrentJobs)
 				return nil
@@ -366,7 +947,7 @@ func printQueueOverview(jobs []*models.Job) {
 	var running, paused, queued []*models.Job
 	for _, j := range jobs {
 		switch j.Status {
-		case "running":
+		case "preparing", "running":
 			running = append(running, j)
 		case "paused":
 			paused = append(paused, j)
@@ -378,34 +959,148 @@ func printQueueOverview(jobs []*models.Job) {
 	if len(running) > 0 {
 		fmt.Println("\nRUNNING")
 		for _, j := range running {
-			fmt.Printf("  #%d %s    iter %d/%d\n", j.ID, j.Branch, j.Iteration, j.MaxIterations)
+			if j.Status == models.StatusPreparing {
+				fmt.Printf("  #%d %s    preparing%s\n", j.ID, j.Branch, formatOwner(j.Owner))
+				continue
+			}
+			fmt.Printf("  #%d %s    iter %d/%d%s\n", j.ID, j.Branch, j.Iteration, j.MaxIterations, formatOwner(j.Owner))
 		}
 	}
 
 	if len(paused) > 0 {
 		fmt.Println("\nPAUSED")
 		for _, j := range paused {
-			fmt.Printf("  #%d %s    iter %d/%d\n", j.ID, j.Branch, j.Iteration, j.MaxIterations)
+			fmt.Printf("  #%d %s    iter %d/%d%s\n", j.ID, j.Branch, j.Iteration, j.MaxIterations, formatOwner(j.Owner))
 		}
 	}
 
 	if len(queued) > 0 {
 		fmt.Printf("\nQUEUED (%d)\n", len(queued))
 		for _, j := range queued {
-			fmt.Printf("  #%d %s    %s\n", j.ID, j.Branch, j.Priority)
+			fmt.Printf("  #%d %s    %s%s\n", j.ID, j.Branch, j.Priority, formatOwner(j.Owner))
+			if j.WaitReason != "" {
+				fmt.Printf("      %s\n", j.WaitReason)
+			}
 		}
 	}
 
 	fmt.Printf("\nDashboard: %s\n", cfg.Server)
 }
 
+// formatOwner returns the owner column of a queue overview line
+func formatOwner(owner string) string {
+	if owner == "" {
+		return ""
+	}
+	return "    " + owner
+}
+
+// formatBudget describes a job's budgets, or returns "" if it has none
+func formatBudget(maxDuration, iterationTimeout time.Duration, maxTokens int64) string {
+	var parts []string
+	if maxDuration > 0 {
+		parts = append(parts, fmt.Sprintf("%s total", maxDuration))
+	}
+	if iterationTimeout > 0 {
+		parts = append(parts, fmt.Sprintf("%s per iteration", iterationTimeout))
+	}
+	if maxTokens > 0 {
+		parts = append(parts, fmt.Sprintf("%d tokens", maxTokens))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func printLogEntry(entry *cli.LogEntry) {
+	fmt.Printf("[iter %d] %s\n", entry.Iteration, entry.Message)
+}
+
+// formatModels describes a job's model overrides, leaving out the ones it
+// takes from the server
+func formatModels(large, small string) string {
+	var parts []string
+	if large != "" {
+		parts = append(parts, "large "+large)
+	}
+	if small != "" {
+		parts = append(parts, "small "+small)
+	}
+	return strings.Join(parts, ", ")
+}
+
 func printJobDetail(job *models.Job) {
 	fmt.Printf("Job #%d\n", job.ID)
 	fmt.Printf("  Branch:     %s\n", job.Branch)
 	fmt.Printf("  Status:     %s\n", job.Status)
 	fmt.Printf("  Iteration:  %d/%d\n", job.Iteration, job.MaxIterations)
 	fmt.Printf("  Priority:   %s\n", job.Priority)
+	if job.Owner != "" {
+		fmt.Printf("  Owner:      %s\n", job.Owner)
+	}
+	if job.LargeModel != "" || job.SmallModel != "" {
+		fmt.Printf("  Models:     %s\n", formatModels(job.LargeModel, job.SmallModel))
+	}
+	if job.OllamaHost != "" {
+		fmt.Printf("  Ollama:     %s\n", job.OllamaHost)
+	} else if job.Endpoint != "" {
+		fmt.Printf("  Endpoint:   %s\n", job.Endpoint)
+	}
+	if budget := formatBudget(job.MaxDuration, job.PerIterationTimeout, job.MaxTokens); budget != "" {
+		fmt.Printf("  Budget:     %s\n", budget)
+	}
+	if len(job.Secrets) > 0 {
+		fmt.Printf("  Secrets:    %s\n", strings.Join(job.Secrets, ", "))
+	}
+	if job.WaitReason != "" {
+		fmt.Printf("  Waiting:    %s\n", job.WaitReason)
+	}
+	if job.Error != "" {
+		fmt.Printf("  Error:      %s\n", job.Error)
+	}
 	if job.PRURL != "" {
 		fmt.Printf("  PR:         %s\n", job.PRURL)
 	}
 }
+
+func printTimeline(iterations []*models.Iteration) {
+	fmt.Printf("%-5s %-20s %-9s %-9s %-5s %-16s %-10s %s\n", "ITER", "MODEL", "COMMIT", "DURATION", "EXIT", "CHANGES", "TOKENS", "RESULT")
+
+	var total models.TokenUsage
+	var insertions, deletions int
+	for _, it := range iterations {
+		commit := it.CommitHash
+		if commit == "" {
+			commit = "-"
+		}
+		model := it.Model
+		if model == "" {
+			model = "-"
+		}
+		changes := fmt.Sprintf("%d files +%d -%d", it.FilesChanged, it.Insertions, it.Deletions)
+		fmt.Printf("%-5d %-20s %-9s %-9s %-5d %-16s %-10d %s\n",
+			it.Number, model, commit, it.Duration().Round(time.Second), it.ExitCode, changes, it.Usage.Total(), iterationResult(it))
+
+		total.Add(it.Usage)
+		insertions += it.Insertions
+		deletions += it.Deletions
+	}
+
+	fmt.Printf("\n%d iterations, +%d -%d, %d tokens\n", len(iterations), insertions, deletions, total.Total())
+}
+
+// iterationResult summarizes how an iteration ended
+func iterationResult(it *models.Iteration) string {
+	switch {
+	case it.FinishedAt == nil:
+		return "running"
+	case it.VerifyPassed != nil && *it.VerifyPassed:
+		return "verified"
+	case it.VerifyPassed != nil:
+		return "verify failed"
+	case it.Completed:
+		return "completed"
+	case it.Error != "":
+		return "error: " + it.Error
+	default:
+		return "continued"
+	}
+}
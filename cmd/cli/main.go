@@ -25,6 +25,10 @@ func main() {
 	}
 
 	client = cli.NewClient(cfg.Server)
+	client.SetToken(cfg.Token)
+	if v := os.Getenv("RALPH_TOKEN"); v != "" {
+		client.SetToken(v)
+	}
 
 	rootCmd := &cobra.Command{
 		Use:     "ralph-o-matic",
@@ -36,6 +40,13 @@ func main() {
 		submitCmd(),
 		statusCmd(),
 		logsCmd(),
+		timelineCmd(),
+		endpointsCmd(),
+		modelsCmd(),
+		doctorCmd(),
+		loginCmd(),
+		tokensCmd(),
+		secretsCmd(),
 		cancelCmd(),
 		pauseCmd(),
 		resumeCmd(),
@@ -11,9 +11,27 @@ import (
 
 	"github.com/ryan/ralph-o-matic/internal/api"
 	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/doctor"
+	"github.com/ryan/ralph-o-matic/internal/executor"
+	"github.com/ryan/ralph-o-matic/internal/git"
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/platform"
 	"github.com/ryan/ralph-o-matic/internal/queue"
+	"github.com/ryan/ralph-o-matic/internal/sandbox"
 )
 
+// drainTimeout is how long running jobs get to finish on shutdown before
+// they are cancelled.
+const drainTimeout = 30 * time.Second
+
+// endpointCheckInterval is how often the Ollama endpoint pool is
+// health-checked
+const endpointCheckInterval = 30 * time.Second
+
+// monitorInterval is how often the Ollama hosts are asked which models they
+// have loaded
+const monitorInterval = 10 * time.Second
+
 // version is set via -ldflags at build time.
 var version = "dev"
 
@@ -22,6 +40,9 @@ func main() {
 		fmt.Printf("ralph-o-matic-server %s\n", version)
 		os.Exit(0)
 	}
+	if len(os.Args) > 1 && os.Args[1] == sandbox.HelperArg {
+		os.Exit(sandbox.RunHelper(os.Args[2:]))
+	}
 
 	if err := run(); err != nil {
 		log.Fatal(err)
@@ -49,23 +70,170 @@ func run() error {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	cfg, err := db.NewConfigRepo(database).Get()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Secrets are encrypted with a key kept outside the database, so a copy
+	// of ralph.db alone does not give them away
+	keyPath := dbPath + ".key"
+	if v := os.Getenv("RALPH_SECRET_KEY_FILE"); v != "" {
+		keyPath = v
+	}
+	key, err := db.LoadSecretKey(keyPath)
+	if err != nil {
+		return err
+	}
+	secrets, err := db.NewSecretRepo(database, key)
+	if err != nil {
+		return fmt.Errorf("failed to open secret store: %w", err)
+	}
+
+	workspaceDir := cfg.WorkspaceDir
+	if v := os.Getenv("RALPH_WORKSPACE"); v != "" {
+		workspaceDir = v
+	}
+	if workspaceDir == "" {
+		workspaceDir = "workspace"
+	}
+	if err := os.MkdirAll(workspaceDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create workspace dir: %w", err)
+	}
+
 	q := queue.New(database)
-	srv := api.NewServer(database, q, addr)
+	q.SetQuota(cfg.MaxJobsPerUser)
+	q.SetPolicy(queue.NewPolicy(cfg.Scheduling))
+	handler := executor.NewRalphHandler(database, cfg, workspaceDir)
+	handler.SetSecrets(secrets)
+	sched := queue.NewScheduler(q, handler.Handle)
+	sched.SetPreparer(handler.Prepare)
+	sched.SetConcurrency(cfg.ConcurrentJobs)
+
+	// Reconcile jobs left running by a previous server process
+	recovered, err := q.Recover(queue.RecoveryOptions{
+		Owner:      sched.Owner(),
+		LeaseTTL:   queue.DefaultLeaseTTL,
+		MaxRetries: cfg.MaxClaudeRetries,
+		Workspaces: git.NewRepoManager(workspaceDir),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to recover orphaned jobs: %w", err)
+	}
+	if n := len(recovered.Requeued) + len(recovered.Failed); n > 0 {
+		log.Printf("Recovered %d orphaned job(s): %d requeued, %d failed",
+			n, len(recovered.Requeued), len(recovered.Failed))
+	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// Route jobs across the Ollama endpoint pool, if one is configured
+	pool := platform.NewPool(cfg)
+	pool.Check(ctx)
+	go pool.Run(ctx, endpointCheckInterval)
+	sched.SetRouter(pool)
+
+	// Watch what the Ollama hosts have loaded, for the dashboard
+	monitor := platform.NewMonitor(cfg)
+	monitor.Poll(ctx)
+	go monitor.Run(ctx, monitorInterval)
+
+	// Hold jobs back until this machine has the memory for their models
+	admission, err := newAdmission(cfg)
+	if err != nil {
+		log.Printf("Memory admission disabled: %v", err)
+	} else {
+		admission.SetMonitor(monitor)
+		sched.SetAdmitter(admission)
+	}
+
+	srv := api.NewServer(database, q, addr)
+	srv.SetScheduler(sched)
+	srv.SetPool(pool)
+	srv.SetMonitor(monitor)
+	srv.SetDoctor(doctor.New(database, workspaceDir))
+	srv.SetSecrets(secrets)
+	srv.SetHandler(handler)
+	if admission != nil {
+		srv.SetAdmission(admission)
+	}
+	srv.SetCORSOrigin(os.Getenv("RALPH_CORS_ORIGIN"))
+	if os.Getenv("RALPH_AUTH") == "off" {
+		log.Println("WARNING: authentication is disabled; anyone who can reach the server can run code on it")
+	} else {
+		if err := bootstrapToken(database); err != nil {
+			return err
+		}
+		srv.EnableAuth()
+	}
+
 	go func() {
 		if err := srv.Start(); err != nil {
 			log.Printf("Server stopped: %v", err)
 		}
 	}()
 
-	log.Printf("ralph-o-matic-server %s listening on %s", version, addr)
+	schedDone := make(chan struct{})
+	go func() {
+		sched.Start(ctx)
+		close(schedDone)
+	}()
+
+	log.Printf("ralph-o-matic-server %s listening on %s (workspace: %s, concurrent jobs: %d)",
+		version, addr, workspaceDir, cfg.ConcurrentJobs)
 	<-ctx.Done()
+	<-schedDone
 
 	log.Println("Shutting down...")
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	return srv.Shutdown(shutdownCtx)
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown error: %v", err)
+	}
+
+	log.Printf("Waiting up to %s for %d running job(s)...", drainTimeout, sched.ActiveJobs())
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancelDrain()
+	if err := sched.Shutdown(drainCtx); err != nil {
+		log.Printf("Running jobs were interrupted and requeued: %v", err)
+	}
+
+	return nil
+}
+
+// newAdmission creates memory admission control for the hardware of this
+// machine, sized with the models in the built-in catalog
+func newAdmission(cfg *models.ServerConfig) (*platform.Admission, error) {
+	hw, err := platform.DetectHardware()
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := platform.LoadEmbeddedCatalog()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Admitting jobs within %.1fGB of model memory", hw.ModelMemoryGB())
+	return platform.NewAdmission(cfg, hw, catalog), nil
+}
+
+// bootstrapToken creates an admin token on first start, so that there is a
+// way in once authentication is on. Its secret is only ever shown here.
+func bootstrapToken(database *db.DB) error {
+	tokens := db.NewTokenRepo(database)
+	n, err := tokens.Count()
+	if err != nil {
+		return fmt.Errorf("failed to count tokens: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	secret, err := tokens.Create(&models.Token{Name: "admin", Scopes: []models.Scope{models.ScopeAdmin}})
+	if err != nil {
+		return fmt.Errorf("failed to create admin token: %w", err)
+	}
+	log.Printf("Created admin token (shown once, store it now): %s", secret)
+	log.Printf("Log in with: ralph-o-matic login --token %s", secret)
+	return nil
 }
@@ -0,0 +1,267 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// sessionCookie holds the ID of a dashboard session
+const sessionCookie = "ralph_session"
+
+// sessionTTL is how long a dashboard login lasts
+const sessionTTL = 7 * 24 * time.Hour
+
+type contextKey int
+
+const tokenKey contextKey = iota
+
+// anonymous stands in for a token while authentication is disabled, when
+// every request may do anything
+var anonymous = &models.Token{Name: "anonymous", Scopes: []models.Scope{models.ScopeAdmin}}
+
+// tokenFrom returns the token a request was authenticated with. While
+// authentication is disabled it is the anonymous token.
+func tokenFrom(ctx context.Context) *models.Token {
+	if token, ok := ctx.Value(tokenKey).(*models.Token); ok {
+		return token
+	}
+	return anonymous
+}
+
+// EnableAuth requires a token, or a dashboard session opened with one, on
+// every request except the health check and the login page
+func (s *Server) EnableAuth() {
+	s.auth = true
+	s.dashboard.SetAuth(true)
+}
+
+// requestToken resolves the bearer token or session cookie of a request. It
+// returns ErrNotFound if the request carries neither or they are not valid.
+func (s *Server) requestToken(r *http.Request) (*models.Token, error) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		secret, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			return nil, db.ErrNotFound
+		}
+		return s.tokens.Authenticate(strings.TrimSpace(secret))
+	}
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		return s.tokens.SessionToken(cookie.Value)
+	}
+	return nil, db.ErrNotFound
+}
+
+// authenticateAPI rejects API requests without a valid token with 401
+func (s *Server) authenticateAPI(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.auth {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, err := s.requestToken(r)
+		if errors.Is(err, db.ErrNotFound) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="ralph-o-matic"`)
+			writeError(w, http.StatusUnauthorized, "authentication required")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenKey, token)))
+	})
+}
+
+// authenticatePage redirects dashboard requests without a session to the
+// login page
+func (s *Server) authenticatePage(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.auth {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, err := s.requestToken(r)
+		if errors.Is(err, db.ErrNotFound) {
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), tokenKey, token)))
+	})
+}
+
+// requireScope rejects requests whose token lacks scope with 403
+func (s *Server) requireScope(scope models.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !tokenFrom(r.Context()).HasScope(scope) {
+				writeError(w, http.StatusForbidden, "token lacks the "+string(scope)+" scope")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authorizeJob reports whether the request may change job, writing 403 if
+// not. Admins may change any job; other tokens their own and jobs without an
+// owner.
+func authorizeJob(w http.ResponseWriter, r *http.Request, job *models.Job) bool {
+	token := tokenFrom(r.Context())
+	if job.Owner == "" || job.Owner == token.Name || token.HasScope(models.ScopeAdmin) {
+		return true
+	}
+	writeError(w, http.StatusForbidden, "job belongs to "+job.Owner)
+	return false
+}
+
+func (s *Server) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	s.dashboard.RenderLogin(w, http.StatusOK, r.URL.Query().Get("next"), "")
+}
+
+// handleLogin opens a dashboard session for a valid token
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	next := r.FormValue("next")
+	if !s.auth {
+		http.Redirect(w, r, safeRedirect(next), http.StatusSeeOther)
+		return
+	}
+
+	token, err := s.tokens.Authenticate(strings.TrimSpace(r.FormValue("token")))
+	if errors.Is(err, db.ErrNotFound) {
+		s.dashboard.RenderLogin(w, http.StatusUnauthorized, next, "Invalid token")
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	session, err := s.tokens.CreateSession(token.ID, sessionTTL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    session,
+		Path:     "/",
+		MaxAge:   int(sessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, safeRedirect(next), http.StatusSeeOther)
+}
+
+// handleLogout ends the dashboard session
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		if err := s.tokens.DeleteSession(cookie.Value); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// safeRedirect returns next if it is a path on this server, so the login
+// form cannot be used to send people elsewhere
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// handleWhoAmI returns the token the request was authenticated with
+func (s *Server) handleWhoAmI(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, tokenFrom(r.Context()))
+}
+
+// CreateTokenRequest is the request body for creating a token
+type CreateTokenRequest struct {
+	Name   string         `json:"name"`
+	Scopes []models.Scope `json:"scopes"`
+}
+
+// CreateTokenResponse is a new token with its secret, which is only ever
+// returned here
+type CreateTokenResponse struct {
+	*models.Token
+	Secret string `json:"secret"`
+}
+
+func (s *Server) handleListTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := s.tokens.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if tokens == nil {
+		tokens = []*models.Token{}
+	}
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+func (s *Server) handleCreateToken(w http.ResponseWriter, r *http.Request) {
+	var req CreateTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	token := &models.Token{Name: req.Name, Scopes: req.Scopes}
+	if err := token.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	secret, err := s.tokens.Create(token)
+	if err != nil {
+		if errors.Is(err, db.ErrTokenExists) {
+			writeError(w, http.StatusConflict, err.Error())
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, CreateTokenResponse{Token: token, Secret: secret})
+}
+
+func (s *Server) handleDeleteToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "tokenID"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid token ID")
+		return
+	}
+
+	if err := s.tokens.Delete(id); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "token not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
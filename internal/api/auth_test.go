@@ -0,0 +1,303 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAuthServer returns a server that requires tokens, with a token for
+// each set of scopes by name
+func newAuthServer(t *testing.T) (*Server, map[string]string) {
+	t.Helper()
+	srv, database := newTestServer(t)
+	srv.EnableAuth()
+
+	secrets := make(map[string]string)
+	repo := db.NewTokenRepo(database)
+	for name, scopes := range map[string][]models.Scope{
+		"reader":  nil,
+		"submit":  {models.ScopeSubmit},
+		"control": {models.ScopeControl},
+		"admin":   {models.ScopeAdmin},
+	} {
+		secret, err := repo.Create(&models.Token{Name: name, Scopes: scopes})
+		require.NoError(t, err)
+		secrets[name] = secret
+	}
+	return srv, secrets
+}
+
+func authRequest(srv *Server, method, path, secret string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set("Authorization", "Bearer "+secret)
+	}
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+	return w
+}
+
+func TestAuth_RequiresToken(t *testing.T) {
+	srv, secrets := newAuthServer(t)
+
+	w := authRequest(srv, "GET", "/api/jobs", "", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+
+	w = authRequest(srv, "GET", "/api/jobs", "ralph_bogus", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = authRequest(srv, "GET", "/api/jobs", secrets["reader"], nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = authRequest(srv, "GET", "/health", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code, "health checks stay public")
+}
+
+func TestAuth_Scopes(t *testing.T) {
+	srv, secrets := newAuthServer(t)
+	job, _ := json.Marshal(map[string]any{
+		"repo_url":       "git@github.com:user/repo.git",
+		"branch":         "main",
+		"prompt":         "test",
+		"max_iterations": 10,
+	})
+
+	w := authRequest(srv, "POST", "/api/jobs", secrets["reader"], job)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = authRequest(srv, "POST", "/api/jobs", secrets["submit"], job)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created models.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	order := []byte(fmt.Sprintf(`{"job_ids": [%d]}`, created.ID))
+
+	w = authRequest(srv, "PUT", "/api/jobs/order", secrets["submit"], order)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = authRequest(srv, "PUT", "/api/jobs/order", secrets["control"], order)
+	assert.Equal(t, http.StatusForbidden, w.Code, "the job is submit's; see TestAuth_ReorderOwnJobs")
+	w = authRequest(srv, "PUT", "/api/jobs/order", secrets["admin"], order)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	patch := []byte(`{"concurrent_jobs": 2}`)
+	w = authRequest(srv, "PATCH", "/api/config", secrets["control"], patch)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = authRequest(srv, "PATCH", "/api/config", secrets["admin"], patch)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Admin implies every scope
+	w = authRequest(srv, "POST", "/api/jobs", secrets["admin"], job)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestAuth_Tokens(t *testing.T) {
+	srv, secrets := newAuthServer(t)
+
+	body := []byte(`{"name": "ci", "scopes": ["submit"]}`)
+	w := authRequest(srv, "POST", "/api/tokens", secrets["control"], body)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = authRequest(srv, "POST", "/api/tokens", secrets["admin"], body)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created CreateTokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "ci", created.Name)
+	require.NotEmpty(t, created.Secret)
+
+	w = authRequest(srv, "GET", "/api/whoami", created.Secret, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var me models.Token
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &me))
+	assert.Equal(t, "ci", me.Name)
+	assert.Equal(t, []models.Scope{models.ScopeSubmit}, me.Scopes)
+
+	w = authRequest(srv, "GET", "/api/tokens", secrets["admin"], nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Secret, "secrets are only returned on creation")
+
+	w = authRequest(srv, "POST", "/api/tokens", secrets["admin"], []byte(`{"name": "x", "scopes": ["root"]}`))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = authRequest(srv, "POST", "/api/tokens", secrets["admin"], []byte(`{"name": "ci", "scopes": ["admin"]}`))
+	assert.Equal(t, http.StatusConflict, w.Code, "names are taken once")
+
+	w = authRequest(srv, "DELETE", fmt.Sprintf("/api/tokens/%d", created.ID), secrets["admin"], nil)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = authRequest(srv, "GET", "/api/whoami", created.Secret, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestAuth_DashboardSession(t *testing.T) {
+	srv, secrets := newAuthServer(t)
+
+	w := authRequest(srv, "GET", "/config", "", nil)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/login?next=%2Fconfig", w.Header().Get("Location"))
+
+	w = authRequest(srv, "GET", "/login", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `name="token"`)
+
+	login := func(secret string) *httptest.ResponseRecorder {
+		form := url.Values{"token": {secret}, "next": {"/config"}}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		srv.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	w = login("ralph_bogus")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid token")
+
+	w = login(secrets["reader"])
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/config", w.Header().Get("Location"))
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	session := cookies[0]
+	assert.True(t, session.HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, session.SameSite)
+
+	withSession := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.AddCookie(session)
+		w := httptest.NewRecorder()
+		srv.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	w = withSession("GET", "/config")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Log out")
+	assert.Equal(t, http.StatusOK, withSession("GET", "/api/jobs").Code, "the dashboard's scripts use the session")
+
+	w = withSession("POST", "/logout")
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, http.StatusSeeOther, withSession("GET", "/config").Code)
+}
+
+func TestSafeRedirect(t *testing.T) {
+	assert.Equal(t, "/jobs/1", safeRedirect("/jobs/1"))
+	assert.Equal(t, "/", safeRedirect(""))
+	assert.Equal(t, "/", safeRedirect("https://evil.example"))
+	assert.Equal(t, "/", safeRedirect("//evil.example"))
+}
+
+func TestAuth_JobOwnership(t *testing.T) {
+	srv, database := newTestServer(t)
+	srv.EnableAuth()
+	repo := db.NewTokenRepo(database)
+	secrets := make(map[string]string)
+	for _, name := range []string{"alice", "bob"} {
+		secret, err := repo.Create(&models.Token{Name: name, Scopes: []models.Scope{models.ScopeSubmit, models.ScopeControl}})
+		require.NoError(t, err)
+		secrets[name] = secret
+	}
+	admin, err := repo.Create(&models.Token{Name: "root", Scopes: []models.Scope{models.ScopeAdmin}})
+	require.NoError(t, err)
+
+	// The token's name owns the job, whatever the request claims
+	body, _ := json.Marshal(map[string]any{
+		"repo_url":       "git@github.com:user/repo.git",
+		"branch":         "main",
+		"prompt":         "test",
+		"max_iterations": 10,
+		"owner":          "bob",
+	})
+	w := authRequest(srv, "POST", "/api/jobs", secrets["alice"], body)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var job models.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, "alice", job.Owner)
+
+	w = authRequest(srv, "GET", "/api/jobs?owner=bob", secrets["bob"], nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list ListJobsResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Zero(t, list.Total)
+
+	path := fmt.Sprintf("/api/jobs/%d", job.ID)
+	w = authRequest(srv, "DELETE", path, secrets["bob"], nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "belongs to alice")
+	w = authRequest(srv, "DELETE", path, admin, nil)
+	assert.Equal(t, http.StatusOK, w.Code, "admins may cancel anyone's job")
+}
+
+func TestAPI_CreateJob_Quota(t *testing.T) {
+	srv, _ := newTestServer(t)
+	w := authRequest(srv, "PATCH", "/api/config", "", []byte(`{"max_jobs_per_user": 1}`))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Without authentication the submitter's git email owns the job
+	body, _ := json.Marshal(map[string]any{
+		"repo_url":       "git@github.com:user/repo.git",
+		"branch":         "main",
+		"prompt":         "test",
+		"max_iterations": 10,
+		"owner":          "alice@example.com",
+	})
+	w = authRequest(srv, "POST", "/api/jobs", "", body)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"owner":"alice@example.com"`)
+
+	w = authRequest(srv, "POST", "/api/jobs", "", body)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "queue quota exceeded")
+}
+
+func TestAuth_ReorderOwnJobs(t *testing.T) {
+	srv, database := newTestServer(t)
+	srv.EnableAuth()
+	repo := db.NewTokenRepo(database)
+	alice, err := repo.Create(&models.Token{Name: "alice", Scopes: []models.Scope{models.ScopeControl}})
+	require.NoError(t, err)
+	admin, err := repo.Create(&models.Token{Name: "root", Scopes: []models.Scope{models.ScopeAdmin}})
+	require.NoError(t, err)
+
+	// Queue: alice, bob, alice
+	var ids []int64
+	for _, owner := range []string{"alice", "bob", "alice"} {
+		job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+		job.Owner = owner
+		require.NoError(t, srv.queue.Enqueue(job))
+		ids = append(ids, job.ID)
+	}
+	order := func() []int64 {
+		queued, err := db.NewJobRepo(database).ListQueued()
+		require.NoError(t, err)
+		var got []int64
+		for _, job := range queued {
+			got = append(got, job.ID)
+		}
+		return got
+	}
+
+	reorder := func(secret string, jobIDs ...int64) int {
+		body, _ := json.Marshal(ReorderRequest{JobIDs: jobIDs})
+		return authRequest(srv, "PUT", "/api/jobs/order", secret, body).Code
+	}
+
+	assert.Equal(t, http.StatusForbidden, reorder(alice, ids[1], ids[0]), "bob's job is not alice's to move")
+
+	// Alice's jobs swap places, but stay behind and ahead of bob's
+	require.Equal(t, http.StatusOK, reorder(alice, ids[2], ids[0]))
+	assert.Equal(t, []int64{ids[2], ids[1], ids[0]}, order())
+
+	require.Equal(t, http.StatusOK, reorder(admin, ids[1], ids[2], ids[0]))
+	assert.Equal(t, []int64{ids[1], ids[2], ids[0]}, order())
+}
@@ -2,10 +2,15 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/executor"
+	"github.com/ryan/ralph-o-matic/internal/platform"
+	"github.com/ryan/ralph-o-matic/internal/queue"
 )
 
 func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
@@ -55,6 +60,10 @@ func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if !executor.HasBackend(merged.Executor) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown executor %q (available: %s)", merged.Executor, strings.Join(executor.Backends(), ", ")))
+		return
+	}
 
 	// Save
 	if err := configRepo.Save(merged); err != nil {
@@ -62,5 +71,35 @@ func (s *Server) handleUpdateConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Apply runtime-adjustable settings
+	if s.pool != nil {
+		s.pool.SetConfig(merged)
+		s.pool.Check(r.Context())
+	}
+	if s.monitor != nil {
+		s.monitor.SetConfig(merged)
+		s.monitor.Poll(r.Context())
+	}
+	if s.admission != nil {
+		s.admission.SetConfig(merged)
+	}
+	if s.handler != nil {
+		s.handler.SetConfig(merged)
+	}
+	s.queue.SetQuota(merged.MaxJobsPerUser)
+	s.queue.SetPolicy(queue.NewPolicy(merged.Scheduling))
+	if s.scheduler != nil {
+		s.scheduler.SetConcurrency(merged.ConcurrentJobs)
+		s.scheduler.Signal()
+	}
+
 	writeJSON(w, http.StatusOK, merged)
 }
+
+func (s *Server) handleListEndpoints(w http.ResponseWriter, r *http.Request) {
+	endpoints := []platform.EndpointStatus{}
+	if s.pool != nil {
+		endpoints = s.pool.Status()
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"endpoints": endpoints})
+}
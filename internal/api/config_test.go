@@ -2,13 +2,17 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/ryan/ralph-o-matic/internal/executor"
 	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/platform"
+	"github.com/ryan/ralph-o-matic/internal/queue"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -57,6 +61,35 @@ func TestAPI_UpdateConfig(t *testing.T) {
 	assert.Equal(t, 3, resp.ConcurrentJobs)
 }
 
+func TestAPI_UpdateConfig_AppliesSchedulerConcurrency(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	sched := queue.NewScheduler(srv.queue, func(ctx context.Context, job *models.Job) error { return nil })
+	srv.SetScheduler(sched)
+
+	req := httptest.NewRequest("PATCH", "/api/config", strings.NewReader(`{"concurrent_jobs": 4}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	srv.Router().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 4, sched.Concurrency())
+}
+
+func TestAPI_UpdateConfig_AppliesToHandler(t *testing.T) {
+	srv, database := newTestServer(t)
+	handler := executor.NewRalphHandler(database, models.DefaultServerConfig(), t.TempDir())
+	srv.SetHandler(handler)
+
+	req := httptest.NewRequest("PATCH", "/api/config", strings.NewReader(`{"sandbox": {"runtime": "podman", "image": "ralph-agents"}}`))
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, models.SandboxPodman, handler.Config().Sandbox.Runtime)
+}
+
 func TestAPI_ConfigRoundTrip_FullModelPlacement(t *testing.T) {
 	srv, _ := newTestServer(t)
 
@@ -145,6 +178,27 @@ func TestAPI_UpdateConfig_InvalidModel(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestAPI_UpdateConfig_Executor(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/api/config", bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	w := patch(`{"executor": "ollama"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var cfg models.ServerConfig
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cfg))
+	assert.Equal(t, "ollama", cfg.Executor)
+
+	w = patch(`{"executor": "cursor"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
 func TestAPI_UpdateConfig_MalformedBody(t *testing.T) {
 	srv, _ := newTestServer(t)
 
@@ -208,3 +262,68 @@ func TestAPI_GetConfig_ResponseStructure(t *testing.T) {
 	assert.Contains(t, largeModel, "device")
 	assert.Contains(t, largeModel, "memory_gb")
 }
+
+func TestAPI_UpdateConfig_EndpointPool(t *testing.T) {
+	srv, _ := newTestServer(t)
+	pool := platform.NewPool(models.DefaultServerConfig())
+	srv.SetPool(pool)
+
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"models": []map[string]interface{}{{"name": "qwen3-coder:70b"}},
+		})
+	}))
+	defer ollama.Close()
+
+	patch := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PATCH", "/api/config", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.Router().ServeHTTP(w, req)
+		return w
+	}
+	endpoints := func() []platform.EndpointStatus {
+		w := httptest.NewRecorder()
+		srv.Router().ServeHTTP(w, httptest.NewRequest("GET", "/api/endpoints", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Endpoints []platform.EndpointStatus `json:"endpoints"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Endpoints
+	}
+
+	assert.Empty(t, endpoints())
+
+	w := patch(`{"ollama": {"endpoints": [{"name": "gpu-1", "host": "` + ollama.URL + `", "capacity": 2, "tags": ["gpu"]}]}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// The pool picks up the new endpoint and checks it straight away
+	got := endpoints()
+	require.Len(t, got, 1)
+	assert.Equal(t, "gpu-1", got[0].Name)
+	assert.True(t, got[0].Healthy)
+	assert.Equal(t, 2, got[0].Capacity)
+	assert.Equal(t, []string{"qwen3-coder:70b"}, got[0].Models)
+
+	// Jobs asking for tags no endpoint carries are turned away
+	body, _ := json.Marshal(map[string]interface{}{
+		"repo_url":       "git@github.com:user/repo.git",
+		"branch":         "main",
+		"prompt":         "test",
+		"max_iterations": 10,
+		"endpoint_tags":  []string{"tpu"},
+	})
+	req := httptest.NewRequest("POST", "/api/jobs", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "no endpoint has tags tpu")
+
+	w = patch(`{"ollama": {"endpoints": []}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, endpoints())
+
+	w = patch(`{"ollama": {"endpoints": [{"host": ""}]}}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,289 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/events"
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// keepAliveInterval is how often an idle stream sends a comment so proxies
+// and clients don't treat the connection as dead
+const keepAliveInterval = 15 * time.Second
+
+// sseStream writes Server-Sent Events to a response
+type sseStream struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// startStream sends the SSE response headers. It fails if the response
+// writer cannot flush, since events would then sit in a buffer.
+func startStream(w http.ResponseWriter) (*sseStream, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("streaming not supported")
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &sseStream{w: w, flusher: flusher}, nil
+}
+
+// send writes one event. id is omitted when zero.
+func (s *sseStream) send(id int64, event events.Type, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	if id > 0 {
+		if _, err := fmt.Fprintf(s.w, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseStream) keepAlive() error {
+	if _, err := fmt.Fprint(s.w, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// streamContext returns a context that ends when either the client goes away
+// or the server shuts down
+func (s *Server) streamContext(r *http.Request) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(r.Context())
+	stop := context.AfterFunc(s.streams, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// handleEvents streams queue-wide job status changes
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	ch, unsubscribe := s.db.Events().Subscribe(0)
+	defer unsubscribe()
+
+	stream, err := startStream(w)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	ctx, cancel := s.streamContext(r)
+	defer cancel()
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := stream.keepAlive(); err != nil {
+				return
+			}
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if e.Type != events.JobStatus {
+				continue
+			}
+			if err := stream.send(0, e.Type, s.redactEvent(e.Data)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleStreamJobLogs tails a job's logs. Each line's event ID is its
+// job_logs.id, so a reconnecting client that sends Last-Event-ID (or
+// ?after=) picks up exactly where it left off. The iteration, since and tail
+// filters of the logs endpoint also apply. The stream ends with a final
+// status event once the job is finished.
+func (s *Server) handleStreamJobLogs(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.ParseInt(chi.URLParam(r, "jobID"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid job ID")
+		return
+	}
+
+	query, err := parseLogQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Subscribe before reading the backlog so no line falls in between
+	ch, unsubscribe := s.db.Events().Subscribe(jobID)
+	defer unsubscribe()
+
+	job, err := s.queue.Get(jobID)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "job not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	stream, err := startStream(w)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	ctx, cancel := s.streamContext(r)
+	defer cancel()
+
+	logRepo := db.NewLogRepo(s.db)
+	redactor := s.redactor(job)
+
+	// sendLogs writes every matching line after the last one sent. Bus
+	// events only wake the stream; reading from the database means lines
+	// dropped for a slow subscriber are still delivered.
+	sendLogs := func() error {
+		logs, err := logRepo.Find(jobID, query)
+		if err != nil {
+			return err
+		}
+		redactLogs(redactor, logs)
+		for _, entry := range logs {
+			if err := stream.send(entry.ID, events.JobLog, entry); err != nil {
+				return err
+			}
+			query.AfterID = entry.ID
+		}
+
+		// Tail only trims the backlog sent on connect
+		query.Tail = 0
+		return nil
+	}
+
+	// finished ends the stream once the job has finished, so a status event
+	// the bus dropped cannot leave it open forever
+	finished := func() bool {
+		current, err := s.queue.Get(jobID)
+		if err != nil {
+			return true
+		}
+		if !current.Status.IsTerminal() {
+			return false
+		}
+		if err := sendLogs(); err == nil {
+			_ = stream.send(0, events.JobStatus, redactor.RedactJob(current))
+		}
+		return true
+	}
+
+	if err := sendLogs(); err != nil {
+		return
+	}
+	if job.Status.IsTerminal() {
+		_ = stream.send(0, events.JobStatus, redactor.RedactJob(job))
+		return
+	}
+
+	ticker := time.NewTicker(keepAliveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := stream.keepAlive(); err != nil {
+				return
+			}
+			if finished() {
+				return
+			}
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			batch := drain(ch, e)
+			if err := sendLogs(); err != nil {
+				return
+			}
+			for _, e := range batch {
+				if e.Type != events.JobStatus {
+					continue
+				}
+				updated, ok := e.Data.(*models.Job)
+				if !ok {
+					continue
+				}
+				if err := stream.send(0, e.Type, redactor.RedactJob(updated)); err != nil {
+					return
+				}
+				if updated.Status.IsTerminal() {
+					return
+				}
+			}
+			if finished() {
+				return
+			}
+		}
+	}
+}
+
+// drain returns first plus any events already waiting on ch, so a burst of
+// log lines costs one database read rather than one per line
+func drain(ch <-chan events.Event, first events.Event) []events.Event {
+	batch := []events.Event{first}
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return batch
+			}
+			batch = append(batch, e)
+		default:
+			return batch
+		}
+	}
+}
+
+// lastEventID returns the log ID a client has already seen, from the
+// Last-Event-ID header browsers send on reconnect or the "after" parameter
+func lastEventID(r *http.Request) (int64, error) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		v = r.URL.Query().Get("after")
+	}
+	if v == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fmt.Errorf("invalid last event ID: %q", v)
+	}
+	return id, nil
+}
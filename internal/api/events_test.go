@@ -0,0 +1,242 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// readEvents parses SSE events from body until it closes or n events are read
+func readEvents(t *testing.T, body *bufio.Reader, n int) []sseEvent {
+	t.Helper()
+	var out []sseEvent
+	var cur sseEvent
+	for len(out) < n {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			return out
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if cur.Event != "" {
+				out = append(out, cur)
+			}
+			cur = sseEvent{}
+		case strings.HasPrefix(line, "id: "):
+			cur.ID = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			cur.Event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			cur.Data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	return out
+}
+
+// newStreamServer serves srv over HTTP. It is closed via t.Cleanup so open
+// streams are cancelled first rather than holding up Close.
+func newStreamServer(t *testing.T, srv *Server) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(srv.Router())
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func openStream(t *testing.T, ts *httptest.Server, path string, header http.Header) *bufio.Reader {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", ts.URL+path, nil)
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	return bufio.NewReader(resp.Body)
+}
+
+func TestAPI_StreamJobLogs_FinishedJob(t *testing.T) {
+	srv, database := newTestServer(t)
+	ts := newStreamServer(t, srv)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, srv.queue.Enqueue(job))
+	logRepo := db.NewLogRepo(database)
+	require.NoError(t, logRepo.Append(job.ID, 1, "first"))
+	require.NoError(t, logRepo.Append(job.ID, 1, "second"))
+	require.NoError(t, srv.queue.Cancel(job))
+
+	events := readEvents(t, openStream(t, ts, fmt.Sprintf("/api/jobs/%d/logs/stream", job.ID), nil), 10)
+	require.Len(t, events, 3)
+
+	assert.Equal(t, "log", events[0].Event)
+	var entry db.JobLog
+	require.NoError(t, json.Unmarshal([]byte(events[0].Data), &entry))
+	assert.Equal(t, "first", entry.Message)
+	assert.Equal(t, fmt.Sprint(entry.ID), events[0].ID)
+
+	assert.Equal(t, "status", events[2].Event)
+	assert.Contains(t, events[2].Data, `"status":"cancelled"`)
+}
+
+func TestAPI_StreamJobLogs_Resume(t *testing.T) {
+	srv, database := newTestServer(t)
+	ts := newStreamServer(t, srv)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, srv.queue.Enqueue(job))
+	logRepo := db.NewLogRepo(database)
+	require.NoError(t, logRepo.Append(job.ID, 1, "first"))
+	require.NoError(t, logRepo.Append(job.ID, 1, "second"))
+	logs, err := logRepo.GetForJob(job.ID)
+	require.NoError(t, err)
+	require.NoError(t, srv.queue.Cancel(job))
+
+	header := http.Header{"Last-Event-Id": {fmt.Sprint(logs[0].ID)}}
+	events := readEvents(t, openStream(t, ts, fmt.Sprintf("/api/jobs/%d/logs/stream", job.ID), header), 10)
+	require.Len(t, events, 2)
+	assert.Contains(t, events[0].Data, "second")
+	assert.Equal(t, "status", events[1].Event)
+}
+
+func TestAPI_StreamJobLogs_Live(t *testing.T) {
+	srv, database := newTestServer(t)
+	ts := newStreamServer(t, srv)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, srv.queue.Enqueue(job))
+
+	body := openStream(t, ts, fmt.Sprintf("/api/jobs/%d/logs/stream", job.ID), nil)
+
+	require.NoError(t, db.NewLogRepo(database).Append(job.ID, 1, "live line"))
+	events := readEvents(t, body, 1)
+	require.Len(t, events, 1)
+	assert.Contains(t, events[0].Data, "live line")
+
+	// Finishing the job ends the stream
+	require.NoError(t, srv.queue.Cancel(job))
+	events = readEvents(t, body, 10)
+	require.Len(t, events, 1)
+	assert.Equal(t, "status", events[0].Event)
+}
+
+func TestAPI_StreamJobLogs_MissedStatus(t *testing.T) {
+	srv, database := newTestServer(t)
+	ts := newStreamServer(t, srv)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, srv.queue.Enqueue(job))
+
+	body := openStream(t, ts, fmt.Sprintf("/api/jobs/%d/logs/stream", job.ID), nil)
+
+	// The job finishes without the stream seeing the status event, as when
+	// the bus drops it for a slow subscriber
+	_, err := database.Conn().Exec("UPDATE jobs SET status = ? WHERE id = ?", models.StatusCancelled, job.ID)
+	require.NoError(t, err)
+	require.NoError(t, db.NewLogRepo(database).Append(job.ID, 1, "last line"))
+
+	events := readEvents(t, body, 10)
+	require.Len(t, events, 2)
+	assert.Contains(t, events[0].Data, "last line")
+	assert.Equal(t, "status", events[1].Event)
+	assert.Contains(t, events[1].Data, `"status":"cancelled"`)
+}
+
+func TestAPI_StreamJobLogs_NotFound(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	req := httptest.NewRequest("GET", "/api/jobs/999/logs/stream", nil)
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAPI_StreamJobLogs_InvalidLastEventID(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	req := httptest.NewRequest("GET", "/api/jobs/1/logs/stream?after=abc", nil)
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAPI_Events(t *testing.T) {
+	srv, _ := newTestServer(t)
+	ts := newStreamServer(t, srv)
+
+	body := openStream(t, ts, "/api/events", nil)
+
+	job := models.NewJob("git@github.com:user/repo.git", "feature/live", "test", 10)
+	require.NoError(t, srv.queue.Enqueue(job))
+
+	events := readEvents(t, body, 1)
+	require.Len(t, events, 1)
+	assert.Equal(t, "status", events[0].Event)
+
+	var got models.Job
+	require.NoError(t, json.Unmarshal([]byte(events[0].Data), &got))
+	assert.Equal(t, job.ID, got.ID)
+	assert.Equal(t, models.StatusQueued, got.Status)
+}
+
+func TestServer_ShutdownClosesStreams(t *testing.T) {
+	srv, _ := newTestServer(t)
+	ts := newStreamServer(t, srv)
+
+	body := openStream(t, ts, "/api/events", nil)
+	require.NoError(t, srv.Shutdown(context.Background()))
+
+	_, err := body.ReadString('\n')
+	assert.Error(t, err) // Stream closed by the server
+}
+
+func TestAPI_StreamJobLogs_Filters(t *testing.T) {
+	srv, database := newTestServer(t)
+	ts := newStreamServer(t, srv)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, srv.queue.Enqueue(job))
+	logRepo := db.NewLogRepo(database)
+	for i, msg := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, logRepo.Append(job.ID, i/2+1, msg))
+	}
+
+	body := openStream(t, ts, fmt.Sprintf("/api/jobs/%d/logs/stream?iteration=2&tail=1", job.ID), nil)
+	events := readEvents(t, body, 1)
+	require.Len(t, events, 1)
+	assert.Contains(t, events[0].Data, `"message":"d"`)
+
+	// Lines from other iterations are not streamed
+	require.NoError(t, logRepo.Append(job.ID, 1, "other"))
+	require.NoError(t, logRepo.Append(job.ID, 2, "mine"))
+	events = readEvents(t, body, 1)
+	require.Len(t, events, 1)
+	assert.Contains(t, events[0].Data, `"message":"mine"`)
+}
@@ -1,15 +1,21 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/executor"
 	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/platform"
+	"github.com/ryan/ralph-o-matic/internal/queue"
 )
 
 // CreateJobRequest is the request body for creating a job
@@ -21,6 +27,28 @@ type CreateJobRequest struct {
 	Priority      string            `json:"priority,omitempty"`
 	WorkingDir    string            `json:"working_dir,omitempty"`
 	Env           map[string]string `json:"env,omitempty"`
+	Secrets       []string          `json:"secrets,omitempty"` // names of stored secrets to set as env vars
+	Executor      string            `json:"executor,omitempty"`
+	VerifyCommand string            `json:"verify_command,omitempty"`
+	StallWindow   int               `json:"stall_window,omitempty"`
+	StallPolicy   string            `json:"stall_policy,omitempty"`
+	ModelPlan     models.ModelPlan  `json:"model_plan,omitempty"`
+
+	// Overrides of the server's models and Ollama host, and the tags an
+	// endpoint of the server's pool must carry to run the job
+	LargeModel   string   `json:"large_model,omitempty"`
+	SmallModel   string   `json:"small_model,omitempty"`
+	OllamaHost   string   `json:"ollama_host,omitempty"`
+	EndpointTags []string `json:"endpoint_tags,omitempty"`
+
+	// Budgets; durations use Go syntax such as "4h" or "45m"
+	MaxDuration         string `json:"max_duration,omitempty"`
+	PerIterationTimeout string `json:"per_iteration_timeout,omitempty"`
+	MaxTokens           int64  `json:"max_tokens,omitempty"`
+
+	// Who submits the job, e.g. their git user.email. Ignored when the
+	// server authenticates; the job belongs to the token's name then.
+	Owner string `json:"owner,omitempty"`
 }
 
 // ListJobsResponse is the response for listing jobs
@@ -31,6 +59,13 @@ type ListJobsResponse struct {
 	Offset int           `json:"offset"`
 }
 
+// UpdateJobRequest is the request body for editing a job. Fields left out
+// keep their value.
+type UpdateJobRequest struct {
+	Priority      *string `json:"priority,omitempty"`
+	MaxIterations *int    `json:"max_iterations,omitempty"`
+}
+
 // ReorderRequest is the request body for reordering jobs
 type ReorderRequest struct {
 	JobIDs []int64 `json:"job_ids"`
@@ -46,6 +81,36 @@ func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
 	job := models.NewJob(req.RepoURL, req.Branch, req.Prompt, req.MaxIterations)
 	job.WorkingDir = req.WorkingDir
 	job.Env = req.Env
+	job.Secrets = req.Secrets
+	job.Owner = strings.TrimSpace(req.Owner)
+	if s.auth {
+		job.Owner = tokenFrom(r.Context()).Name
+	}
+
+	if !executor.HasBackend(req.Executor) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unknown executor %q (available: %s)", req.Executor, strings.Join(executor.Backends(), ", ")))
+		return
+	}
+	job.Executor = req.Executor
+	job.VerifyCommand = strings.TrimSpace(req.VerifyCommand)
+	job.StallWindow = req.StallWindow
+	job.StallPolicy = models.StallPolicy(req.StallPolicy)
+	job.MaxTokens = req.MaxTokens
+	job.ModelPlan = req.ModelPlan
+	job.LargeModel = strings.TrimSpace(req.LargeModel)
+	job.SmallModel = strings.TrimSpace(req.SmallModel)
+	job.OllamaHost = strings.TrimSpace(req.OllamaHost)
+	job.EndpointTags = req.EndpointTags
+
+	var err error
+	if job.MaxDuration, err = parseDuration("max_duration", req.MaxDuration); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if job.PerIterationTimeout, err = parseDuration("per_iteration_timeout", req.PerIterationTimeout); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	if req.Priority != "" {
 		priority, err := models.ParsePriority(req.Priority)
@@ -56,12 +121,115 @@ func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
 		job.Priority = priority
 	}
 
+	if err := job.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid job: "+err.Error())
+		return
+	}
+	if !s.checkSecrets(w, r, job) {
+		return
+	}
+	// Jobs only talk to the Ollama hosts the server is configured with, as
+	// the models API does; the agent and its sandbox reach whatever is here
+	if job.OllamaHost != "" {
+		hosts, err := s.ollamaHosts(job.OllamaHost)
+		if err != nil {
+			writeHostsError(w, err)
+			return
+		}
+		job.OllamaHost = hosts[0]
+	}
+	if err := s.checkModels(r.Context(), job); err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, errModelNotInstalled) {
+			status = http.StatusBadRequest
+		}
+		writeError(w, status, err.Error())
+		return
+	}
+
 	if err := s.queue.Enqueue(job); err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+		status := http.StatusBadRequest
+		if errors.Is(err, queue.ErrQuotaExceeded) {
+			status = http.StatusTooManyRequests
+		}
+		writeError(w, status, err.Error())
 		return
 	}
+	s.signalScheduler()
 
-	writeJSON(w, http.StatusCreated, job)
+	s.writeJob(w, http.StatusCreated, job)
+}
+
+// errModelNotInstalled is returned by checkModels for a model Ollama does not have
+var errModelNotInstalled = errors.New("model not installed")
+
+// checkModels confirms the models a job overrides are installed on the
+// Ollama host it will run against, or for a job routed across the server's
+// endpoint pool on at least one endpoint it may be placed on, so that a typo
+// fails the submit rather than the job
+func (s *Server) checkModels(ctx context.Context, job *models.Job) error {
+	cfg, err := db.NewConfigRepo(s.db).Get()
+	if err != nil {
+		return err
+	}
+
+	hosts := []string{job.Config(cfg).Ollama.Host}
+	if job.OllamaHost == "" && len(cfg.Ollama.Endpoints) > 0 {
+		hosts = nil
+		for _, e := range cfg.Ollama.Endpoints {
+			if e.HasTags(job.EndpointTags) {
+				hosts = append(hosts, e.Host)
+			}
+		}
+		if len(hosts) == 0 {
+			return fmt.Errorf("%w: no endpoint has tags %s", errModelNotInstalled, strings.Join(job.EndpointTags, ", "))
+		}
+	}
+	if job.LargeModel == "" && job.SmallModel == "" {
+		return nil
+	}
+
+	for _, check := range []struct{ field, name string }{
+		{"large_model", job.LargeModel},
+		{"small_model", job.SmallModel},
+	} {
+		if check.name == "" {
+			continue
+		}
+		var lastErr error
+		found := false
+		for _, host := range hosts {
+			ok, err := platform.NewOllamaClient(host).HasModel(ctx, check.name)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if ok {
+				found = true
+				break
+			}
+		}
+		switch {
+		case found:
+		case lastErr != nil:
+			return fmt.Errorf("cannot check %s: %w", check.field, lastErr)
+		default:
+			return fmt.Errorf("%w: %s %q is not available on %s", errModelNotInstalled, check.field, check.name, strings.Join(hosts, ", "))
+		}
+	}
+	return nil
+}
+
+// parseDuration parses an optional duration field from a request
+func parseDuration(field, value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", field, err)
+	}
+	return d, nil
 }
 
 func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
@@ -75,6 +243,8 @@ func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	opts.Owner = r.URL.Query().Get("owner")
+
 	// Parse pagination
 	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
 		limit, _ := strconv.Atoi(limitStr)
@@ -91,6 +261,10 @@ func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for i, job := range jobs {
+		jobs[i] = s.redactJob(job)
+	}
+
 	writeJSON(w, http.StatusOK, ListJobsResponse{
 		Jobs:   jobs,
 		Total:  total,
@@ -116,7 +290,7 @@ func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, http.StatusOK, job)
+	s.writeJob(w, http.StatusOK, job)
 }
 
 func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
@@ -136,12 +310,16 @@ func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.queue.Cancel(job); err != nil {
+	if !authorizeJob(w, r, job) {
+		return
+	}
+
+	if err := s.controller().Cancel(job); err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, job)
+	s.writeJob(w, http.StatusOK, job)
 }
 
 func (s *Server) handleUpdateJob(w http.ResponseWriter, r *http.Request) {
@@ -161,31 +339,36 @@ func (s *Server) handleUpdateJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var updates map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON")
+	if !authorizeJob(w, r, job) {
+		return
+	}
+
+	var req UpdateJobRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON; only priority and max_iterations can be edited")
 		return
 	}
 
-	// Apply updates
-	if priority, ok := updates["priority"].(string); ok {
-		p, err := models.ParsePriority(priority)
+	if req.Priority != nil {
+		p, err := models.ParsePriority(*req.Priority)
 		if err != nil {
 			writeError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 		job.Priority = p
 	}
-	if maxIter, ok := updates["max_iterations"].(float64); ok {
-		job.MaxIterations = int(maxIter)
+	if req.MaxIterations != nil {
+		job.MaxIterations = *req.MaxIterations
 	}
 
-	if err := s.queue.Update(job); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+	if err := s.queue.Edit(job); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, job)
+	s.writeJob(w, http.StatusOK, job)
 }
 
 func (s *Server) handlePauseJob(w http.ResponseWriter, r *http.Request) {
@@ -205,12 +388,16 @@ func (s *Server) handlePauseJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.queue.Pause(job); err != nil {
+	if !authorizeJob(w, r, job) {
+		return
+	}
+
+	if err := s.controller().Pause(job); err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, job)
+	s.writeJob(w, http.StatusOK, job)
 }
 
 func (s *Server) handleResumeJob(w http.ResponseWriter, r *http.Request) {
@@ -230,12 +417,16 @@ func (s *Server) handleResumeJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.queue.Resume(job); err != nil {
+	if !authorizeJob(w, r, job) {
+		return
+	}
+
+	if err := s.controller().Resume(job); err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, job)
+	s.writeJob(w, http.StatusOK, job)
 }
 
 func (s *Server) handleReorderJobs(w http.ResponseWriter, r *http.Request) {
@@ -245,7 +436,32 @@ func (s *Server) handleReorderJobs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := s.queue.Reorder(req.JobIDs); err != nil {
+	// Admins order the whole queue. Everyone else may only shuffle their own
+	// jobs among the places those already hold, not move them past others'.
+	if tokenFrom(r.Context()).HasScope(models.ScopeAdmin) {
+		if err := s.queue.Reorder(req.JobIDs); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string][]int64{"reordered": req.JobIDs})
+		return
+	}
+
+	for _, id := range req.JobIDs {
+		job, err := s.queue.Get(id)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeError(w, http.StatusNotFound, fmt.Sprintf("job %d not found", id))
+				return
+			}
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !authorizeJob(w, r, job) {
+			return
+		}
+	}
+	if err := s.queue.ReorderAmong(req.JobIDs); err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -260,12 +476,110 @@ func (s *Server) handleGetJobLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	query, err := parseLogQuery(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	logRepo := db.NewLogRepo(s.db)
-	logs, err := logRepo.GetForJob(jobID)
+	logs, err := logRepo.Find(jobID, query)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
+	if job, err := s.queue.Get(jobID); err == nil {
+		redactLogs(s.redactor(job), logs)
+	}
 	writeJSON(w, http.StatusOK, map[string]interface{}{"logs": logs})
 }
+
+func (s *Server) handleGetIterations(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.ParseInt(chi.URLParam(r, "jobID"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid job ID")
+		return
+	}
+
+	if _, err := s.queue.Get(jobID); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "job not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	iterationRepo := db.NewIterationRepo(s.db)
+	iterations, err := iterationRepo.ListForJob(jobID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"iterations": iterations})
+}
+
+func (s *Server) handleGetAgentEvents(w http.ResponseWriter, r *http.Request) {
+	jobID, err := strconv.ParseInt(chi.URLParam(r, "jobID"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid job ID")
+		return
+	}
+
+	iteration := 0
+	if v := r.URL.Query().Get("iteration"); v != "" {
+		iteration, err = strconv.Atoi(v)
+		if err != nil || iteration < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid iteration: %q", v))
+			return
+		}
+	}
+
+	eventRepo := db.NewAgentEventRepo(s.db)
+	evs, err := eventRepo.Find(jobID, iteration)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"events": evs})
+}
+
+// parseLogQuery reads the log filters shared by the logs and stream
+// endpoints: ?iteration=N, ?since=<RFC3339>, ?tail=N and ?after=<log id>
+func parseLogQuery(r *http.Request) (db.LogQuery, error) {
+	var q db.LogQuery
+	params := r.URL.Query()
+
+	if v := params.Get("iteration"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("invalid iteration: %q", v)
+		}
+		q.Iteration = n
+	}
+	if v := params.Get("since"); v != "" {
+		since, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return q, fmt.Errorf("invalid since: %q (want RFC3339)", v)
+		}
+		q.Since = since
+	}
+	if v := params.Get("tail"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return q, fmt.Errorf("invalid tail: %q", v)
+		}
+		q.Tail = n
+	}
+
+	after, err := lastEventID(r)
+	if err != nil {
+		return q, err
+	}
+	q.AfterID = after
+
+	return q, nil
+}
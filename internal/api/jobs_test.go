@@ -3,11 +3,14 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/ryan/ralph-o-matic/internal/db"
 	"github.com/ryan/ralph-o-matic/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -24,6 +27,9 @@ func TestAPI_CreateJob(t *testing.T) {
 		"priority":       "high",
 		"working_dir":    "packages/auth",
 		"env":            map[string]string{"NODE_ENV": "test"},
+		"verify_command": "go test ./...",
+		"stall_window":   6,
+		"stall_policy":   "escalate",
 	}
 
 	body, _ := json.Marshal(payload)
@@ -41,6 +47,9 @@ func TestAPI_CreateJob(t *testing.T) {
 	assert.Greater(t, resp.ID, int64(0))
 	assert.Equal(t, models.StatusQueued, resp.Status)
 	assert.Equal(t, "ralph/feature/test-result", resp.ResultBranch)
+	assert.Equal(t, "go test ./...", resp.VerifyCommand)
+	assert.Equal(t, 6, resp.StallWindow)
+	assert.Equal(t, models.StallPolicyEscalate, resp.StallPolicy)
 }
 
 func TestAPI_CreateJob_Invalid(t *testing.T) {
@@ -60,6 +69,165 @@ func TestAPI_CreateJob_Invalid(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 }
 
+func TestAPI_CreateJob_Executor(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	create := func(executor string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{
+			"repo_url":       "git@github.com:user/repo.git",
+			"branch":         "main",
+			"prompt":         "Run all tests",
+			"max_iterations": 10,
+			"executor":       executor,
+		})
+		req := httptest.NewRequest("POST", "/api/jobs", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	w := create("aider")
+	require.Equal(t, http.StatusCreated, w.Code)
+	var resp models.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "aider", resp.Executor)
+
+	w = create("cursor")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "unknown executor")
+}
+
+func TestAPI_CreateJob_Budgets(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	create := func(budgets map[string]interface{}) *httptest.ResponseRecorder {
+		payload := map[string]interface{}{
+			"repo_url":       "git@github.com:user/repo.git",
+			"branch":         "main",
+			"prompt":         "Run all tests",
+			"max_iterations": 10,
+		}
+		for k, v := range budgets {
+			payload[k] = v
+		}
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest("POST", "/api/jobs", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	w := create(map[string]interface{}{"max_duration": "4h", "per_iteration_timeout": "45m", "max_tokens": 2000000})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var resp models.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 4*time.Hour, resp.MaxDuration)
+	assert.Equal(t, 45*time.Minute, resp.PerIterationTimeout)
+	assert.Equal(t, int64(2000000), resp.MaxTokens)
+
+	w = create(map[string]interface{}{"max_duration": "forever"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid max_duration")
+
+	w = create(map[string]interface{}{"max_tokens": -5})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAPI_CreateJob_ModelPlan(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	create := func(plan interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{
+			"repo_url":       "git@github.com:user/repo.git",
+			"branch":         "main",
+			"prompt":         "Run all tests",
+			"max_iterations": 10,
+			"model_plan":     plan,
+		})
+		req := httptest.NewRequest("POST", "/api/jobs", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	w := create([]map[string]interface{}{
+		{"name": "qwen2.5-coder:14b", "iterations": 10},
+		{"name": "qwen3-coder:70b"},
+	})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var resp models.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.ModelPlan, 2)
+	assert.Equal(t, "qwen2.5-coder:14b", resp.ModelPlan[0].Name)
+	assert.Equal(t, 10, resp.ModelPlan[0].Iterations)
+	assert.Equal(t, "qwen3-coder:70b", resp.ModelPlan[1].Name)
+
+	// The first stage has no way to hand over to the second
+	w = create([]map[string]interface{}{{"name": "small"}, {"name": "large"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "model_plan[0]")
+}
+
+func TestAPI_CreateJob_ModelOverrides(t *testing.T) {
+	srv, database := newTestServer(t)
+
+	// A fake Ollama with a single model installed
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name string `json:"name"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Name != "qwen3-coder:30b" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ollama.Close()
+	setOllamaHosts(t, database, "http://gpu-1:11434", ollama.URL)
+
+	create := func(overrides map[string]interface{}) *httptest.ResponseRecorder {
+		payload := map[string]interface{}{
+			"repo_url":       "git@github.com:user/repo.git",
+			"branch":         "main",
+			"prompt":         "Run all tests",
+			"max_iterations": 10,
+		}
+		for k, v := range overrides {
+			payload[k] = v
+		}
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest("POST", "/api/jobs", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	w := create(map[string]interface{}{"large_model": "qwen3-coder:30b", "ollama_host": ollama.URL})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var resp models.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "qwen3-coder:30b", resp.LargeModel)
+	assert.Equal(t, ollama.URL, resp.OllamaHost)
+
+	// Hosts the server is not configured with are refused, like in the models API
+	w = create(map[string]interface{}{"ollama_host": "http://169.254.169.254"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "configured: http://gpu-1:11434")
+
+	w = create(map[string]interface{}{"small_model": "qwen3-coder:3b", "ollama_host": ollama.URL})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `small_model \"qwen3-coder:3b\" is not available`)
+
+	// An unreachable host cannot confirm the model
+	unreachable := ollama.URL
+	ollama.Close()
+	w = create(map[string]interface{}{"large_model": "qwen3-coder:30b", "ollama_host": unreachable})
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
+
 func TestAPI_GetJob(t *testing.T) {
 	srv, _ := newTestServer(t)
 
@@ -197,7 +365,38 @@ func TestAPI_ResumeJob(t *testing.T) {
 
 	var resp models.Job
 	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
-	assert.Equal(t, models.StatusRunning, resp.Status)
+	assert.Equal(t, models.StatusQueued, resp.Status)
+}
+
+func TestAPI_UpdateJob(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	job.Owner = "alice"
+	require.NoError(t, srv.queue.Enqueue(job))
+	path := "/api/jobs/" + strconv.FormatInt(job.ID, 10)
+
+	w := serve(srv, "PATCH", path, `{"priority": "high", "max_iterations": 20}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp models.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, models.PriorityHigh, resp.Priority)
+	assert.Equal(t, 20, resp.MaxIterations)
+	assert.Equal(t, "alice", resp.Owner)
+
+	assert.Equal(t, http.StatusBadRequest, serve(srv, "PATCH", path, `{"max_iterations": 0}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(srv, "PATCH", path, `{"priority": "urgent"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(srv, "PATCH", path, `{"owner": "bob"}`).Code, "only settings are editable")
+
+	// A finished job stays as it ended
+	job, err := srv.queue.Get(job.ID)
+	require.NoError(t, err)
+	require.NoError(t, srv.queue.Cancel(job))
+	assert.Equal(t, http.StatusBadRequest, serve(srv, "PATCH", path, `{"max_iterations": 30}`).Code)
+	fetched, err := srv.queue.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 20, fetched.MaxIterations)
+	assert.Equal(t, models.StatusCancelled, fetched.Status)
 }
 
 func TestAPI_ReorderJobs(t *testing.T) {
@@ -224,3 +423,107 @@ func TestAPI_ReorderJobs(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 }
+
+func TestAPI_GetJobLogs_Filters(t *testing.T) {
+	srv, database := newTestServer(t)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, srv.queue.Enqueue(job))
+	logRepo := db.NewLogRepo(database)
+	for i, msg := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, logRepo.Append(job.ID, i/2+1, msg))
+	}
+
+	get := func(query string) (int, []db.JobLog) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/jobs/%d/logs%s", job.ID, query), nil)
+		w := httptest.NewRecorder()
+		srv.Router().ServeHTTP(w, req)
+
+		var resp struct {
+			Logs []db.JobLog `json:"logs"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Logs
+	}
+
+	code, logs := get("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, logs, 4)
+
+	_, logs = get("?iteration=2")
+	require.Len(t, logs, 2)
+	assert.Equal(t, "c", logs[0].Message)
+
+	_, logs = get("?tail=1")
+	require.Len(t, logs, 1)
+	assert.Equal(t, "d", logs[0].Message)
+
+	_, logs = get("?since=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	assert.Empty(t, logs)
+
+	code, _ = get("?iteration=zero")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = get("?since=yesterday")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestAPI_GetAgentEvents(t *testing.T) {
+	srv, database := newTestServer(t)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, db.NewJobRepo(database).Create(job))
+	eventRepo := db.NewAgentEventRepo(database)
+	require.NoError(t, eventRepo.Append(job.ID, 1, []*models.AgentEvent{{Type: models.AgentEventMessage, Content: "one"}}))
+	require.NoError(t, eventRepo.Append(job.ID, 2, []*models.AgentEvent{{Type: models.AgentEventToolCall, Tool: "Bash"}}))
+
+	get := func(query string) (int, []*models.AgentEvent) {
+		req := httptest.NewRequest("GET", fmt.Sprintf("/api/jobs/%d/agent-events%s", job.ID, query), nil)
+		w := httptest.NewRecorder()
+		srv.Router().ServeHTTP(w, req)
+		var resp struct {
+			Events []*models.AgentEvent `json:"events"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Events
+	}
+
+	code, evs := get("")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, evs, 2)
+
+	code, evs = get("?iteration=2")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, evs, 1)
+	assert.Equal(t, "Bash", evs[0].Tool)
+
+	code, _ = get("?iteration=zero")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestAPI_GetIterations(t *testing.T) {
+	srv, database := newTestServer(t)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, db.NewJobRepo(database).Create(job))
+	it := models.NewIteration(job.ID, 1)
+	it.CommitHash = "abc1234"
+	it.Finish()
+	require.NoError(t, db.NewIterationRepo(database).Save(it))
+
+	req := httptest.NewRequest("GET", fmt.Sprintf("/api/jobs/%d/iterations", job.ID), nil)
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Iterations []*models.Iteration `json:"iterations"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Iterations, 1)
+	assert.Equal(t, "abc1234", resp.Iterations[0].CommitHash)
+
+	req = httptest.NewRequest("GET", "/api/jobs/999/iterations", nil)
+	w = httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
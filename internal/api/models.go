@@ -0,0 +1,186 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/events"
+	"github.com/ryan/ralph-o-matic/internal/platform"
+)
+
+// SSE event names of a model pull stream
+const (
+	pullProgressEvent events.Type = "progress" // Data is a PullEvent
+	pullDoneEvent     events.Type = "done"     // Data is a PullEvent for the last host
+	pullErrorEvent    events.Type = "error"    // Data is an error response
+)
+
+// PullModelRequest is the request for pulling a model onto Ollama
+type PullModelRequest struct {
+	Name string `json:"name"`
+	Host string `json:"host,omitempty"` // one configured host; all of them if empty
+}
+
+// PullEvent is one progress update of a model pull on one host
+type PullEvent struct {
+	Host  string `json:"host"`
+	Model string `json:"model"`
+	platform.PullProgress
+}
+
+// HostModels lists the models installed on one Ollama host
+type HostModels struct {
+	Host   string                 `json:"host"`
+	Models []platform.OllamaModel `json:"models"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// errUnknownHost is returned by ollamaHosts for a host not in the config
+var errUnknownHost = errors.New("unknown Ollama host")
+
+// ollamaHosts returns the configured Ollama hosts a models request applies
+// to: host if given, which must be one of them, otherwise every endpoint in
+// the pool or the single configured host. Only configured hosts are
+// accepted so the API cannot be used to reach arbitrary servers.
+func (s *Server) ollamaHosts(host string) ([]string, error) {
+	cfg, err := db.NewConfigRepo(s.db).Get()
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := cfg.Ollama.Hosts()
+	if host == "" {
+		return hosts, nil
+	}
+	host = strings.TrimSuffix(host, "/")
+	for _, h := range hosts {
+		if strings.TrimSuffix(h, "/") == host {
+			return []string{h}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %s; configured: %s", errUnknownHost, host, strings.Join(hosts, ", "))
+}
+
+// writeHostsError answers a models request whose host could not be resolved
+func writeHostsError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errUnknownHost) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+func (s *Server) handleListModels(w http.ResponseWriter, r *http.Request) {
+	hosts, err := s.ollamaHosts(r.URL.Query().Get("host"))
+	if err != nil {
+		writeHostsError(w, err)
+		return
+	}
+
+	result := make([]HostModels, 0, len(hosts))
+	for _, host := range hosts {
+		entry := HostModels{Host: host, Models: []platform.OllamaModel{}}
+		installed, err := platform.NewOllamaClient(host).ListModels(r.Context())
+		if err != nil {
+			entry.Error = err.Error()
+		} else if installed != nil {
+			entry.Models = installed
+		}
+		result = append(result, entry)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"hosts": result})
+}
+
+// handlePullModel pulls a model onto one or every configured Ollama host,
+// streaming Ollama's progress as SSE. The stream ends with a done event, or
+// an error event naming the host the pull failed on.
+func (s *Server) handlePullModel(w http.ResponseWriter, r *http.Request) {
+	var req PullModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	hosts, err := s.ollamaHosts(req.Host)
+	if err != nil {
+		writeHostsError(w, err)
+		return
+	}
+
+	stream, err := startStream(w)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	ctx, cancel := s.streamContext(r)
+	defer cancel()
+
+	// Jobs may now be placeable on endpoints that gained the model
+	if s.pool != nil {
+		defer s.pool.Check(s.streams)
+	}
+
+	var last PullEvent
+	for _, host := range hosts {
+		last = PullEvent{Host: host, Model: req.Name}
+		err := platform.NewOllamaClient(host).PullModelProgress(ctx, req.Name, func(p platform.PullProgress) {
+			last.PullProgress = p
+			stream.send(0, pullProgressEvent, last)
+		})
+		if err != nil {
+			stream.send(0, pullErrorEvent, map[string]string{"error": fmt.Sprintf("%s: %v", host, err)})
+			return
+		}
+	}
+
+	stream.send(0, pullDoneEvent, last)
+}
+
+// handleDeleteModel removes a model from one or every configured Ollama host
+func (s *Server) handleDeleteModel(w http.ResponseWriter, r *http.Request) {
+	name, err := url.PathUnescape(chi.URLParam(r, "*"))
+	if err != nil || name == "" {
+		writeError(w, http.StatusBadRequest, "invalid model name")
+		return
+	}
+
+	hosts, err := s.ollamaHosts(r.URL.Query().Get("host"))
+	if err != nil {
+		writeHostsError(w, err)
+		return
+	}
+
+	var deleted []string
+	for _, host := range hosts {
+		err := platform.NewOllamaClient(host).DeleteModel(r.Context(), name)
+		if errors.Is(err, platform.ErrModelNotFound) {
+			continue
+		}
+		if err != nil {
+			writeError(w, http.StatusBadGateway, fmt.Sprintf("%s: %v", host, err))
+			return
+		}
+		deleted = append(deleted, host)
+	}
+	if len(deleted) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("model %s is not installed on %s", name, strings.Join(hosts, ", ")))
+		return
+	}
+
+	if s.pool != nil {
+		s.pool.Check(r.Context())
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"name": name, "hosts": deleted})
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/platform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeModelHost is an Ollama that lists, pulls and deletes models in memory
+type fakeModelHost struct {
+	mu        sync.Mutex
+	installed map[string]bool
+}
+
+func newFakeModelHost(t *testing.T, installed ...string) (*fakeModelHost, *httptest.Server) {
+	t.Helper()
+	f := &fakeModelHost{installed: make(map[string]bool)}
+	for _, name := range installed {
+		f.installed[name] = true
+	}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return f, server
+}
+
+func (f *fakeModelHost) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body struct {
+		Name string `json:"name"`
+	}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	switch r.URL.Path {
+	case "/api/tags":
+		var list []map[string]interface{}
+		for name := range f.installed {
+			list = append(list, map[string]interface{}{"name": name, "size": 1 << 30})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"models": list})
+	case "/api/pull":
+		enc := json.NewEncoder(w)
+		if strings.HasPrefix(body.Name, "missing") {
+			enc.Encode(map[string]string{"error": "pull model manifest: file does not exist"})
+			return
+		}
+		enc.Encode(map[string]interface{}{"status": "pulling manifest"})
+		enc.Encode(map[string]interface{}{"status": "pulling abc", "total": 100, "completed": 50})
+		enc.Encode(map[string]interface{}{"status": "success"})
+		f.installed[body.Name] = true
+	case "/api/delete":
+		if !f.installed[body.Name] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.installed, body.Name)
+	}
+}
+
+func setOllamaHosts(t *testing.T, database *db.DB, hosts ...string) {
+	t.Helper()
+	cfg := models.DefaultServerConfig()
+	if len(hosts) == 1 {
+		cfg.Ollama.Host = hosts[0]
+	} else {
+		for _, host := range hosts {
+			cfg.Ollama.Endpoints = append(cfg.Ollama.Endpoints, models.OllamaEndpoint{Host: host})
+		}
+	}
+	require.NoError(t, db.NewConfigRepo(database).Save(cfg))
+}
+
+func pullModel(t *testing.T, ts *httptest.Server, body string) []sseEvent {
+	t.Helper()
+	resp, err := http.Post(ts.URL+"/api/models/pull", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	return readEvents(t, bufio.NewReader(resp.Body), 100)
+}
+
+func TestAPI_ListModels(t *testing.T) {
+	srv, database := newTestServer(t)
+	_, a := newFakeModelHost(t, "qwen3-coder:70b")
+	_, b := newFakeModelHost(t)
+	setOllamaHosts(t, database, a.URL, b.URL)
+
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, httptest.NewRequest("GET", "/api/models", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Hosts []HostModels `json:"hosts"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Hosts, 2)
+	assert.Equal(t, a.URL, resp.Hosts[0].Host)
+	require.Len(t, resp.Hosts[0].Models, 1)
+	assert.Equal(t, "qwen3-coder:70b", resp.Hosts[0].Models[0].Name)
+	assert.Equal(t, 1.0, resp.Hosts[0].Models[0].SizeGB)
+	assert.Empty(t, resp.Hosts[1].Models)
+
+	// Only configured hosts can be asked for
+	w = httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, httptest.NewRequest("GET", "/api/models?host=http://elsewhere:11434", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAPI_PullModel(t *testing.T) {
+	srv, database := newTestServer(t)
+	host, ollama := newFakeModelHost(t)
+	setOllamaHosts(t, database, ollama.URL)
+	ts := newStreamServer(t, srv)
+
+	events := pullModel(t, ts, `{"name": "qwen3-coder:70b"}`)
+	require.Len(t, events, 4)
+	for _, e := range events[:3] {
+		assert.Equal(t, "progress", e.Event)
+	}
+	assert.Equal(t, "done", events[3].Event)
+
+	var progress PullEvent
+	require.NoError(t, json.Unmarshal([]byte(events[1].Data), &progress))
+	assert.Equal(t, ollama.URL, progress.Host)
+	assert.Equal(t, "qwen3-coder:70b", progress.Model)
+	assert.Equal(t, int64(100), progress.Total)
+	assert.Equal(t, int64(50), progress.Completed)
+	assert.True(t, host.installed["qwen3-coder:70b"])
+}
+
+func TestAPI_PullModel_Fails(t *testing.T) {
+	srv, database := newTestServer(t)
+	_, ollama := newFakeModelHost(t)
+	setOllamaHosts(t, database, ollama.URL)
+	ts := newStreamServer(t, srv)
+
+	events := pullModel(t, ts, `{"name": "missing:7b"}`)
+	require.Len(t, events, 1)
+	assert.Equal(t, "error", events[0].Event)
+	assert.Contains(t, events[0].Data, "file does not exist")
+
+	// Bad requests are answered before the stream starts
+	resp, err := http.Post(ts.URL+"/api/models/pull", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestAPI_PullModel_RefreshesPool(t *testing.T) {
+	srv, database := newTestServer(t)
+	_, a := newFakeModelHost(t)
+	_, b := newFakeModelHost(t)
+	setOllamaHosts(t, database, a.URL, b.URL)
+	cfg, err := db.NewConfigRepo(database).Get()
+	require.NoError(t, err)
+	pool := platform.NewPool(cfg)
+	srv.SetPool(pool)
+	ts := newStreamServer(t, srv)
+
+	events := pullModel(t, ts, `{"name": "llama3:8b", "host": "`+b.URL+`"}`)
+	require.NotEmpty(t, events)
+	assert.Equal(t, "done", events[len(events)-1].Event)
+
+	statuses := pool.Status()
+	require.Len(t, statuses, 2)
+	assert.Empty(t, statuses[0].Models, "only the requested host pulls")
+	assert.Equal(t, []string{"llama3:8b"}, statuses[1].Models)
+}
+
+func TestAPI_DeleteModel(t *testing.T) {
+	srv, database := newTestServer(t)
+	host, ollama := newFakeModelHost(t, "org/model:7b")
+	setOllamaHosts(t, database, ollama.URL)
+
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, httptest.NewRequest("DELETE", "/api/models/org/model:7b", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), ollama.URL)
+	assert.Empty(t, host.installed)
+
+	w = httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, httptest.NewRequest("DELETE", "/api/models/org/model:7b", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// SetSecretRequest is the request body for storing a secret
+type SetSecretRequest struct {
+	Value string   `json:"value"`
+	Users []string `json:"users,omitempty"` // token names that may use it besides admins
+}
+
+// SetSecrets attaches the encrypted store of the secrets jobs can name. Job
+// responses hide the values of a job's secrets once it is set.
+func (s *Server) SetSecrets(secrets *db.SecretRepo) {
+	s.secrets = secrets
+}
+
+// redactor returns a redactor for the secrets job uses, or nil if it uses
+// none. A store that cannot be read hides nothing rather than failing the
+// request; the values it holds are never in a response anyway.
+func (s *Server) redactor(job *models.Job) *models.Redactor {
+	if s.secrets == nil || len(job.Secrets) == 0 {
+		return nil
+	}
+	redactor, err := s.secrets.Redactor(job.Secrets)
+	if err != nil {
+		log.Printf("Failed to load secrets of job %d for redaction: %v", job.ID, err)
+		return nil
+	}
+	return redactor
+}
+
+// redactJob returns job with the values of its secrets hidden
+func (s *Server) redactJob(job *models.Job) *models.Job {
+	return s.redactor(job).RedactJob(job)
+}
+
+// writeJob writes job with the values of its secrets hidden
+func (s *Server) writeJob(w http.ResponseWriter, status int, job *models.Job) {
+	writeJSON(w, status, s.redactJob(job))
+}
+
+// redactLogs hides secret values in log lines in place
+func redactLogs(redactor *models.Redactor, logs []*db.JobLog) {
+	for _, entry := range logs {
+		entry.Message = redactor.Redact(entry.Message)
+	}
+}
+
+// redactEvent hides secret values in the job an event carries
+func (s *Server) redactEvent(data interface{}) interface{} {
+	if job, ok := data.(*models.Job); ok {
+		return s.redactJob(job)
+	}
+	return data
+}
+
+// checkSecrets confirms the job's secrets exist and that the request's token
+// may use them, writing an error if not
+func (s *Server) checkSecrets(w http.ResponseWriter, r *http.Request, job *models.Job) bool {
+	if len(job.Secrets) == 0 {
+		return true
+	}
+	if s.secrets == nil {
+		writeError(w, http.StatusBadRequest, "job names secrets but the server has no secret store")
+		return false
+	}
+	token := tokenFrom(r.Context())
+	for _, name := range job.Secrets {
+		secret, err := s.secrets.Get(name)
+		if err != nil {
+			if errors.Is(err, db.ErrNotFound) {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return false
+			}
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return false
+		}
+		if !token.HasScope(models.ScopeAdmin) && !secret.Allows(token.Name) {
+			writeError(w, http.StatusForbidden, "not allowed to use secret "+name)
+			return false
+		}
+	}
+	return true
+}
+
+// requireSecrets answers 503 if the server has no secret store
+func (s *Server) requireSecrets(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.secrets == nil {
+			writeError(w, http.StatusServiceUnavailable, "secret store not configured")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleListSecrets(w http.ResponseWriter, r *http.Request) {
+	secrets, err := s.secrets.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if secrets == nil {
+		secrets = []*models.Secret{}
+	}
+	writeJSON(w, http.StatusOK, secrets)
+}
+
+func (s *Server) handleSetSecret(w http.ResponseWriter, r *http.Request) {
+	var req SetSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	name := chi.URLParam(r, "name")
+	if err := models.ValidateSecretName(name); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Value == "" {
+		writeError(w, http.StatusBadRequest, "value is required")
+		return
+	}
+	if err := s.secrets.Set(name, req.Value, req.Users); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeleteSecret(w http.ResponseWriter, r *http.Request) {
+	if err := s.secrets.Delete(chi.URLParam(r, "name")); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			writeError(w, http.StatusNotFound, "secret not found")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
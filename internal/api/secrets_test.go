@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSecretServer returns a test server with a secret store holding
+// NPM_TOKEN
+func newSecretServer(t *testing.T) (*Server, *db.DB) {
+	t.Helper()
+	srv, database := newTestServer(t)
+	secrets, err := db.NewSecretRepo(database, bytes.Repeat([]byte{1}, db.SecretKeySize))
+	require.NoError(t, err)
+	require.NoError(t, secrets.Set("NPM_TOKEN", "npm_abc123", nil))
+	srv.SetSecrets(secrets)
+	return srv, database
+}
+
+func serve(srv *Server, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, req)
+	return w
+}
+
+func TestAPI_Secrets(t *testing.T) {
+	srv, _ := newTestServer(t)
+	assert.Equal(t, http.StatusServiceUnavailable, serve(srv, "GET", "/api/secrets", "").Code)
+
+	srv, _ = newSecretServer(t)
+	assert.Equal(t, http.StatusNoContent, serve(srv, "PUT", "/api/secrets/GH_TOKEN", `{"value": "ghp_x", "users": ["alice"]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(srv, "PUT", "/api/secrets/GH_TOKEN", `{"value": ""}`).Code)
+	assert.Equal(t, http.StatusBadRequest, serve(srv, "PUT", "/api/secrets/1BAD", `{"value": "x"}`).Code)
+
+	w := serve(srv, "GET", "/api/secrets", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "ghp_x", "values are never returned")
+	var secrets []models.Secret
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &secrets))
+	require.Len(t, secrets, 2)
+	assert.Equal(t, "GH_TOKEN", secrets[0].Name)
+	assert.Equal(t, []string{"alice"}, secrets[0].Users)
+
+	assert.Equal(t, http.StatusNoContent, serve(srv, "DELETE", "/api/secrets/GH_TOKEN", "").Code)
+	assert.Equal(t, http.StatusNotFound, serve(srv, "DELETE", "/api/secrets/GH_TOKEN", "").Code)
+}
+
+func TestAPI_CreateJob_Secrets(t *testing.T) {
+	body := `{"repo_url": "git@github.com:user/repo.git", "branch": "main", "prompt": "publish", "max_iterations": 5, "secrets": ["%s"]}`
+
+	srv, _ := newTestServer(t)
+	w := serve(srv, "POST", "/api/jobs", fmt.Sprintf(body, "NPM_TOKEN"))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "no secret store")
+
+	srv, _ = newSecretServer(t)
+	w = serve(srv, "POST", "/api/jobs", fmt.Sprintf(body, "AWS_KEY"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "secret AWS_KEY: not found")
+
+	w = serve(srv, "POST", "/api/jobs", fmt.Sprintf(body, "NPM_TOKEN"))
+	require.Equal(t, http.StatusCreated, w.Code)
+	var job models.Job
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(t, []string{"NPM_TOKEN"}, job.Secrets)
+}
+
+func TestAPI_CreateJob_SecretUsers(t *testing.T) {
+	srv, tokens := newAuthServer(t)
+	secrets, err := db.NewSecretRepo(srv.db, bytes.Repeat([]byte{1}, db.SecretKeySize))
+	require.NoError(t, err)
+	require.NoError(t, secrets.Set("NPM_TOKEN", "npm_abc123", nil))
+	require.NoError(t, secrets.Set("CI_TOKEN", "ci_abc123", []string{"submit"}))
+	srv.SetSecrets(secrets)
+
+	submit := func(token, secret string) int {
+		body := fmt.Sprintf(`{"repo_url": "git@github.com:user/repo.git", "branch": "b-%s-%s", "prompt": "publish", "max_iterations": 5, "secrets": [%q]}`, token, secret, secret)
+		return authRequest(srv, "POST", "/api/jobs", tokens[token], []byte(body)).Code
+	}
+	assert.Equal(t, http.StatusForbidden, submit("submit", "NPM_TOKEN"), "only admins may use a secret without users")
+	assert.Equal(t, http.StatusCreated, submit("submit", "CI_TOKEN"))
+	assert.Equal(t, http.StatusCreated, submit("admin", "NPM_TOKEN"))
+}
+
+func TestAPI_RedactsSecrets(t *testing.T) {
+	srv, database := newSecretServer(t)
+
+	// A value that also ended up in plain env, logs and the job's error
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	job.Env = map[string]string{"NPM_CONFIG": "//registry/:_authToken=npm_abc123"}
+	job.Secrets = []string{"NPM_TOKEN"}
+	require.NoError(t, srv.queue.Enqueue(job))
+	job.Error = "push rejected for npm_abc123"
+	require.NoError(t, db.NewJobRepo(database).Update(job))
+	require.NoError(t, db.NewLogRepo(database).Append(job.ID, 1, "token npm_abc123"))
+
+	for _, path := range []string{fmt.Sprintf("/api/jobs/%d", job.ID), "/api/jobs", fmt.Sprintf("/api/jobs/%d/logs", job.ID)} {
+		w := serve(srv, "GET", path, "")
+		require.Equal(t, http.StatusOK, w.Code, path)
+		assert.NotContains(t, w.Body.String(), "npm_abc123", path)
+		assert.Contains(t, w.Body.String(), models.Redacted, path)
+	}
+}
@@ -13,6 +13,10 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/ryan/ralph-o-matic/internal/dashboard"
 	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/doctor"
+	"github.com/ryan/ralph-o-matic/internal/executor"
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/platform"
 	"github.com/ryan/ralph-o-matic/internal/queue"
 	"github.com/ryan/ralph-o-matic/web"
 )
@@ -21,10 +25,25 @@ import (
 type Server struct {
 	db        *db.DB
 	queue     *queue.Queue
+	scheduler *queue.Scheduler
+	pool      *platform.Pool
+	monitor   *platform.Monitor
+	admission *platform.Admission
+	doctor    *doctor.Doctor
+	handler   *executor.RalphHandler
 	dashboard *dashboard.Dashboard
+	tokens    *db.TokenRepo
+	secrets   *db.SecretRepo
+	auth      bool   // requests need a token; see EnableAuth
+	origin    string // other origin browsers may call the API from; see SetCORSOrigin
 	addr      string
 	router    chi.Router
 	server    *http.Server
+
+	// streams is cancelled on shutdown to end open SSE connections, which
+	// would otherwise hold http.Server.Shutdown until its deadline
+	streams     context.Context
+	stopStreams context.CancelFunc
 }
 
 // NewServer creates a new API server
@@ -34,11 +53,15 @@ func NewServer(database *db.DB, q *queue.Queue, addr string) *Server {
 		log.Fatalf("failed to load templates: %v", err)
 	}
 
+	streams, stopStreams := context.WithCancel(context.Background())
 	s := &Server{
-		db:        database,
-		queue:     q,
-		dashboard: dashboard.New(database, q, templatesFS),
-		addr:      addr,
+		db:          database,
+		queue:       q,
+		dashboard:   dashboard.New(database, q, templatesFS),
+		tokens:      db.NewTokenRepo(database),
+		addr:        addr,
+		streams:     streams,
+		stopStreams: stopStreams,
 	}
 
 	s.setupRoutes()
@@ -51,51 +74,177 @@ func (s *Server) setupRoutes() {
 	// Middleware
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Timeout(60 * time.Second))
-	r.Use(corsMiddleware)
-
-	// Health check
-	r.Get("/health", s.handleHealth)
-
-	// Dashboard
-	r.Get("/", s.dashboard.HandleIndex)
-	r.Get("/config", s.dashboard.HandleConfig)
-	r.Get("/jobs/{jobID}", func(w http.ResponseWriter, r *http.Request) {
-		idStr := chi.URLParam(r, "jobID")
-		id, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid job ID", http.StatusBadRequest)
-			return
-		}
-		s.dashboard.HandleJob(w, r, id)
+	r.Use(s.cors)
+
+	// Streaming endpoints stay open for as long as the client listens, so
+	// the request timeout applies only to the routes grouped under it
+	timeout := middleware.Timeout(60 * time.Second)
+
+	r.Group(func(r chi.Router) {
+		r.Use(timeout)
+
+		// Health check
+		r.Get("/health", s.handleHealth)
+
+		// Dashboard login
+		r.Get("/login", s.handleLoginPage)
+		r.Post("/login", s.handleLogin)
+		r.Post("/logout", s.handleLogout)
+
+		// Dashboard
+		r.Group(func(r chi.Router) {
+			r.Use(s.authenticatePage)
+			r.Get("/", s.dashboard.HandleIndex)
+			r.Get("/config", s.dashboard.HandleConfig)
+			r.Get("/jobs/{jobID}", func(w http.ResponseWriter, r *http.Request) {
+				idStr := chi.URLParam(r, "jobID")
+				id, err := strconv.ParseInt(idStr, 10, 64)
+				if err != nil {
+					http.Error(w, "Invalid job ID", http.StatusBadRequest)
+					return
+				}
+				s.dashboard.HandleJob(w, r, id)
+			})
+		})
 	})
 
+	// Every token can read; changes need the scope that grants them
+	submit := s.requireScope(models.ScopeSubmit)
+	control := s.requireScope(models.ScopeControl)
+	admin := s.requireScope(models.ScopeAdmin)
+
 	// API routes
 	r.Route("/api", func(r chi.Router) {
+		r.Use(s.authenticateAPI)
+
+		r.Get("/events", s.handleEvents)
+
 		r.Route("/jobs", func(r chi.Router) {
-			r.Post("/", s.handleCreateJob)
-			r.Get("/", s.handleListJobs)
-			r.Put("/order", s.handleReorderJobs)
+			r.Group(func(r chi.Router) {
+				r.Use(timeout)
+				r.With(submit).Post("/", s.handleCreateJob)
+				r.Get("/", s.handleListJobs)
+				r.With(control).Put("/order", s.handleReorderJobs)
+			})
 
 			r.Route("/{jobID}", func(r chi.Router) {
-				r.Get("/", s.handleGetJob)
-				r.Delete("/", s.handleCancelJob)
-				r.Patch("/", s.handleUpdateJob)
-				r.Get("/logs", s.handleGetJobLogs)
-				r.Post("/pause", s.handlePauseJob)
-				r.Post("/resume", s.handleResumeJob)
+				r.Get("/logs/stream", s.handleStreamJobLogs)
+
+				r.Group(func(r chi.Router) {
+					r.Use(timeout)
+					r.Get("/", s.handleGetJob)
+					r.With(control).Delete("/", s.handleCancelJob)
+					r.With(control).Patch("/", s.handleUpdateJob)
+					r.Get("/logs", s.handleGetJobLogs)
+					r.Get("/iterations", s.handleGetIterations)
+					r.Get("/agent-events", s.handleGetAgentEvents)
+					r.With(control).Post("/pause", s.handlePauseJob)
+					r.With(control).Post("/resume", s.handleResumeJob)
+				})
 			})
 		})
 
 		r.Route("/config", func(r chi.Router) {
+			r.Use(timeout)
 			r.Get("/", s.handleGetConfig)
-			r.Patch("/", s.handleUpdateConfig)
+			r.With(admin).Patch("/", s.handleUpdateConfig)
+		})
+
+		r.With(timeout).Get("/endpoints", s.handleListEndpoints)
+		r.With(timeout).Get("/system", s.handleGetSystem)
+		r.With(timeout).Get("/doctor", s.handleDoctor)
+
+		r.Route("/models", func(r chi.Router) {
+			// Pulls stream progress for as long as the download takes
+			r.With(admin).Post("/pull", s.handlePullModel)
+
+			r.Group(func(r chi.Router) {
+				r.Use(timeout)
+				r.Get("/", s.handleListModels)
+				r.With(admin).Delete("/*", s.handleDeleteModel)
+			})
+		})
+
+		r.With(timeout).Get("/whoami", s.handleWhoAmI)
+
+		r.Route("/tokens", func(r chi.Router) {
+			r.Use(timeout, admin)
+			r.Get("/", s.handleListTokens)
+			r.Post("/", s.handleCreateToken)
+			r.Delete("/{tokenID}", s.handleDeleteToken)
+		})
+
+		r.Route("/secrets", func(r chi.Router) {
+			r.Use(timeout, admin, s.requireSecrets)
+			r.Get("/", s.handleListSecrets)
+			r.Put("/{name}", s.handleSetSecret)
+			r.Delete("/{name}", s.handleDeleteSecret)
 		})
 	})
 
 	s.router = r
 }
 
+// SetScheduler attaches the scheduler that runs queued jobs so the API can
+// wake it on new work and apply config changes to it
+func (s *Server) SetScheduler(sched *queue.Scheduler) {
+	s.scheduler = sched
+}
+
+// SetPool attaches the Ollama endpoint pool jobs are routed across so the
+// API can report its health and apply config changes to it
+func (s *Server) SetPool(pool *platform.Pool) {
+	s.pool = pool
+}
+
+// SetMonitor attaches the monitor of what the Ollama hosts have loaded so
+// the API and dashboard can show it and apply config changes to it
+func (s *Server) SetMonitor(m *platform.Monitor) {
+	s.monitor = m
+	s.dashboard.SetMonitor(m)
+}
+
+// SetAdmission attaches the memory admission control of the scheduler so
+// the API can apply config changes to it
+func (s *Server) SetAdmission(a *platform.Admission) {
+	s.admission = a
+}
+
+// SetDoctor attaches the diagnostics of the server's environment served at
+// /api/doctor
+func (s *Server) SetDoctor(d *doctor.Doctor) {
+	s.doctor = d
+}
+
+// SetHandler attaches the handler that runs jobs so the API can apply config
+// changes to it
+func (s *Server) SetHandler(h *executor.RalphHandler) {
+	s.handler = h
+}
+
+// jobController changes a job's run state. Queue only records the change;
+// Scheduler also stops running subprocesses and hands resumed jobs to workers.
+type jobController interface {
+	Pause(job *models.Job) error
+	Resume(job *models.Job) error
+	Cancel(job *models.Job) error
+}
+
+// controller returns the scheduler if attached, otherwise the bare queue
+func (s *Server) controller() jobController {
+	if s.scheduler != nil {
+		return s.scheduler
+	}
+	return s.queue
+}
+
+// signalScheduler wakes the scheduler if one is attached
+func (s *Server) signalScheduler() {
+	if s.scheduler != nil {
+		s.scheduler.Signal()
+	}
+}
+
 // Router returns the chi router for testing
 func (s *Server) Router() chi.Router {
 	return s.router
@@ -113,8 +262,9 @@ func (s *Server) Start() error {
 	return s.server.ListenAndServe()
 }
 
-// Shutdown gracefully shuts down the server
+// Shutdown gracefully shuts down the server, closing open event streams
 func (s *Server) Shutdown(ctx context.Context) error {
+	s.stopStreams()
 	if s.server == nil {
 		return nil
 	}
@@ -136,10 +286,24 @@ func writeError(w http.ResponseWriter, status int, message string) {
 	writeJSON(w, status, map[string]string{"error": message})
 }
 
-// CORS middleware
-func corsMiddleware(next http.Handler) http.Handler {
+// SetCORSOrigin lets pages served from origin, such as
+// "https://ralph.example.com", call the API from the browser. Without it
+// only the dashboard, served by the API itself, can.
+func (s *Server) SetCORSOrigin(origin string) {
+	s.origin = origin
+}
+
+// cors answers cross-origin requests from the configured origin only, so
+// that other sites cannot use a browser's dashboard session
+func (s *Server) cors(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Add("Vary", "Origin")
+		if s.origin == "" || r.Header.Get("Origin") != s.origin {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Access-Control-Allow-Origin", s.origin)
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
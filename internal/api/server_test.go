@@ -49,13 +49,21 @@ func TestServer_NotFound(t *testing.T) {
 func TestServer_CORS(t *testing.T) {
 	srv, _ := newTestServer(t)
 
-	req := httptest.NewRequest("OPTIONS", "/api/jobs", nil)
-	req.Header.Set("Origin", "http://localhost:3000")
-	req.Header.Set("Access-Control-Request-Method", "POST")
-	w := httptest.NewRecorder()
-
-	srv.Router().ServeHTTP(w, req)
-
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("OPTIONS", "/api/jobs", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+		srv.Router().ServeHTTP(w, req)
+		return w
+	}
+
+	// No other origin is allowed unless configured
+	assert.Empty(t, preflight("http://localhost:3000").Header().Get("Access-Control-Allow-Origin"))
+
+	srv.SetCORSOrigin("http://localhost:3000")
+	w := preflight("http://localhost:3000")
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.NotEmpty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "http://localhost:3000", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, preflight("https://evil.example.com").Header().Get("Access-Control-Allow-Origin"))
 }
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/ryan/ralph-o-matic/internal/platform"
+)
+
+// SystemStatus describes the server's hardware and what its Ollama hosts
+// have loaded
+type SystemStatus struct {
+	Hardware *platform.HardwareInfo  `json:"hardware,omitempty"`
+	Ollama   []platform.HostActivity `json:"ollama"`
+}
+
+// detectHardware probes the server's hardware once; it does not change
+// while the server runs and probing shells out to GPU tools
+var detectHardware = sync.OnceValues(platform.DetectHardware)
+
+func (s *Server) handleGetSystem(w http.ResponseWriter, r *http.Request) {
+	status := SystemStatus{Ollama: []platform.HostActivity{}}
+	if hw, err := detectHardware(); err == nil {
+		status.Hardware = hw
+	}
+	if s.monitor != nil {
+		status.Ollama = s.monitor.Snapshot()
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleDoctor runs the diagnostics of the server's environment
+func (s *Server) handleDoctor(w http.ResponseWriter, r *http.Request) {
+	if s.doctor == nil {
+		writeError(w, http.StatusServiceUnavailable, "diagnostics are not available")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.doctor.Run(r.Context()))
+}
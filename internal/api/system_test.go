@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/doctor"
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/platform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPI_GetSystem(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"models": []platform.RunningModel{
+				{Name: "qwen3-coder:70b", Size: 100, SizeVRAM: 40, ExpiresAt: time.Now().Add(time.Minute)},
+			},
+		})
+	}))
+	defer ollama.Close()
+
+	get := func() SystemStatus {
+		w := httptest.NewRecorder()
+		srv.Router().ServeHTTP(w, httptest.NewRequest("GET", "/api/system", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		var status SystemStatus
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		return status
+	}
+
+	// Without a monitor there is no Ollama activity to report
+	assert.Empty(t, get().Ollama)
+
+	cfg := models.DefaultServerConfig()
+	cfg.Ollama.Host = ollama.URL
+	monitor := platform.NewMonitor(cfg)
+	monitor.Poll(t.Context())
+	srv.SetMonitor(monitor)
+
+	status := get()
+	require.Len(t, status.Ollama, 1)
+	assert.Equal(t, ollama.URL, status.Ollama[0].Host)
+	require.Len(t, status.Ollama[0].Models, 1)
+	assert.Equal(t, "60%/40% CPU/GPU", status.Ollama[0].Models[0].Processor())
+
+	// The dashboard shows the same
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "qwen3-coder:70b")
+	assert.Contains(t, w.Body.String(), "60%/40% CPU/GPU")
+}
+
+func TestAPI_Doctor(t *testing.T) {
+	srv, database := newTestServer(t)
+
+	w := httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, httptest.NewRequest("GET", "/api/doctor", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	srv.SetDoctor(doctor.New(database, t.TempDir()))
+	w = httptest.NewRecorder()
+	srv.Router().ServeHTTP(w, httptest.NewRequest("GET", "/api/doctor", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var report doctor.Report
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	checks := make(map[string]doctor.Check)
+	for _, c := range report.Checks {
+		checks[c.Name] = c
+	}
+	assert.Equal(t, doctor.StatusOK, checks["database"].Status)
+	assert.Equal(t, doctor.StatusOK, checks["workspace"].Status)
+	assert.Contains(t, checks, "git")
+	assert.Contains(t, checks, "gh")
+}
@@ -8,14 +8,18 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/platform"
 )
 
 // Client communicates with the ralph-o-matic server
 type Client struct {
 	baseURL    string
+	token      string // bearer token sent with every request, if set
 	httpClient *http.Client
+	retryDelay time.Duration // wait before reconnecting a dropped log stream
 }
 
 // NewClient creates a new API client
@@ -23,9 +27,15 @@ func NewClient(baseURL string) *Client {
 	return &Client{
 		baseURL:    strings.TrimSuffix(baseURL, "/"),
 		httpClient: &http.Client{},
+		retryDelay: defaultRetryDelay,
 	}
 }
 
+// SetToken sets the API token the client authenticates with
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
 // CreateJobRequest is the request for creating a job
 type CreateJobRequest struct {
 	RepoURL       string            `json:"repo_url"`
@@ -35,13 +45,39 @@ type CreateJobRequest struct {
 	Priority      string            `json:"priority,omitempty"`
 	WorkingDir    string            `json:"working_dir,omitempty"`
 	Env           map[string]string `json:"env,omitempty"`
+	Secrets       []string          `json:"secrets,omitempty"`
+	Executor      string            `json:"executor,omitempty"`
+	VerifyCommand string            `json:"verify_command,omitempty"`
+	StallWindow   int               `json:"stall_window,omitempty"`
+	StallPolicy   string            `json:"stall_policy,omitempty"`
+	ModelPlan     models.ModelPlan  `json:"model_plan,omitempty"`
+	LargeModel    string            `json:"large_model,omitempty"`
+	SmallModel    string            `json:"small_model,omitempty"`
+	OllamaHost    string            `json:"ollama_host,omitempty"`
+	EndpointTags  []string          `json:"endpoint_tags,omitempty"`
+
+	MaxDuration         string `json:"max_duration,omitempty"`
+	PerIterationTimeout string `json:"per_iteration_timeout,omitempty"`
+	MaxTokens           int64  `json:"max_tokens,omitempty"`
+
+	// Submitter's git user.email; the server uses the token's name instead
+	// when it authenticates
+	Owner string `json:"owner,omitempty"`
 }
 
-// GetJobs retrieves jobs from the server
-func (c *Client) GetJobs(statuses []string) ([]*models.Job, int, error) {
-	path := "/api/jobs"
+// GetJobs retrieves jobs from the server, optionally only those with
+// statuses or submitted by owner
+func (c *Client) GetJobs(statuses []string, owner string) ([]*models.Job, int, error) {
+	query := url.Values{}
 	if len(statuses) > 0 {
-		path += "?status=" + url.QueryEscape(strings.Join(statuses, ","))
+		query.Set("status", strings.Join(statuses, ","))
+	}
+	if owner != "" {
+		query.Set("owner", owner)
+	}
+	path := "/api/jobs"
+	if len(query) > 0 {
+		path += "?" + query.Encode()
 	}
 
 	var resp struct {
@@ -126,16 +162,122 @@ func (c *Client) UpdateConfig(updates map[string]interface{}) (*models.ServerCon
 }
 
 // GetLogs retrieves logs for a job
-func (c *Client) GetLogs(jobID int64) ([]map[string]interface{}, error) {
+func (c *Client) GetLogs(jobID int64, opts LogOptions) ([]*LogEntry, error) {
 	var resp struct {
-		Logs []map[string]interface{} `json:"logs"`
+		Logs []*LogEntry `json:"logs"`
 	}
-	if err := c.get(fmt.Sprintf("/api/jobs/%d/logs", jobID), &resp); err != nil {
+	if err := c.get(fmt.Sprintf("/api/jobs/%d/logs%s", jobID, opts.query()), &resp); err != nil {
 		return nil, err
 	}
 	return resp.Logs, nil
 }
 
+// GetIterations returns the per-iteration timeline of a job
+func (c *Client) GetIterations(jobID int64) ([]*models.Iteration, error) {
+	var resp struct {
+		Iterations []*models.Iteration `json:"iterations"`
+	}
+	if err := c.get(fmt.Sprintf("/api/jobs/%d/iterations", jobID), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Iterations, nil
+}
+
+// GetEndpoints returns the health and load of the server's Ollama endpoints
+func (c *Client) GetEndpoints() ([]platform.EndpointStatus, error) {
+	var resp struct {
+		Endpoints []platform.EndpointStatus `json:"endpoints"`
+	}
+	if err := c.get("/api/endpoints", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Endpoints, nil
+}
+
+// DoctorCheck is the result of one of the server's diagnostics
+type DoctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"` // "ok", "warn" or "fail"
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"`
+}
+
+// DoctorReport is the result of every one of the server's diagnostics
+type DoctorReport struct {
+	Checks  []DoctorCheck `json:"checks"`
+	Healthy bool          `json:"healthy"`
+}
+
+// Doctor runs the server's diagnostics of its environment
+func (c *Client) Doctor() (*DoctorReport, error) {
+	var report DoctorReport
+	if err := c.get("/api/doctor", &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// WhoAmI returns the token the client authenticates with
+func (c *Client) WhoAmI() (*models.Token, error) {
+	var token models.Token
+	if err := c.get("/api/whoami", &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// CreatedToken is a new token with its secret, which the server only
+// returns once
+type CreatedToken struct {
+	models.Token
+	Secret string `json:"secret"`
+}
+
+// CreateToken creates an API token
+func (c *Client) CreateToken(name string, scopes []models.Scope) (*CreatedToken, error) {
+	req := map[string]interface{}{"name": name, "scopes": scopes}
+	var token CreatedToken
+	if err := c.post("/api/tokens", req, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ListTokens returns the server's API tokens
+func (c *Client) ListTokens() ([]*models.Token, error) {
+	var tokens []*models.Token
+	if err := c.get("/api/tokens", &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// DeleteToken revokes an API token
+func (c *Client) DeleteToken(id int64) error {
+	return c.delete(fmt.Sprintf("/api/tokens/%d", id), nil)
+}
+
+// SetSecret stores a secret on the server that users, besides admins, may
+// use, replacing any existing value
+func (c *Client) SetSecret(name, value string, users []string) error {
+	req := map[string]interface{}{"value": value, "users": users}
+	return c.put("/api/secrets/"+url.PathEscape(name), req, nil)
+}
+
+// ListSecrets returns the names of the server's secrets
+func (c *Client) ListSecrets() ([]*models.Secret, error) {
+	var secrets []*models.Secret
+	if err := c.get("/api/secrets", &secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// DeleteSecret removes a secret from the server
+func (c *Client) DeleteSecret(name string) error {
+	return c.delete("/api/secrets/"+url.PathEscape(name), nil)
+}
+
 // Ping checks if server is reachable
 func (c *Client) Ping() error {
 	return c.get("/health", nil)
@@ -179,6 +321,7 @@ func (c *Client) request(method, path string, body, result interface{}) error {
 	if body != nil {
 		req.Header.Set("Content-Type", "application/json")
 	}
+	c.authorize(req)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -187,11 +330,7 @@ func (c *Client) request(method, path string, body, result interface{}) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		var errResp struct {
-			Error string `json:"error"`
-		}
-		json.NewDecoder(resp.Body).Decode(&errResp)
-		return fmt.Errorf("server error: %s", errResp.Error)
+		return responseError(resp)
 	}
 
 	if result != nil {
@@ -202,3 +341,23 @@ func (c *Client) request(method, path string, body, result interface{}) error {
 
 	return nil
 }
+
+// authorize adds the client's token to req
+func (c *Client) authorize(req *http.Request) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+}
+
+// responseError returns the error of a failed response, with a hint to log
+// in if the server wants a token
+func responseError(resp *http.Response) error {
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&errResp)
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("server error: %s (run `ralph-o-matic login`)", errResp.Error)
+	}
+	return fmt.Errorf("server error: %s", errResp.Error)
+}
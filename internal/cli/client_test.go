@@ -24,13 +24,25 @@ func TestClient_GetJobs(t *testing.T) {
 	defer server.Close()
 
 	client := NewClient(server.URL)
-	jobs, total, err := client.GetJobs(nil)
+	jobs, total, err := client.GetJobs(nil, "")
 
 	require.NoError(t, err)
 	assert.Equal(t, 0, total)
 	assert.Len(t, jobs, 0)
 }
 
+func TestClient_GetJobs_Filters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "queued,running", r.URL.Query().Get("status"))
+		assert.Equal(t, "alice@example.com", r.URL.Query().Get("owner"))
+		json.NewEncoder(w).Encode(map[string]interface{}{"jobs": []*models.Job{}, "total": 0})
+	}))
+	defer server.Close()
+
+	_, _, err := NewClient(server.URL).GetJobs([]string{"queued", "running"}, "alice@example.com")
+	require.NoError(t, err)
+}
+
 func TestClient_CreateJob(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, "POST", r.Method)
@@ -73,3 +85,139 @@ func TestClient_PauseJob(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, models.StatusPaused, job.Status)
 }
+
+func TestClient_GetIterations(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/jobs/1/iterations", r.URL.Path)
+
+		it := models.NewIteration(1, 1)
+		it.CommitHash = "abc1234"
+		json.NewEncoder(w).Encode(map[string]interface{}{"iterations": []*models.Iteration{it}})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	iterations, err := client.GetIterations(1)
+
+	require.NoError(t, err)
+	require.Len(t, iterations, 1)
+	assert.Equal(t, 1, iterations[0].Number)
+	assert.Equal(t, "abc1234", iterations[0].CommitHash)
+}
+
+func TestClient_Doctor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/doctor", r.URL.Path)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"checks": []map[string]interface{}{
+				{"name": "git", "status": "ok", "detail": "installed"},
+				{"name": "gh", "status": "fail", "detail": "gh is not logged in", "fix": "Run `gh auth login`"},
+			},
+			"healthy": false,
+		})
+	}))
+	defer server.Close()
+
+	report, err := NewClient(server.URL).Doctor()
+	require.NoError(t, err)
+	assert.False(t, report.Healthy)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, "fail", report.Checks[1].Status)
+	assert.Equal(t, "Run `gh auth login`", report.Checks[1].Fix)
+}
+
+func TestClient_GetEndpoints(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/endpoints", r.URL.Path)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"endpoints": []map[string]interface{}{
+				{"name": "gpu-1", "host": "http://gpu-1:11434", "capacity": 2, "active": 1, "healthy": true},
+			},
+		})
+	}))
+	defer server.Close()
+
+	endpoints, err := NewClient(server.URL).GetEndpoints()
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+	assert.Equal(t, "gpu-1", endpoints[0].Name)
+	assert.Equal(t, 1, endpoints[0].Active)
+	assert.True(t, endpoints[0].Healthy)
+}
+
+func TestClient_SendsToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ralph_secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "authentication required"})
+			return
+		}
+		json.NewEncoder(w).Encode(models.Token{ID: 1, Name: "alice", Scopes: []models.Scope{models.ScopeSubmit}})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	_, err := client.WhoAmI()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ralph-o-matic login")
+
+	client.SetToken("ralph_secret")
+	me, err := client.WhoAmI()
+	require.NoError(t, err)
+	assert.Equal(t, "alice", me.Name)
+}
+
+func TestClient_CreateToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/api/tokens", r.URL.Path)
+
+		var req struct {
+			Name   string         `json:"name"`
+			Scopes []models.Scope `json:"scopes"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id": 2, "name": req.Name, "scopes": req.Scopes, "secret": "ralph_new",
+		})
+	}))
+	defer server.Close()
+
+	token, err := NewClient(server.URL).CreateToken("ci", []models.Scope{models.ScopeSubmit})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), token.ID)
+	assert.Equal(t, "ci", token.Name)
+	assert.Equal(t, "ralph_new", token.Secret)
+}
+
+func TestClient_Secrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "PUT /api/secrets/NPM_TOKEN":
+			var req struct {
+				Value string   `json:"value"`
+				Users []string `json:"users"`
+			}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			assert.Equal(t, "npm_abc123", req.Value)
+			assert.Equal(t, []string{"ci"}, req.Users)
+			w.WriteHeader(http.StatusNoContent)
+		case "GET /api/secrets":
+			json.NewEncoder(w).Encode([]map[string]string{{"name": "NPM_TOKEN"}})
+		case "DELETE /api/secrets/NPM_TOKEN":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	require.NoError(t, client.SetSecret("NPM_TOKEN", "npm_abc123", []string{"ci"}))
+	secrets, err := client.ListSecrets()
+	require.NoError(t, err)
+	require.Len(t, secrets, 1)
+	assert.Equal(t, "NPM_TOKEN", secrets[0].Name)
+	require.NoError(t, client.DeleteSecret("NPM_TOKEN"))
+}
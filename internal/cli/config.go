@@ -13,6 +13,7 @@ type Config struct {
 	Server               string `yaml:"server"`
 	DefaultPriority      string `yaml:"default_priority"`
 	DefaultMaxIterations int    `yaml:"default_max_iterations"`
+	Token                string `yaml:"token,omitempty"` // API token, set by `ralph-o-matic login`
 }
 
 // DefaultConfig returns a config with defaults
@@ -92,6 +93,9 @@ func (c *Config) Merge(other *Config) *Config {
 	if other.DefaultMaxIterations > 0 {
 		result.DefaultMaxIterations = other.DefaultMaxIterations
 	}
+	if other.Token != "" {
+		result.Token = other.Token
+	}
 
 	return &result
 }
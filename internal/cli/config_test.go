@@ -54,10 +54,12 @@ func TestConfig_Merge(t *testing.T) {
 
 	overrides := &Config{
 		Server: "http://new-server:9090",
+		Token:  "ralph_secret",
 	}
 
 	merged := base.Merge(overrides)
 
 	assert.Equal(t, "http://new-server:9090", merged.Server)
 	assert.Equal(t, base.DefaultPriority, merged.DefaultPriority)
+	assert.Equal(t, "ralph_secret", merged.Token)
 }
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// defaultRetryDelay is how long FollowLogs waits before reconnecting
+const defaultRetryDelay = 2 * time.Second
+
+// LogEntry is a single line of job output
+type LogEntry struct {
+	ID        int64     `json:"id"`
+	JobID     int64     `json:"job_id"`
+	Iteration int       `json:"iteration"`
+	Timestamp time.Time `json:"timestamp"`
+	Message   string    `json:"message"`
+}
+
+// LogOptions filters the logs returned by GetLogs and FollowLogs.
+// Zero values match everything.
+type LogOptions struct {
+	Iteration int       // only lines from this iteration
+	Since     time.Time // only lines written at or after this time
+	Tail      int       // only the last Tail lines written so far
+	AfterID   int64     // only lines after this log ID
+}
+
+func (o LogOptions) query() string {
+	v := url.Values{}
+	if o.Iteration > 0 {
+		v.Set("iteration", strconv.Itoa(o.Iteration))
+	}
+	if !o.Since.IsZero() {
+		v.Set("since", o.Since.UTC().Format(time.RFC3339))
+	}
+	if o.Tail > 0 {
+		v.Set("tail", strconv.Itoa(o.Tail))
+	}
+	if o.AfterID > 0 {
+		v.Set("after", strconv.FormatInt(o.AfterID, 10))
+	}
+	if len(v) == 0 {
+		return ""
+	}
+	return "?" + v.Encode()
+}
+
+// FollowLogs streams a job's logs to onLog until the job finishes or ctx is
+// cancelled, and returns the job's final state. If the connection drops,
+// for example because the server restarted, it reconnects and resumes after
+// the last line received; onRetry (which may be nil) is told why.
+func (c *Client) FollowLogs(ctx context.Context, jobID int64, opts LogOptions, onLog func(*LogEntry), onRetry func(error)) (*models.Job, error) {
+	for {
+		job, retry, err := c.streamLogs(ctx, jobID, &opts, onLog)
+		if job != nil {
+			return job, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if !retry {
+			return nil, err
+		}
+		if onRetry != nil {
+			onRetry(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(c.retryDelay):
+		}
+	}
+}
+
+// streamLogs reads one connection's worth of the log stream, advancing
+// opts past every line delivered. It returns the job once the server
+// reports it finished; otherwise retry says whether reconnecting may help.
+func (c *Client) streamLogs(ctx context.Context, jobID int64, opts *LogOptions, onLog func(*LogEntry)) (*models.Job, bool, error) {
+	path := fmt.Sprintf("/api/jobs/%d/logs/stream%s", jobID, opts.query())
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+path, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	c.authorize(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, true, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, resp.StatusCode >= 500, responseError(resp)
+	}
+
+	var event, data string
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			job, err := handleLogEvent(event, data, opts, onLog)
+			if err != nil || job != nil {
+				return job, false, err
+			}
+			event, data = "", ""
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, true, fmt.Errorf("stream interrupted: %w", err)
+	}
+	return nil, true, fmt.Errorf("stream interrupted: %w", io.ErrUnexpectedEOF)
+}
+
+// handleLogEvent dispatches one SSE event, returning the job when the event
+// is the final status
+func handleLogEvent(event, data string, opts *LogOptions, onLog func(*LogEntry)) (*models.Job, error) {
+	switch event {
+	case "log":
+		var entry LogEntry
+		if err := json.Unmarshal([]byte(data), &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode log event: %w", err)
+		}
+		opts.AfterID = entry.ID
+		opts.Tail = 0 // Tail only trims the first backlog
+		if onLog != nil {
+			onLog(&entry)
+		}
+	case "status":
+		var job models.Job
+		if err := json.Unmarshal([]byte(data), &job); err != nil {
+			return nil, fmt.Errorf("failed to decode status event: %w", err)
+		}
+		if job.Status.IsTerminal() {
+			return &job, nil
+		}
+	}
+	return nil, nil
+}
+
+// ParseSince interprets a --since value: a duration before now ("10m",
+// "2h") or an RFC3339 timestamp
+func ParseSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("invalid since %q: duration must be positive", value)
+		}
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid since %q: want a duration like 10m or an RFC3339 time", value)
+}
@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_GetLogs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/jobs/1/logs", r.URL.Path)
+		assert.Equal(t, "3", r.URL.Query().Get("iteration"))
+		assert.Equal(t, "5", r.URL.Query().Get("tail"))
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"logs": []LogEntry{{ID: 7, JobID: 1, Iteration: 3, Message: "hello"}},
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	logs, err := client.GetLogs(1, LogOptions{Iteration: 3, Tail: 5})
+
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "hello", logs[0].Message)
+	assert.Equal(t, 3, logs[0].Iteration)
+}
+
+func TestClient_FollowLogs_Reconnects(t *testing.T) {
+	connects := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/jobs/1/logs/stream", r.URL.Path)
+		connects++
+		w.Header().Set("Content-Type", "text/event-stream")
+
+		switch connects {
+		case 1:
+			assert.Equal(t, "2", r.URL.Query().Get("tail"))
+			fmt.Fprint(w, "id: 10\nevent: log\ndata: {\"id\":10,\"iteration\":1,\"message\":\"first\"}\n\n")
+			// Connection drops without a final status
+		case 2:
+			// Resumes after the last line and no longer trims the backlog
+			assert.Equal(t, "10", r.URL.Query().Get("after"))
+			assert.Empty(t, r.URL.Query().Get("tail"))
+			fmt.Fprint(w, ": keep-alive\n\n")
+			fmt.Fprint(w, "id: 11\nevent: log\ndata: {\"id\":11,\"iteration\":2,\"message\":\"second\"}\n\n")
+			fmt.Fprint(w, "event: status\ndata: {\"id\":1,\"status\":\"completed\"}\n\n")
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.retryDelay = time.Millisecond
+
+	var messages []string
+	retries := 0
+	job, err := client.FollowLogs(context.Background(), 1, LogOptions{Tail: 2},
+		func(e *LogEntry) { messages = append(messages, e.Message) },
+		func(error) { retries++ })
+
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, job.Status)
+	assert.Equal(t, []string{"first", "second"}, messages)
+	assert.Equal(t, 1, retries)
+	assert.Equal(t, 2, connects)
+}
+
+func TestClient_FollowLogs_NotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "job not found"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	_, err := client.FollowLogs(context.Background(), 99, LogOptions{}, nil, nil)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "job not found")
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+
+	got, err := ParseSince("10m", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(-10*time.Minute), got)
+
+	got, err = ParseSince("2025-01-01T08:00:00Z", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 1, 1, 8, 0, 0, 0, time.UTC), got)
+
+	_, err = ParseSince("yesterday", now)
+	assert.Error(t, err)
+	_, err = ParseSince("-5m", now)
+	assert.Error(t, err)
+}
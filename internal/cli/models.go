@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ryan/ralph-o-matic/internal/platform"
+)
+
+// HostModels lists the models installed on one of the server's Ollama hosts
+type HostModels struct {
+	Host   string                 `json:"host"`
+	Models []platform.OllamaModel `json:"models"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// PullEvent is one progress update of a model pull on one host
+type PullEvent struct {
+	Host  string `json:"host"`
+	Model string `json:"model"`
+	platform.PullProgress
+}
+
+// ListModels returns the models installed on the server's Ollama hosts, or
+// only on host if it is not empty
+func (c *Client) ListModels(host string) ([]HostModels, error) {
+	var resp struct {
+		Hosts []HostModels `json:"hosts"`
+	}
+	if err := c.get("/api/models"+hostQuery(host), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Hosts, nil
+}
+
+// PullModel has the server pull a model onto its Ollama hosts, or only onto
+// host if it is not empty, calling onProgress with each update until the
+// pull finishes
+func (c *Client) PullModel(ctx context.Context, name, host string, onProgress func(*PullEvent)) error {
+	body, err := json.Marshal(map[string]string{"name": name, "host": host})
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/models/pull", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	c.authorize(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return responseError(resp)
+	}
+
+	var event, data string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "":
+			done, err := handlePullEvent(event, data, onProgress)
+			if err != nil || done {
+				return err
+			}
+			event, data = "", ""
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("pull stream interrupted: %w", err)
+	}
+	return fmt.Errorf("pull stream interrupted: %w", io.ErrUnexpectedEOF)
+}
+
+// handlePullEvent dispatches one SSE event of a pull, reporting whether the
+// pull is over
+func handlePullEvent(event, data string, onProgress func(*PullEvent)) (bool, error) {
+	switch event {
+	case "progress":
+		var p PullEvent
+		if err := json.Unmarshal([]byte(data), &p); err != nil {
+			return false, fmt.Errorf("failed to decode progress event: %w", err)
+		}
+		if onProgress != nil {
+			onProgress(&p)
+		}
+	case "done":
+		return true, nil
+	case "error":
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &errResp); err != nil {
+			return true, fmt.Errorf("failed to decode error event: %w", err)
+		}
+		return true, fmt.Errorf("pull failed: %s", errResp.Error)
+	}
+	return false, nil
+}
+
+// DeleteModel has the server remove a model from its Ollama hosts, or only
+// from host if it is not empty, and returns the hosts it was removed from
+func (c *Client) DeleteModel(name, host string) ([]string, error) {
+	segments := strings.Split(name, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+
+	var resp struct {
+		Hosts []string `json:"hosts"`
+	}
+	if err := c.delete("/api/models/"+strings.Join(segments, "/")+hostQuery(host), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Hosts, nil
+}
+
+func hostQuery(host string) string {
+	if host == "" {
+		return ""
+	}
+	return "?host=" + url.QueryEscape(host)
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ListModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/models", r.URL.Path)
+		assert.Equal(t, "http://gpu-1:11434", r.URL.Query().Get("host"))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"hosts": []map[string]interface{}{
+				{"host": "http://gpu-1:11434", "models": []map[string]interface{}{{"name": "qwen3-coder:70b", "size_gb": 42.0}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	hosts, err := NewClient(server.URL).ListModels("http://gpu-1:11434")
+	require.NoError(t, err)
+	require.Len(t, hosts, 1)
+	require.Len(t, hosts[0].Models, 1)
+	assert.Equal(t, "qwen3-coder:70b", hosts[0].Models[0].Name)
+	assert.Equal(t, 42.0, hosts[0].Models[0].SizeGB)
+}
+
+func TestClient_PullModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/api/models/pull", r.URL.Path)
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		if body["name"] == "missing:7b" {
+			fmt.Fprint(w, "event: error\ndata: {\"error\":\"http://a: file does not exist\"}\n\n")
+			return
+		}
+		fmt.Fprint(w, "event: progress\ndata: {\"host\":\"http://a\",\"model\":\"llama3\",\"status\":\"pulling manifest\"}\n\n")
+		fmt.Fprint(w, "event: progress\ndata: {\"host\":\"http://a\",\"model\":\"llama3\",\"status\":\"pulling abc\",\"total\":100,\"completed\":40}\n\n")
+		fmt.Fprint(w, "event: done\ndata: {\"host\":\"http://a\",\"model\":\"llama3\",\"status\":\"success\"}\n\n")
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	var updates []*PullEvent
+	require.NoError(t, client.PullModel(context.Background(), "llama3", "", func(e *PullEvent) {
+		updates = append(updates, e)
+	}))
+	require.Len(t, updates, 2)
+	assert.Equal(t, "pulling manifest", updates[0].Status)
+	assert.Equal(t, int64(40), updates[1].Completed)
+
+	err := client.PullModel(context.Background(), "missing:7b", "", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "file does not exist")
+}
+
+func TestClient_PullModel_StreamCut(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: progress\ndata: {\"status\":\"pulling manifest\"}\n\n")
+	}))
+	defer server.Close()
+
+	err := NewClient(server.URL).PullModel(context.Background(), "llama3", "", nil)
+	assert.ErrorContains(t, err, "interrupted")
+}
+
+func TestClient_DeleteModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "DELETE", r.Method)
+		assert.Equal(t, "/api/models/org/model:7b", r.URL.Path)
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": "org/model:7b", "hosts": []string{"http://a"}})
+	}))
+	defer server.Close()
+
+	hosts, err := NewClient(server.URL).DeleteModel("org/model:7b", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://a"}, hosts)
+}
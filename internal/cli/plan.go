@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// ParseModelPlan parses the --model-plan flag: comma-separated models, each
+// optionally followed by "@" and its switch conditions joined with "+". A
+// number switches after that many iterations, "stall" when the loop stalls
+// and "failN" after N agent errors in a row, e.g.
+// "qwen2.5-coder:14b@10+stall,qwen3-coder:70b".
+func ParseModelPlan(s string) (models.ModelPlan, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var plan models.ModelPlan
+	for _, part := range strings.Split(s, ",") {
+		name, conditions, _ := strings.Cut(strings.TrimSpace(part), "@")
+		stage := models.ModelStage{ModelPlacement: models.ModelPlacement{Name: name}}
+
+		if conditions != "" {
+			for _, cond := range strings.Split(conditions, "+") {
+				switch {
+				case cond == "stall":
+					stage.OnStall = true
+				case strings.HasPrefix(cond, "fail"):
+					n, err := strconv.Atoi(strings.TrimPrefix(cond, "fail"))
+					if err != nil || n <= 0 {
+						return nil, fmt.Errorf("invalid condition %q for %s: want failN with N > 0", cond, name)
+					}
+					stage.OnFailures = n
+				default:
+					n, err := strconv.Atoi(cond)
+					if err != nil || n <= 0 {
+						return nil, fmt.Errorf("invalid condition %q for %s: want an iteration count, stall or failN", cond, name)
+					}
+					stage.Iterations = n
+				}
+			}
+		}
+		plan = append(plan, stage)
+	}
+
+	if err := plan.Validate(); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+func TestParseModelPlan(t *testing.T) {
+	plan, err := ParseModelPlan("qwen2.5-coder:14b@10+stall, qwen3-coder:30b@fail2,qwen3-coder:70b")
+	require.NoError(t, err)
+	assert.Equal(t, models.ModelPlan{
+		{ModelPlacement: models.ModelPlacement{Name: "qwen2.5-coder:14b"}, Iterations: 10, OnStall: true},
+		{ModelPlacement: models.ModelPlacement{Name: "qwen3-coder:30b"}, OnFailures: 2},
+		{ModelPlacement: models.ModelPlacement{Name: "qwen3-coder:70b"}},
+	}, plan)
+
+	plan, err = ParseModelPlan("")
+	require.NoError(t, err)
+	assert.Nil(t, plan)
+}
+
+func TestParseModelPlan_Invalid(t *testing.T) {
+	for _, s := range []string{
+		"small@ten,large",
+		"small@fail0,large",
+		"small,large", // no way to leave the first stage
+		"@5,large",
+	} {
+		_, err := ParseModelPlan(s)
+		assert.Error(t, err, s)
+	}
+}
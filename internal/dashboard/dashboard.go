@@ -10,6 +10,7 @@ import (
 
 	"github.com/ryan/ralph-o-matic/internal/db"
 	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/platform"
 	"github.com/ryan/ralph-o-matic/internal/queue"
 )
 
@@ -50,6 +51,22 @@ func TemplateFuncs() template.FuncMap {
 		"multiply": func(a interface{}, b interface{}) float64 {
 			return toFloat64(a) * toFloat64(b)
 		},
+		"deref": func(b *bool) bool {
+			return b != nil && *b
+		},
+		"gigabytes": func(n int64) string {
+			return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+		},
+		"until": func(t time.Time) string {
+			d := time.Until(t)
+			if d <= 0 {
+				return "now"
+			}
+			if d < time.Minute {
+				return fmt.Sprintf("in %ds", int(d.Seconds()))
+			}
+			return fmt.Sprintf("in %dm", int(d.Minutes()))
+		},
 	}
 }
 
@@ -60,11 +77,16 @@ type Dashboard struct {
 	dashboardTmpl *template.Template
 	jobTmpl       *template.Template
 	configTmpl    *template.Template
+	loginTmpl     *template.Template
+	monitor       *platform.Monitor // what the Ollama hosts have loaded; nil hides the panel
+	auth          bool              // pages show a logout button
 }
 
 // New creates a new dashboard handler from a template filesystem
 func New(database *db.DB, q *queue.Queue, templatesFS fs.FS) *Dashboard {
+	d := &Dashboard{db: database, queue: q}
 	funcs := TemplateFuncs()
+	funcs["authEnabled"] = func() bool { return d.auth }
 
 	dashboardTmpl := template.Must(
 		template.New("layout.html").Funcs(funcs).ParseFS(templatesFS, "layout.html", "dashboard.html"),
@@ -78,13 +100,26 @@ func New(database *db.DB, q *queue.Queue, templatesFS fs.FS) *Dashboard {
 		template.New("layout.html").Funcs(funcs).ParseFS(templatesFS, "layout.html", "config.html"),
 	)
 
-	return &Dashboard{
-		db:            database,
-		queue:         q,
-		dashboardTmpl: dashboardTmpl,
-		jobTmpl:       jobTmpl,
-		configTmpl:    configTmpl,
-	}
+	loginTmpl := template.Must(
+		template.New("layout.html").Funcs(funcs).ParseFS(templatesFS, "layout.html", "login.html"),
+	)
+
+	d.dashboardTmpl = dashboardTmpl
+	d.jobTmpl = jobTmpl
+	d.configTmpl = configTmpl
+	d.loginTmpl = loginTmpl
+	return d
+}
+
+// SetMonitor makes the dashboard show what the Ollama hosts have loaded
+func (d *Dashboard) SetMonitor(m *platform.Monitor) {
+	d.monitor = m
+}
+
+// SetAuth makes pages show a logout button, for servers that require a
+// login
+func (d *Dashboard) SetAuth(enabled bool) {
+	d.auth = enabled
 }
 
 // IndexData is the data for the dashboard index
@@ -94,13 +129,14 @@ type IndexData struct {
 	Paused    []*models.Job
 	Queued    []*models.Job
 	Completed []*models.Job
+	Ollama    []platform.HostActivity
 }
 
 // HandleIndex renders the dashboard
 func (d *Dashboard) HandleIndex(w http.ResponseWriter, r *http.Request) {
 	jobRepo := db.NewJobRepo(d.db)
 
-	running, _, _ := jobRepo.List(db.ListOptions{Statuses: []models.JobStatus{models.StatusRunning}})
+	running, _, _ := jobRepo.List(db.ListOptions{Statuses: []models.JobStatus{models.StatusPreparing, models.StatusRunning}})
 	paused, _, _ := jobRepo.List(db.ListOptions{Statuses: []models.JobStatus{models.StatusPaused}})
 	queued, _, _ := jobRepo.List(db.ListOptions{Statuses: []models.JobStatus{models.StatusQueued}})
 	completed, _, _ := jobRepo.List(db.ListOptions{
@@ -115,15 +151,20 @@ func (d *Dashboard) HandleIndex(w http.ResponseWriter, r *http.Request) {
 		Queued:    queued,
 		Completed: completed,
 	}
+	if d.monitor != nil {
+		data.Ollama = d.monitor.Snapshot()
+	}
 
 	d.render(w, d.dashboardTmpl, data)
 }
 
 // JobData is the data for the job detail page
 type JobData struct {
-	QueueSize int
-	Job       *models.Job
-	Logs      []*db.JobLog
+	QueueSize  int
+	Job        *models.Job
+	Logs       []*db.JobLog
+	LastLogID  int64 // live log stream resumes after this line
+	Iterations []*models.Iteration
 }
 
 // HandleJob renders the job detail page
@@ -137,10 +178,19 @@ func (d *Dashboard) HandleJob(w http.ResponseWriter, r *http.Request, jobID int6
 	logRepo := db.NewLogRepo(d.db)
 	logs, _ := logRepo.GetForJob(jobID)
 
+	iterationRepo := db.NewIterationRepo(d.db)
+	iterations, _ := iterationRepo.ListForJob(jobID)
+
 	data := JobData{
-		QueueSize: d.queue.Size(),
-		Job:       job,
-		Logs:      logs,
+		QueueSize:  d.queue.Size(),
+		Job:        job,
+		Logs:       logs,
+		Iterations: iterations,
+	}
+	for _, entry := range logs {
+		if entry.ID > data.LastLogID {
+			data.LastLogID = entry.ID
+		}
 	}
 
 	d.render(w, d.jobTmpl, data)
@@ -178,6 +228,10 @@ func (d *Dashboard) HandleConfig(w http.ResponseWriter, r *http.Request) {
 		{"small_model.memory_gb", fmt.Sprintf("%.0f", cfg.SmallModel.MemoryGB)},
 		{"default_max_iterations", fmt.Sprintf("%d", cfg.DefaultMaxIterations)},
 		{"concurrent_jobs", fmt.Sprintf("%d", cfg.ConcurrentJobs)},
+		{"max_jobs_per_user", fmt.Sprintf("%d", cfg.MaxJobsPerUser)},
+		{"scheduling.policy", cfg.Scheduling.Policy},
+		{"scheduling.aging_minutes", fmt.Sprintf("%d", cfg.Scheduling.AgingMinutes)},
+		{"sandbox.runtime", cfg.Sandbox.Runtime},
 		{"workspace_dir", cfg.WorkspaceDir},
 		{"job_retention_days", fmt.Sprintf("%d", cfg.JobRetentionDays)},
 	}
@@ -190,6 +244,21 @@ func (d *Dashboard) HandleConfig(w http.ResponseWriter, r *http.Request) {
 	d.render(w, d.configTmpl, data)
 }
 
+// LoginData is the data for the login page
+type LoginData struct {
+	Next  string // page to return to after logging in
+	Error string
+}
+
+// RenderLogin renders the login page with status
+func (d *Dashboard) RenderLogin(w http.ResponseWriter, status int, next, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := d.loginTmpl.Execute(w, LoginData{Next: next, Error: message}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
 func toFloat64(v interface{}) float64 {
 	switch n := v.(type) {
 	case float64:
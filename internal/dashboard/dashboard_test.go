@@ -60,6 +60,29 @@ func TestDashboard_Job(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "feature/test")
 }
 
+func TestDashboard_JobTimeline(t *testing.T) {
+	d, q := newTestDashboard(t)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test prompt", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	it := models.NewIteration(job.ID, 1)
+	it.CommitHash = "abc1234"
+	it.Insertions = 12
+	it.Finish()
+	require.NoError(t, db.NewIterationRepo(d.db).Save(it))
+
+	req := httptest.NewRequest("GET", "/jobs/1", nil)
+	w := httptest.NewRecorder()
+
+	d.HandleJob(w, req, job.ID)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Timeline")
+	assert.Contains(t, w.Body.String(), "abc1234")
+	assert.Contains(t, w.Body.String(), "+12")
+}
+
 func TestDashboard_JobNotFound(t *testing.T) {
 	d, _ := newTestDashboard(t)
 
@@ -0,0 +1,95 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// AgentEventRepo handles persistence of the structured events agents report
+type AgentEventRepo struct {
+	db *DB
+}
+
+// NewAgentEventRepo creates a new agent event repository
+func NewAgentEventRepo(db *DB) *AgentEventRepo {
+	return &AgentEventRepo{db: db}
+}
+
+// Append stores the events of one iteration of a job, in order
+func (r *AgentEventRepo) Append(jobID int64, iteration int, evs []*models.AgentEvent) error {
+	if len(evs) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, ev := range evs {
+		result, err := tx.Exec(`
+			INSERT INTO agent_events (
+				job_id, iteration, type, tool, content, is_error,
+				input_tokens, output_tokens, cache_read_tokens, cache_creation_tokens
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`,
+			jobID, iteration, ev.Type, ev.Tool, ev.Content, ev.IsError,
+			ev.Usage.InputTokens, ev.Usage.OutputTokens, ev.Usage.CacheReadTokens, ev.Usage.CacheCreationTokens,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to append agent event: %w", err)
+		}
+
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("failed to get agent event id: %w", err)
+		}
+		ev.ID = id
+		ev.JobID = jobID
+		ev.Iteration = iteration
+	}
+
+	return tx.Commit()
+}
+
+// Find retrieves a job's events in the order they happened. A positive
+// iteration restricts them to that iteration.
+func (r *AgentEventRepo) Find(jobID int64, iteration int) ([]*models.AgentEvent, error) {
+	query := `
+		SELECT id, job_id, iteration, type, tool, content, is_error,
+			input_tokens, output_tokens, cache_read_tokens, cache_creation_tokens, timestamp
+		FROM agent_events WHERE job_id = ?`
+	args := []interface{}{jobID}
+	if iteration > 0 {
+		query += " AND iteration = ?"
+		args = append(args, iteration)
+	}
+	query += " ORDER BY id"
+
+	rows, err := r.db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query agent events: %w", err)
+	}
+	defer rows.Close()
+
+	var evs []*models.AgentEvent
+	for rows.Next() {
+		ev := &models.AgentEvent{}
+		var tool, content sql.NullString
+		if err := rows.Scan(
+			&ev.ID, &ev.JobID, &ev.Iteration, &ev.Type, &tool, &content, &ev.IsError,
+			&ev.Usage.InputTokens, &ev.Usage.OutputTokens, &ev.Usage.CacheReadTokens, &ev.Usage.CacheCreationTokens,
+			&ev.Timestamp,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan agent event: %w", err)
+		}
+		ev.Tool = tool.String
+		ev.Content = content.String
+		evs = append(evs, ev)
+	}
+
+	return evs, nil
+}
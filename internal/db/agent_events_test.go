@@ -0,0 +1,43 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentEventRepo_AppendFind(t *testing.T) {
+	db := newTestDB(t)
+	jobRepo := NewJobRepo(db)
+	repo := NewAgentEventRepo(db)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, jobRepo.Create(job))
+
+	require.NoError(t, repo.Append(job.ID, 1, []*models.AgentEvent{
+		{Type: models.AgentEventMessage, Content: "Looking at the tests"},
+		{Type: models.AgentEventToolCall, Tool: "Bash", Content: `{"command":"go test ./..."}`},
+		{Type: models.AgentEventToolResult, Tool: "Bash", Content: "FAIL", IsError: true},
+	}))
+	require.NoError(t, repo.Append(job.ID, 2, []*models.AgentEvent{
+		{Type: models.AgentEventResult, Content: "<promise>COMPLETE</promise>", Usage: models.TokenUsage{InputTokens: 100, OutputTokens: 20}},
+	}))
+	require.NoError(t, repo.Append(job.ID, 3, nil))
+
+	all, err := repo.Find(job.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, all, 4)
+	assert.Equal(t, models.AgentEventMessage, all[0].Type)
+	assert.Equal(t, "Bash", all[1].Tool)
+	assert.True(t, all[2].IsError)
+	assert.Equal(t, job.ID, all[3].JobID)
+	assert.Equal(t, int64(20), all[3].Usage.OutputTokens)
+	assert.False(t, all[3].Timestamp.IsZero())
+
+	second, err := repo.Find(job.ID, 2)
+	require.NoError(t, err)
+	require.Len(t, second, 1)
+	assert.Equal(t, 2, second[0].Iteration)
+}
@@ -60,13 +60,25 @@ func (r *ConfigRepo) Save(cfg *models.ServerConfig) error {
 	if err != nil {
 		return fmt.Errorf("failed to marshal ollama: %w", err)
 	}
+	schedulingJSON, err := json.Marshal(cfg.Scheduling)
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduling: %w", err)
+	}
+	sandboxJSON, err := json.Marshal(cfg.Sandbox)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sandbox: %w", err)
+	}
 
 	values := map[string]string{
 		"large_model":            string(largeModelJSON),
 		"small_model":            string(smallModelJSON),
 		"ollama":                 string(ollamaJSON),
+		"executor":               cfg.Executor,
 		"default_max_iterations": strconv.Itoa(cfg.DefaultMaxIterations),
 		"concurrent_jobs":        strconv.Itoa(cfg.ConcurrentJobs),
+		"max_jobs_per_user":      strconv.Itoa(cfg.MaxJobsPerUser),
+		"scheduling":             string(schedulingJSON),
+		"sandbox":                string(sandboxJSON),
 		"workspace_dir":          cfg.WorkspaceDir,
 		"job_retention_days":     strconv.Itoa(cfg.JobRetentionDays),
 		"max_claude_retries":     strconv.Itoa(cfg.MaxClaudeRetries),
@@ -145,6 +157,8 @@ func applyConfigValue(cfg *models.ServerConfig, key, value string) error {
 			return err
 		}
 		cfg.Ollama = oc
+	case "executor":
+		cfg.Executor = value
 	case "default_max_iterations":
 		v, err := strconv.Atoi(value)
 		if err != nil {
@@ -157,6 +171,24 @@ func applyConfigValue(cfg *models.ServerConfig, key, value string) error {
 			return err
 		}
 		cfg.ConcurrentJobs = v
+	case "scheduling":
+		var sc models.SchedulingConfig
+		if err := json.Unmarshal([]byte(value), &sc); err != nil {
+			return err
+		}
+		cfg.Scheduling = sc
+	case "sandbox":
+		var sc models.SandboxConfig
+		if err := json.Unmarshal([]byte(value), &sc); err != nil {
+			return err
+		}
+		cfg.Sandbox = sc
+	case "max_jobs_per_user":
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		cfg.MaxJobsPerUser = v
 	case "workspace_dir":
 		cfg.WorkspaceDir = value
 	case "job_retention_days":
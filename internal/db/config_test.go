@@ -33,6 +33,7 @@ func TestConfigRepo_Save(t *testing.T) {
 	cfg.LargeModel.Device = "gpu"
 	cfg.LargeModel.MemoryGB = 20
 	cfg.ConcurrentJobs = 5
+	cfg.Executor = "opencode"
 
 	err := repo.Save(cfg)
 	require.NoError(t, err)
@@ -45,6 +46,7 @@ func TestConfigRepo_Save(t *testing.T) {
 	assert.Equal(t, "gpu", fetched.LargeModel.Device)
 	assert.Equal(t, 20.0, fetched.LargeModel.MemoryGB)
 	assert.Equal(t, 5, fetched.ConcurrentJobs)
+	assert.Equal(t, "opencode", fetched.Executor)
 }
 
 func TestConfigRepo_SaveOllama(t *testing.T) {
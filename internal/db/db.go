@@ -9,6 +9,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/ryan/ralph-o-matic/internal/events"
 	_ "modernc.org/sqlite"
 )
 
@@ -18,6 +19,7 @@ var migrationsFS embed.FS
 // DB wraps the database connection
 type DB struct {
 	conn   *sql.DB
+	events *events.Bus
 	mu     sync.Mutex
 	closed bool
 }
@@ -55,7 +57,7 @@ func New(path string) (*DB, error) {
 		}
 	}
 
-	return &DB{conn: conn}, nil
+	return &DB{conn: conn, events: events.NewBus()}, nil
 }
 
 // Close closes the database connection
@@ -81,6 +83,11 @@ func (db *DB) Conn() *sql.DB {
 	return db.conn
 }
 
+// Events returns the bus that repositories publish job changes to
+func (db *DB) Events() *events.Bus {
+	return db.events
+}
+
 // Migrate applies all pending migrations
 func (db *DB) Migrate() error {
 	db.mu.Lock()
@@ -103,39 +110,11 @@ func (db *DB) Migrate() error {
 		return fmt.Errorf("failed to get migration version: %w", err)
 	}
 
-	// Read migration files
-	entries, err := migrationsFS.ReadDir("migrations")
+	migrations, err := availableMigrations()
 	if err != nil {
-		return fmt.Errorf("failed to read migrations: %w", err)
+		return err
 	}
 
-	// Sort migrations by version
-	var migrations []struct {
-		version int
-		name    string
-	}
-	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
-			continue
-		}
-		// Parse version from filename (e.g., "001_initial_schema.sql")
-		parts := strings.SplitN(entry.Name(), "_", 2)
-		if len(parts) < 2 {
-			continue
-		}
-		version, err := strconv.Atoi(parts[0])
-		if err != nil {
-			continue
-		}
-		migrations = append(migrations, struct {
-			version int
-			name    string
-		}{version, entry.Name()})
-	}
-	sort.Slice(migrations, func(i, j int) bool {
-		return migrations[i].version < migrations[j].version
-	})
-
 	// Apply pending migrations
 	for _, m := range migrations {
 		if m.version <= currentVersion {
@@ -170,6 +149,54 @@ func (db *DB) Migrate() error {
 	return nil
 }
 
+// migration is one schema change embedded in the binary
+type migration struct {
+	version int
+	name    string
+}
+
+// availableMigrations returns the embedded migrations, oldest first
+func availableMigrations() ([]migration, error) {
+	entries, err := migrationsFS.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		// Parse version from filename (e.g., "001_initial_schema.sql")
+		parts := strings.SplitN(entry.Name(), "_", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		version, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		migrations = append(migrations, migration{version, entry.Name()})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	return migrations, nil
+}
+
+// LatestMigrationVersion returns the version of the newest migration this
+// binary can apply
+func LatestMigrationVersion() (int, error) {
+	migrations, err := availableMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].version, nil
+}
+
 // MigrationVersion returns the current migration version
 func (db *DB) MigrationVersion() (int, error) {
 	db.mu.Lock()
@@ -89,7 +89,10 @@ func TestDB_MigrationVersion(t *testing.T) {
 
 	version, err = db.MigrationVersion()
 	require.NoError(t, err)
+	latest, err := LatestMigrationVersion()
+	require.NoError(t, err)
 	assert.Greater(t, version, 0)
+	assert.Equal(t, latest, version)
 }
 
 // Helper to create a test database with migrations applied
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// IterationRepo handles persistence of a job's iteration timeline
+type IterationRepo struct {
+	db *DB
+}
+
+// NewIterationRepo creates a new iteration repository
+func NewIterationRepo(db *DB) *IterationRepo {
+	return &IterationRepo{db: db}
+}
+
+// Save inserts or updates an iteration record. The loop saves each iteration
+// when it starts and again when it finishes.
+func (r *IterationRepo) Save(it *models.Iteration) error {
+	var failingJSON []byte
+	if len(it.FailingTests) > 0 {
+		var err error
+		failingJSON, err = json.Marshal(it.FailingTests)
+		if err != nil {
+			return fmt.Errorf("failed to encode failing tests: %w", err)
+		}
+	}
+
+	_, err := r.db.conn.Exec(`
+		INSERT INTO iterations (
+			job_id, iteration, model, started_at, finished_at,
+			exit_code, completed, verify_passed, error,
+			commit_hash, files_changed, insertions, deletions,
+			input_tokens, output_tokens,
+			diff_hash, output_hash, failing_tests
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(job_id, iteration) DO UPDATE SET
+			model = excluded.model, started_at = excluded.started_at, finished_at = excluded.finished_at,
+			exit_code = excluded.exit_code, completed = excluded.completed,
+			verify_passed = excluded.verify_passed, error = excluded.error,
+			commit_hash = excluded.commit_hash, files_changed = excluded.files_changed,
+			insertions = excluded.insertions, deletions = excluded.deletions,
+			input_tokens = excluded.input_tokens, output_tokens = excluded.output_tokens,
+			diff_hash = excluded.diff_hash, output_hash = excluded.output_hash,
+			failing_tests = excluded.failing_tests
+	`,
+		it.JobID, it.Number, it.Model, it.StartedAt, it.FinishedAt,
+		it.ExitCode, it.Completed, it.VerifyPassed, it.Error,
+		it.CommitHash, it.FilesChanged, it.Insertions, it.Deletions,
+		it.Usage.InputTokens, it.Usage.OutputTokens,
+		it.DiffHash, it.OutputHash, failingJSON,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save iteration: %w", err)
+	}
+	return nil
+}
+
+// ListForJob returns a job's iterations in order
+func (r *IterationRepo) ListForJob(jobID int64) ([]*models.Iteration, error) {
+	rows, err := r.db.conn.Query(`
+		SELECT
+			job_id, iteration, model, started_at, finished_at,
+			exit_code, completed, verify_passed, error,
+			commit_hash, files_changed, insertions, deletions,
+			input_tokens, output_tokens,
+			diff_hash, output_hash, failing_tests
+		FROM iterations WHERE job_id = ? ORDER BY iteration
+	`, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list iterations: %w", err)
+	}
+	defer rows.Close()
+
+	var iterations []*models.Iteration
+	for rows.Next() {
+		it := &models.Iteration{}
+		var finishedAt sql.NullTime
+		var verifyPassed sql.NullBool
+		var model, errStr, commitHash, diffHash, outputHash, failingJSON sql.NullString
+		if err := rows.Scan(
+			&it.JobID, &it.Number, &model, &it.StartedAt, &finishedAt,
+			&it.ExitCode, &it.Completed, &verifyPassed, &errStr,
+			&commitHash, &it.FilesChanged, &it.Insertions, &it.Deletions,
+			&it.Usage.InputTokens, &it.Usage.OutputTokens,
+			&diffHash, &outputHash, &failingJSON,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan iteration: %w", err)
+		}
+		if finishedAt.Valid {
+			it.FinishedAt = &finishedAt.Time
+		}
+		if verifyPassed.Valid {
+			it.VerifyPassed = &verifyPassed.Bool
+		}
+		it.Model = model.String
+		it.Error = errStr.String
+		it.CommitHash = commitHash.String
+		it.DiffHash = diffHash.String
+		it.OutputHash = outputHash.String
+		if failingJSON.Valid && failingJSON.String != "" {
+			if err := json.Unmarshal([]byte(failingJSON.String), &it.FailingTests); err != nil {
+				return nil, fmt.Errorf("failed to decode failing tests: %w", err)
+			}
+		}
+		iterations = append(iterations, it)
+	}
+
+	return iterations, nil
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIterationRepo_SaveList(t *testing.T) {
+	db := newTestDB(t)
+	jobRepo := NewJobRepo(db)
+	repo := NewIterationRepo(db)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, jobRepo.Create(job))
+
+	first := models.NewIteration(job.ID, 1)
+	require.NoError(t, repo.Save(first))
+
+	iterations, err := repo.ListForJob(job.ID)
+	require.NoError(t, err)
+	require.Len(t, iterations, 1)
+	assert.Nil(t, iterations[0].FinishedAt)
+	assert.Nil(t, iterations[0].VerifyPassed)
+
+	// Saving again updates the same row
+	first.CommitHash = "abc1234"
+	first.FilesChanged, first.Insertions, first.Deletions = 2, 10, 3
+	first.Usage = models.TokenUsage{InputTokens: 100, OutputTokens: 20}
+	first.Finish()
+	require.NoError(t, repo.Save(first))
+
+	second := models.NewIteration(job.ID, 2)
+	second.Completed = true
+	passed := false
+	second.VerifyPassed = &passed
+	second.ExitCode = 1
+	second.Error = "exit status 1"
+	second.Model = "qwen3-coder:70b"
+	second.DiffHash = "d1"
+	second.OutputHash = "o1"
+	second.FailingTests = []string{"TestA", "TestB"}
+	second.Finish()
+	require.NoError(t, repo.Save(second))
+
+	iterations, err = repo.ListForJob(job.ID)
+	require.NoError(t, err)
+	require.Len(t, iterations, 2)
+
+	assert.Equal(t, 1, iterations[0].Number)
+	assert.Equal(t, "abc1234", iterations[0].CommitHash)
+	assert.Equal(t, 10, iterations[0].Insertions)
+	assert.Equal(t, int64(120), iterations[0].Usage.Total())
+	assert.NotNil(t, iterations[0].FinishedAt)
+
+	assert.Equal(t, 2, iterations[1].Number)
+	assert.True(t, iterations[1].Completed)
+	assert.Equal(t, "qwen3-coder:70b", iterations[1].Model)
+	require.NotNil(t, iterations[1].VerifyPassed)
+	assert.False(t, *iterations[1].VerifyPassed)
+	assert.Equal(t, "exit status 1", iterations[1].Error)
+	assert.Equal(t, "d1", iterations[1].DiffHash)
+	assert.Equal(t, "o1", iterations[1].OutputHash)
+	assert.Equal(t, []string{"TestA", "TestB"}, iterations[1].FailingTests)
+	assert.Nil(t, iterations[0].FailingTests)
+}
@@ -6,12 +6,20 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/ryan/ralph-o-matic/internal/models"
 )
 
 var ErrNotFound = errors.New("not found")
 
+// ErrLeaseLost is returned when a heartbeat finds the job leased to someone else
+var ErrLeaseLost = errors.New("lease lost")
+
+// ErrStatusChanged is returned when a job is no longer in the status a
+// transition of it started from
+var ErrStatusChanged = errors.New("job status changed")
+
 // JobRepo handles job persistence
 type JobRepo struct {
 	db *DB
@@ -39,20 +47,36 @@ func (r *JobRepo) Create(job *models.Job) error {
 			return fmt.Errorf("failed to encode env: %w", err)
 		}
 	}
+	planJSON, err := encodeModelPlan(job.ModelPlan)
+	if err != nil {
+		return err
+	}
+	tagsJSON, err := encodeEndpointTags(job.EndpointTags)
+	if err != nil {
+		return err
+	}
+	secretsJSON, err := encodeSecretNames(job.Secrets)
+	if err != nil {
+		return err
+	}
 
 	result, err := r.db.conn.Exec(`
 		INSERT INTO jobs (
-			status, priority, position,
+			status, priority, position, owner,
 			repo_url, branch, result_branch, working_dir,
-			prompt, max_iterations, env,
+			prompt, max_iterations, env, secrets, executor, verify_command, stall_window, stall_policy,
+			max_duration, per_iteration_timeout, max_tokens, model_plan,
+			large_model, small_model, ollama_host, endpoint_tags, endpoint,
 			iteration, retry_count,
 			created_at, started_at, paused_at, completed_at,
 			pr_url, error
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`,
-		job.Status, job.Priority, job.Position,
+		job.Status, job.Priority, job.Position, job.Owner,
 		job.RepoURL, job.Branch, job.ResultBranch, job.WorkingDir,
-		job.Prompt, job.MaxIterations, envJSON,
+		job.Prompt, job.MaxIterations, envJSON, secretsJSON, job.Executor, job.VerifyCommand, job.StallWindow, job.StallPolicy,
+		job.MaxDuration, job.PerIterationTimeout, job.MaxTokens, planJSON,
+		job.LargeModel, job.SmallModel, job.OllamaHost, tagsJSON, job.Endpoint,
 		job.Iteration, job.RetryCount,
 		job.CreatedAt, job.StartedAt, job.PausedAt, job.CompletedAt,
 		job.PRURL, job.Error,
@@ -73,26 +97,33 @@ func (r *JobRepo) Create(job *models.Job) error {
 // Get retrieves a job by ID
 func (r *JobRepo) Get(id int64) (*models.Job, error) {
 	job := &models.Job{}
-	var envJSON sql.NullString
-	var startedAt, pausedAt, completedAt sql.NullTime
-	var workingDir, prURL, errStr sql.NullString
+	var envJSON, secretsJSON, planJSON, tagsJSON sql.NullString
+	var startedAt, pausedAt, completedAt, heartbeatAt sql.NullTime
+	var workingDir, executor, verifyCommand, stallPolicy, prURL, errStr, leaseOwner sql.NullString
+	var largeModel, smallModel, ollamaHost, endpoint, waitReason, owner sql.NullString
 
 	err := r.db.conn.QueryRow(`
 		SELECT
-			id, status, priority, position,
+			id, status, priority, position, owner,
 			repo_url, branch, result_branch, working_dir,
-			prompt, max_iterations, env,
+			prompt, max_iterations, env, secrets, executor, verify_command, stall_window, stall_policy,
+			max_duration, per_iteration_timeout, max_tokens, model_plan,
+			large_model, small_model, ollama_host, endpoint_tags, endpoint,
 			iteration, retry_count,
 			created_at, started_at, paused_at, completed_at,
-			pr_url, error
+			pr_url, error, wait_reason,
+			lease_owner, heartbeat_at
 		FROM jobs WHERE id = ?
 	`, id).Scan(
-		&job.ID, &job.Status, &job.Priority, &job.Position,
+		&job.ID, &job.Status, &job.Priority, &job.Position, &owner,
 		&job.RepoURL, &job.Branch, &job.ResultBranch, &workingDir,
-		&job.Prompt, &job.MaxIterations, &envJSON,
+		&job.Prompt, &job.MaxIterations, &envJSON, &secretsJSON, &executor, &verifyCommand, &job.StallWindow, &stallPolicy,
+		&job.MaxDuration, &job.PerIterationTimeout, &job.MaxTokens, &planJSON,
+		&largeModel, &smallModel, &ollamaHost, &tagsJSON, &endpoint,
 		&job.Iteration, &job.RetryCount,
 		&job.CreatedAt, &startedAt, &pausedAt, &completedAt,
-		&prURL, &errStr,
+		&prURL, &errStr, &waitReason,
+		&leaseOwner, &heartbeatAt,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -102,9 +133,33 @@ func (r *JobRepo) Get(id int64) (*models.Job, error) {
 	}
 
 	// Handle nullable fields
+	if owner.Valid {
+		job.Owner = owner.String
+	}
 	if workingDir.Valid {
 		job.WorkingDir = workingDir.String
 	}
+	if executor.Valid {
+		job.Executor = executor.String
+	}
+	if verifyCommand.Valid {
+		job.VerifyCommand = verifyCommand.String
+	}
+	if stallPolicy.Valid {
+		job.StallPolicy = models.StallPolicy(stallPolicy.String)
+	}
+	if largeModel.Valid {
+		job.LargeModel = largeModel.String
+	}
+	if smallModel.Valid {
+		job.SmallModel = smallModel.String
+	}
+	if ollamaHost.Valid {
+		job.OllamaHost = ollamaHost.String
+	}
+	if endpoint.Valid {
+		job.Endpoint = endpoint.String
+	}
 	if startedAt.Valid {
 		job.StartedAt = &startedAt.Time
 	}
@@ -120,17 +175,67 @@ func (r *JobRepo) Get(id int64) (*models.Job, error) {
 	if errStr.Valid {
 		job.Error = errStr.String
 	}
+	if waitReason.Valid {
+		job.WaitReason = waitReason.String
+	}
+	if leaseOwner.Valid {
+		job.LeaseOwner = leaseOwner.String
+	}
+	if heartbeatAt.Valid {
+		job.HeartbeatAt = &heartbeatAt.Time
+	}
 	if envJSON.Valid && envJSON.String != "" {
 		if err := json.Unmarshal([]byte(envJSON.String), &job.Env); err != nil {
 			return nil, fmt.Errorf("failed to decode env: %w", err)
 		}
 	}
+	if secretsJSON.Valid && secretsJSON.String != "" {
+		if err := json.Unmarshal([]byte(secretsJSON.String), &job.Secrets); err != nil {
+			return nil, fmt.Errorf("failed to decode secrets: %w", err)
+		}
+	}
+	if planJSON.Valid && planJSON.String != "" {
+		if err := json.Unmarshal([]byte(planJSON.String), &job.ModelPlan); err != nil {
+			return nil, fmt.Errorf("failed to decode model plan: %w", err)
+		}
+	}
+	if tagsJSON.Valid && tagsJSON.String != "" {
+		if err := json.Unmarshal([]byte(tagsJSON.String), &job.EndpointTags); err != nil {
+			return nil, fmt.Errorf("failed to decode endpoint tags: %w", err)
+		}
+	}
 
 	return job, nil
 }
 
 // Update saves changes to an existing job
 func (r *JobRepo) Update(job *models.Job) error {
+	return updateJob(r.db.conn, job)
+}
+
+// Restart saves job, which starts over from its first iteration, and deletes
+// the iterations recorded for its earlier attempt in the same transaction
+func (r *JobRepo) Restart(job *models.Job) error {
+	tx, err := r.db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := updateJob(tx, job); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM iterations WHERE job_id = ?", job.ID); err != nil {
+		return fmt.Errorf("failed to delete iterations: %w", err)
+	}
+	return tx.Commit()
+}
+
+// updateJob saves every column of job through conn, a connection or a
+// transaction
+func updateJob(conn interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}, job *models.Job) error {
 	var envJSON []byte
 	var err error
 	if job.Env != nil {
@@ -139,23 +244,35 @@ func (r *JobRepo) Update(job *models.Job) error {
 			return fmt.Errorf("failed to encode env: %w", err)
 		}
 	}
+	planJSON, err := encodeModelPlan(job.ModelPlan)
+	if err != nil {
+		return err
+	}
+	tagsJSON, err := encodeEndpointTags(job.EndpointTags)
+	if err != nil {
+		return err
+	}
 
-	_, err = r.db.conn.Exec(`
+	_, err = conn.Exec(`
 		UPDATE jobs SET
 			status = ?, priority = ?, position = ?,
 			repo_url = ?, branch = ?, result_branch = ?, working_dir = ?,
-			prompt = ?, max_iterations = ?, env = ?,
+			prompt = ?, max_iterations = ?, env = ?, executor = ?, verify_command = ?, stall_window = ?, stall_policy = ?,
+			max_duration = ?, per_iteration_timeout = ?, max_tokens = ?, model_plan = ?,
+			large_model = ?, small_model = ?, ollama_host = ?, endpoint_tags = ?, endpoint = ?,
 			iteration = ?, retry_count = ?,
 			started_at = ?, paused_at = ?, completed_at = ?,
-			pr_url = ?, error = ?
+			pr_url = ?, error = ?, wait_reason = ?
 		WHERE id = ?
 	`,
 		job.Status, job.Priority, job.Position,
 		job.RepoURL, job.Branch, job.ResultBranch, job.WorkingDir,
-		job.Prompt, job.MaxIterations, envJSON,
+		job.Prompt, job.MaxIterations, envJSON, job.Executor, job.VerifyCommand, job.StallWindow, job.StallPolicy,
+		job.MaxDuration, job.PerIterationTimeout, job.MaxTokens, planJSON,
+		job.LargeModel, job.SmallModel, job.OllamaHost, tagsJSON, job.Endpoint,
 		job.Iteration, job.RetryCount,
 		job.StartedAt, job.PausedAt, job.CompletedAt,
-		job.PRURL, job.Error,
+		job.PRURL, job.Error, job.WaitReason,
 		job.ID,
 	)
 	if err != nil {
@@ -165,6 +282,159 @@ func (r *JobRepo) Update(job *models.Job) error {
 	return nil
 }
 
+// encodeModelPlan encodes a job's model plan as JSON, or nil if it has none
+func encodeModelPlan(plan models.ModelPlan) ([]byte, error) {
+	if len(plan) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(plan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode model plan: %w", err)
+	}
+	return data, nil
+}
+
+// encodeEndpointTags encodes a job's endpoint tags as JSON, or nil if it has
+// none
+func encodeEndpointTags(tags []string) ([]byte, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode endpoint tags: %w", err)
+	}
+	return data, nil
+}
+
+// encodeSecretNames encodes the names of a job's secrets as JSON, or nil if
+// it has none
+func encodeSecretNames(names []string) ([]byte, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode secret names: %w", err)
+	}
+	return data, nil
+}
+
+// UpdateIteration saves only the iteration counter, leaving status and other
+// fields that may be changed concurrently through the API untouched
+func (r *JobRepo) UpdateIteration(id int64, iteration int) error {
+	_, err := r.db.conn.Exec("UPDATE jobs SET iteration = ? WHERE id = ?", iteration, id)
+	if err != nil {
+		return fmt.Errorf("failed to update iteration: %w", err)
+	}
+	return nil
+}
+
+// UpdateStatus saves a transition of job from status from: its status,
+// timestamps, error and wait reason, leaving fields that may be changed
+// concurrently through the API untouched. If the job has left from in the
+// meantime nothing is saved and ErrStatusChanged is returned.
+func (r *JobRepo) UpdateStatus(job *models.Job, from models.JobStatus) error {
+	result, err := r.db.conn.Exec(`
+		UPDATE jobs SET
+			status = ?, started_at = ?, paused_at = ?, completed_at = ?,
+			error = ?, wait_reason = ?
+		WHERE id = ? AND status = ?
+	`, job.Status, job.StartedAt, job.PausedAt, job.CompletedAt, job.Error, job.WaitReason, job.ID, from)
+	if err != nil {
+		return fmt.Errorf("failed to update job status: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrStatusChanged
+	}
+	return nil
+}
+
+// UpdateSettings saves only the fields users may edit, priority and
+// max_iterations, leaving status, owner and progress as they are. Jobs that
+// have finished in the meantime are left untouched and ErrNotFound returned.
+func (r *JobRepo) UpdateSettings(job *models.Job) error {
+	result, err := r.db.conn.Exec(`
+		UPDATE jobs SET priority = ?, max_iterations = ?
+		WHERE id = ? AND status NOT IN (?, ?, ?)
+	`, job.Priority, job.MaxIterations, job.ID, models.StatusCompleted, models.StatusFailed, models.StatusCancelled)
+	if err != nil {
+		return fmt.Errorf("failed to update job settings: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpdatePRURL saves only the job's pull request URL, leaving status and
+// other fields that may be changed concurrently through the API untouched
+func (r *JobRepo) UpdatePRURL(id int64, url string) error {
+	_, err := r.db.conn.Exec("UPDATE jobs SET pr_url = ? WHERE id = ?", url, id)
+	if err != nil {
+		return fmt.Errorf("failed to update PR URL: %w", err)
+	}
+	return nil
+}
+
+// UpdateWaitReason records why a job is still queued. Jobs that have left
+// the queue in the meantime are left untouched.
+func (r *JobRepo) UpdateWaitReason(id int64, reason string) error {
+	_, err := r.db.conn.Exec("UPDATE jobs SET wait_reason = ? WHERE id = ? AND status = ?", reason, id, models.StatusQueued)
+	if err != nil {
+		return fmt.Errorf("failed to update wait reason: %w", err)
+	}
+	return nil
+}
+
+// CountActive returns the number of jobs owner has queued, preparing,
+// running or paused
+func (r *JobRepo) CountActive(owner string) (int, error) {
+	var n int
+	err := r.db.conn.QueryRow("SELECT COUNT(*) FROM jobs WHERE owner = ? AND status IN (?, ?, ?, ?)",
+		owner, models.StatusQueued, models.StatusPreparing, models.StatusRunning, models.StatusPaused).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count active jobs: %w", err)
+	}
+	return n, nil
+}
+
+// AcquireLease records owner as the process running the job
+func (r *JobRepo) AcquireLease(id int64, owner string) error {
+	_, err := r.db.conn.Exec("UPDATE jobs SET lease_owner = ?, heartbeat_at = ? WHERE id = ?", owner, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to acquire lease: %w", err)
+	}
+	return nil
+}
+
+// Heartbeat refreshes the lease on a job. It returns ErrLeaseLost if the job
+// is no longer leased to owner.
+func (r *JobRepo) Heartbeat(id int64, owner string) error {
+	result, err := r.db.conn.Exec("UPDATE jobs SET heartbeat_at = ? WHERE id = ? AND lease_owner = ?", time.Now(), id, owner)
+	if err != nil {
+		return fmt.Errorf("failed to heartbeat: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to heartbeat: %w", err)
+	}
+	if n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// ReleaseLease clears the lease on a job if it is still held by owner
+func (r *JobRepo) ReleaseLease(id int64, owner string) error {
+	_, err := r.db.conn.Exec("UPDATE jobs SET lease_owner = NULL, heartbeat_at = NULL WHERE id = ? AND lease_owner IS ?", id, owner)
+	if err != nil {
+		return fmt.Errorf("failed to release lease: %w", err)
+	}
+	return nil
+}
+
 // Delete removes a job by ID
 func (r *JobRepo) Delete(id int64) error {
 	_, err := r.db.conn.Exec("DELETE FROM jobs WHERE id = ?", id)
@@ -177,6 +447,7 @@ func (r *JobRepo) Delete(id int64) error {
 // ListOptions configures List queries
 type ListOptions struct {
 	Statuses []models.JobStatus
+	Owner    string // only jobs submitted by this owner, if set
 	Limit    int
 	Offset   int
 }
@@ -194,6 +465,10 @@ func (r *JobRepo) List(opts ListOptions) ([]*models.Job, int, error) {
 		}
 		where = append(where, "status IN ("+strings.Join(placeholders, ",")+")")
 	}
+	if opts.Owner != "" {
+		where = append(where, "owner = ?")
+		args = append(args, opts.Owner)
+	}
 
 	whereClause := ""
 	if len(where) > 0 {
@@ -315,6 +590,20 @@ func (r *JobRepo) GetByBranch(branch string, activeOnly bool) (*models.Job, erro
 
 // UpdatePositions updates the position of multiple jobs
 func (r *JobRepo) UpdatePositions(jobIDs []int64) error {
+	positions := make([]int, len(jobIDs))
+	for i := range positions {
+		positions[i] = i + 1
+	}
+	return r.SetPositions(jobIDs, positions)
+}
+
+// SetPositions moves each of jobIDs to the position at the same index in
+// positions, in one transaction
+func (r *JobRepo) SetPositions(jobIDs []int64, positions []int) error {
+	if len(jobIDs) != len(positions) {
+		return fmt.Errorf("%d jobs for %d positions", len(jobIDs), len(positions))
+	}
+
 	tx, err := r.db.conn.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -322,7 +611,7 @@ func (r *JobRepo) UpdatePositions(jobIDs []int64) error {
 	defer tx.Rollback()
 
 	for i, id := range jobIDs {
-		_, err := tx.Exec("UPDATE jobs SET position = ? WHERE id = ?", i+1, id)
+		_, err := tx.Exec("UPDATE jobs SET position = ? WHERE id = ?", positions[i], id)
 		if err != nil {
 			return fmt.Errorf("failed to update position for job %d: %w", id, err)
 		}
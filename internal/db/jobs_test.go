@@ -50,6 +50,53 @@ func TestJobRepo_Get(t *testing.T) {
 	assert.Equal(t, job.Status, fetched.Status)
 }
 
+func TestJobRepo_ExecutionSettings(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewJobRepo(db)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	job.Executor = "aider"
+	job.VerifyCommand = "go test ./..."
+	job.StallWindow = 6
+	job.StallPolicy = models.StallPolicyFail
+	job.MaxDuration = 4 * time.Hour
+	job.PerIterationTimeout = 45 * time.Minute
+	job.MaxTokens = 2000000
+	job.ModelPlan = models.ModelPlan{
+		{ModelPlacement: models.ModelPlacement{Name: "qwen2.5-coder:14b"}, Iterations: 10, OnStall: true},
+		{ModelPlacement: models.ModelPlacement{Name: "qwen3-coder:70b", Device: "cpu"}},
+	}
+	job.SmallModel = "qwen2.5-coder:1.5b"
+	job.OllamaHost = "http://gpu-box:11434"
+	job.EndpointTags = []string{"gpu", "a100"}
+	job.Endpoint = "http://gpu-1:11434"
+	job.Secrets = []string{"NPM_TOKEN"}
+	require.NoError(t, repo.Create(job))
+
+	fetched, err := repo.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "aider", fetched.Executor)
+	assert.Equal(t, "qwen2.5-coder:1.5b", fetched.SmallModel)
+	assert.Equal(t, "http://gpu-box:11434", fetched.OllamaHost)
+	assert.Equal(t, []string{"gpu", "a100"}, fetched.EndpointTags)
+	assert.Equal(t, "http://gpu-1:11434", fetched.Endpoint)
+	assert.Equal(t, []string{"NPM_TOKEN"}, fetched.Secrets)
+	assert.Equal(t, "go test ./...", fetched.VerifyCommand)
+	assert.Equal(t, 6, fetched.StallWindow)
+	assert.Equal(t, models.StallPolicyFail, fetched.StallPolicy)
+	assert.Equal(t, 4*time.Hour, fetched.MaxDuration)
+	assert.Equal(t, 45*time.Minute, fetched.PerIterationTimeout)
+	assert.Equal(t, int64(2000000), fetched.MaxTokens)
+	assert.Equal(t, job.ModelPlan, fetched.ModelPlan)
+
+	fetched.Executor = ""
+	require.NoError(t, repo.Update(fetched))
+
+	fetched, err = repo.Get(job.ID)
+	require.NoError(t, err)
+	assert.Empty(t, fetched.Executor)
+}
+
 func TestJobRepo_Get_NotFound(t *testing.T) {
 	db := newTestDB(t)
 	repo := NewJobRepo(db)
@@ -85,6 +132,87 @@ func TestJobRepo_Update(t *testing.T) {
 	assert.Equal(t, 5, fetched.Iteration)
 }
 
+func TestJobRepo_UpdateIteration(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewJobRepo(db)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, repo.Create(job))
+
+	// Simulate a concurrent status change made through the API
+	job.Status = models.StatusCancelled
+	require.NoError(t, repo.Update(job))
+
+	require.NoError(t, repo.UpdateIteration(job.ID, 3))
+
+	fetched, err := repo.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 3, fetched.Iteration)
+	assert.Equal(t, models.StatusCancelled, fetched.Status)
+}
+
+func TestJobRepo_Restart(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewJobRepo(db)
+	iterations := NewIterationRepo(db)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, repo.Create(job))
+	require.NoError(t, iterations.Save(models.NewIteration(job.ID, 1)))
+	require.NoError(t, iterations.Save(models.NewIteration(job.ID, 2)))
+
+	job.Iteration = 0
+	job.RetryCount = 1
+	require.NoError(t, repo.Restart(job))
+
+	fetched, err := repo.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fetched.RetryCount)
+	recorded, err := iterations.ListForJob(job.ID)
+	require.NoError(t, err)
+	assert.Empty(t, recorded)
+}
+
+func TestJobRepo_UpdatePRURL(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewJobRepo(db)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, repo.Create(job))
+
+	// Simulate a cancel made through the API while the PR was being created
+	job.Status = models.StatusCancelled
+	require.NoError(t, repo.Update(job))
+
+	require.NoError(t, repo.UpdatePRURL(job.ID, "https://github.com/user/repo/pull/1"))
+
+	fetched, err := repo.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/user/repo/pull/1", fetched.PRURL)
+	assert.Equal(t, models.StatusCancelled, fetched.Status)
+}
+
+func TestJobRepo_UpdateWaitReason(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewJobRepo(db)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, repo.Create(job))
+
+	require.NoError(t, repo.UpdateWaitReason(job.ID, "waiting for memory: need 42GB, 18GB free"))
+	fetched, err := repo.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "waiting for memory: need 42GB, 18GB free", fetched.WaitReason)
+
+	// A job that has left the queue keeps no reason to wait
+	require.NoError(t, fetched.TransitionTo(models.StatusRunning))
+	require.NoError(t, repo.Update(fetched))
+	require.NoError(t, repo.UpdateWaitReason(job.ID, "waiting for an Ollama endpoint"))
+	fetched, err = repo.Get(job.ID)
+	require.NoError(t, err)
+	assert.Empty(t, fetched.WaitReason)
+}
+
 func TestJobRepo_Delete(t *testing.T) {
 	db := newTestDB(t)
 	repo := NewJobRepo(db)
@@ -142,6 +270,44 @@ func TestJobRepo_List_WithStatus(t *testing.T) {
 	}
 }
 
+func TestJobRepo_List_WithOwner(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewJobRepo(db)
+
+	for _, owner := range []string{"alice@example.com", "bob@example.com", "alice@example.com", ""} {
+		job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+		job.Owner = owner
+		require.NoError(t, repo.Create(job))
+	}
+
+	jobs, total, err := repo.List(ListOptions{Owner: "alice@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	for _, job := range jobs {
+		assert.Equal(t, "alice@example.com", job.Owner)
+	}
+}
+
+func TestJobRepo_CountActive(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewJobRepo(db)
+
+	for _, status := range []models.JobStatus{models.StatusQueued, models.StatusRunning, models.StatusPaused, models.StatusCompleted, models.StatusFailed} {
+		job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+		job.Status = status
+		job.Owner = "alice"
+		require.NoError(t, repo.Create(job))
+	}
+
+	n, err := repo.CountActive("alice")
+	require.NoError(t, err)
+	assert.Equal(t, 3, n, "finished jobs do not count")
+
+	n, err = repo.CountActive("bob")
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
 func TestJobRepo_List_WithPagination(t *testing.T) {
 	db := newTestDB(t)
 	repo := NewJobRepo(db)
@@ -298,3 +464,32 @@ func TestJobRepo_CountByStatus(t *testing.T) {
 	assert.Equal(t, 1, counts[models.StatusRunning])
 	assert.Equal(t, 2, counts[models.StatusCompleted])
 }
+
+func TestJobRepo_Lease(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewJobRepo(db)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, repo.Create(job))
+
+	require.NoError(t, repo.AcquireLease(job.ID, "host-a:1"))
+	fetched, err := repo.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "host-a:1", fetched.LeaseOwner)
+	require.NotNil(t, fetched.HeartbeatAt)
+
+	require.NoError(t, repo.Heartbeat(job.ID, "host-a:1"))
+	assert.ErrorIs(t, repo.Heartbeat(job.ID, "host-b:2"), ErrLeaseLost)
+
+	// Releasing someone else's lease is a no-op
+	require.NoError(t, repo.ReleaseLease(job.ID, "host-b:2"))
+	fetched, err = repo.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "host-a:1", fetched.LeaseOwner)
+
+	require.NoError(t, repo.ReleaseLease(job.ID, "host-a:1"))
+	fetched, err = repo.Get(job.ID)
+	require.NoError(t, err)
+	assert.Empty(t, fetched.LeaseOwner)
+	assert.Nil(t, fetched.HeartbeatAt)
+}
@@ -2,16 +2,19 @@ package db
 
 import (
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/events"
 )
 
 // JobLog represents a single log entry
 type JobLog struct {
-	ID        int64
-	JobID     int64
-	Iteration int
-	Timestamp time.Time
-	Message   string
+	ID        int64     `json:"id"`
+	JobID     int64     `json:"job_id"`
+	Iteration int       `json:"iteration"`
+	Timestamp time.Time `json:"timestamp"`
+	Message   string    `json:"message"`
 }
 
 // LogRepo handles job log persistence
@@ -24,15 +27,32 @@ func NewLogRepo(db *DB) *LogRepo {
 	return &LogRepo{db: db}
 }
 
-// Append adds a log entry for a job
+// Append adds a log entry for a job and publishes it to the event bus
 func (r *LogRepo) Append(jobID int64, iteration int, message string) error {
-	_, err := r.db.conn.Exec(`
+	result, err := r.db.conn.Exec(`
 		INSERT INTO job_logs (job_id, iteration, message)
 		VALUES (?, ?, ?)
 	`, jobID, iteration, message)
 	if err != nil {
 		return fmt.Errorf("failed to append log: %w", err)
 	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to get log id: %w", err)
+	}
+
+	r.db.events.Publish(events.Event{
+		Type:  events.JobLog,
+		JobID: jobID,
+		Data: &JobLog{
+			ID:        id,
+			JobID:     jobID,
+			Iteration: iteration,
+			Timestamp: time.Now().UTC(),
+			Message:   message,
+		},
+	})
 	return nil
 }
 
@@ -51,6 +71,51 @@ func (r *LogRepo) GetLatest(jobID int64, limit int) ([]*JobLog, error) {
 	return r.queryLogs("SELECT id, job_id, iteration, timestamp, message FROM job_logs WHERE job_id = ? ORDER BY timestamp DESC LIMIT ?", jobID, limit)
 }
 
+// GetAfter retrieves logs for a job with an ID greater than afterID, in the
+// order they were written. Streaming clients use it to resume from the last
+// line they saw.
+func (r *LogRepo) GetAfter(jobID, afterID int64) ([]*JobLog, error) {
+	return r.Find(jobID, LogQuery{AfterID: afterID})
+}
+
+// LogQuery filters the logs returned by Find. Zero values match everything.
+type LogQuery struct {
+	Iteration int       // only lines from this iteration
+	Since     time.Time // only lines written at or after this time
+	AfterID   int64     // only lines with a greater ID
+	Tail      int       // only the last Tail matching lines
+}
+
+// Find retrieves a job's logs matching q, in the order they were written
+func (r *LogRepo) Find(jobID int64, q LogQuery) ([]*JobLog, error) {
+	where := []string{"job_id = ?"}
+	args := []interface{}{jobID}
+
+	if q.Iteration > 0 {
+		where = append(where, "iteration = ?")
+		args = append(args, q.Iteration)
+	}
+	if !q.Since.IsZero() {
+		// Match the format SQLite's CURRENT_TIMESTAMP default writes
+		where = append(where, "timestamp >= ?")
+		args = append(args, q.Since.UTC().Format("2006-01-02 15:04:05"))
+	}
+	if q.AfterID > 0 {
+		where = append(where, "id > ?")
+		args = append(args, q.AfterID)
+	}
+
+	query := "SELECT id, job_id, iteration, timestamp, message FROM job_logs WHERE " + strings.Join(where, " AND ")
+	if q.Tail > 0 {
+		query = "SELECT * FROM (" + query + " ORDER BY id DESC LIMIT ?) ORDER BY id"
+		args = append(args, q.Tail)
+	} else {
+		query += " ORDER BY id"
+	}
+
+	return r.queryLogs(query, args...)
+}
+
 // DeleteForJob removes all logs for a job
 func (r *LogRepo) DeleteForJob(jobID int64) error {
 	_, err := r.db.conn.Exec("DELETE FROM job_logs WHERE job_id = ?", jobID)
@@ -2,7 +2,9 @@ package db
 
 import (
 	"testing"
+	"time"
 
+	"github.com/ryan/ralph-o-matic/internal/events"
 	"github.com/ryan/ralph-o-matic/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -112,3 +114,99 @@ func TestLogRepo_GetLatest(t *testing.T) {
 	require.NoError(t, err)
 	assert.Len(t, logs, 5)
 }
+
+func TestLogRepo_GetAfter(t *testing.T) {
+	db := newTestDB(t)
+	jobRepo := NewJobRepo(db)
+	logRepo := NewLogRepo(db)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, jobRepo.Create(job))
+
+	for _, msg := range []string{"one", "two", "three"} {
+		require.NoError(t, logRepo.Append(job.ID, 1, msg))
+	}
+
+	all, err := logRepo.GetAfter(job.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+
+	rest, err := logRepo.GetAfter(job.ID, all[0].ID)
+	require.NoError(t, err)
+	require.Len(t, rest, 2)
+	assert.Equal(t, "two", rest[0].Message)
+	assert.Equal(t, "three", rest[1].Message)
+}
+
+func TestLogRepo_AppendPublishes(t *testing.T) {
+	db := newTestDB(t)
+	jobRepo := NewJobRepo(db)
+	logRepo := NewLogRepo(db)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, jobRepo.Create(job))
+
+	ch, unsub := db.Events().Subscribe(job.ID)
+	defer unsub()
+
+	require.NoError(t, logRepo.Append(job.ID, 2, "hello"))
+
+	e := <-ch
+	assert.Equal(t, events.JobLog, e.Type)
+	entry, ok := e.Data.(*JobLog)
+	require.True(t, ok)
+	assert.Greater(t, entry.ID, int64(0))
+	assert.Equal(t, 2, entry.Iteration)
+	assert.Equal(t, "hello", entry.Message)
+}
+
+func TestLogRepo_Find(t *testing.T) {
+	db := newTestDB(t)
+	jobRepo := NewJobRepo(db)
+	logRepo := NewLogRepo(db)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, jobRepo.Create(job))
+
+	for i, msg := range []string{"a", "b", "c", "d", "e"} {
+		require.NoError(t, logRepo.Append(job.ID, i/2+1, msg))
+	}
+
+	messages := func(logs []*JobLog) []string {
+		var out []string
+		for _, l := range logs {
+			out = append(out, l.Message)
+		}
+		return out
+	}
+
+	logs, err := logRepo.Find(job.ID, LogQuery{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, messages(logs))
+
+	logs, err = logRepo.Find(job.ID, LogQuery{Iteration: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c", "d"}, messages(logs))
+
+	logs, err = logRepo.Find(job.ID, LogQuery{Tail: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d", "e"}, messages(logs))
+
+	logs, err = logRepo.Find(job.ID, LogQuery{Iteration: 1, Tail: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, messages(logs))
+
+	all, err := logRepo.Find(job.ID, LogQuery{})
+	require.NoError(t, err)
+	logs, err = logRepo.Find(job.ID, LogQuery{AfterID: all[2].ID})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"d", "e"}, messages(logs))
+
+	logs, err = logRepo.Find(job.ID, LogQuery{Since: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+	assert.Len(t, logs, 5)
+
+	logs, err = logRepo.Find(job.ID, LogQuery{Since: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Empty(t, logs)
+}
@@ -0,0 +1,5 @@
+-- Job leases: the server process running a job records itself as the lease
+-- owner and refreshes heartbeat_at while it works, so a restarted (or second)
+-- server can tell live running jobs from ones orphaned by a crash.
+ALTER TABLE jobs ADD COLUMN lease_owner TEXT;
+ALTER TABLE jobs ADD COLUMN heartbeat_at DATETIME;
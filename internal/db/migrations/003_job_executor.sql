@@ -0,0 +1,3 @@
+-- Job executor: the agent backend a job runs with. NULL means the server's
+-- configured default.
+ALTER TABLE jobs ADD COLUMN executor TEXT;
@@ -0,0 +1,19 @@
+-- Agent events: the structured steps (messages, tool calls, results, token
+-- usage) an agent reported during each iteration of a job
+CREATE TABLE IF NOT EXISTS agent_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id INTEGER NOT NULL,
+    iteration INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    tool TEXT,
+    content TEXT,
+    is_error BOOLEAN NOT NULL DEFAULT 0,
+    input_tokens INTEGER NOT NULL DEFAULT 0,
+    output_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_read_tokens INTEGER NOT NULL DEFAULT 0,
+    cache_creation_tokens INTEGER NOT NULL DEFAULT 0,
+    timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_agent_events_job_iteration ON agent_events(job_id, iteration);
@@ -0,0 +1,3 @@
+-- Verification gate: a shell command run in the workspace whenever the agent
+-- claims completion. The job only completes once it exits 0.
+ALTER TABLE jobs ADD COLUMN verify_command TEXT;
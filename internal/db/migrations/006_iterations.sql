@@ -0,0 +1,27 @@
+-- Iterations: one row per pass of the ralph loop, forming a job's timeline
+CREATE TABLE IF NOT EXISTS iterations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id INTEGER NOT NULL,
+    iteration INTEGER NOT NULL,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME,
+
+    -- Outcome
+    exit_code INTEGER NOT NULL DEFAULT 0,
+    completed BOOLEAN NOT NULL DEFAULT 0,
+    verify_passed BOOLEAN,
+    error TEXT,
+
+    -- Diffstat of the iteration's commit
+    commit_hash TEXT,
+    files_changed INTEGER NOT NULL DEFAULT 0,
+    insertions INTEGER NOT NULL DEFAULT 0,
+    deletions INTEGER NOT NULL DEFAULT 0,
+
+    -- Token usage
+    input_tokens INTEGER NOT NULL DEFAULT 0,
+    output_tokens INTEGER NOT NULL DEFAULT 0,
+
+    FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE,
+    UNIQUE (job_id, iteration)
+);
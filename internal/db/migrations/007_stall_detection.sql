@@ -0,0 +1,8 @@
+-- Stall detection: per-job window and policy, and a fingerprint of each
+-- iteration to compare across that window
+ALTER TABLE jobs ADD COLUMN stall_window INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN stall_policy TEXT;
+
+ALTER TABLE iterations ADD COLUMN diff_hash TEXT;
+ALTER TABLE iterations ADD COLUMN output_hash TEXT;
+ALTER TABLE iterations ADD COLUMN failing_tests TEXT;
@@ -0,0 +1,5 @@
+-- Budgets: total running time and per-iteration timeout (in nanoseconds),
+-- and total tokens. Zero means unlimited.
+ALTER TABLE jobs ADD COLUMN max_duration INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN per_iteration_timeout INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN max_tokens INTEGER NOT NULL DEFAULT 0;
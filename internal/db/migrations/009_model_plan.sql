@@ -0,0 +1,5 @@
+-- Model plan: the ordered models a job escalates through (JSON), and the
+-- model each iteration ran with
+ALTER TABLE jobs ADD COLUMN model_plan TEXT;
+
+ALTER TABLE iterations ADD COLUMN model TEXT;
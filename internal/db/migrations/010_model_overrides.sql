@@ -0,0 +1,4 @@
+-- Per-job model and Ollama host overrides; NULL uses the server config
+ALTER TABLE jobs ADD COLUMN large_model TEXT;
+ALTER TABLE jobs ADD COLUMN small_model TEXT;
+ALTER TABLE jobs ADD COLUMN ollama_host TEXT;
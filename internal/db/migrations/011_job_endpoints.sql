@@ -0,0 +1,4 @@
+-- Endpoint routing: tags a job requires of the Ollama endpoint it runs on
+-- (JSON), and the endpoint it was last placed on
+ALTER TABLE jobs ADD COLUMN endpoint_tags TEXT;
+ALTER TABLE jobs ADD COLUMN endpoint TEXT;
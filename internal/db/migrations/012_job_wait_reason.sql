@@ -0,0 +1,2 @@
+-- Why a queued job has not started yet, e.g. waiting for memory
+ALTER TABLE jobs ADD COLUMN wait_reason TEXT;
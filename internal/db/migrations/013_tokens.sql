@@ -0,0 +1,19 @@
+-- API tokens, stored as SHA-256 hashes of the secret, with the scopes they
+-- grant (comma-separated)
+CREATE TABLE IF NOT EXISTS tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME
+);
+
+-- Dashboard sessions, opened by logging in with a token. Expiry is in Unix
+-- seconds so that it compares as a number.
+CREATE TABLE IF NOT EXISTS sessions (
+    hash TEXT PRIMARY KEY,
+    token_id INTEGER NOT NULL,
+    expires_at INTEGER NOT NULL,
+    FOREIGN KEY (token_id) REFERENCES tokens(id) ON DELETE CASCADE
+);
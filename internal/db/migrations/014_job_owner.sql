@@ -0,0 +1,4 @@
+-- Who submitted a job: the name of their API token, or their git user.email
+-- when the server does not authenticate
+ALTER TABLE jobs ADD COLUMN owner TEXT;
+CREATE INDEX idx_jobs_owner ON jobs(owner);
@@ -0,0 +1,12 @@
+-- Secrets jobs receive as environment variables. Values are encrypted with
+-- the server's key using AES-256-GCM, stored as the nonce followed by the
+-- ciphertext, with the name as additional data.
+CREATE TABLE IF NOT EXISTS secrets (
+    name TEXT PRIMARY KEY,
+    value BLOB NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Names of the secrets a job receives, as a JSON array
+ALTER TABLE jobs ADD COLUMN secrets TEXT;
@@ -0,0 +1,3 @@
+-- Token names allowed to submit jobs using a secret, as a JSON array; admins
+-- may always use it
+ALTER TABLE secrets ADD COLUMN users TEXT;
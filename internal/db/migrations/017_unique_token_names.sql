@@ -0,0 +1,6 @@
+-- Jobs are owned by token name, so a name must belong to one token. Later
+-- tokens sharing a name get their ID appended before the index is added.
+UPDATE tokens SET name = name || '-' || id
+WHERE id NOT IN (SELECT MIN(id) FROM tokens GROUP BY name);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tokens_name ON tokens(name);
@@ -0,0 +1,209 @@
+package db
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// SecretKeySize is the length in bytes of the key secrets are encrypted with
+const SecretKeySize = 32
+
+// SecretRepo handles secret persistence. Values are encrypted with the
+// server's key and only decrypted to hand them to jobs.
+type SecretRepo struct {
+	db   *DB
+	aead cipher.AEAD
+}
+
+// NewSecretRepo creates a secret repository encrypting with key
+func NewSecretRepo(db *DB, key []byte) (*SecretRepo, error) {
+	if len(key) != SecretKeySize {
+		return nil, fmt.Errorf("secret key must be %d bytes, got %d", SecretKeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretRepo{db: db, aead: aead}, nil
+}
+
+// LoadSecretKey reads the hex-encoded key at path, generating and storing a
+// new one, readable only by the server's user, if the file does not exist
+func LoadSecretKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, SecretKeySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate secret key: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write secret key: %w", err)
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret key: %w", err)
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != SecretKeySize {
+		return nil, fmt.Errorf("secret key in %s must be %d hex-encoded bytes", path, SecretKeySize)
+	}
+	return key, nil
+}
+
+// Set stores a secret that users, besides admins, may use, replacing any
+// existing value
+func (r *SecretRepo) Set(name, value string, users []string) error {
+	if err := models.ValidateSecretName(name); err != nil {
+		return err
+	}
+	usersJSON, err := json.Marshal(users)
+	if err != nil {
+		return fmt.Errorf("failed to encode secret users: %w", err)
+	}
+
+	nonce := make([]byte, r.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := r.aead.Seal(nonce, nonce, []byte(value), []byte(name))
+
+	now := time.Now().UTC()
+	_, err = r.db.conn.Exec(`
+		INSERT INTO secrets (name, value, users, created_at, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET value = excluded.value, users = excluded.users, updated_at = excluded.updated_at
+	`, name, sealed, string(usersJSON), now, now)
+	if err != nil {
+		return fmt.Errorf("failed to store secret: %w", err)
+	}
+	return nil
+}
+
+// Get returns a secret, without its value
+func (r *SecretRepo) Get(name string) (*models.Secret, error) {
+	row := r.db.conn.QueryRow("SELECT name, users, created_at, updated_at FROM secrets WHERE name = ?", name)
+	s, err := scanSecret(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("secret %s: %w", name, ErrNotFound)
+	}
+	return s, err
+}
+
+// List returns every secret, without values, sorted by name
+func (r *SecretRepo) List() ([]*models.Secret, error) {
+	rows, err := r.db.conn.Query("SELECT name, users, created_at, updated_at FROM secrets ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	defer rows.Close()
+
+	var secrets []*models.Secret
+	for rows.Next() {
+		s, err := scanSecret(rows)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, s)
+	}
+	return secrets, rows.Err()
+}
+
+func scanSecret(row interface{ Scan(...any) error }) (*models.Secret, error) {
+	s := &models.Secret{}
+	var users sql.NullString
+	if err := row.Scan(&s.Name, &users, &s.CreatedAt, &s.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan secret: %w", err)
+	}
+	if users.Valid && users.String != "" {
+		if err := json.Unmarshal([]byte(users.String), &s.Users); err != nil {
+			return nil, fmt.Errorf("failed to decode secret users: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// Delete removes a secret
+func (r *SecretRepo) Delete(name string) error {
+	result, err := r.db.conn.Exec("DELETE FROM secrets WHERE name = ?", name)
+	if err != nil {
+		return fmt.Errorf("failed to delete secret: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Values decrypts the named secrets. It returns an error wrapping
+// ErrNotFound if one does not exist.
+func (r *SecretRepo) Values(names []string) (map[string]string, error) {
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		var sealed []byte
+		err := r.db.conn.QueryRow("SELECT value FROM secrets WHERE name = ?", name).Scan(&sealed)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("secret %s: %w", name, ErrNotFound)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get secret: %w", err)
+		}
+		value, err := r.open(name, sealed)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+// Redactor returns a redactor hiding the values of the named secrets, or nil
+// if there are none. Names no longer stored are skipped.
+func (r *SecretRepo) Redactor(names []string) (*models.Redactor, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var values []string
+	for _, name := range names {
+		v, err := r.Values([]string{name})
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v[name])
+	}
+	return models.NewRedactor(values), nil
+}
+
+// open decrypts a stored value
+func (r *SecretRepo) open(name string, sealed []byte) (string, error) {
+	n := r.aead.NonceSize()
+	if len(sealed) < n {
+		return "", fmt.Errorf("secret %s is corrupt", name)
+	}
+	value, err := r.aead.Open(nil, sealed[:n], sealed[n:], []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret %s; was the server's secret key replaced? %w", name, err)
+	}
+	return string(value), nil
+}
@@ -0,0 +1,110 @@
+package db
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSecretRepo(t *testing.T, db *DB) *SecretRepo {
+	t.Helper()
+	repo, err := NewSecretRepo(db, bytes.Repeat([]byte{7}, SecretKeySize))
+	require.NoError(t, err)
+	return repo
+}
+
+func TestSecretRepo_SetAndValues(t *testing.T) {
+	db := newTestDB(t)
+	repo := newTestSecretRepo(t, db)
+
+	require.NoError(t, repo.Set("NPM_TOKEN", "npm_abc123", nil))
+	require.NoError(t, repo.Set("GH_TOKEN", "ghp_old", nil))
+	require.NoError(t, repo.Set("GH_TOKEN", "ghp_new", nil))
+	assert.Error(t, repo.Set("not a name", "x", nil))
+
+	values, err := repo.Values([]string{"GH_TOKEN", "NPM_TOKEN"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"GH_TOKEN": "ghp_new", "NPM_TOKEN": "npm_abc123"}, values)
+
+	_, err = repo.Values([]string{"AWS_KEY"})
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Contains(t, err.Error(), "AWS_KEY")
+
+	// Only ciphertext reaches the database
+	var stored []byte
+	require.NoError(t, db.conn.QueryRow("SELECT value FROM secrets WHERE name = 'NPM_TOKEN'").Scan(&stored))
+	assert.NotContains(t, string(stored), "npm_abc123")
+
+	// and it cannot be read with another key
+	other, err := NewSecretRepo(db, bytes.Repeat([]byte{8}, SecretKeySize))
+	require.NoError(t, err)
+	_, err = other.Values([]string{"NPM_TOKEN"})
+	assert.ErrorContains(t, err, "failed to decrypt secret NPM_TOKEN")
+}
+
+func TestSecretRepo_ListAndDelete(t *testing.T) {
+	repo := newTestSecretRepo(t, newTestDB(t))
+	require.NoError(t, repo.Set("B_TOKEN", "b", nil))
+	require.NoError(t, repo.Set("A_TOKEN", "a", []string{"alice"}))
+
+	secrets, err := repo.List()
+	require.NoError(t, err)
+	require.Len(t, secrets, 2)
+	assert.Equal(t, "A_TOKEN", secrets[0].Name)
+	assert.Equal(t, []string{"alice"}, secrets[0].Users)
+	assert.False(t, secrets[0].CreatedAt.IsZero())
+	assert.Empty(t, secrets[1].Users)
+
+	secret, err := repo.Get("A_TOKEN")
+	require.NoError(t, err)
+	assert.True(t, secret.Allows("alice"))
+	assert.False(t, secret.Allows("bob"))
+	_, err = repo.Get("C_TOKEN")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, repo.Delete("A_TOKEN"))
+	assert.ErrorIs(t, repo.Delete("A_TOKEN"), ErrNotFound)
+	secrets, err = repo.List()
+	require.NoError(t, err)
+	assert.Len(t, secrets, 1)
+}
+
+func TestSecretRepo_Redactor(t *testing.T) {
+	repo := newTestSecretRepo(t, newTestDB(t))
+	require.NoError(t, repo.Set("NPM_TOKEN", "npm_abc123", nil))
+
+	require.NoError(t, repo.Set("GH_TOKEN", "ghp_x", nil))
+
+	// Only the named secrets are decrypted; deleted ones are skipped
+	redactor, err := repo.Redactor([]string{"NPM_TOKEN", "DELETED"})
+	require.NoError(t, err)
+	assert.Equal(t, "token="+models.Redacted+" ghp_x", redactor.Redact("token=npm_abc123 ghp_x"))
+
+	redactor, err = repo.Redactor(nil)
+	require.NoError(t, err)
+	assert.Nil(t, redactor)
+}
+
+func TestLoadSecretKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ralph.db.key")
+
+	key, err := LoadSecretKey(path)
+	require.NoError(t, err)
+	assert.Len(t, key, SecretKeySize)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	again, err := LoadSecretKey(path)
+	require.NoError(t, err)
+	assert.Equal(t, key, again)
+
+	require.NoError(t, os.WriteFile(path, []byte("not hex"), 0o600))
+	_, err = LoadSecretKey(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// tokenPrefix marks ralph-o-matic secrets so they are recognisable in
+// config files and secret scanners
+const tokenPrefix = "ralph_"
+
+// ErrTokenExists is returned by TokenRepo.Create for a name another token
+// already has. Jobs belong to a token's name, so names are unique.
+var ErrTokenExists = errors.New("token name already in use")
+
+// TokenRepo handles API token and dashboard session persistence. Only
+// hashes of secrets are stored.
+type TokenRepo struct {
+	db *DB
+}
+
+// NewTokenRepo creates a new token repository
+func NewTokenRepo(db *DB) *TokenRepo {
+	return &TokenRepo{db: db}
+}
+
+// Create stores a new token and returns its secret, which cannot be
+// recovered later
+func (r *TokenRepo) Create(token *models.Token) (string, error) {
+	if err := token.Validate(); err != nil {
+		return "", err
+	}
+
+	secret, err := newSecret()
+	if err != nil {
+		return "", err
+	}
+
+	token.CreatedAt = time.Now().UTC()
+	result, err := r.db.conn.Exec(`
+		INSERT INTO tokens (name, hash, scopes, created_at) VALUES (?, ?, ?, ?)
+	`, token.Name, hashSecret(secret), encodeScopes(token.Scopes), token.CreatedAt)
+	if err != nil {
+		var exists bool
+		if r.db.conn.QueryRow("SELECT EXISTS (SELECT 1 FROM tokens WHERE name = ?)", token.Name).Scan(&exists) == nil && exists {
+			return "", fmt.Errorf("token %s: %w", token.Name, ErrTokenExists)
+		}
+		return "", fmt.Errorf("failed to insert token: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", fmt.Errorf("failed to get last insert id: %w", err)
+	}
+	token.ID = id
+
+	return secret, nil
+}
+
+// Authenticate returns the token whose secret this is and records its use,
+// or ErrNotFound
+func (r *TokenRepo) Authenticate(secret string) (*models.Token, error) {
+	token, err := r.scanToken(r.db.conn.QueryRow(`
+		SELECT id, name, scopes, created_at, last_used_at FROM tokens WHERE hash = ?
+	`, hashSecret(secret)))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if _, err := r.db.conn.Exec("UPDATE tokens SET last_used_at = ? WHERE id = ?", now, token.ID); err != nil {
+		return nil, fmt.Errorf("failed to record token use: %w", err)
+	}
+	token.LastUsedAt = &now
+	return token, nil
+}
+
+// List returns every token, oldest first
+func (r *TokenRepo) List() ([]*models.Token, error) {
+	rows, err := r.db.conn.Query("SELECT id, name, scopes, created_at, last_used_at FROM tokens ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*models.Token
+	for rows.Next() {
+		token, err := r.scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// Count returns the number of tokens
+func (r *TokenRepo) Count() (int, error) {
+	var n int
+	if err := r.db.conn.QueryRow("SELECT COUNT(*) FROM tokens").Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count tokens: %w", err)
+	}
+	return n, nil
+}
+
+// Delete revokes a token and ends its dashboard sessions
+func (r *TokenRepo) Delete(id int64) error {
+	if _, err := r.db.conn.Exec("DELETE FROM sessions WHERE token_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	result, err := r.db.conn.Exec("DELETE FROM tokens WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete token: %w", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// CreateSession opens a dashboard session for a token and returns its ID
+func (r *TokenRepo) CreateSession(tokenID int64, ttl time.Duration) (string, error) {
+	id, err := newSecret()
+	if err != nil {
+		return "", err
+	}
+
+	// Sweep expired sessions while we are here
+	now := time.Now()
+	if _, err := r.db.conn.Exec("DELETE FROM sessions WHERE expires_at <= ?", now.Unix()); err != nil {
+		return "", fmt.Errorf("failed to expire sessions: %w", err)
+	}
+
+	_, err = r.db.conn.Exec("INSERT INTO sessions (hash, token_id, expires_at) VALUES (?, ?, ?)",
+		hashSecret(id), tokenID, now.Add(ttl).Unix())
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+	return id, nil
+}
+
+// SessionToken returns the token a session was opened with, or ErrNotFound
+// if the session does not exist or has expired
+func (r *TokenRepo) SessionToken(sessionID string) (*models.Token, error) {
+	return r.scanToken(r.db.conn.QueryRow(`
+		SELECT t.id, t.name, t.scopes, t.created_at, t.last_used_at
+		FROM sessions s JOIN tokens t ON t.id = s.token_id
+		WHERE s.hash = ? AND s.expires_at > ?
+	`, hashSecret(sessionID), time.Now().Unix()))
+}
+
+// DeleteSession ends a dashboard session
+func (r *TokenRepo) DeleteSession(sessionID string) error {
+	if _, err := r.db.conn.Exec("DELETE FROM sessions WHERE hash = ?", hashSecret(sessionID)); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// scanToken reads one token row
+func (r *TokenRepo) scanToken(row interface{ Scan(...any) error }) (*models.Token, error) {
+	token := &models.Token{}
+	var scopes string
+	var lastUsedAt sql.NullTime
+	if err := row.Scan(&token.ID, &token.Name, &scopes, &token.CreatedAt, &lastUsedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+
+	parsed, err := models.ParseScopes(scopes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode scopes of token %d: %w", token.ID, err)
+	}
+	token.Scopes = parsed
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return token, nil
+}
+
+// encodeScopes joins scopes for storage
+func encodeScopes(scopes []models.Scope) string {
+	parts := make([]string, len(scopes))
+	for i, s := range scopes {
+		parts[i] = string(s)
+	}
+	return strings.Join(parts, ",")
+}
+
+// newSecret returns a random token secret
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return tokenPrefix + hex.EncodeToString(b), nil
+}
+
+// hashSecret returns the stored form of a secret. Secrets are random, so a
+// plain hash is enough; there is nothing to brute-force.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
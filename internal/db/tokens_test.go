@@ -0,0 +1,95 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenRepo_CreateAndAuthenticate(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewTokenRepo(db)
+
+	token := &models.Token{Name: "alice", Scopes: []models.Scope{models.ScopeSubmit, models.ScopeControl}}
+	secret, err := repo.Create(token)
+	require.NoError(t, err)
+	assert.NotZero(t, token.ID)
+	assert.Contains(t, secret, "ralph_")
+
+	found, err := repo.Authenticate(secret)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", found.Name)
+	assert.Equal(t, token.Scopes, found.Scopes)
+	assert.NotNil(t, found.LastUsedAt)
+
+	_, err = repo.Authenticate(secret + "x")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = repo.Create(&models.Token{Name: "bob", Scopes: []models.Scope{"root"}})
+	assert.Error(t, err)
+
+	// Jobs belong to a token's name, so it cannot be taken twice
+	_, err = repo.Create(&models.Token{Name: "alice", Scopes: []models.Scope{models.ScopeAdmin}})
+	assert.ErrorIs(t, err, ErrTokenExists)
+}
+
+func TestTokenRepo_ListAndDelete(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewTokenRepo(db)
+
+	a := &models.Token{Name: "alice", Scopes: []models.Scope{models.ScopeAdmin}}
+	_, err := repo.Create(a)
+	require.NoError(t, err)
+	b := &models.Token{Name: "bob"}
+	secret, err := repo.Create(b)
+	require.NoError(t, err)
+
+	tokens, err := repo.List()
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	assert.Equal(t, "alice", tokens[0].Name)
+	assert.Empty(t, tokens[1].Scopes, "a token without scopes can only read")
+
+	require.NoError(t, repo.Delete(b.ID))
+	_, err = repo.Authenticate(secret)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, repo.Delete(b.ID), ErrNotFound)
+
+	n, err := repo.Count()
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestTokenRepo_Sessions(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewTokenRepo(db)
+
+	token := &models.Token{Name: "alice", Scopes: []models.Scope{models.ScopeControl}}
+	_, err := repo.Create(token)
+	require.NoError(t, err)
+
+	session, err := repo.CreateSession(token.ID, time.Hour)
+	require.NoError(t, err)
+	found, err := repo.SessionToken(session)
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, found.ID)
+
+	require.NoError(t, repo.DeleteSession(session))
+	_, err = repo.SessionToken(session)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	expired, err := repo.CreateSession(token.ID, -time.Second)
+	require.NoError(t, err)
+	_, err = repo.SessionToken(expired)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Revoking the token ends its sessions
+	session, err = repo.CreateSession(token.ID, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, repo.Delete(token.ID))
+	_, err = repo.SessionToken(session)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package doctor
+
+import "syscall"
+
+// freeDiskGB returns the space available to unprivileged users on the disk
+// holding dir
+func freeDiskGB(dir string) (float64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return float64(stat.Bavail) * float64(stat.Bsize) / (1 << 30), nil
+}
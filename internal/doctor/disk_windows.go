@@ -0,0 +1,10 @@
+//go:build windows
+
+package doctor
+
+import "errors"
+
+// freeDiskGB is not implemented on Windows
+func freeDiskGB(dir string) (float64, error) {
+	return 0, errors.New("free disk space is not checked on Windows")
+}
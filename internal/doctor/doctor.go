@@ -0,0 +1,285 @@
+// Package doctor diagnoses the environment the server runs jobs in, so that
+// a missing tool or an unreachable Ollama is reported with a fix up front
+// instead of as an opaque error halfway through a job.
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/executor"
+	"github.com/ryan/ralph-o-matic/internal/git"
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/platform"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn" // jobs run, but may be slow or fail later
+	StatusFail Status = "fail" // jobs cannot run until it is fixed
+)
+
+// Free disk space on the workspace below which the disk check warns or fails
+const (
+	warnFreeDiskGB = 10
+	failFreeDiskGB = 1
+)
+
+// pingTimeout bounds the check of one Ollama host
+const pingTimeout = 5 * time.Second
+
+// Check is the result of one diagnostic
+type Check struct {
+	Name   string `json:"name"`
+	Status Status `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"` // what to do about a warning or failure
+}
+
+// Report is the result of every diagnostic
+type Report struct {
+	Checks  []Check `json:"checks"`
+	Healthy bool    `json:"healthy"` // no check failed
+}
+
+// Doctor checks the tools, services and resources jobs depend on
+type Doctor struct {
+	db           *db.DB
+	workspaceDir string
+
+	// Probes of the machine; tests replace them
+	gitInstalled    func() bool
+	ghInstalled     func() bool
+	ghAuthenticated func() bool
+	claudeInstalled func() bool
+	installed       func(command string) bool
+	detectHardware  func() (*platform.HardwareInfo, error)
+	freeDiskGB      func(dir string) (float64, error)
+}
+
+// New creates a doctor for a server using database and cloning jobs into
+// workspaceDir
+func New(database *db.DB, workspaceDir string) *Doctor {
+	gh := git.NewGH()
+	return &Doctor{
+		db:              database,
+		workspaceDir:    workspaceDir,
+		gitInstalled:    git.New().IsInstalled,
+		ghInstalled:     gh.IsInstalled,
+		ghAuthenticated: gh.IsAuthenticated,
+		claudeInstalled: executor.IsClaudeInstalled,
+		installed:       commandInstalled,
+		detectHardware:  platform.DetectHardware,
+		freeDiskGB:      freeDiskGB,
+	}
+}
+
+// Run performs every check
+func (d *Doctor) Run(ctx context.Context) *Report {
+	var checks []Check
+
+	cfg, err := db.NewConfigRepo(d.db).Get()
+	if err != nil {
+		checks = append(checks, Check{
+			Name:   "config",
+			Status: StatusFail,
+			Detail: err.Error(),
+			Fix:    "Check that the database file is readable and not corrupt",
+		})
+		cfg = models.DefaultServerConfig()
+	}
+
+	checks = append(checks, d.checkGit(), d.checkGH(), d.checkClaude(cfg), d.checkSandbox(cfg))
+	checks = append(checks, d.checkOllama(ctx, cfg)...)
+	checks = append(checks, d.checkWorkspace(), d.checkDisk(), d.checkDatabase(), d.checkHardware())
+
+	report := &Report{Checks: checks, Healthy: true}
+	for _, c := range checks {
+		if c.Status == StatusFail {
+			report.Healthy = false
+		}
+	}
+	return report
+}
+
+func (d *Doctor) checkGit() Check {
+	if !d.gitInstalled() {
+		return Check{Name: "git", Status: StatusFail, Detail: "git is not installed",
+			Fix: "Install git (https://git-scm.com/downloads) and make sure it is on the server's PATH"}
+	}
+	return Check{Name: "git", Status: StatusOK, Detail: "installed"}
+}
+
+func (d *Doctor) checkGH() Check {
+	if !d.ghInstalled() {
+		return Check{Name: "gh", Status: StatusFail, Detail: "the GitHub CLI is not installed",
+			Fix: "Install gh (https://cli.github.com) and make sure it is on the server's PATH"}
+	}
+	if !d.ghAuthenticated() {
+		return Check{Name: "gh", Status: StatusFail, Detail: "gh is not logged in, so pull requests cannot be opened",
+			Fix: "Run `gh auth login` as the user the server runs as"}
+	}
+	return Check{Name: "gh", Status: StatusOK, Detail: "installed and logged in"}
+}
+
+// checkClaude fails if claude is missing and it is the server's executor;
+// jobs can still pick it, so it is a warning otherwise
+func (d *Doctor) checkClaude(cfg *models.ServerConfig) Check {
+	if d.claudeInstalled() {
+		return Check{Name: "claude", Status: StatusOK, Detail: "installed"}
+	}
+	status := StatusWarn
+	if cfg.Executor == "" || cfg.Executor == executor.DefaultBackend {
+		status = StatusFail
+	}
+	return Check{Name: "claude", Status: status, Detail: "Claude Code is not installed",
+		Fix: "Install it with `npm install -g @anthropic-ai/claude-code`, or set executor to another backend"}
+}
+
+// commandInstalled reports whether command is on the PATH
+func commandInstalled(command string) bool {
+	_, err := exec.LookPath(command)
+	return err == nil
+}
+
+// checkSandbox checks the configured sandbox runtime is installed; a missing
+// one fails every job rather than running it unconfined
+func (d *Doctor) checkSandbox(cfg *models.ServerConfig) Check {
+	sb := cfg.Sandbox
+	if !sb.Enabled() {
+		return Check{Name: "sandbox", Status: StatusOK, Detail: "off; jobs run unconfined as the server user"}
+	}
+	if !d.installed(sb.Runtime) {
+		return Check{Name: "sandbox", Status: StatusFail, Detail: sb.Runtime + " is not installed, so jobs cannot start",
+			Fix: fmt.Sprintf("Install %s on the server, or pick another sandbox.runtime", sb.Runtime)}
+	}
+	if sb.Runtime == models.SandboxBwrap && (sb.CPUs > 0 || sb.MemoryGB > 0) && !d.installed("systemd-run") {
+		return Check{Name: "sandbox", Status: StatusFail, Detail: "cpu and memory limits with bwrap need systemd-run",
+			Fix: "Run the server under systemd, or use podman or docker for limits"}
+	}
+	return Check{Name: "sandbox", Status: StatusOK, Detail: "jobs run in " + sb.Runtime}
+}
+
+// checkOllama checks every configured Ollama host answers and has the
+// server's models
+func (d *Doctor) checkOllama(ctx context.Context, cfg *models.ServerConfig) []Check {
+	var checks []Check
+	for _, host := range cfg.Ollama.Hosts() {
+		client := platform.NewOllamaClient(host)
+		name := "ollama " + host
+
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err := client.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			checks = append(checks, Check{Name: name, Status: StatusFail, Detail: err.Error(),
+				Fix: fmt.Sprintf("Start Ollama on %s (`ollama serve`), or point ollama.host at a running server", host)})
+			continue
+		}
+		checks = append(checks, Check{Name: name, Status: StatusOK, Detail: "reachable"})
+
+		for _, model := range []string{cfg.LargeModel.Name, cfg.SmallModel.Name} {
+			checks = append(checks, checkModel(ctx, client, host, model))
+		}
+	}
+	return checks
+}
+
+// checkModel warns about a missing model: jobs pull it before they start,
+// but the first one waits for the download
+func checkModel(ctx context.Context, client *platform.OllamaClient, host, model string) Check {
+	name := "model " + model
+	ok, err := client.HasModel(ctx, model)
+	if err != nil {
+		return Check{Name: name, Status: StatusFail, Detail: fmt.Sprintf("could not check %s: %v", host, err),
+			Fix: fmt.Sprintf("Check the Ollama logs on %s", host)}
+	}
+	if !ok {
+		return Check{Name: name, Status: StatusWarn, Detail: "not installed on " + host,
+			Fix: fmt.Sprintf("Run `ralph-o-matic models pull %s`; otherwise the next job pulls it before starting", model)}
+	}
+	return Check{Name: name, Status: StatusOK, Detail: "installed on " + host}
+}
+
+// checkWorkspace creates and removes a file in the workspace directory
+func (d *Doctor) checkWorkspace() Check {
+	fix := fmt.Sprintf("Make %s writable by the user the server runs as, or set RALPH_WORKSPACE to a writable directory", d.workspaceDir)
+	if err := os.MkdirAll(d.workspaceDir, 0o755); err != nil {
+		return Check{Name: "workspace", Status: StatusFail, Detail: err.Error(), Fix: fix}
+	}
+	f, err := os.CreateTemp(d.workspaceDir, ".doctor-*")
+	if err != nil {
+		return Check{Name: "workspace", Status: StatusFail, Detail: err.Error(), Fix: fix}
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return Check{Name: "workspace", Status: StatusOK, Detail: d.workspaceDir + " is writable"}
+}
+
+func (d *Doctor) checkDisk() Check {
+	free, err := d.freeDiskGB(d.workspaceDir)
+	if err != nil {
+		return Check{Name: "disk", Status: StatusWarn, Detail: err.Error()}
+	}
+
+	detail := fmt.Sprintf("%.1fGB free in %s", free, d.workspaceDir)
+	fix := "Free up space or move the workspace to a larger disk; every job clones its repository there"
+	switch {
+	case free < failFreeDiskGB:
+		return Check{Name: "disk", Status: StatusFail, Detail: detail, Fix: fix}
+	case free < warnFreeDiskGB:
+		return Check{Name: "disk", Status: StatusWarn, Detail: detail, Fix: fix}
+	}
+	return Check{Name: "disk", Status: StatusOK, Detail: detail}
+}
+
+// checkDatabase compares the schema version with the migrations this binary
+// carries
+func (d *Doctor) checkDatabase() Check {
+	current, err := d.db.MigrationVersion()
+	if err != nil {
+		return Check{Name: "database", Status: StatusFail, Detail: err.Error(),
+			Fix: "Check that the database file is readable and not corrupt"}
+	}
+	latest, err := db.LatestMigrationVersion()
+	if err != nil {
+		return Check{Name: "database", Status: StatusFail, Detail: err.Error()}
+	}
+
+	switch {
+	case current < latest:
+		return Check{Name: "database", Status: StatusFail,
+			Detail: fmt.Sprintf("schema is at migration %d of %d", current, latest),
+			Fix:    "Restart the server to apply the pending migrations"}
+	case current > latest:
+		return Check{Name: "database", Status: StatusWarn,
+			Detail: fmt.Sprintf("schema is at migration %d, newer than this server's %d", current, latest),
+			Fix:    "Upgrade the server to the version that migrated the database"}
+	}
+	return Check{Name: "database", Status: StatusOK, Detail: fmt.Sprintf("schema is at migration %d", current)}
+}
+
+func (d *Doctor) checkHardware() Check {
+	hw, err := d.detectHardware()
+	if err != nil {
+		return Check{Name: "hardware", Status: StatusWarn, Detail: err.Error(),
+			Fix: "Jobs are admitted without checking memory; keep concurrent_jobs low enough for your models"}
+	}
+
+	parts := []string{fmt.Sprintf("%.0fGB RAM", hw.SystemRAMGB)}
+	for _, gpu := range hw.GPUs {
+		parts = append(parts, fmt.Sprintf("%s (%.0fGB)", gpu.Name, gpu.VRAMGB))
+	}
+	if !hw.HasGPU() {
+		parts = append(parts, "no GPU")
+	}
+	return Check{Name: "hardware", Status: StatusOK, Detail: strings.Join(parts, ", ")}
+}
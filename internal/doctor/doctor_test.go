@@ -0,0 +1,162 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/platform"
+)
+
+// newTestDoctor returns a doctor on a healthy machine whose Ollama has the
+// installed models
+func newTestDoctor(t *testing.T, installed ...string) *Doctor {
+	t.Helper()
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if r.URL.Path == "/api/show" {
+			for _, name := range installed {
+				if body["name"] == name {
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ollama.Close)
+
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	t.Cleanup(func() { database.Close() })
+
+	cfg := models.DefaultServerConfig()
+	cfg.Ollama.Host = ollama.URL
+	require.NoError(t, db.NewConfigRepo(database).Save(cfg))
+
+	d := New(database, t.TempDir())
+	d.gitInstalled = func() bool { return true }
+	d.ghInstalled = func() bool { return true }
+	d.ghAuthenticated = func() bool { return true }
+	d.claudeInstalled = func() bool { return true }
+	d.installed = func(string) bool { return true }
+	d.detectHardware = func() (*platform.HardwareInfo, error) {
+		return &platform.HardwareInfo{SystemRAMGB: 64, GPUs: []platform.GPUInfo{{Name: "RTX 4090", VRAMGB: 24}}}, nil
+	}
+	d.freeDiskGB = func(string) (float64, error) { return 100, nil }
+	return d
+}
+
+func findCheck(t *testing.T, report *Report, name string) Check {
+	t.Helper()
+	for _, c := range report.Checks {
+		if c.Name == name {
+			return c
+		}
+	}
+	t.Fatalf("no %q check in report", name)
+	return Check{}
+}
+
+func TestDoctor_Healthy(t *testing.T) {
+	d := newTestDoctor(t, "qwen3-coder:70b", "qwen2.5-coder:7b")
+
+	report := d.Run(context.Background())
+	for _, c := range report.Checks {
+		assert.Equal(t, StatusOK, c.Status, "%s: %s", c.Name, c.Detail)
+		assert.Empty(t, c.Fix, c.Name)
+	}
+	assert.True(t, report.Healthy)
+	assert.Equal(t, "64GB RAM, RTX 4090 (24GB)", findCheck(t, report, "hardware").Detail)
+}
+
+func TestDoctor_ReportsFixes(t *testing.T) {
+	d := newTestDoctor(t, "qwen2.5-coder:7b")
+	d.ghAuthenticated = func() bool { return false }
+	d.freeDiskGB = func(string) (float64, error) { return 4, nil }
+	d.detectHardware = func() (*platform.HardwareInfo, error) { return nil, errors.New("unsupported OS: plan9") }
+
+	report := d.Run(context.Background())
+	assert.False(t, report.Healthy)
+
+	gh := findCheck(t, report, "gh")
+	assert.Equal(t, StatusFail, gh.Status)
+	assert.Contains(t, gh.Fix, "gh auth login")
+
+	model := findCheck(t, report, "model qwen3-coder:70b")
+	assert.Equal(t, StatusWarn, model.Status, "jobs pull missing models themselves")
+	assert.Contains(t, model.Fix, "ralph-o-matic models pull qwen3-coder:70b")
+
+	assert.Equal(t, StatusWarn, findCheck(t, report, "disk").Status)
+	assert.Equal(t, StatusWarn, findCheck(t, report, "hardware").Status)
+}
+
+func TestDoctor_ClaudeOnlyFailsAsServerExecutor(t *testing.T) {
+	d := newTestDoctor(t)
+	d.claudeInstalled = func() bool { return false }
+
+	report := d.Run(context.Background())
+	assert.Equal(t, StatusFail, findCheck(t, report, "claude").Status)
+
+	cfg := models.DefaultServerConfig()
+	cfg.Executor = "aider"
+	require.NoError(t, db.NewConfigRepo(d.db).Save(cfg))
+	report = d.Run(context.Background())
+	assert.Equal(t, StatusWarn, findCheck(t, report, "claude").Status)
+}
+
+func TestDoctor_Sandbox(t *testing.T) {
+	d := newTestDoctor(t)
+	d.installed = func(command string) bool { return command == "bwrap" }
+
+	cfg := models.DefaultServerConfig()
+	assert.Equal(t, StatusOK, d.checkSandbox(cfg).Status, "no sandbox needs nothing installed")
+
+	cfg.Sandbox = models.SandboxConfig{Runtime: models.SandboxBwrap}
+	assert.Equal(t, StatusOK, d.checkSandbox(cfg).Status)
+
+	cfg.Sandbox.MemoryGB = 8
+	assert.Equal(t, StatusFail, d.checkSandbox(cfg).Status, "limits need systemd-run")
+
+	cfg.Sandbox = models.SandboxConfig{Runtime: models.SandboxPodman, Image: "ralph-agents"}
+	check := d.checkSandbox(cfg)
+	assert.Equal(t, StatusFail, check.Status)
+	assert.Contains(t, check.Fix, "Install podman")
+}
+
+func TestDoctor_OllamaDown(t *testing.T) {
+	d := newTestDoctor(t)
+	cfg := models.DefaultServerConfig()
+	cfg.Ollama.Host = "http://127.0.0.1:1"
+	require.NoError(t, db.NewConfigRepo(d.db).Save(cfg))
+
+	report := d.Run(context.Background())
+	check := findCheck(t, report, "ollama http://127.0.0.1:1")
+	assert.Equal(t, StatusFail, check.Status)
+	assert.Contains(t, check.Fix, "ollama serve")
+	for _, c := range report.Checks {
+		assert.NotContains(t, c.Name, "model ", "models are not checked on an unreachable host")
+	}
+}
+
+func TestDoctor_WorkspaceNotWritable(t *testing.T) {
+	d := newTestDoctor(t)
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+	d.workspaceDir = filepath.Join(file, "workspace")
+
+	check := d.checkWorkspace()
+	assert.Equal(t, StatusFail, check.Status)
+	assert.Contains(t, check.Fix, "RALPH_WORKSPACE")
+}
@@ -0,0 +1,92 @@
+package events
+
+import (
+	"log"
+	"sync"
+)
+
+// Type names the kind of event; it is sent as the SSE event name
+type Type string
+
+const (
+	// JobStatus is published when a job changes state. Data is a *models.Job.
+	JobStatus Type = "status"
+	// JobLog is published when a log line is appended. Data is a *db.JobLog.
+	JobLog Type = "log"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// further events are dropped for it
+const subscriberBuffer = 256
+
+// Event is a single notification carried by the bus
+type Event struct {
+	Type  Type
+	JobID int64
+	Data  interface{}
+}
+
+// Bus fans events out to in-process subscribers. Publishing never blocks:
+// a subscriber that stops reading misses events rather than stalling the
+// queue or the job writing logs.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[*subscription]struct{}
+}
+
+type subscription struct {
+	ch    chan Event
+	jobID int64
+}
+
+// NewBus creates an empty event bus
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*subscription]struct{})}
+}
+
+// Publish delivers an event to every matching subscriber
+func (b *Bus) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs {
+		if sub.jobID != 0 && sub.jobID != e.JobID {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			log.Printf("Dropping %s event for job %d: subscriber is not keeping up", e.Type, e.JobID)
+		}
+	}
+}
+
+// Subscribe returns a channel of events for jobID (0 for all jobs) and a
+// func that unsubscribes and closes the channel
+func (b *Bus) Subscribe(jobID int64) (<-chan Event, func()) {
+	sub := &subscription{
+		ch:    make(chan Event, subscriberBuffer),
+		jobID: jobID,
+	}
+
+	b.mu.Lock()
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, sub)
+			b.mu.Unlock()
+			close(sub.ch)
+		})
+	}
+}
+
+// Subscribers returns the number of active subscriptions
+func (b *Bus) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs)
+}
@@ -0,0 +1,56 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBus_PublishSubscribe(t *testing.T) {
+	bus := NewBus()
+
+	all, unsubAll := bus.Subscribe(0)
+	defer unsubAll()
+	job2, unsubJob2 := bus.Subscribe(2)
+	defer unsubJob2()
+
+	bus.Publish(Event{Type: JobStatus, JobID: 1})
+	bus.Publish(Event{Type: JobLog, JobID: 2, Data: "hello"})
+
+	assert.Equal(t, int64(1), (<-all).JobID)
+	assert.Equal(t, int64(2), (<-all).JobID)
+
+	e := <-job2
+	assert.Equal(t, JobLog, e.Type)
+	assert.Equal(t, "hello", e.Data)
+	assert.Empty(t, job2)
+}
+
+func TestBus_Unsubscribe(t *testing.T) {
+	bus := NewBus()
+
+	ch, unsub := bus.Subscribe(0)
+	assert.Equal(t, 1, bus.Subscribers())
+
+	unsub()
+	unsub() // Safe to call twice
+	assert.Equal(t, 0, bus.Subscribers())
+
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	// Publishing with no subscribers is a no-op
+	bus.Publish(Event{Type: JobStatus, JobID: 1})
+}
+
+func TestBus_SlowSubscriberDoesNotBlock(t *testing.T) {
+	bus := NewBus()
+
+	ch, unsub := bus.Subscribe(0)
+	defer unsub()
+
+	for i := 0; i < subscriberBuffer+10; i++ {
+		bus.Publish(Event{Type: JobLog, JobID: 1})
+	}
+	assert.Len(t, ch, subscriberBuffer)
+}
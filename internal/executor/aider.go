@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// AiderExecutor runs aider against the configured Ollama models
+type AiderExecutor struct {
+	config    *models.ServerConfig
+	killGrace time.Duration
+}
+
+// NewAiderExecutor creates a new aider executor
+func NewAiderExecutor(config *models.ServerConfig) *AiderExecutor {
+	return &AiderExecutor{config: config, killGrace: DefaultKillGrace}
+}
+
+// BuildEnv creates the environment variables pointing aider at Ollama
+func (e *AiderExecutor) BuildEnv(extra map[string]string) []string {
+	return buildEnv(map[string]string{
+		"OLLAMA_API_BASE": e.config.Ollama.Host,
+	}, extra)
+}
+
+// BuildCommand creates the aider command arguments. The prompt is read from
+// promptFile so long prompts don't hit argument length limits.
+func (e *AiderExecutor) BuildCommand(promptFile string) []string {
+	return []string{
+		"aider",
+		"--model", "ollama_chat/" + e.config.LargeModel.Name,
+		"--weak-model", "ollama_chat/" + e.config.SmallModel.Name,
+		"--yes-always",
+		"--no-auto-commits", // the ralph loop commits each iteration itself
+		"--no-pretty",
+		"--no-stream",
+		"--no-check-update",
+		"--message-file", promptFile,
+	}
+}
+
+// Execute runs aider once with the given prompt
+func (e *AiderExecutor) Execute(ctx context.Context, workDir, prompt string, env map[string]string, onOutput OutputCallback) (*ExecutionResult, error) {
+	// Keep the prompt outside the workspace so it never ends up in a commit
+	promptFile, err := writeTempFile("ralph-aider-prompt-*.md", prompt)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(promptFile)
+
+	args := e.BuildCommand(promptFile)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = workDir
+	cmd.Env = e.BuildEnv(env)
+
+	output, exitErr, err := runProcess(ctx, cmd, e.killGrace, onOutput, promptFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return outputResult(output, exitErr), nil
+}
+
+// writeTempFile writes content to a new temporary file and returns its path
+func writeTempFile(pattern, content string) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(content); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write temp file: %w", err)
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAiderExecutor_BuildCommand(t *testing.T) {
+	exec := NewAiderExecutor(models.DefaultServerConfig())
+
+	cmd := exec.BuildCommand("/tmp/prompt.md")
+
+	assert.Equal(t, "aider", cmd[0])
+	assert.Contains(t, cmd, "ollama_chat/qwen3-coder:70b")
+	assert.Contains(t, cmd, "ollama_chat/qwen2.5-coder:7b")
+	assert.Contains(t, cmd, "--no-auto-commits")
+	assert.Equal(t, []string{"--message-file", "/tmp/prompt.md"}, cmd[len(cmd)-2:])
+}
+
+func TestAiderExecutor_BuildEnv(t *testing.T) {
+	cfg := models.DefaultServerConfig()
+	cfg.Ollama.Host = "http://192.168.1.50:11434"
+	exec := NewAiderExecutor(cfg)
+
+	env := exec.BuildEnv(map[string]string{"CUSTOM": "value"})
+
+	assert.Contains(t, env, "OLLAMA_API_BASE=http://192.168.1.50:11434")
+	assert.Contains(t, env, "CUSTOM=value")
+}
+
+func TestAiderExecutor_Execute(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake aider is a shell script")
+	}
+
+	// A fake aider that echoes the prompt it was given
+	binDir := t.TempDir()
+	script := "#!/bin/sh\nwhile [ \"$1\" != \"--message-file\" ]; do shift; done\ncat \"$2\"\necho\necho '<promise>COMPLETE</promise>'\n"
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "aider"), []byte(script), 0o755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	var lines []string
+	exec := NewAiderExecutor(models.DefaultServerConfig())
+	result, err := exec.Execute(context.Background(), t.TempDir(), "Fix the tests", nil, func(line string) {
+		lines = append(lines, line)
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, result.Error)
+	assert.True(t, result.Completed)
+	assert.Contains(t, lines, "Fix the tests")
+}
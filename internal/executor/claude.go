@@ -1,168 +1,114 @@
 package executor
 
 import (
-	"bufio"
-	"bytes"
 	"context"
-	"fmt"
-	"io"
-	"os"
 	"os/exec"
-	"regexp"
-	"strconv"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/ryan/ralph-o-matic/internal/models"
 )
 
+// DefaultKillGrace is how long an agent gets to exit after SIGTERM before
+// the whole process group is killed
+const DefaultKillGrace = 10 * time.Second
+
 // ClaudeExecutor manages Claude Code subprocess execution
 type ClaudeExecutor struct {
-	config *models.ServerConfig
+	config    *models.ServerConfig
+	killGrace time.Duration
 }
 
 // NewClaudeExecutor creates a new executor
 func NewClaudeExecutor(config *models.ServerConfig) *ClaudeExecutor {
-	return &ClaudeExecutor{config: config}
+	return &ClaudeExecutor{config: config, killGrace: DefaultKillGrace}
 }
 
 // BuildEnv creates the environment variables for Claude Code with Ollama
 func (e *ClaudeExecutor) BuildEnv(extra map[string]string) []string {
-	env := os.Environ()
-
-	// Ollama configuration
-	ollamaEnv := map[string]string{
+	return buildEnv(map[string]string{
 		"ANTHROPIC_BASE_URL":            e.config.Ollama.Host,
 		"ANTHROPIC_AUTH_TOKEN":          "ollama",
 		"ANTHROPIC_API_KEY":             "",
 		"ANTHROPIC_MODEL":               e.config.LargeModel.Name,
 		"ANTHROPIC_DEFAULT_HAIKU_MODEL": e.config.SmallModel.Name,
-	}
-
-	for k, v := range ollamaEnv {
-		env = append(env, fmt.Sprintf("%s=%s", k, v))
-	}
-
-	for k, v := range extra {
-		env = append(env, fmt.Sprintf("%s=%s", k, v))
-	}
-
-	return env
+	}, extra)
 }
 
-// BuildCommand creates the claude command arguments
+// BuildCommand creates the claude command arguments. stream-json output
+// reports each message, tool call and the final result as a JSON line.
 func (e *ClaudeExecutor) BuildCommand(prompt string) []string {
 	return []string{
 		"claude",
 		"--print", // Non-interactive mode
+		"--output-format", "stream-json",
+		"--verbose", // required by stream-json in print mode
 	}
 }
 
-// ExecutionResult contains the results of running Claude Code
-type ExecutionResult struct {
-	Output     string
-	Iterations int
-	Completed  bool
-	Error      error
-}
-
-// OutputCallback is called for each line of output
-type OutputCallback func(line string)
-
-// Execute runs Claude Code with the given prompt
+// Execute runs Claude Code with the given prompt, logging a readable
+// rendering of its events. Cancelling ctx sends SIGTERM to claude's process
+// group, followed by SIGKILL if it has not exited within the kill grace
+// period.
 func (e *ClaudeExecutor) Execute(ctx context.Context, workDir, prompt string, env map[string]string, onOutput OutputCallback) (*ExecutionResult, error) {
-	cmd := exec.CommandContext(ctx, "claude", "--print")
+	args := e.BuildCommand(prompt)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
 	cmd.Dir = workDir
 	cmd.Env = e.BuildEnv(env)
 
 	// Pass prompt via stdin
 	cmd.Stdin = strings.NewReader(prompt)
 
-	// Capture output
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
-	}
-
-	stderr, err := cmd.StderrPipe()
+	parser := NewStreamParser()
+	output, exitErr, err := runProcess(ctx, cmd, e.killGrace, func(line string) {
+		evs, ok := parser.Parse(line)
+		if onOutput == nil {
+			return
+		}
+		if !ok {
+			onOutput(line)
+			return
+		}
+		for _, ev := range evs {
+			for _, l := range RenderEvent(ev) {
+				onOutput(l)
+			}
+		}
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
-	}
-
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start claude: %w", err)
+		return nil, err
 	}
 
-	// Read output in goroutines
-	var outputBuf bytes.Buffer
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	go func() {
-		defer wg.Done()
-		e.readOutput(stdout, &outputBuf, onOutput)
-	}()
-
-	go func() {
-		defer wg.Done()
-		e.readOutput(stderr, &outputBuf, onOutput)
-	}()
-
-	wg.Wait()
-
-	err = cmd.Wait()
-
-	output := outputBuf.String()
-	result := &ExecutionResult{
-		Output:     output,
-		Iterations: ParseIterations(output),
-		Completed:  ContainsPromise(output, "COMPLETE") || ContainsPromise(output, "DONE"),
-		Error:      err,
-	}
-
-	return result, nil
+	final := parser.FinalMessage()
+	return &ExecutionResult{
+		Output:       output,
+		FinalMessage: final,
+		Events:       parser.Events(),
+		Usage:        parser.Usage(),
+		Completed:    IsComplete(final),
+		Error:        exitErr,
+	}, nil
 }
 
-func (e *ClaudeExecutor) readOutput(r io.Reader, buf *bytes.Buffer, callback OutputCallback) {
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := scanner.Text()
-		buf.WriteString(line + "\n")
-		if callback != nil {
-			callback(line)
-		}
+// outputResult builds the result for agents that only produce plain text,
+// where the whole output stands in for the final message
+func outputResult(output string, exitErr error) *ExecutionResult {
+	return &ExecutionResult{
+		Output:       output,
+		FinalMessage: output,
+		Completed:    IsComplete(output),
+		Error:        exitErr,
 	}
 }
 
-// ParseIterations extracts the current iteration number from output
-func ParseIterations(output string) int {
-	// Look for patterns like "[iteration 5]" or "Iteration: 5"
-	patterns := []*regexp.Regexp{
-		regexp.MustCompile(`\[iteration\s+(\d+)\]`),
-		regexp.MustCompile(`Iteration:\s*(\d+)`),
-		regexp.MustCompile(`iter\s+(\d+)`),
-	}
-
-	maxIter := 0
-	for _, pattern := range patterns {
-		matches := pattern.FindAllStringSubmatch(output, -1)
-		for _, match := range matches {
-			if len(match) >= 2 {
-				if iter, err := strconv.Atoi(match[1]); err == nil && iter > maxIter {
-					maxIter = iter
-				}
-			}
-		}
-	}
-
-	return maxIter
+// IsComplete reports whether an agent's final message declares the task done
+func IsComplete(message string) bool {
+	return ContainsPromise(message, "COMPLETE") || ContainsPromise(message, "DONE")
 }
 
-// ContainsPromise checks if output contains a promise tag with the given text
-func ContainsPromise(output, promiseText string) bool {
-	pattern := fmt.Sprintf(`<promise>%s</promise>`, regexp.QuoteMeta(promiseText))
-	matched, _ := regexp.MatchString(pattern, output)
-	return matched
+// ContainsPromise checks if text contains a promise tag with the given text
+func ContainsPromise(text, promiseText string) bool {
+	return strings.Contains(text, "<promise>"+promiseText+"</promise>")
 }
 
 // IsClaudeInstalled checks if claude CLI is available
@@ -1,10 +1,17 @@
 package executor
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ryan/ralph-o-matic/internal/models"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestClaudeExecutor_BuildEnv(t *testing.T) {
@@ -41,18 +48,10 @@ func TestClaudeExecutor_BuildCommand(t *testing.T) {
 
 	assert.Equal(t, "claude", cmd[0])
 	assert.Contains(t, cmd, "--print")
+	assert.Contains(t, cmd, "stream-json")
 	// Prompt should be passed via stdin, not command line
 }
 
-func TestClaudeExecutor_ParseOutput_Iteration(t *testing.T) {
-	output := `[iteration 5] Running tests...
-[iteration 5] Tests failed: 3 errors
-[iteration 5] Fixing auth.go`
-
-	iterations := ParseIterations(output)
-	assert.Equal(t, 5, iterations)
-}
-
 func TestClaudeExecutor_ParseOutput_Promise(t *testing.T) {
 	output := `All tests passing!
 <promise>COMPLETE</promise>`
@@ -108,3 +107,41 @@ func TestClaudeExecutor_BuildEnv_EmptyHost(t *testing.T) {
 	env := exec.BuildEnv(nil)
 	assert.Contains(t, env, "ANTHROPIC_BASE_URL=")
 }
+
+func TestClaudeExecutor_Execute_CancelKillsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are not supported on windows")
+	}
+
+	// A fake claude that ignores SIGTERM and spawns a child that does too
+	binDir := t.TempDir()
+	script := "#!/bin/sh\ntrap '' TERM\necho started\nsh -c \"trap '' TERM; sleep 30\" &\nwait\n"
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "claude"), []byte(script), 0o755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	exec := NewClaudeExecutor(models.DefaultServerConfig())
+	exec.killGrace = 100 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	var once sync.Once
+
+	done := make(chan *ExecutionResult, 1)
+	go func() {
+		result, err := exec.Execute(ctx, t.TempDir(), "prompt", nil, func(line string) {
+			once.Do(func() { close(started) })
+		})
+		assert.NoError(t, err)
+		done <- result
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case result := <-done:
+		assert.Error(t, result.Error)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Execute did not return after cancellation")
+	}
+}
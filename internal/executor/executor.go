@@ -0,0 +1,116 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// DefaultBackend is the executor used when neither the job nor the server
+// config names one
+const DefaultBackend = "claude"
+
+// ErrUnknownBackend is returned when a job or config names an executor that
+// is not registered
+var ErrUnknownBackend = errors.New("unknown executor backend")
+
+// Executor runs one iteration of a coding agent in a job's workspace.
+// Implementations must stop promptly when ctx is cancelled and report the
+// agent's own failures in ExecutionResult.Error rather than as an error
+// return, which is reserved for failing to run the agent at all.
+type Executor interface {
+	Execute(ctx context.Context, workDir, prompt string, env map[string]string, onOutput OutputCallback) (*ExecutionResult, error)
+}
+
+// ExecutionResult contains the results of running one iteration
+type ExecutionResult struct {
+	Output       string
+	FinalMessage string               // what the agent finished with; completion is judged on this
+	Events       []*models.AgentEvent // structured steps, for agents that report them
+	Usage        models.TokenUsage
+	Completed    bool
+	Error        error
+}
+
+// OutputCallback is called for each line of output
+type OutputCallback func(line string)
+
+// Factory builds an executor from the server config
+type Factory func(cfg *models.ServerConfig) Executor
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		"claude":   func(cfg *models.ServerConfig) Executor { return NewClaudeExecutor(cfg) },
+		"aider":    func(cfg *models.ServerConfig) Executor { return NewAiderExecutor(cfg) },
+		"opencode": func(cfg *models.ServerConfig) Executor { return NewOpencodeExecutor(cfg) },
+		"ollama":   func(cfg *models.ServerConfig) Executor { return NewOllamaExecutor(cfg) },
+	}
+)
+
+// Register makes an executor available by name, replacing any existing
+// registration. Tests use it to install a FakeExecutor.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = factory
+}
+
+// Backends returns the names of all registered executors, sorted
+func Backends() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasBackend reports whether an executor is registered under name. The
+// empty name is accepted and means "use the default".
+func HasBackend(name string) bool {
+	if name == "" {
+		return true
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := registry[name]
+	return ok
+}
+
+// New creates the executor registered under name, falling back to
+// DefaultBackend when name is empty
+func New(name string, cfg *models.ServerConfig) (Executor, error) {
+	if name == "" {
+		name = DefaultBackend
+	}
+
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownBackend, name)
+	}
+	return factory(cfg), nil
+}
+
+// BackendFor returns the executor name a job runs with: the job's own
+// choice, else the server default, else DefaultBackend
+func BackendFor(job *models.Job, cfg *models.ServerConfig) string {
+	if job.Executor != "" {
+		return job.Executor
+	}
+	if cfg.Executor != "" {
+		return cfg.Executor
+	}
+	return DefaultBackend
+}
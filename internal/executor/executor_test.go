@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackends(t *testing.T) {
+	backends := Backends()
+	for _, name := range []string{"aider", "claude", "ollama", "opencode"} {
+		assert.Contains(t, backends, name)
+	}
+	assert.IsIncreasing(t, backends)
+}
+
+func TestHasBackend(t *testing.T) {
+	assert.True(t, HasBackend(""))
+	assert.True(t, HasBackend("claude"))
+	assert.True(t, HasBackend("aider"))
+	assert.False(t, HasBackend("cursor"))
+}
+
+func TestNew(t *testing.T) {
+	cfg := models.DefaultServerConfig()
+
+	e, err := New("", cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &ClaudeExecutor{}, e)
+
+	e, err = New("opencode", cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &OpencodeExecutor{}, e)
+
+	_, err = New("cursor", cfg)
+	assert.ErrorIs(t, err, ErrUnknownBackend)
+}
+
+func TestRegister(t *testing.T) {
+	fake := NewFakeExecutor()
+	Register("test-register", func(*models.ServerConfig) Executor { return fake })
+
+	assert.True(t, HasBackend("test-register"))
+	e, err := New("test-register", models.DefaultServerConfig())
+	require.NoError(t, err)
+	assert.Same(t, fake, e)
+}
+
+func TestBackendFor(t *testing.T) {
+	cfg := models.DefaultServerConfig()
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+
+	assert.Equal(t, "claude", BackendFor(job, cfg))
+
+	cfg.Executor = "aider"
+	assert.Equal(t, "aider", BackendFor(job, cfg))
+
+	job.Executor = "ollama"
+	assert.Equal(t, "ollama", BackendFor(job, cfg))
+
+	cfg.Executor = ""
+	job.Executor = ""
+	assert.Equal(t, DefaultBackend, BackendFor(job, cfg))
+}
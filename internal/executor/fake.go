@@ -0,0 +1,102 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// FakeStep scripts one iteration of a FakeExecutor
+type FakeStep struct {
+	Output    []string          // lines reported through onOutput
+	Completed bool              // whether the iteration claims completion
+	Err       error             // reported as the agent's failure
+	Files     map[string]string // written into the workspace, relative paths
+	Delay     time.Duration     // how long the iteration takes
+	Events    []*models.AgentEvent
+	Usage     models.TokenUsage
+}
+
+// FakeCall records one invocation of a FakeExecutor
+type FakeCall struct {
+	WorkDir string
+	Prompt  string
+	Env     map[string]string
+}
+
+// FakeExecutor replays scripted steps instead of running an agent, one step
+// per call, repeating the last step once the script runs out. It lets the
+// ralph loop be exercised end to end without any model.
+type FakeExecutor struct {
+	mu    sync.Mutex
+	steps []FakeStep
+	calls []FakeCall
+}
+
+// NewFakeExecutor creates a fake executor that plays steps in order
+func NewFakeExecutor(steps ...FakeStep) *FakeExecutor {
+	return &FakeExecutor{steps: steps}
+}
+
+// Calls returns the invocations made so far
+func (e *FakeExecutor) Calls() []FakeCall {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]FakeCall(nil), e.calls...)
+}
+
+// Execute plays the next scripted step
+func (e *FakeExecutor) Execute(ctx context.Context, workDir, prompt string, env map[string]string, onOutput OutputCallback) (*ExecutionResult, error) {
+	e.mu.Lock()
+	var step FakeStep
+	if n := len(e.calls); n < len(e.steps) {
+		step = e.steps[n]
+	} else if len(e.steps) > 0 {
+		step = e.steps[len(e.steps)-1]
+	}
+	e.calls = append(e.calls, FakeCall{WorkDir: workDir, Prompt: prompt, Env: env})
+	e.mu.Unlock()
+
+	if step.Delay > 0 {
+		select {
+		case <-ctx.Done():
+			return &ExecutionResult{Error: ctx.Err()}, nil
+		case <-time.After(step.Delay):
+		}
+	}
+
+	for name, content := range step.Files {
+		path := filepath.Join(workDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+
+	for _, line := range step.Output {
+		if onOutput != nil {
+			onOutput(line)
+		}
+	}
+
+	output := strings.Join(step.Output, "\n")
+	if len(step.Output) > 0 {
+		output += "\n"
+	}
+	return &ExecutionResult{
+		Output:       output,
+		FinalMessage: output,
+		Events:       step.Events,
+		Usage:        step.Usage,
+		Completed:    step.Completed,
+		Error:        step.Err,
+	}, nil
+}
@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeExecutor_PlaysSteps(t *testing.T) {
+	boom := errors.New("boom")
+	fake := NewFakeExecutor(
+		FakeStep{Output: []string{"working"}, Files: map[string]string{"dir/out.txt": "one"}},
+		FakeStep{Err: boom},
+		FakeStep{Output: []string{"<promise>COMPLETE</promise>"}, Completed: true},
+	)
+	workDir := t.TempDir()
+
+	var lines []string
+	result, err := fake.Execute(context.Background(), workDir, "p1", nil, func(line string) {
+		lines = append(lines, line)
+	})
+	require.NoError(t, err)
+	assert.False(t, result.Completed)
+	assert.Equal(t, []string{"working"}, lines)
+	data, err := os.ReadFile(filepath.Join(workDir, "dir", "out.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "one", string(data))
+
+	result, err = fake.Execute(context.Background(), workDir, "p2", nil, nil)
+	require.NoError(t, err)
+	assert.ErrorIs(t, result.Error, boom)
+
+	// The last step repeats once the script runs out
+	for i := 0; i < 2; i++ {
+		result, err = fake.Execute(context.Background(), workDir, "p3", nil, nil)
+		require.NoError(t, err)
+		assert.True(t, result.Completed)
+	}
+
+	calls := fake.Calls()
+	require.Len(t, calls, 4)
+	assert.Equal(t, "p1", calls[0].Prompt)
+	assert.Equal(t, workDir, calls[0].WorkDir)
+}
+
+func TestFakeExecutor_HonorsCancel(t *testing.T) {
+	fake := NewFakeExecutor(FakeStep{Delay: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	result, err := fake.Execute(ctx, t.TempDir(), "p", nil, nil)
+	require.NoError(t, err)
+	assert.ErrorIs(t, result.Error, context.Canceled)
+}
@@ -0,0 +1,167 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ryan/ralph-o-matic/internal/git"
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/platform"
+)
+
+// DefaultContextBudget caps how many bytes of workspace files the ollama
+// executor sends to the model with each prompt
+const DefaultContextBudget = 256 * 1024
+
+const ollamaSystemPrompt = `You are an autonomous coding agent working in a git repository.
+You cannot run commands; you change the repository only by replying with
+unified diffs against the files shown, each inside a ` + "```diff" + ` fenced block
+with paths relative to the repository root (--- a/path, +++ b/path).
+Use /dev/null as the old path to create a file. Keep each diff minimal.
+When the task is fully complete, reply with <promise>COMPLETE</promise>.`
+
+var diffBlockPattern = regexp.MustCompile("(?s)```diff[^\\n]*\\n(.*?)```")
+
+// OllamaExecutor talks to the Ollama chat API directly, without a separate
+// agent CLI. It sends the task together with the workspace's files and
+// applies the diffs the model replies with.
+type OllamaExecutor struct {
+	config        *models.ServerConfig
+	contextBudget int
+}
+
+// NewOllamaExecutor creates a new ollama executor
+func NewOllamaExecutor(config *models.ServerConfig) *OllamaExecutor {
+	return &OllamaExecutor{config: config, contextBudget: DefaultContextBudget}
+}
+
+// Execute runs one chat round with the large model and applies its diffs.
+// env is ignored since no subprocess is started.
+func (e *OllamaExecutor) Execute(ctx context.Context, workDir, prompt string, env map[string]string, onOutput OutputCallback) (*ExecutionResult, error) {
+	files, err := e.workspaceContext(ctx, workDir)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []platform.ChatMessage{
+		{Role: "system", Content: ollamaSystemPrompt},
+		{Role: "user", Content: files + "\n## Task\n\n" + prompt},
+	}
+
+	lines := &lineWriter{onLine: onOutput}
+	client := platform.NewOllamaClient(e.config.Ollama.Host)
+	reply, err := client.Chat(ctx, e.config.LargeModel.Name, messages, lines.Write)
+	lines.Flush()
+	if err != nil {
+		if ctx.Err() != nil {
+			return &ExecutionResult{Output: reply, Error: ctx.Err()}, nil
+		}
+		return nil, err
+	}
+
+	result := outputResult(reply, nil)
+	for _, diff := range ExtractDiffs(reply) {
+		if err := applyDiff(ctx, workDir, diff); err != nil {
+			result.Error = err
+			break
+		}
+	}
+	return result, nil
+}
+
+// workspaceContext renders the tracked text files in workDir as markdown,
+// stopping once the context budget is used up
+func (e *OllamaExecutor) workspaceContext(ctx context.Context, workDir string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", "-C", workDir, "ls-files")
+	cmd.Env = git.HostEnv()
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to list workspace files: %w", err)
+	}
+
+	var b strings.Builder
+	b.WriteString("## Repository files\n")
+	var skipped []string
+	for _, name := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if name == "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(workDir, name))
+		if err != nil || bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+			continue // deleted, unreadable or binary
+		}
+		if b.Len()+len(data) > e.contextBudget {
+			skipped = append(skipped, name)
+			continue
+		}
+		fmt.Fprintf(&b, "\n### %s\n```\n%s\n```\n", name, data)
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(&b, "\nOther files (not shown): %s\n", strings.Join(skipped, ", "))
+	}
+	return b.String(), nil
+}
+
+// ExtractDiffs returns the contents of every ```diff block in a reply
+func ExtractDiffs(reply string) []string {
+	var diffs []string
+	for _, match := range diffBlockPattern.FindAllStringSubmatch(reply, -1) {
+		if strings.TrimSpace(match[1]) != "" {
+			diffs = append(diffs, match[1])
+		}
+	}
+	return diffs
+}
+
+// applyDiff applies a unified diff to the workspace. --recount tolerates
+// the wrong hunk line counts models often produce.
+func applyDiff(ctx context.Context, workDir, diff string) error {
+	cmd := exec.CommandContext(ctx, "git", "apply", "--whitespace=nowarn", "--recount", "-")
+	cmd.Dir = workDir
+	cmd.Env = git.HostEnv()
+	cmd.Stdin = strings.NewReader(diff)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to apply diff: %s: %w", strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// lineWriter turns streamed chunks of text into whole lines
+type lineWriter struct {
+	onLine  OutputCallback
+	pending strings.Builder
+}
+
+func (w *lineWriter) Write(chunk string) {
+	for {
+		i := strings.IndexByte(chunk, '\n')
+		if i < 0 {
+			w.pending.WriteString(chunk)
+			return
+		}
+		w.pending.WriteString(chunk[:i])
+		w.emit()
+		chunk = chunk[i+1:]
+	}
+}
+
+// Flush emits any partial last line
+func (w *lineWriter) Flush() {
+	if w.pending.Len() > 0 {
+		w.emit()
+	}
+}
+
+func (w *lineWriter) emit() {
+	if w.onLine != nil {
+		w.onLine(w.pending.String())
+	}
+	w.pending.Reset()
+}
@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newChatServer fakes Ollama's /api/chat, streaming reply in two chunks and
+// recording the last request
+func newChatServer(t *testing.T, reply string, got *map[string]interface{}) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(got)
+
+		half := len(reply) / 2
+		for _, chunk := range []string{reply[:half], reply[half:]} {
+			data, _ := json.Marshal(map[string]interface{}{
+				"message": map[string]string{"role": "assistant", "content": chunk},
+				"done":    false,
+			})
+			fmt.Fprintf(w, "%s\n", data)
+		}
+		fmt.Fprintln(w, `{"message":{"role":"assistant","content":""},"done":true}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOllamaExecutor_Execute_AppliesDiff(t *testing.T) {
+	workDir := newTestRepo(t, map[string]string{"hello.txt": "hello\n"})
+
+	reply := "Updating the greeting.\n```diff\n--- a/hello.txt\n+++ b/hello.txt\n@@ -1 +1 @@\n-hello\n+hello, world\n```\n<promise>COMPLETE</promise>\n"
+	var got map[string]interface{}
+	server := newChatServer(t, reply, &got)
+
+	cfg := models.DefaultServerConfig()
+	cfg.Ollama.Host = server.URL
+
+	var lines []string
+	exec := NewOllamaExecutor(cfg)
+	result, err := exec.Execute(context.Background(), workDir, "Greet the world", nil, func(line string) {
+		lines = append(lines, line)
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, result.Error)
+	assert.True(t, result.Completed)
+	assert.Equal(t, "qwen3-coder:70b", got["model"])
+	assert.Contains(t, lines, "Updating the greeting.")
+
+	// The workspace file was sent as context along with the task
+	messages := got["messages"].([]interface{})
+	user := messages[1].(map[string]interface{})["content"].(string)
+	assert.Contains(t, user, "### hello.txt")
+	assert.Contains(t, user, "Greet the world")
+
+	data, err := os.ReadFile(filepath.Join(workDir, "hello.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello, world\n", string(data))
+}
+
+func TestOllamaExecutor_Execute_BadDiff(t *testing.T) {
+	workDir := newTestRepo(t, map[string]string{"hello.txt": "hello\n"})
+
+	reply := "```diff\n--- a/missing.txt\n+++ b/missing.txt\n@@ -1 +1 @@\n-nope\n+yes\n```\n"
+	var got map[string]interface{}
+	server := newChatServer(t, reply, &got)
+
+	cfg := models.DefaultServerConfig()
+	cfg.Ollama.Host = server.URL
+
+	result, err := NewOllamaExecutor(cfg).Execute(context.Background(), workDir, "task", nil, nil)
+	require.NoError(t, err)
+
+	assert.False(t, result.Completed)
+	require.Error(t, result.Error)
+	assert.Contains(t, result.Error.Error(), "failed to apply diff")
+}
+
+func TestOllamaExecutor_ContextBudget(t *testing.T) {
+	workDir := newTestRepo(t, map[string]string{
+		"a.txt": "small\n",
+		"b.txt": "0123456789012345678901234567890123456789\n",
+	})
+
+	exec := NewOllamaExecutor(models.DefaultServerConfig())
+	exec.contextBudget = 60
+
+	files, err := exec.workspaceContext(context.Background(), workDir)
+	require.NoError(t, err)
+	assert.Contains(t, files, "### a.txt")
+	assert.NotContains(t, files, "### b.txt")
+	assert.Contains(t, files, "not shown): b.txt")
+}
+
+func TestExtractDiffs(t *testing.T) {
+	reply := "first\n```diff\n--- a/x\n+++ b/x\n```\ntext\n```go\nfunc x() {}\n```\n```diff\n--- a/y\n+++ b/y\n```\n"
+
+	diffs := ExtractDiffs(reply)
+	require.Len(t, diffs, 2)
+	assert.Contains(t, diffs[0], "a/x")
+	assert.Contains(t, diffs[1], "a/y")
+}
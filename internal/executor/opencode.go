@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// OpencodeExecutor runs opencode against the configured Ollama models
+type OpencodeExecutor struct {
+	config    *models.ServerConfig
+	killGrace time.Duration
+}
+
+// NewOpencodeExecutor creates a new opencode executor
+func NewOpencodeExecutor(config *models.ServerConfig) *OpencodeExecutor {
+	return &OpencodeExecutor{config: config, killGrace: DefaultKillGrace}
+}
+
+// BuildConfig returns an opencode config that registers Ollama as an
+// OpenAI-compatible provider serving the large and small models
+func (e *OpencodeExecutor) BuildConfig() ([]byte, error) {
+	modelNames := map[string]interface{}{
+		e.config.LargeModel.Name: map[string]interface{}{},
+	}
+	if e.config.SmallModel.Name != "" {
+		modelNames[e.config.SmallModel.Name] = map[string]interface{}{}
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"$schema": "https://opencode.ai/config.json",
+		"provider": map[string]interface{}{
+			"ollama": map[string]interface{}{
+				"npm":  "@ai-sdk/openai-compatible",
+				"name": "Ollama",
+				"options": map[string]interface{}{
+					"baseURL": strings.TrimSuffix(e.config.Ollama.Host, "/") + "/v1",
+				},
+				"models": modelNames,
+			},
+		},
+	}, "", "  ")
+}
+
+// BuildEnv creates the environment variables for opencode, pointing it at
+// the generated config file
+func (e *OpencodeExecutor) BuildEnv(configFile string, extra map[string]string) []string {
+	return buildEnv(map[string]string{
+		"OPENCODE_CONFIG": configFile,
+	}, extra)
+}
+
+// BuildCommand creates the opencode command arguments
+func (e *OpencodeExecutor) BuildCommand(prompt string) []string {
+	return []string{
+		"opencode", "run",
+		"--model", "ollama/" + e.config.LargeModel.Name,
+		prompt,
+	}
+}
+
+// Execute runs opencode once with the given prompt
+func (e *OpencodeExecutor) Execute(ctx context.Context, workDir, prompt string, env map[string]string, onOutput OutputCallback) (*ExecutionResult, error) {
+	data, err := e.BuildConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build opencode config: %w", err)
+	}
+	configFile, err := writeTempFile("ralph-opencode-*.json", string(data))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(configFile)
+
+	args := e.BuildCommand(prompt)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = workDir
+	cmd.Env = e.BuildEnv(configFile, env)
+
+	output, exitErr, err := runProcess(ctx, cmd, e.killGrace, onOutput, configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return outputResult(output, exitErr), nil
+}
@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpencodeExecutor_BuildCommand(t *testing.T) {
+	exec := NewOpencodeExecutor(models.DefaultServerConfig())
+
+	cmd := exec.BuildCommand("Write tests")
+
+	assert.Equal(t, []string{"opencode", "run", "--model", "ollama/qwen3-coder:70b", "Write tests"}, cmd)
+}
+
+func TestOpencodeExecutor_BuildConfig(t *testing.T) {
+	cfg := models.DefaultServerConfig()
+	cfg.Ollama.Host = "http://remote:11434/"
+	exec := NewOpencodeExecutor(cfg)
+
+	data, err := exec.BuildConfig()
+	require.NoError(t, err)
+
+	var parsed struct {
+		Provider map[string]struct {
+			Options struct {
+				BaseURL string `json:"baseURL"`
+			} `json:"options"`
+			Models map[string]interface{} `json:"models"`
+		} `json:"provider"`
+	}
+	require.NoError(t, json.Unmarshal(data, &parsed))
+
+	ollama := parsed.Provider["ollama"]
+	assert.Equal(t, "http://remote:11434/v1", ollama.Options.BaseURL)
+	assert.Contains(t, ollama.Models, "qwen3-coder:70b")
+	assert.Contains(t, ollama.Models, "qwen2.5-coder:7b")
+}
+
+func TestOpencodeExecutor_BuildEnv(t *testing.T) {
+	exec := NewOpencodeExecutor(models.DefaultServerConfig())
+
+	env := exec.BuildEnv("/tmp/opencode.json", nil)
+
+	assert.Contains(t, env, "OPENCODE_CONFIG=/tmp/opencode.json")
+}
@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/platform"
+	"github.com/ryan/ralph-o-matic/internal/queue"
+)
+
+// preparePingTimeout bounds the check that a job's Ollama host answers
+const preparePingTimeout = 10 * time.Second
+
+// Prepare makes a job ready to run: the Ollama host it runs against must
+// answer and have every model the job needs. Missing models are pulled,
+// with progress in the job's logs. The scheduler holds the job in preparing
+// until Prepare returns and fails it if Prepare does.
+func (h *RalphHandler) Prepare(ctx context.Context, job *models.Job) error {
+	server := h.Config()
+	host := job.Config(server).Ollama.Host
+	client := platform.NewOllamaClient(host)
+
+	pingCtx, cancel := context.WithTimeout(ctx, preparePingTimeout)
+	err := client.Ping(pingCtx)
+	cancel()
+	if err != nil {
+		return queue.AgentError(fmt.Errorf("Ollama at %s is not reachable: %w", host, err))
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range job.Models(server) {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		has, err := client.HasModel(ctx, name)
+		if err != nil {
+			return queue.AgentError(fmt.Errorf("failed to check Ollama at %s for %s: %w", host, name, err))
+		}
+		if has {
+			continue
+		}
+
+		h.appendLog(job, fmt.Sprintf("Model %s is not on %s; pulling it", name, host))
+		if err := client.PullModelProgress(ctx, name, h.pullProgress(job, name)); err != nil {
+			h.appendLog(job, fmt.Sprintf("Pulling %s failed: %v", name, err))
+			return queue.AgentError(err)
+		}
+		h.appendLog(job, fmt.Sprintf("Pulled %s", name))
+	}
+	return nil
+}
+
+// pullProgress logs a model pull's progress to the job: each new status,
+// and downloads in steps of 10%
+func (h *RalphHandler) pullProgress(job *models.Job, name string) func(platform.PullProgress) {
+	var status string
+	var logged int64
+	return func(p platform.PullProgress) {
+		if p.Status != status {
+			status, logged = p.Status, -1
+			if p.Total == 0 {
+				h.appendLog(job, fmt.Sprintf("Pulling %s: %s", name, p.Status))
+			}
+		}
+		if p.Total == 0 {
+			return
+		}
+		if percent := p.Completed * 100 / p.Total / 10 * 10; percent > logged {
+			logged = percent
+			h.appendLog(job, fmt.Sprintf("Pulling %s: %s %d%% of %s", name, p.Status, percent, formatBytes(p.Total)))
+		}
+	}
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. "4.2 GiB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
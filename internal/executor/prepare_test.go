@@ -0,0 +1,132 @@
+package executor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/queue"
+)
+
+// fakeOllama answers /api/show for the installed models and streams pull
+// progress for the rest, installing them, or failing with pullErr if set
+type fakeOllama struct {
+	mu        sync.Mutex
+	installed map[string]bool
+	pulled    []string
+	pullErr   string
+}
+
+func (f *fakeOllama) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var body map[string]string
+	json.NewDecoder(r.Body).Decode(&body)
+
+	switch r.URL.Path {
+	case "/api/tags":
+		w.Write([]byte(`{"models":[]}`))
+	case "/api/show":
+		if !f.installed[body["name"]] {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	case "/api/pull":
+		f.pulled = append(f.pulled, body["name"])
+		enc := json.NewEncoder(w)
+		enc.Encode(map[string]interface{}{"status": "pulling manifest"})
+		if f.pullErr != "" {
+			enc.Encode(map[string]string{"error": f.pullErr})
+			return
+		}
+		for _, done := range []int{0, 40, 45, 100} {
+			enc.Encode(map[string]interface{}{"status": "pulling abc", "total": 100 << 20, "completed": done << 20})
+		}
+		enc.Encode(map[string]interface{}{"status": "success"})
+		f.installed[body["name"]] = true
+	}
+}
+
+func prepareJob(t *testing.T, database *db.DB, host string) *models.Job {
+	t.Helper()
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	job.OllamaHost = host
+	require.NoError(t, db.NewJobRepo(database).Create(job))
+	return job
+}
+
+func jobLogs(t *testing.T, database *db.DB, jobID int64) []string {
+	t.Helper()
+	logs, err := db.NewLogRepo(database).GetForJob(jobID)
+	require.NoError(t, err)
+	lines := make([]string, len(logs))
+	for i, l := range logs {
+		lines[i] = l.Message
+	}
+	return lines
+}
+
+func TestRalphHandler_Prepare_PullsMissingModels(t *testing.T) {
+	ollama := &fakeOllama{installed: map[string]bool{"qwen2.5-coder:7b": true}}
+	server := httptest.NewServer(ollama)
+	defer server.Close()
+
+	database := newTestDB(t)
+	job := prepareJob(t, database, server.URL)
+	handler := NewRalphHandler(database, models.DefaultServerConfig(), t.TempDir())
+
+	require.NoError(t, handler.Prepare(context.Background(), job))
+	assert.Equal(t, []string{"qwen3-coder:70b"}, ollama.pulled, "only the missing model is pulled")
+
+	assert.Equal(t, []string{
+		"Model qwen3-coder:70b is not on " + server.URL + "; pulling it",
+		"Pulling qwen3-coder:70b: pulling manifest",
+		"Pulling qwen3-coder:70b: pulling abc 0% of 100.0 MiB",
+		"Pulling qwen3-coder:70b: pulling abc 40% of 100.0 MiB",
+		"Pulling qwen3-coder:70b: pulling abc 100% of 100.0 MiB",
+		"Pulling qwen3-coder:70b: success",
+		"Pulled qwen3-coder:70b",
+	}, jobLogs(t, database, job.ID))
+
+	// Once the models are there, preparing is a quiet check
+	require.NoError(t, handler.Prepare(context.Background(), job))
+	assert.Len(t, ollama.pulled, 1)
+}
+
+func TestRalphHandler_Prepare_PullFails(t *testing.T) {
+	ollama := &fakeOllama{installed: map[string]bool{}, pullErr: "pull model manifest: file does not exist"}
+	server := httptest.NewServer(ollama)
+	defer server.Close()
+
+	database := newTestDB(t)
+	job := prepareJob(t, database, server.URL)
+	handler := NewRalphHandler(database, models.DefaultServerConfig(), t.TempDir())
+
+	err := handler.Prepare(context.Background(), job)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "qwen3-coder:70b")
+	assert.Contains(t, err.Error(), "file does not exist")
+	assert.Len(t, ollama.pulled, 1, "the job fails on the first model that cannot be pulled")
+}
+
+func TestRalphHandler_Prepare_HostDown(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	database := newTestDB(t)
+	job := prepareJob(t, database, server.URL)
+	handler := NewRalphHandler(database, models.DefaultServerConfig(), t.TempDir())
+
+	err := handler.Prepare(context.Background(), job)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not reachable")
+	assert.ErrorIs(t, err, queue.ErrAgentFailed, "the scheduler may blame the endpoint")
+}
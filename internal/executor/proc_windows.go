@@ -0,0 +1,21 @@
+//go:build windows
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows, which has no process groups
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcessGroup kills the process; Windows has no SIGTERM
+func terminateProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
+
+// killProcessGroup kills the process
+func killProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
@@ -0,0 +1,147 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runProcess starts cmd in its own process group, streams its stdout and
+// stderr line by line to onOutput, and waits for it to exit. Cancelling ctx
+// sends SIGTERM to the group, followed by SIGKILL once killGrace has passed.
+// If ctx carries a sandbox, cmd runs in it with mounts, the files outside
+// its working directory that it reads, mounted read-only. The returned error
+// covers failures to start; the exit status is returned separately so
+// callers can record it as an iteration result.
+func runProcess(ctx context.Context, cmd *exec.Cmd, killGrace time.Duration, onOutput OutputCallback, mounts ...string) (output string, exitErr error, err error) {
+	if jail := sandboxFrom(ctx); jail != nil {
+		cleanup, err := jail.Wrap(cmd, mounts...)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to sandbox %s: %w", cmd.Args[0], err)
+		}
+		defer cleanup()
+	}
+	setProcessGroup(cmd)
+	var killTimer *time.Timer
+	cmd.Cancel = func() error {
+		killTimer = time.AfterFunc(killGrace, func() {
+			_ = killProcessGroup(cmd.Process)
+		})
+		return terminateProcessGroup(cmd.Process)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return "", nil, fmt.Errorf("failed to start %s: %w", cmd.Args[0], err)
+	}
+
+	// Read output in goroutines
+	var outputBuf bytes.Buffer
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		readOutput(stdout, &outputBuf, &mu, onOutput)
+	}()
+
+	go func() {
+		defer wg.Done()
+		readOutput(stderr, &outputBuf, &mu, onOutput)
+	}()
+
+	wg.Wait()
+
+	exitErr = cmd.Wait()
+	if killTimer != nil {
+		killTimer.Stop()
+	}
+
+	return outputBuf.String(), exitErr, nil
+}
+
+func readOutput(r io.Reader, buf *bytes.Buffer, mu *sync.Mutex, callback OutputCallback) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		mu.Lock()
+		buf.WriteString(line + "\n")
+		if callback != nil {
+			callback(line)
+		}
+		mu.Unlock()
+	}
+}
+
+// inheritedEnv lists the variables of the server's environment agents
+// inherit. Anything else, such as the server's own credentials, is dropped;
+// jobs pass what they need through their env and secrets.
+var inheritedEnv = map[string]bool{
+	"PATH": true, "HOME": true, "USER": true, "LOGNAME": true, "SHELL": true,
+	"LANG": true, "LANGUAGE": true, "TERM": true, "TZ": true, "TMPDIR": true,
+	"SSL_CERT_FILE": true, "SSL_CERT_DIR": true,
+	"HTTP_PROXY": true, "HTTPS_PROXY": true, "NO_PROXY": true,
+	"http_proxy": true, "https_proxy": true, "no_proxy": true,
+	// Needed for processes to start on Windows
+	"SystemRoot": true, "SystemDrive": true, "ComSpec": true, "PATHEXT": true,
+	"TEMP": true, "TMP": true, "USERPROFILE": true, "APPDATA": true, "LOCALAPPDATA": true,
+}
+
+// scrubEnv returns the entries of environ that agents may inherit
+func scrubEnv(environ []string) []string {
+	var env []string
+	for _, entry := range environ {
+		name, _, _ := strings.Cut(entry, "=")
+		if inheritedEnv[name] || strings.HasPrefix(name, "LC_") {
+			env = append(env, entry)
+		}
+	}
+	return env
+}
+
+// buildEnv returns the allowed part of the server's environment followed by
+// the agent's own settings and then the job's extra variables, so later
+// entries win
+func buildEnv(agent, extra map[string]string) []string {
+	env := scrubEnv(os.Environ())
+	for k, v := range agent {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	for k, v := range extra {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+	return env
+}
+
+// ExitCode returns the exit status an agent or command error represents: 0
+// for nil, the process's code when it exited, and -1 for anything else
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		return ee.ExitCode()
+	}
+	return -1
+}
@@ -2,93 +2,639 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/ryan/ralph-o-matic/internal/db"
 	"github.com/ryan/ralph-o-matic/internal/git"
 	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/queue"
+	"github.com/ryan/ralph-o-matic/internal/sandbox"
 )
 
+// ErrBudgetExhausted is the cause of a job stopping because it used up its
+// max_duration or max_tokens budget
+var ErrBudgetExhausted = errors.New("budget exhausted")
+
+// errIterationTimeout cancels an agent run that outlasts the job's
+// per_iteration_timeout
+var errIterationTimeout = errors.New("iteration timed out")
+
+// finalizeTimeout bounds pushing and opening the PR for a job whose own
+// context has already run out
+const finalizeTimeout = 5 * time.Minute
+
 // RalphHandler implements the ralph loop execution
 type RalphHandler struct {
-	db          *db.DB
-	config      *models.ServerConfig
-	repoManager *git.RepoManager
-	executor    *ClaudeExecutor
-	jobRepo     *db.JobRepo
-	logRepo     *db.LogRepo
+	db            *db.DB
+	repoManager   *git.RepoManager
+	jobRepo       *db.JobRepo
+	logRepo       *db.LogRepo
+	eventRepo     *db.AgentEventRepo
+	iterationRepo *db.IterationRepo
+	secrets       *db.SecretRepo
+
+	mu        sync.Mutex
+	config    *models.ServerConfig       // see Config
+	redactors map[int64]*models.Redactor // hides the secrets of running jobs in their logs
 }
 
 // NewRalphHandler creates a new ralph handler
 func NewRalphHandler(database *db.DB, config *models.ServerConfig, workspaceDir string) *RalphHandler {
 	return &RalphHandler{
-		db:          database,
-		config:      config,
-		repoManager: git.NewRepoManager(workspaceDir),
-		executor:    NewClaudeExecutor(config),
-		jobRepo:     db.NewJobRepo(database),
-		logRepo:     db.NewLogRepo(database),
+		db:            database,
+		config:        config,
+		repoManager:   git.NewRepoManager(workspaceDir),
+		jobRepo:       db.NewJobRepo(database),
+		logRepo:       db.NewLogRepo(database),
+		eventRepo:     db.NewAgentEventRepo(database),
+		iterationRepo: db.NewIterationRepo(database),
+		redactors:     make(map[int64]*models.Redactor),
 	}
 }
 
-// Handle executes the ralph loop for a job
+// Config returns the server config jobs start with
+func (h *RalphHandler) Config() *models.ServerConfig {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.config
+}
+
+// SetConfig replaces the server config. Running jobs keep the sandbox they
+// started in; the change applies to jobs started afterwards.
+func (h *RalphHandler) SetConfig(config *models.ServerConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.config = config
+}
+
+// SetSecrets sets the store the secrets jobs name are read from
+func (h *RalphHandler) SetSecrets(secrets *db.SecretRepo) {
+	h.secrets = secrets
+}
+
+// Handle executes the ralph loop for a job. Each iteration runs the job's
+// executor once and commits the result; the loop ends when the model emits a
+// completion promise, the iteration cap is reached, the job stalls or runs
+// out of budget, or the job is paused or cancelled.
 func (h *RalphHandler) Handle(ctx context.Context, job *models.Job) error {
-	log.Printf("Starting ralph loop for job %d: %s", job.ID, job.Branch)
+	previous, err := h.iterationRepo.ListForJob(job.ID)
+	if err != nil {
+		return err
+	}
+
+	env, err := h.jobEnv(job)
+	if err != nil {
+		return err
+	}
+	defer h.forget(job)
 
-	// Setup workspace
-	workDir, err := h.repoManager.Setup(ctx, job.ID, job.RepoURL, job.Branch)
+	// Settings changed through the API apply from the next job on; a running
+	// job keeps the config it started with
+	server := h.Config()
+	backend := BackendFor(job, server)
+	stage, onStage := resumeStage(job.ModelPlan, previous)
+	cfg := configFor(server, job, stage)
+	jail, err := h.openSandbox(job, cfg)
 	if err != nil {
+		return err
+	}
+	if jail != nil {
+		defer jail.Close()
+		ctx = withSandbox(ctx, jail)
+	}
+	agent, err := newAgent(backend, cfg, jail)
+	if err != nil {
+		return err
+	}
+	log.Printf("Starting ralph loop for job %d: %s (executor %s, model %s)", job.ID, job.Branch, backend, cfg.LargeModel.Name)
+	if job.Endpoint != "" {
+		h.appendLog(job, fmt.Sprintf("Running against Ollama at %s", job.Endpoint))
+	}
+
+	// Setup workspace, reusing it when resuming a job that already has progress
+	if job.Iteration > 0 && h.repoManager.HasWorkspace(job.ID) {
+		log.Printf("Resuming job %d from iteration %d", job.ID, job.Iteration)
+	} else if _, err := h.repoManager.Setup(ctx, job.ID, job.RepoURL, job.Branch); err != nil {
+		if ctx.Err() != nil {
+			return h.stop(ctx, job, "", nil, nil)
+		}
 		return fmt.Errorf("failed to setup workspace: %w", err)
 	}
+	workDir := h.jobDir(job)
+
+	// Budgets carry over from earlier runs of a resumed job
+	elapsed, used := spent(previous)
+	if job.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, job.MaxDuration-elapsed,
+			fmt.Errorf("%w: max_duration of %s reached", ErrBudgetExhausted, job.MaxDuration))
+		defer cancel()
+	}
+
+	failures := 0
+	var verification *models.Verification // last result of the verify command
+	var feedback *models.Verification     // failed verification to show the next iteration
+	var hint *models.Stall                // stall to warn the next iteration about
+	hinted := false
+	window, policy := stallSettings(job)
+	var history []*models.Iteration // iterations of this run compared for stalls
+	for {
+		// Honor pause/cancel requests and edits made while the last
+		// iteration ran
+		stopped, err := h.checkInterrupted(job)
+		if err != nil {
+			return err
+		}
+		if stopped {
+			log.Printf("Job %d %s after %d iterations", job.ID, job.Status, job.Iteration)
+			return nil
+		}
+		if !shouldContinue(job) {
+			break
+		}
+		if ctx.Err() != nil {
+			return h.stop(ctx, job, workDir, nil, verification)
+		}
+		if job.MaxTokens > 0 && used >= job.MaxTokens {
+			return h.exhausted(ctx, job, verification,
+				fmt.Errorf("%w: max_tokens of %d reached (%d used)", ErrBudgetExhausted, job.MaxTokens, used))
+		}
+
+		h.updateIteration(job, job.Iteration+1)
+		h.appendLog(job, fmt.Sprintf("=== Iteration %d/%d ===", job.Iteration, job.MaxIterations))
+		it := models.NewIteration(job.ID, job.Iteration)
+		it.Model = cfg.LargeModel.Name
+		h.saveIteration(it)
+
+		prompt := job.Prompt
+		if feedback != nil {
+			prompt = VerificationFeedback(prompt, feedback)
+			feedback = nil
+		}
+		if hint != nil {
+			prompt = StallHint(prompt, hint)
+			hint = nil
+		}
+
+		iterCtx, cancelIteration := ctx, context.CancelFunc(func() {})
+		if job.PerIterationTimeout > 0 {
+			iterCtx, cancelIteration = context.WithTimeoutCause(ctx, job.PerIterationTimeout, errIterationTimeout)
+		}
+		result, err := agent.Execute(iterCtx, workDir, prompt, env, func(line string) {
+			h.appendLog(job, line)
+		})
+		timedOut := ctx.Err() == nil && errors.Is(context.Cause(iterCtx), errIterationTimeout)
+		cancelIteration()
+		if err != nil {
+			it.ExitCode = -1
+			it.Error = h.redact(job, err.Error())
+			h.finishIteration(it)
+			return queue.AgentError(fmt.Errorf("%s execution failed: %w", backend, err))
+		}
+		if timedOut {
+			// Whatever the agent was doing was cut off; keep its partial work
+			// but not its claim
+			result.Completed = false
+			result.Error = fmt.Errorf("timed out after %s", job.PerIterationTimeout)
+		}
+		it.ExitCode = ExitCode(result.Error)
+		it.Completed = result.Completed
+		it.Usage = result.Usage
+		if result.Error != nil {
+			it.Error = h.redact(job, result.Error.Error())
+		}
+		for _, e := range result.Events {
+			e.Content = h.redact(job, e.Content)
+		}
+		if err := h.eventRepo.Append(job.ID, job.Iteration, result.Events); err != nil {
+			log.Printf("Failed to store agent events for job %d: %v", job.ID, err)
+		}
+		used += result.Usage.Total()
+		if ctx.Err() != nil {
+			return h.stop(ctx, job, workDir, it, verification)
+		}
+
+		if hash := h.commit(ctx, job, it, fmt.Sprintf("Ralph iteration %d", job.Iteration)); hash != "" {
+			h.appendLog(job, fmt.Sprintf("Committed iteration %d as %s (%d files, +%d -%d)", job.Iteration, hash, it.FilesChanged, it.Insertions, it.Deletions))
+		}
+
+		var verified *models.Verification // verification run this iteration, if any
+		if result.Completed && job.VerifyCommand != "" {
+			verified, err = h.verify(ctx, job, workDir, env)
+			if err != nil {
+				h.finishIteration(it)
+				return err
+			}
+			if ctx.Err() != nil {
+				return h.stop(ctx, job, workDir, it, verification)
+			}
+			verification = verified
+			it.VerifyPassed = &verified.Passed
+		}
+		h.fingerprint(ctx, job, it, result, verified)
+		h.finishIteration(it)
+
+		if result.Completed && (verified == nil || verified.Passed) {
+			log.Printf("Job %d completed successfully after %d iterations with %s", job.ID, job.Iteration, cfg.LargeModel.Name)
+			return h.finalize(ctx, job, true, "", verification)
+		}
+
+		switch {
+		case verified != nil:
+			// The claim was premature; show the agent why and keep going
+			feedback = verified
+			failures = 0
+		case result.Error != nil:
+			failures++
+			h.appendLog(job, fmt.Sprintf("%s exited with error: %v", backend, result.Error))
+		default:
+			failures = 0
+		}
+
+		// Move up the model plan once the current stage has had its turn
+		onStage++
+		if stage+1 < len(job.ModelPlan) && job.ModelPlan[stage].Exhausted(onStage, failures) {
+			why := fmt.Sprintf("%d iterations without completing", onStage)
+			if failures > 0 {
+				why = fmt.Sprintf("%d failed iterations in a row", failures)
+			}
+			if agent, cfg, err = h.escalate(job, server, backend, stage, why, jail); err != nil {
+				return err
+			}
+			stage, onStage, failures = stage+1, 0, 0
+			history, hinted = nil, false
+			continue
+		}
+		if failures > server.MaxClaudeRetries {
+			return queue.AgentError(fmt.Errorf("%s failed %d iterations in a row: %w", backend, failures, result.Error))
+		}
+
+		history = append(history, it)
+		stall := DetectStall(history, window)
+		if stall == nil {
+			continue
+		}
+		h.appendLog(job, fmt.Sprintf("Stalled: %s", stall.Reason))
+
+		// The escalate policy moves up the plan even from stages that do not
+		// switch on stalls themselves
+		if stage+1 < len(job.ModelPlan) && (job.ModelPlan[stage].OnStall || policy == models.StallPolicyEscalate) {
+			if agent, cfg, err = h.escalate(job, server, backend, stage, "stalled", jail); err != nil {
+				return err
+			}
+			stage, onStage, failures = stage+1, 0, 0
+			history, hinted = nil, false
+			continue
+		}
+
+		switch {
+		case policy == models.StallPolicyHint && !hinted:
+			// Warn the agent once and give it a fresh window to recover
+			h.appendLog(job, "Telling the agent it is stuck")
+			hint = stall
+			hinted = true
+			history = nil
+		case policy == models.StallPolicyEscalate:
+			// No model is left to escalate to
+			return fmt.Errorf("%w: stalled: %s", queue.ErrNeedsAttention, stall.Reason)
+		default:
+			log.Printf("Job %d stalled after %d iterations: %s", job.ID, job.Iteration, stall.Reason)
+			reason := fmt.Sprintf("stalled: %s", stall.Reason)
+			if err := h.finalize(ctx, job, false, reason, verification); err != nil {
+				return err
+			}
+			return errors.New(reason)
+		}
+	}
+
+	log.Printf("Job %d reached max iterations (%d)", job.ID, job.MaxIterations)
+	reason := fmt.Sprintf("reached max iterations (%d) without completing", job.MaxIterations)
+	if err := h.finalize(ctx, job, false, reason, verification); err != nil {
+		return err
+	}
+	return errors.New(reason)
+}
+
+// stop winds down a job whose context was cancelled by the scheduler or ran
+// out of time. Work in progress is committed when the job is expected to
+// continue later (pause, shutdown or a lost endpoint) or is about to be
+// offered as a PR (budget exhausted); a paused or cancelled job returns nil
+// with its status synced. it is the interrupted iteration, if one was running.
+func (h *RalphHandler) stop(ctx context.Context, job *models.Job, workDir string, it *models.Iteration, verification *models.Verification) error {
+	cause := context.Cause(ctx)
+	exhausted := errors.Is(cause, ErrBudgetExhausted)
+
+	resumable := errors.Is(cause, queue.ErrJobPaused) || errors.Is(cause, queue.ErrShutdown) || errors.Is(cause, queue.ErrEndpointLost)
+	if workDir != "" && (resumable || exhausted) {
+		commitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+
+		if hash := h.commit(commitCtx, job, it, fmt.Sprintf("Ralph iteration %d (interrupted)", job.Iteration)); hash != "" {
+			h.appendLog(job, fmt.Sprintf("Committed partial iteration %d as %s", job.Iteration, hash))
+		}
+	}
+	if it != nil {
+		it.Error = fmt.Sprintf("interrupted: %v", cause)
+		h.finishIteration(it)
+	}
+
+	if errors.Is(cause, queue.ErrJobPaused) || errors.Is(cause, queue.ErrJobCancelled) {
+		h.appendLog(job, fmt.Sprintf("Stopped during iteration %d: %v", job.Iteration, cause))
+		if _, err := h.checkInterrupted(job); err != nil {
+			return err
+		}
+		return nil
+	}
+	if exhausted && workDir != "" {
+		return h.exhausted(ctx, job, verification, cause)
+	}
+	if errors.Is(cause, queue.ErrEndpointLost) {
+		h.appendLog(job, fmt.Sprintf("Stopped during iteration %d: %v; moving to another endpoint", job.Iteration, cause))
+	}
+
+	return cause
+}
+
+// exhausted fails a job that ran out of budget after opening a PR with the
+// work it has done so far
+func (h *RalphHandler) exhausted(ctx context.Context, job *models.Job, verification *models.Verification, cause error) error {
+	h.appendLog(job, fmt.Sprintf("Stopping after iteration %d: %v", job.Iteration, cause))
+	log.Printf("Job %d stopped: %v", job.ID, cause)
+
+	finalCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), finalizeTimeout)
+	defer cancel()
+	if err := h.finalize(finalCtx, job, false, cause.Error(), verification); err != nil {
+		return err
+	}
+	return cause
+}
+
+// spent returns the running time and tokens used by a job's finished
+// iterations, which count against its budgets
+func spent(iterations []*models.Iteration) (time.Duration, int64) {
+	var elapsed time.Duration
+	var used int64
+	for _, it := range iterations {
+		if it.FinishedAt != nil {
+			elapsed += it.Duration()
+		}
+		used += it.Usage.Total()
+	}
+	return elapsed, used
+}
+
+// resumeStage returns the stage of plan a job was on when it stopped, judged
+// by the model of its last iteration, and how many iterations ran on it
+func resumeStage(plan models.ModelPlan, iterations []*models.Iteration) (stage, onStage int) {
+	if len(plan) == 0 || len(iterations) == 0 {
+		return 0, 0
+	}
+
+	last := iterations[len(iterations)-1].Model
+	for i := range plan {
+		if plan[i].Name == last {
+			stage = i
+		}
+	}
+	for i := len(iterations) - 1; i >= 0 && iterations[i].Model == last; i-- {
+		onStage++
+	}
+	return stage, onStage
+}
+
+// configFor returns the config a job's executor is built with: server with
+// the job's overrides applied, and the large model taken from the given stage
+// of the job's model plan
+func configFor(server *models.ServerConfig, job *models.Job, stage int) *models.ServerConfig {
+	base := job.Config(server)
+	if len(job.ModelPlan) == 0 {
+		return base
+	}
+	cfg := *base
+	cfg.LargeModel = job.ModelPlan[stage].ModelPlacement
+	return &cfg
+}
 
-	// Determine working directory
+// escalate builds the executor for the stage after stage in the job's model
+// plan, logging why the job is moving on
+func (h *RalphHandler) escalate(job *models.Job, server *models.ServerConfig, backend string, stage int, why string, jail *sandbox.Session) (Executor, *models.ServerConfig, error) {
+	cfg := configFor(server, job, stage+1)
+	agent, err := newAgent(backend, cfg, jail)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h.appendLog(job, fmt.Sprintf("Switching model from %s to %s: %s", job.ModelPlan[stage].Name, cfg.LargeModel.Name, why))
+	log.Printf("Job %d escalated to %s: %s", job.ID, cfg.LargeModel.Name, why)
+	return agent, cfg, nil
+}
+
+// openSandbox confines the job's agent and verify command if the server has
+// a sandbox configured, letting them reach only the job's Ollama host and
+// git remote. It returns nil if jobs run unconfined.
+func (h *RalphHandler) openSandbox(job *models.Job, cfg *models.ServerConfig) (*sandbox.Session, error) {
+	settings := cfg.Sandbox
+	if !settings.Enabled() {
+		return nil, nil
+	}
+	sb, err := sandbox.New(settings)
+	if err != nil {
+		return nil, err
+	}
+	jail, err := sb.Open(fmt.Sprintf("ralph-job-%d", job.ID), cfg.Ollama.Host, job.RepoURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sandbox: %w", err)
+	}
+	jail.SetRepository(h.repoManager.WorkspacePath(job.ID))
+	h.appendLog(job, fmt.Sprintf("Running in a %s sandbox", settings.Runtime))
+	return jail, nil
+}
+
+// checkInterrupted reloads the job status and the settings users may edit,
+// and reports whether the job was paused or cancelled since it started
+// running
+func (h *RalphHandler) checkInterrupted(job *models.Job) (bool, error) {
+	current, err := h.jobRepo.Get(job.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to reload job: %w", err)
+	}
+
+	job.Priority = current.Priority
+	job.MaxIterations = current.MaxIterations
+	if current.Status == models.StatusRunning {
+		return false, nil
+	}
+
+	job.Status = current.Status
+	job.PausedAt = current.PausedAt
+	job.CompletedAt = current.CompletedAt
+	return true, nil
+}
+
+// jobDir returns the directory the executor runs in for a job. Git always
+// runs at the workspace root instead, so the agent cannot plant a repository
+// of its own in working_dir for the server to use.
+func (h *RalphHandler) jobDir(job *models.Job) string {
+	workDir := h.repoManager.WorkspacePath(job.ID)
 	if job.WorkingDir != "" {
-		workDir = workDir + "/" + job.WorkingDir
+		workDir = filepath.Join(workDir, job.WorkingDir)
 	}
+	return workDir
+}
 
-	// Execute claude with the prompt
-	result, err := h.executor.Execute(ctx, workDir, job.Prompt, job.Env, func(line string) {
-		_ = h.logRepo.Append(job.ID, job.Iteration, line)
-	})
+// commit commits the job's workspace, recording the commit and its diffstat
+// on it (which may be nil). It returns the short hash, or "" if there was
+// nothing to commit.
+func (h *RalphHandler) commit(ctx context.Context, job *models.Job, it *models.Iteration, message string) string {
+	workDir := h.repoManager.WorkspacePath(job.ID)
+	hash, err := h.repoManager.Commit(ctx, workDir, message)
+	if err != nil {
+		log.Printf("Warning: failed to commit %q in %s: %v", message, workDir, err)
+		return ""
+	}
+	if hash == "" || it == nil {
+		return hash
+	}
 
+	it.CommitHash = hash
+	stat, err := h.repoManager.DiffStat(ctx, workDir, hash)
 	if err != nil {
-		return fmt.Errorf("claude execution failed: %w", err)
+		log.Printf("Warning: failed to get diffstat of %s: %v", hash, err)
+		return hash
 	}
+	it.FilesChanged = stat.FilesChanged
+	it.Insertions = stat.Insertions
+	it.Deletions = stat.Deletions
+	return hash
+}
 
-	// Update iteration from output
-	if result.Iterations > job.Iteration {
-		h.updateIteration(job, result.Iterations)
+// fingerprint records what an iteration changed, which tests it left failing
+// and what the agent answered, so a stalled loop can be spotted. v is the
+// verification run during the iteration, if any.
+func (h *RalphHandler) fingerprint(ctx context.Context, job *models.Job, it *models.Iteration, result *ExecutionResult, v *models.Verification) {
+	if it.CommitHash != "" {
+		patch, err := h.repoManager.Patch(ctx, h.repoManager.WorkspacePath(job.ID), it.CommitHash)
+		if err != nil {
+			// Something was committed; never let it count as no change
+			log.Printf("Warning: failed to read diff of %s: %v", it.CommitHash, err)
+			it.DiffHash = it.CommitHash
+		} else {
+			it.DiffHash = HashDiff(patch)
+		}
 	}
 
-	// Check completion
-	if result.Completed {
-		log.Printf("Job %d completed successfully after %d iterations", job.ID, job.Iteration)
-		return h.finalize(ctx, job, true)
+	answer := result.FinalMessage
+	if answer == "" {
+		answer = result.Output
 	}
+	it.OutputHash = HashOutput(answer)
 
-	// Check max iterations
-	if job.HasReachedMaxIterations() {
-		log.Printf("Job %d reached max iterations (%d)", job.ID, job.MaxIterations)
-		return h.finalize(ctx, job, false)
+	testOutput := result.Output
+	if v != nil {
+		testOutput += "\n" + v.Output
 	}
+	it.FailingTests = FailingTests(testOutput)
+}
 
-	// Continue running (scheduler will handle re-execution)
-	return nil
+func (h *RalphHandler) saveIteration(it *models.Iteration) {
+	if err := h.iterationRepo.Save(it); err != nil {
+		log.Printf("Failed to save iteration %d of job %d: %v", it.Number, it.JobID, err)
+	}
+}
+
+func (h *RalphHandler) finishIteration(it *models.Iteration) {
+	it.Finish()
+	h.saveIteration(it)
+}
+
+// jobEnv returns the job's environment variables with the values of its
+// secrets added, and starts hiding those values in its logs
+func (h *RalphHandler) jobEnv(job *models.Job) (map[string]string, error) {
+	if len(job.Secrets) == 0 {
+		return job.Env, nil
+	}
+	if h.secrets == nil {
+		return nil, errors.New("job uses secrets but the server has no secret store")
+	}
+	values, err := h.secrets.Values(job.Secrets)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]string, len(job.Env)+len(values))
+	for k, v := range job.Env {
+		env[k] = v
+	}
+	secretValues := make([]string, 0, len(values))
+	for k, v := range values {
+		env[k] = v
+		secretValues = append(secretValues, v)
+	}
+
+	h.mu.Lock()
+	h.redactors[job.ID] = models.NewRedactor(secretValues)
+	h.mu.Unlock()
+	return env, nil
+}
+
+// redact hides the values of the job's secrets in s
+func (h *RalphHandler) redact(job *models.Job, s string) string {
+	h.mu.Lock()
+	redactor := h.redactors[job.ID]
+	h.mu.Unlock()
+	return redactor.Redact(s)
+}
+
+// forget stops tracking the secrets of a job that is no longer running
+func (h *RalphHandler) forget(job *models.Job) {
+	h.mu.Lock()
+	delete(h.redactors, job.ID)
+	h.mu.Unlock()
+}
+
+func (h *RalphHandler) appendLog(job *models.Job, line string) {
+	if err := h.logRepo.Append(job.ID, job.Iteration, h.redact(job, line)); err != nil {
+		log.Printf("Failed to append log for job %d: %v", job.ID, err)
+	}
 }
 
 func (h *RalphHandler) updateIteration(job *models.Job, iteration int) {
 	job.Iteration = iteration
-	if err := h.jobRepo.Update(job); err != nil {
+	if err := h.jobRepo.UpdateIteration(job.ID, iteration); err != nil {
 		log.Printf("Failed to update job iteration: %v", err)
 	}
 }
 
-func (h *RalphHandler) finalize(ctx context.Context, job *models.Job, success bool) error {
-	workDir := h.repoManager.WorkspacePath(job.ID)
-	if job.WorkingDir != "" {
-		workDir = workDir + "/" + job.WorkingDir
+// verify runs the job's verify command in the workspace, logging its output
+func (h *RalphHandler) verify(ctx context.Context, job *models.Job, workDir string, env map[string]string) (*models.Verification, error) {
+	h.appendLog(job, fmt.Sprintf("Completion claimed; verifying with: %s", job.VerifyCommand))
+
+	v, err := Verify(ctx, workDir, job.VerifyCommand, env, func(line string) {
+		h.appendLog(job, line)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to run verify command: %w", err)
+	}
+	// The output goes into the next prompt and the PR body
+	v.Output = h.redact(job, v.Output)
+
+	if v.Passed {
+		h.appendLog(job, fmt.Sprintf("Verification passed in %s", v.Duration.Round(time.Millisecond)))
+	} else {
+		h.appendLog(job, fmt.Sprintf("Verification failed with exit code %d; continuing", v.ExitCode))
 	}
+	return v, nil
+}
+
+// finalize commits what is left, pushes the result branch and opens a PR.
+// reason says why an unsuccessful job stopped.
+func (h *RalphHandler) finalize(ctx context.Context, job *models.Job, success bool, reason string, verification *models.Verification) error {
+	workDir := h.repoManager.WorkspacePath(job.ID)
 
 	// Commit any remaining changes
 	hash, err := h.repoManager.Commit(ctx, workDir, fmt.Sprintf("Ralph iteration %d", job.Iteration))
@@ -100,13 +646,13 @@ func (h *RalphHandler) finalize(ctx context.Context, job *models.Job, success bo
 	}
 
 	// Push and create PR
-	prURL, err := h.repoManager.PushAndCreatePR(ctx, workDir, job.Branch, job.Iteration, success, "")
+	prURL, err := h.repoManager.PushAndCreatePR(ctx, workDir, job.Branch, job.Iteration, success, reason, "", verification)
 	if err != nil {
 		return fmt.Errorf("failed to create PR: %w", err)
 	}
 
 	job.PRURL = prURL
-	if err := h.jobRepo.Update(job); err != nil {
+	if err := h.jobRepo.UpdatePRURL(job.ID, prURL); err != nil {
 		log.Printf("Failed to update job with PR URL: %v", err)
 	}
 
@@ -114,6 +660,24 @@ func (h *RalphHandler) finalize(ctx context.Context, job *models.Job, success bo
 	return nil
 }
 
+// stallSettings returns the window and policy a job is checked for stalls
+// with; a window of 0 turns the check off
+func stallSettings(job *models.Job) (int, models.StallPolicy) {
+	policy := job.StallPolicy
+	if policy == "" {
+		policy = models.StallPolicyHint
+	}
+	if policy == models.StallPolicyOff {
+		return 0, policy
+	}
+
+	window := job.StallWindow
+	if window == 0 {
+		window = models.DefaultStallWindow
+	}
+	return window, policy
+}
+
 func shouldContinue(job *models.Job) bool {
 	return job.Iteration < job.MaxIterations
 }
@@ -1,10 +1,20 @@
 package executor
 
 import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ryan/ralph-o-matic/internal/db"
 	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/queue"
+	"github.com/ryan/ralph-o-matic/internal/sandbox"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -18,6 +28,87 @@ func newTestDB(t *testing.T) *db.DB {
 	return database
 }
 
+// gitIdentity lets tests commit without depending on the user's git config
+var gitIdentity = map[string]string{
+	"GIT_AUTHOR_NAME":     "ralph",
+	"GIT_AUTHOR_EMAIL":    "ralph@example.com",
+	"GIT_COMMITTER_NAME":  "ralph",
+	"GIT_COMMITTER_EMAIL": "ralph@example.com",
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, "git %s: %s", strings.Join(args, " "), out)
+	return strings.TrimSpace(string(out))
+}
+
+// newTestRepo creates a git repository on branch main with files committed
+func newTestRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	for k, v := range gitIdentity {
+		t.Setenv(k, v)
+	}
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "initial")
+	return dir
+}
+
+// newTestRemote creates a bare repository to clone from and push to, and
+// puts a fake gh on PATH whose clone fails (so git clone is used) and whose
+// pr create prints a PR URL. The second return value is a file that receives
+// the arguments of the last gh pr create.
+func newTestRemote(t *testing.T) (string, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake gh is a shell script")
+	}
+	src := newTestRepo(t, map[string]string{"README.md": "# test\n"})
+
+	remote := filepath.Join(t.TempDir(), "remote.git")
+	runGit(t, "", "clone", "-q", "--bare", src, remote)
+
+	binDir := t.TempDir()
+	prArgs := filepath.Join(binDir, "pr-args")
+	script := "#!/bin/sh\nif [ \"$1\" = pr ]; then printf '%s\\n' \"$@\" > " + prArgs + "; echo https://github.com/user/repo/pull/1; exit 0; fi\nexit 1\n"
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "gh"), []byte(script), 0o755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	return remote, prArgs
+}
+
+// runFakeJob runs a job through the ralph loop with a FakeExecutor playing
+// steps, returning the fake and the error from Handle
+func runFakeJob(t *testing.T, database *db.DB, job *models.Job, steps ...FakeStep) (*FakeExecutor, error) {
+	t.Helper()
+	fake := NewFakeExecutor(steps...)
+	name := "fake-" + strings.ReplaceAll(t.Name(), "/", "-")
+	Register(name, func(*models.ServerConfig) Executor { return fake })
+	job.Executor = name
+
+	jobRepo := db.NewJobRepo(database)
+	require.NoError(t, jobRepo.Create(job))
+	require.NoError(t, job.TransitionTo(models.StatusRunning))
+	require.NoError(t, jobRepo.Update(job))
+
+	handler := NewRalphHandler(database, models.DefaultServerConfig(), t.TempDir())
+	err := handler.Handle(context.Background(), job)
+	return fake, err
+}
+
 func TestRalphHandler_ShouldContinue(t *testing.T) {
 	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
 
@@ -46,3 +137,591 @@ func TestRalphHandler_UpdateIteration(t *testing.T) {
 	fetched, _ := jobRepo.Get(job.ID)
 	assert.Equal(t, 5, fetched.Iteration)
 }
+
+func TestRalphHandler_CheckInterrupted(t *testing.T) {
+	database := newTestDB(t)
+	jobRepo := db.NewJobRepo(database)
+	handler := NewRalphHandler(database, models.DefaultServerConfig(), "/tmp")
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, jobRepo.Create(job))
+	require.NoError(t, job.TransitionTo(models.StatusRunning))
+	require.NoError(t, jobRepo.Update(job))
+
+	stopped, err := handler.checkInterrupted(job)
+	require.NoError(t, err)
+	assert.False(t, stopped)
+
+	// Edits made through the API are picked up
+	other, err := jobRepo.Get(job.ID)
+	require.NoError(t, err)
+	other.MaxIterations = 3
+	require.NoError(t, jobRepo.UpdateSettings(other))
+	stopped, err = handler.checkInterrupted(job)
+	require.NoError(t, err)
+	assert.False(t, stopped)
+	assert.Equal(t, 3, job.MaxIterations)
+
+	// Pause through a separate copy, as the API would
+	require.NoError(t, other.TransitionTo(models.StatusPaused))
+	require.NoError(t, jobRepo.Update(other))
+
+	stopped, err = handler.checkInterrupted(job)
+	require.NoError(t, err)
+	assert.True(t, stopped)
+	assert.Equal(t, models.StatusPaused, job.Status)
+	assert.NotNil(t, job.PausedAt)
+}
+
+func TestRalphHandler_JobDir(t *testing.T) {
+	database := newTestDB(t)
+	handler := NewRalphHandler(database, models.DefaultServerConfig(), "/work")
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	job.ID = 7
+	assert.Equal(t, "/work/job-7", handler.jobDir(job))
+
+	job.WorkingDir = "packages/auth"
+	assert.Equal(t, "/work/job-7/packages/auth", handler.jobDir(job))
+}
+
+func TestRalphHandler_Handle_FakeExecutor(t *testing.T) {
+	remote, _ := newTestRemote(t)
+	database := newTestDB(t)
+
+	job := models.NewJob(remote, "main", "Make it work", 10)
+	fake, err := runFakeJob(t, database, job,
+		FakeStep{
+			Output: []string{"writing code"},
+			Files:  map[string]string{"main.go": "package main\n"},
+			Events: []*models.AgentEvent{{Type: models.AgentEventToolCall, Tool: "Write", Content: `{"file_path":"main.go"}`}},
+		},
+		FakeStep{Output: []string{"<promise>COMPLETE</promise>"}, Completed: true, Files: map[string]string{"main_test.go": "package main\n"}},
+	)
+	require.NoError(t, err)
+
+	assert.Len(t, fake.Calls(), 2)
+	assert.Equal(t, "Make it work", fake.Calls()[0].Prompt)
+	assert.Equal(t, 2, job.Iteration)
+	assert.Equal(t, "https://github.com/user/repo/pull/1", job.PRURL)
+
+	// Each iteration was committed and pushed to the result branch
+	log := runGit(t, remote, "log", "--format=%s", "ralph/main-result")
+	assert.Equal(t, "Ralph iteration 2\nRalph iteration 1\ninitial", log)
+
+	logs, err := db.NewLogRepo(database).GetForJob(job.ID)
+	require.NoError(t, err)
+	var messages []string
+	for _, l := range logs {
+		messages = append(messages, l.Message)
+	}
+	assert.Contains(t, messages, "writing code")
+
+	// Agent events are stored against the iteration that produced them
+	evs, err := db.NewAgentEventRepo(database).Find(job.ID, 1)
+	require.NoError(t, err)
+	require.Len(t, evs, 1)
+	assert.Equal(t, "Write", evs[0].Tool)
+
+	// The timeline records each iteration's commit and diffstat
+	iterations, err := db.NewIterationRepo(database).ListForJob(job.ID)
+	require.NoError(t, err)
+	require.Len(t, iterations, 2)
+	for i, it := range iterations {
+		assert.Equal(t, i+1, it.Number)
+		assert.NotEmpty(t, it.CommitHash)
+		assert.Equal(t, 1, it.FilesChanged)
+		assert.Equal(t, 1, it.Insertions)
+		assert.NotNil(t, it.FinishedAt)
+		assert.Nil(t, it.VerifyPassed)
+	}
+	assert.False(t, iterations[0].Completed)
+	assert.True(t, iterations[1].Completed)
+}
+
+func TestRalphHandler_Handle_NestedRepository(t *testing.T) {
+	remote, _ := newTestRemote(t)
+	database := newTestDB(t)
+
+	// A repository the agent points working_dir at, whose config runs a
+	// program when git pushes over ssh
+	ran := filepath.Join(t.TempDir(), "ran")
+	script := filepath.Join(t.TempDir(), "ssh.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho $0 >> "+ran+"\nexit 1\n"), 0o755))
+	planted := newTestRepo(t, map[string]string{"README.md": "# planted\n"})
+	runGit(t, planted, "config", "core.sshCommand", script)
+	runGit(t, planted, "remote", "add", "origin", "git@ralph.invalid:user/repo.git")
+	runGit(t, planted, "branch", "ralph/main-result")
+
+	job := models.NewJob(remote, "main", "Make it work", 10)
+	job.WorkingDir = "services/api"
+	_, err := runFakeJob(t, database, job, FakeStep{
+		Output:    []string{"<promise>COMPLETE</promise>"},
+		Completed: true,
+		Files: map[string]string{
+			".git":    "gitdir: " + filepath.Join(planted, ".git") + "\n",
+			"main.go": "package main\n",
+		},
+	})
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, ran, "the planted ssh command never ran")
+	log := runGit(t, remote, "log", "--format=%s", "ralph/main-result")
+	assert.Equal(t, "Ralph iteration 1\ninitial", log)
+}
+
+func TestRalphHandler_Handle_Secrets(t *testing.T) {
+	remote, _ := newTestRemote(t)
+	database := newTestDB(t)
+	secrets, err := db.NewSecretRepo(database, bytes.Repeat([]byte{1}, db.SecretKeySize))
+	require.NoError(t, err)
+	require.NoError(t, secrets.Set("NPM_TOKEN", "npm_abc123", nil))
+
+	fake := NewFakeExecutor(FakeStep{Output: []string{"using npm_abc123"}, Completed: true})
+	Register("fake-secrets", func(*models.ServerConfig) Executor { return fake })
+	job := models.NewJob(remote, "main", "Publish it", 10)
+	job.Executor = "fake-secrets"
+	job.Env = map[string]string{"CI": "1"}
+	job.Secrets = []string{"NPM_TOKEN"}
+	jobRepo := db.NewJobRepo(database)
+	require.NoError(t, jobRepo.Create(job))
+	require.NoError(t, job.TransitionTo(models.StatusRunning))
+	require.NoError(t, jobRepo.Update(job))
+
+	handler := NewRalphHandler(database, models.DefaultServerConfig(), t.TempDir())
+	assert.Error(t, handler.Handle(context.Background(), job), "no secret store")
+
+	handler.SetSecrets(secrets)
+	require.NoError(t, handler.Handle(context.Background(), job))
+
+	// The agent gets the value but the log only ever sees it redacted
+	require.Len(t, fake.Calls(), 1)
+	assert.Equal(t, map[string]string{"CI": "1", "NPM_TOKEN": "npm_abc123"}, fake.Calls()[0].Env)
+	logs, err := db.NewLogRepo(database).GetForJob(job.ID)
+	require.NoError(t, err)
+	var messages []string
+	for _, l := range logs {
+		messages = append(messages, l.Message)
+	}
+	assert.Contains(t, messages, "using "+models.Redacted)
+	assert.Empty(t, handler.redactors, "forgotten once the job ends")
+}
+
+func TestRalphHandler_SetConfig(t *testing.T) {
+	remote, _ := newTestRemote(t)
+	database := newTestDB(t)
+
+	fake := NewFakeExecutor(FakeStep{Completed: true})
+	Register("fake-default", func(cfg *models.ServerConfig) Executor {
+		assert.Equal(t, "http://gpu-box:11434", cfg.Ollama.Host)
+		return fake
+	})
+	handler := NewRalphHandler(database, models.DefaultServerConfig(), t.TempDir())
+
+	// A new default executor and Ollama host apply to the next job
+	cfg := *models.DefaultServerConfig()
+	cfg.Executor = "fake-default"
+	cfg.Ollama.Host = "http://gpu-box:11434"
+	handler.SetConfig(&cfg)
+
+	job := models.NewJob(remote, "main", "Make it work", 10)
+	jobRepo := db.NewJobRepo(database)
+	require.NoError(t, jobRepo.Create(job))
+	require.NoError(t, job.TransitionTo(models.StatusRunning))
+	require.NoError(t, jobRepo.Update(job))
+	require.NoError(t, handler.Handle(context.Background(), job))
+	assert.Len(t, fake.Calls(), 1)
+}
+
+func TestRalphHandler_Handle_UnknownExecutor(t *testing.T) {
+	database := newTestDB(t)
+	handler := NewRalphHandler(database, models.DefaultServerConfig(), t.TempDir())
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	job.Executor = "cursor"
+
+	err := handler.Handle(context.Background(), job)
+	assert.ErrorIs(t, err, ErrUnknownBackend)
+}
+
+func TestRalphHandler_Handle_VerifyCommand(t *testing.T) {
+	remote, prArgs := newTestRemote(t)
+	database := newTestDB(t)
+
+	job := models.NewJob(remote, "main", "Create done.txt", 10)
+	job.VerifyCommand = "test -f done.txt || { echo done.txt is missing; exit 3; }"
+	fake, err := runFakeJob(t, database, job,
+		// Claims completion without doing the work
+		FakeStep{Output: []string{"<promise>COMPLETE</promise>"}, Completed: true},
+		FakeStep{Output: []string{"<promise>COMPLETE</promise>"}, Completed: true, Files: map[string]string{"done.txt": "ok\n"}},
+	)
+	require.NoError(t, err)
+
+	calls := fake.Calls()
+	require.Len(t, calls, 2)
+	assert.Equal(t, "Create done.txt", calls[0].Prompt)
+	assert.Contains(t, calls[1].Prompt, "## Verification failed")
+	assert.Contains(t, calls[1].Prompt, "exited with code 3")
+	assert.Contains(t, calls[1].Prompt, "done.txt is missing")
+
+	body, err := os.ReadFile(prArgs)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "Verification passed")
+
+	iterations, err := db.NewIterationRepo(database).ListForJob(job.ID)
+	require.NoError(t, err)
+	require.Len(t, iterations, 2)
+	require.NotNil(t, iterations[0].VerifyPassed)
+	assert.False(t, *iterations[0].VerifyPassed)
+	assert.Empty(t, iterations[0].CommitHash) // nothing changed
+	require.NotNil(t, iterations[1].VerifyPassed)
+	assert.True(t, *iterations[1].VerifyPassed)
+}
+
+func TestRalphHandler_Handle_VerifyNeverPasses(t *testing.T) {
+	remote, prArgs := newTestRemote(t)
+	database := newTestDB(t)
+
+	job := models.NewJob(remote, "main", "Fix it", 2)
+	job.VerifyCommand = "echo still broken; exit 1"
+	fake, err := runFakeJob(t, database, job, FakeStep{Completed: true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "max iterations")
+	assert.Len(t, fake.Calls(), 2)
+
+	// The PR reports the real verification result rather than success
+	body, err := os.ReadFile(prArgs)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "failed with exit code 1")
+	assert.Contains(t, string(body), "still broken")
+}
+
+func TestRalphHandler_Handle_StallFails(t *testing.T) {
+	remote, prArgs := newTestRemote(t)
+	database := newTestDB(t)
+
+	job := models.NewJob(remote, "main", "Make it work", 10)
+	job.StallWindow = 2
+	job.StallPolicy = models.StallPolicyFail
+	fake, err := runFakeJob(t, database, job,
+		FakeStep{Output: []string{"looking around"}, Files: map[string]string{"a.go": "package a\n"}},
+		FakeStep{Output: []string{"still thinking"}},
+		FakeStep{Output: []string{"thinking harder"}},
+	)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "stalled: no changes were committed in the last 2 iterations")
+	assert.Len(t, fake.Calls(), 3)
+
+	// The work so far is still offered as a PR
+	_, err = os.Stat(prArgs)
+	assert.NoError(t, err)
+
+	iterations, err := db.NewIterationRepo(database).ListForJob(job.ID)
+	require.NoError(t, err)
+	require.Len(t, iterations, 3)
+	assert.NotEmpty(t, iterations[0].DiffHash)
+	assert.Empty(t, iterations[1].DiffHash)
+	assert.NotEmpty(t, iterations[1].OutputHash)
+}
+
+func TestRalphHandler_Handle_StallHint(t *testing.T) {
+	remote, _ := newTestRemote(t)
+	database := newTestDB(t)
+
+	job := models.NewJob(remote, "main", "Make it work", 10)
+	job.StallWindow = 2
+	fake, err := runFakeJob(t, database, job, FakeStep{Output: []string{"--- FAIL: TestWork (0.00s)"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "stalled")
+
+	// Warned once after the first window, then failed after the second
+	calls := fake.Calls()
+	require.Len(t, calls, 4)
+	assert.NotContains(t, calls[1].Prompt, "## You are stuck")
+	assert.Contains(t, calls[2].Prompt, "## You are stuck")
+	assert.NotContains(t, calls[3].Prompt, "## You are stuck")
+
+	iterations, err := db.NewIterationRepo(database).ListForJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TestWork"}, iterations[0].FailingTests)
+}
+
+func TestRalphHandler_Handle_StallEscalates(t *testing.T) {
+	remote, _ := newTestRemote(t)
+	database := newTestDB(t)
+
+	job := models.NewJob(remote, "main", "Make it work", 10)
+	job.StallWindow = 2
+	job.StallPolicy = models.StallPolicyEscalate
+	fake, err := runFakeJob(t, database, job, FakeStep{Output: []string{"nothing to do"}})
+	require.ErrorIs(t, err, queue.ErrNeedsAttention)
+	assert.Len(t, fake.Calls(), 2)
+	assert.Empty(t, job.PRURL)
+}
+
+func TestRalphHandler_Handle_StallEscalatesThroughModelPlan(t *testing.T) {
+	remote, _ := newTestRemote(t)
+	database := newTestDB(t)
+
+	job := models.NewJob(remote, "main", "Make it work", 10)
+	job.StallWindow = 2
+	job.StallPolicy = models.StallPolicyEscalate
+	job.ModelPlan = models.ModelPlan{
+		{ModelPlacement: models.ModelPlacement{Name: "small"}},
+		{ModelPlacement: models.ModelPlacement{Name: "large"}},
+	}
+	fake, err := runFakeJob(t, database, job, FakeStep{Output: []string{"nothing to do"}})
+
+	// Stalling on the small model moves to the large one, and stalling on
+	// the last model pauses the job
+	require.ErrorIs(t, err, queue.ErrNeedsAttention)
+	assert.Len(t, fake.Calls(), 4)
+	iterations, err := db.NewIterationRepo(database).ListForJob(job.ID)
+	require.NoError(t, err)
+	require.Len(t, iterations, 4)
+	assert.Equal(t, "small", iterations[1].Model)
+	assert.Equal(t, "large", iterations[2].Model)
+	assert.Equal(t, "large", iterations[3].Model)
+}
+
+func TestRalphHandler_Handle_StallOff(t *testing.T) {
+	remote, _ := newTestRemote(t)
+	database := newTestDB(t)
+
+	job := models.NewJob(remote, "main", "Make it work", 3)
+	job.StallWindow = 2
+	job.StallPolicy = models.StallPolicyOff
+	fake, err := runFakeJob(t, database, job, FakeStep{Output: []string{"nothing to do"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "max iterations")
+	assert.Len(t, fake.Calls(), 3)
+}
+
+func TestRalphHandler_Handle_MaxTokens(t *testing.T) {
+	remote, prArgs := newTestRemote(t)
+	database := newTestDB(t)
+
+	job := models.NewJob(remote, "main", "Make it work", 10)
+	job.MaxTokens = 1000
+	fake, err := runFakeJob(t, database, job,
+		FakeStep{Files: map[string]string{"a.go": "package a\n"}, Usage: models.TokenUsage{InputTokens: 500, OutputTokens: 100}},
+		FakeStep{Files: map[string]string{"b.go": "package b\n"}, Usage: models.TokenUsage{InputTokens: 500, OutputTokens: 100}},
+		FakeStep{Completed: true},
+	)
+	require.ErrorIs(t, err, ErrBudgetExhausted)
+	assert.Contains(t, err.Error(), "max_tokens of 1000 reached (1200 used)")
+	assert.Len(t, fake.Calls(), 2)
+
+	// The partial work is still offered as a PR
+	body, err := os.ReadFile(prArgs)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "max_tokens of 1000 reached")
+	assert.Equal(t, "https://github.com/user/repo/pull/1", job.PRURL)
+}
+
+func TestRalphHandler_Handle_MaxDuration(t *testing.T) {
+	remote, prArgs := newTestRemote(t)
+	database := newTestDB(t)
+
+	job := models.NewJob(remote, "main", "Make it work", 10)
+	job.MaxDuration = 500 * time.Millisecond
+	fake, err := runFakeJob(t, database, job,
+		FakeStep{Files: map[string]string{"a.go": "package a\n"}},
+		FakeStep{Delay: 10 * time.Second, Completed: true},
+	)
+	require.ErrorIs(t, err, ErrBudgetExhausted)
+	assert.Contains(t, err.Error(), "max_duration of 500ms reached")
+	assert.Len(t, fake.Calls(), 2)
+
+	body, err := os.ReadFile(prArgs)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "max_duration of 500ms reached")
+
+	iterations, err := db.NewIterationRepo(database).ListForJob(job.ID)
+	require.NoError(t, err)
+	require.Len(t, iterations, 2)
+	assert.Contains(t, iterations[1].Error, "interrupted")
+	assert.NotNil(t, iterations[1].FinishedAt)
+}
+
+func TestRalphHandler_Handle_PerIterationTimeout(t *testing.T) {
+	remote, _ := newTestRemote(t)
+	database := newTestDB(t)
+
+	job := models.NewJob(remote, "main", "Make it work", 10)
+	job.PerIterationTimeout = 200 * time.Millisecond
+	fake, err := runFakeJob(t, database, job,
+		FakeStep{Delay: 10 * time.Second, Completed: true},
+		FakeStep{Output: []string{"<promise>COMPLETE</promise>"}, Completed: true},
+	)
+	require.NoError(t, err)
+	assert.Len(t, fake.Calls(), 2)
+
+	// The slow iteration was cut off and the loop moved on
+	iterations, err := db.NewIterationRepo(database).ListForJob(job.ID)
+	require.NoError(t, err)
+	require.Len(t, iterations, 2)
+	assert.Equal(t, "timed out after 200ms", iterations[0].Error)
+	assert.False(t, iterations[0].Completed)
+	assert.True(t, iterations[1].Completed)
+}
+
+func TestRalphHandler_Handle_ModelPlan(t *testing.T) {
+	remote, _ := newTestRemote(t)
+	database := newTestDB(t)
+
+	job := models.NewJob(remote, "main", "Make it work", 10)
+	job.ModelPlan = models.ModelPlan{
+		{ModelPlacement: models.ModelPlacement{Name: "qwen2.5-coder:14b"}, Iterations: 2},
+		{ModelPlacement: models.ModelPlacement{Name: "qwen3-coder:70b"}},
+	}
+	_, err := runFakeJob(t, database, job,
+		FakeStep{Files: map[string]string{"a.go": "package a\n"}},
+		FakeStep{Files: map[string]string{"b.go": "package b\n"}},
+		FakeStep{Files: map[string]string{"c.go": "package c\n"}},
+		FakeStep{Output: []string{"<promise>COMPLETE</promise>"}, Completed: true},
+	)
+	require.NoError(t, err)
+
+	// Each iteration records the model it ran with, so the timeline shows
+	// which one finished the job
+	iterations, err := db.NewIterationRepo(database).ListForJob(job.ID)
+	require.NoError(t, err)
+	var used []string
+	for _, it := range iterations {
+		used = append(used, it.Model)
+	}
+	assert.Equal(t, []string{"qwen2.5-coder:14b", "qwen2.5-coder:14b", "qwen3-coder:70b", "qwen3-coder:70b"}, used)
+
+	logs, err := db.NewLogRepo(database).GetForJob(job.ID)
+	require.NoError(t, err)
+	var messages []string
+	for _, l := range logs {
+		messages = append(messages, l.Message)
+	}
+	assert.Contains(t, messages, "Switching model from qwen2.5-coder:14b to qwen3-coder:70b: 2 iterations without completing")
+}
+
+func TestRalphHandler_Handle_ModelPlanOnStall(t *testing.T) {
+	remote, _ := newTestRemote(t)
+	database := newTestDB(t)
+
+	job := models.NewJob(remote, "main", "Make it work", 10)
+	job.StallWindow = 2
+	job.StallPolicy = models.StallPolicyFail
+	job.ModelPlan = models.ModelPlan{
+		{ModelPlacement: models.ModelPlacement{Name: "small"}, OnStall: true},
+		{ModelPlacement: models.ModelPlacement{Name: "large"}},
+	}
+	fake, err := runFakeJob(t, database, job,
+		FakeStep{Output: []string{"looking"}},
+		FakeStep{Output: []string{"still looking"}},
+		FakeStep{Output: []string{"<promise>COMPLETE</promise>"}, Completed: true},
+	)
+	require.NoError(t, err)
+	assert.Len(t, fake.Calls(), 3)
+
+	iterations, err := db.NewIterationRepo(database).ListForJob(job.ID)
+	require.NoError(t, err)
+	require.Len(t, iterations, 3)
+	assert.Equal(t, "small", iterations[1].Model)
+	assert.Equal(t, "large", iterations[2].Model)
+}
+
+func TestNewAgent_SandboxedOllamaHost(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake bwrap is a shell script")
+	}
+	binDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "bwrap"), []byte("#!/bin/sh\nexit 1\n"), 0o755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	sb, err := sandbox.New(models.SandboxConfig{Runtime: models.SandboxBwrap})
+	require.NoError(t, err)
+	jail, err := sb.Open("ralph-job-1", "http://localhost:11434")
+	require.NoError(t, err)
+	defer jail.Close()
+
+	cfg := models.DefaultServerConfig()
+	cfg.Ollama.Host = "http://localhost:11434"
+
+	// The ollama backend talks to Ollama from the server process
+	agent, err := newAgent("ollama", cfg, jail)
+	require.NoError(t, err)
+	assert.Equal(t, "http://localhost:11434", agent.(*OllamaExecutor).config.Ollama.Host)
+
+	// Agents started as commands in the sandbox reach it through a forward
+	agent, err = newAgent("claude", cfg, jail)
+	require.NoError(t, err)
+	assert.Equal(t, jail.Config(cfg).Ollama.Host, agent.(*ClaudeExecutor).config.Ollama.Host)
+	assert.NotEqual(t, cfg.Ollama.Host, agent.(*ClaudeExecutor).config.Ollama.Host)
+}
+
+func TestResumeStage(t *testing.T) {
+	plan := models.ModelPlan{
+		{ModelPlacement: models.ModelPlacement{Name: "small"}, Iterations: 5},
+		{ModelPlacement: models.ModelPlacement{Name: "large"}},
+	}
+	ran := func(names ...string) []*models.Iteration {
+		var iterations []*models.Iteration
+		for i, name := range names {
+			iterations = append(iterations, &models.Iteration{Number: i + 1, Model: name})
+		}
+		return iterations
+	}
+
+	stage, onStage := resumeStage(plan, nil)
+	assert.Equal(t, 0, stage)
+	assert.Equal(t, 0, onStage)
+
+	stage, onStage = resumeStage(plan, ran("small", "small", "small"))
+	assert.Equal(t, 0, stage)
+	assert.Equal(t, 3, onStage)
+
+	stage, onStage = resumeStage(plan, ran("small", "small", "large"))
+	assert.Equal(t, 1, stage)
+	assert.Equal(t, 1, onStage)
+
+	stage, _ = resumeStage(nil, ran("small"))
+	assert.Equal(t, 0, stage)
+}
+
+func TestRalphHandler_Handle_ModelOverrides(t *testing.T) {
+	remote, _ := newTestRemote(t)
+	database := newTestDB(t)
+
+	var built *models.ServerConfig
+	fake := NewFakeExecutor(FakeStep{Output: []string{"<promise>COMPLETE</promise>"}, Completed: true})
+	Register("fake-overrides", func(cfg *models.ServerConfig) Executor {
+		built = cfg
+		return fake
+	})
+
+	job := models.NewJob(remote, "main", "Make it work", 10)
+	job.Executor = "fake-overrides"
+	job.LargeModel = "qwen3-coder:30b"
+	job.SmallModel = "qwen2.5-coder:1.5b"
+	job.OllamaHost = "http://gpu-box:11434"
+
+	jobRepo := db.NewJobRepo(database)
+	require.NoError(t, jobRepo.Create(job))
+	require.NoError(t, job.TransitionTo(models.StatusRunning))
+	require.NoError(t, jobRepo.Update(job))
+
+	cfg := models.DefaultServerConfig()
+	handler := NewRalphHandler(database, cfg, t.TempDir())
+	require.NoError(t, handler.Handle(context.Background(), job))
+
+	// The backend sees the job's models and host; the server config is untouched
+	require.NotNil(t, built)
+	env := NewClaudeExecutor(built).BuildEnv(nil)
+	assert.Contains(t, env, "ANTHROPIC_BASE_URL=http://gpu-box:11434")
+	assert.Contains(t, env, "ANTHROPIC_MODEL=qwen3-coder:30b")
+	assert.Contains(t, env, "ANTHROPIC_DEFAULT_HAIKU_MODEL=qwen2.5-coder:1.5b")
+	assert.Equal(t, "qwen3-coder:70b", cfg.LargeModel.Name)
+
+	iterations, err := db.NewIterationRepo(database).ListForJob(job.ID)
+	require.NoError(t, err)
+	require.Len(t, iterations, 1)
+	assert.Equal(t, "qwen3-coder:30b", iterations[0].Model)
+}
@@ -0,0 +1,38 @@
+package executor
+
+import (
+	"context"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/ryan/ralph-o-matic/internal/sandbox"
+)
+
+type sandboxKey struct{}
+
+// withSandbox makes the agents and verify commands started with ctx run in
+// jail. A nil jail leaves them unconfined.
+func withSandbox(ctx context.Context, jail *sandbox.Session) context.Context {
+	if jail == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, sandboxKey{}, jail)
+}
+
+// sandboxFrom returns the sandbox commands started with ctx run in, if any
+func sandboxFrom(ctx context.Context) *sandbox.Session {
+	jail, _ := ctx.Value(sandboxKey{}).(*sandbox.Session)
+	return jail
+}
+
+// inProcess names the executors that run in the server process rather than
+// as a command, and so reach Ollama from outside any sandbox
+var inProcess = map[string]bool{"ollama": true}
+
+// newAgent creates the executor registered under backend, pointing agents
+// that run in jail at the address they reach the Ollama host at there
+func newAgent(backend string, cfg *models.ServerConfig, jail *sandbox.Session) (Executor, error) {
+	if !inProcess[backend] {
+		cfg = jail.Config(cfg)
+	}
+	return New(backend, cfg)
+}
@@ -0,0 +1,153 @@
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// maxListedTests caps how many failing tests a stall reason names
+const maxListedTests = 5
+
+var (
+	// Failing test lines from the runners agents most often use: go test,
+	// pytest and cargo test. Names stop at a backslash so matches inside
+	// JSON-escaped output end at the line break.
+	failingTestPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`--- FAIL: ([\w/.-]+)`),
+		regexp.MustCompile(`FAILED ([\w/.-]+::[\w.:\[\]-]+)`),
+		regexp.MustCompile(`test ([\w:]+) \.\.\. FAILED`),
+	}
+
+	digitsPattern     = regexp.MustCompile(`\d+`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// HashDiff fingerprints a patch, returning "" for an empty one
+func HashDiff(patch string) string {
+	if strings.TrimSpace(patch) == "" {
+		return ""
+	}
+	return shortHash(patch)
+}
+
+// HashOutput fingerprints what an agent said. Numbers and whitespace are
+// ignored so that timings, counts and line numbers do not make otherwise
+// identical answers look different.
+func HashOutput(output string) string {
+	normalized := digitsPattern.ReplaceAllString(output, "0")
+	normalized = strings.TrimSpace(whitespacePattern.ReplaceAllString(normalized, " "))
+	if normalized == "" {
+		return ""
+	}
+	return shortHash(normalized)
+}
+
+// FailingTests returns the sorted, de-duplicated names of the tests reported
+// as failing in output
+func FailingTests(output string) []string {
+	seen := make(map[string]bool)
+	var tests []string
+	for _, pattern := range failingTestPatterns {
+		for _, m := range pattern.FindAllStringSubmatch(output, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				tests = append(tests, m[1])
+			}
+		}
+	}
+	sort.Strings(tests)
+	return tests
+}
+
+// DetectStall looks at the last window iterations of history for a loop that
+// has stopped making progress: nothing committed, the same tests failing,
+// the same answer every time, or changes that are made and undone in a
+// cycle. It returns nil while there is too little history to judge.
+func DetectStall(history []*models.Iteration, window int) *models.Stall {
+	if window < 2 || len(history) < window {
+		return nil
+	}
+	recent := history[len(history)-window:]
+
+	if allSame(recent, func(it *models.Iteration) string { return it.DiffHash }) {
+		reason := fmt.Sprintf("no changes were committed in the last %d iterations", window)
+		if recent[0].DiffHash != "" {
+			reason = fmt.Sprintf("the last %d iterations each committed the same change", window)
+		}
+		return &models.Stall{Kind: models.StallNoProgress, Window: window, Reason: reason}
+	}
+
+	if failing := recent[0].FailingTests; len(failing) > 0 &&
+		allSame(recent, func(it *models.Iteration) string { return strings.Join(it.FailingTests, "\n") }) {
+		names := failing
+		if len(names) > maxListedTests {
+			names = append(names[:maxListedTests:maxListedTests], fmt.Sprintf("and %d more", len(failing)-maxListedTests))
+		}
+		return &models.Stall{
+			Kind:   models.StallRepeatedFailure,
+			Window: window,
+			Reason: fmt.Sprintf("the same tests failed in each of the last %d iterations: %s", window, strings.Join(names, ", ")),
+		}
+	}
+
+	if recent[0].OutputHash != "" && allSame(recent, func(it *models.Iteration) string { return it.OutputHash }) {
+		return &models.Stall{
+			Kind:   models.StallRepeatedOutput,
+			Window: window,
+			Reason: fmt.Sprintf("the agent gave the same answer in each of the last %d iterations", window),
+		}
+	}
+
+	for period := 2; period*2 <= window; period++ {
+		if cycles(recent, period) {
+			return &models.Stall{
+				Kind:   models.StallOscillation,
+				Window: window,
+				Reason: fmt.Sprintf("the last %d iterations cycled through the same %d changes", window, period),
+			}
+		}
+	}
+
+	return nil
+}
+
+// StallHint appends a note to the prompt telling the agent that its last
+// iterations went nowhere and it should change approach
+func StallHint(prompt string, stall *models.Stall) string {
+	return fmt.Sprintf("%s\n\n## You are stuck\n\n"+
+		"Your recent work is not making progress: %s. Repeating the same approach will not help. "+
+		"Step back, re-read the task and the code involved, question the assumptions behind your "+
+		"last attempts, and try a substantially different approach.\n",
+		strings.TrimRight(prompt, "\n"), stall.Reason)
+}
+
+// allSame reports whether key returns the same value for every iteration
+func allSame(iterations []*models.Iteration, key func(*models.Iteration) string) bool {
+	for _, it := range iterations[1:] {
+		if key(it) != key(iterations[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+// cycles reports whether the iterations' diffs repeat every period iterations
+func cycles(iterations []*models.Iteration, period int) bool {
+	for i := period; i < len(iterations); i++ {
+		if iterations[i].DiffHash != iterations[i-period].DiffHash {
+			return false
+		}
+	}
+	return true
+}
+
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
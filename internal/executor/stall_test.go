@@ -0,0 +1,119 @@
+package executor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fingerprints builds a history from diff hashes, one iteration each
+func fingerprints(diffs ...string) []*models.Iteration {
+	var history []*models.Iteration
+	for i, d := range diffs {
+		history = append(history, &models.Iteration{Number: i + 1, DiffHash: d, OutputHash: strings.Repeat("o", i+1)})
+	}
+	return history
+}
+
+func TestHashDiff(t *testing.T) {
+	assert.Equal(t, "", HashDiff(""))
+	assert.Equal(t, "", HashDiff("\n"))
+	assert.Equal(t, HashDiff("+a\n"), HashDiff("+a\n"))
+	assert.NotEqual(t, HashDiff("+a\n"), HashDiff("-a\n"))
+}
+
+func TestHashOutput(t *testing.T) {
+	assert.Equal(t, "", HashOutput("  \n"))
+	assert.Equal(t, HashOutput("ran 12 tests in 3.1s"), HashOutput("ran 14 tests in  0.9s\n"))
+	assert.NotEqual(t, HashOutput("tests pass"), HashOutput("tests fail"))
+}
+
+func TestFailingTests(t *testing.T) {
+	output := strings.Join([]string{
+		"--- FAIL: TestParse (0.00s)",
+		"    --- FAIL: TestParse/empty (0.00s)",
+		"FAILED tests/test_api.py::test_create - AssertionError",
+		"test store::tests::roundtrip ... FAILED",
+		"--- FAIL: TestParse (0.00s)",
+		"--- PASS: TestOther (0.00s)",
+	}, "\n")
+
+	assert.Equal(t, []string{"TestParse", "TestParse/empty", "store::tests::roundtrip", "tests/test_api.py::test_create"}, FailingTests(output))
+	assert.Empty(t, FailingTests("ok  \tpkg\t0.1s"))
+
+	// Output embedded in JSON keeps its escaped line breaks
+	assert.Equal(t, []string{"TestParse"}, FailingTests(`{"content":"--- FAIL: TestParse (0.00s)\nFAIL"}`))
+}
+
+func TestDetectStall(t *testing.T) {
+	t.Run("too little history", func(t *testing.T) {
+		assert.Nil(t, DetectStall(fingerprints("", ""), 3))
+		assert.Nil(t, DetectStall(fingerprints("", "", ""), 0))
+	})
+
+	t.Run("progress", func(t *testing.T) {
+		assert.Nil(t, DetectStall(fingerprints("a", "b", "c", "d"), 4))
+		assert.Nil(t, DetectStall(fingerprints("", "", "", "a"), 4))
+	})
+
+	t.Run("no changes", func(t *testing.T) {
+		stall := DetectStall(fingerprints("a", "", "", ""), 3)
+		require.NotNil(t, stall)
+		assert.Equal(t, models.StallNoProgress, stall.Kind)
+		assert.Contains(t, stall.Reason, "no changes were committed in the last 3 iterations")
+	})
+
+	t.Run("same change", func(t *testing.T) {
+		stall := DetectStall(fingerprints("a", "a"), 2)
+		require.NotNil(t, stall)
+		assert.Equal(t, models.StallNoProgress, stall.Kind)
+		assert.Contains(t, stall.Reason, "same change")
+	})
+
+	t.Run("same failing tests", func(t *testing.T) {
+		history := fingerprints("a", "b", "c")
+		for _, it := range history {
+			it.FailingTests = []string{"TestA", "TestB"}
+		}
+		stall := DetectStall(history, 3)
+		require.NotNil(t, stall)
+		assert.Equal(t, models.StallRepeatedFailure, stall.Kind)
+		assert.Contains(t, stall.Reason, "TestA, TestB")
+
+		history[1].FailingTests = []string{"TestA"}
+		assert.Nil(t, DetectStall(history, 3))
+	})
+
+	t.Run("same answer", func(t *testing.T) {
+		history := fingerprints("a", "b", "c")
+		for _, it := range history {
+			it.OutputHash = "same"
+		}
+		stall := DetectStall(history, 3)
+		require.NotNil(t, stall)
+		assert.Equal(t, models.StallRepeatedOutput, stall.Kind)
+	})
+
+	t.Run("oscillation", func(t *testing.T) {
+		stall := DetectStall(fingerprints("x", "a", "b", "a", "b"), 4)
+		require.NotNil(t, stall)
+		assert.Equal(t, models.StallOscillation, stall.Kind)
+		assert.Contains(t, stall.Reason, "same 2 changes")
+
+		stall = DetectStall(fingerprints("a", "b", "c", "a", "b", "c"), 6)
+		require.NotNil(t, stall)
+		assert.Contains(t, stall.Reason, "same 3 changes")
+
+		assert.Nil(t, DetectStall(fingerprints("a", "b", "a", "c"), 4))
+	})
+}
+
+func TestStallHint(t *testing.T) {
+	prompt := StallHint("Fix the tests\n", &models.Stall{Reason: "no changes were committed in the last 4 iterations"})
+
+	assert.True(t, strings.HasPrefix(prompt, "Fix the tests\n\n## You are stuck"))
+	assert.Contains(t, prompt, "no changes were committed in the last 4 iterations.")
+}
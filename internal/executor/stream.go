@@ -0,0 +1,244 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// maxToolSummary caps how much of a tool's input or output is echoed into
+// the job log; the full text is kept in the stored event
+const maxToolSummary = 200
+
+// streamMessage is one line of claude's --output-format stream-json output
+type streamMessage struct {
+	Type    string `json:"type"` // system, assistant, user or result
+	Subtype string `json:"subtype"`
+	Model   string `json:"model"`
+
+	Message struct {
+		ID      string         `json:"id"`
+		Content []contentBlock `json:"content"`
+		Usage   *streamUsage   `json:"usage"`
+	} `json:"message"`
+
+	// Set on the final result message
+	Result   string       `json:"result"`
+	IsError  bool         `json:"is_error"`
+	NumTurns int          `json:"num_turns"`
+	Usage    *streamUsage `json:"usage"`
+}
+
+type contentBlock struct {
+	Type      string          `json:"type"` // text, tool_use or tool_result
+	Text      string          `json:"text"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+	ToolUseID string          `json:"tool_use_id"`
+	Content   json.RawMessage `json:"content"`
+	IsError   bool            `json:"is_error"`
+}
+
+type streamUsage struct {
+	InputTokens              int64 `json:"input_tokens"`
+	OutputTokens             int64 `json:"output_tokens"`
+	CacheReadInputTokens     int64 `json:"cache_read_input_tokens"`
+	CacheCreationInputTokens int64 `json:"cache_creation_input_tokens"`
+}
+
+func (u *streamUsage) tokens() models.TokenUsage {
+	if u == nil {
+		return models.TokenUsage{}
+	}
+	return models.TokenUsage{
+		InputTokens:         u.InputTokens,
+		OutputTokens:        u.OutputTokens,
+		CacheReadTokens:     u.CacheReadInputTokens,
+		CacheCreationTokens: u.CacheCreationInputTokens,
+	}
+}
+
+// StreamParser turns claude's stream-json output into typed agent events,
+// tracking the final assistant message and the tokens used along the way
+type StreamParser struct {
+	events       []*models.AgentEvent
+	toolNames    map[string]string // tool_use id -> tool name
+	seenMessages map[string]bool   // assistant message ids already counted
+	usage        models.TokenUsage
+	lastMessage  string
+	result       *models.AgentEvent
+}
+
+// NewStreamParser creates an empty parser
+func NewStreamParser() *StreamParser {
+	return &StreamParser{
+		toolNames:    make(map[string]string),
+		seenMessages: make(map[string]bool),
+	}
+}
+
+// Parse consumes one line of output and returns the events it produced. ok
+// is false when the line is not stream-json, such as stderr noise, so the
+// caller can log it verbatim.
+func (p *StreamParser) Parse(line string) (evs []*models.AgentEvent, ok bool) {
+	trimmed := strings.TrimSpace(line)
+	if !strings.HasPrefix(trimmed, "{") {
+		return nil, false
+	}
+	var msg streamMessage
+	if err := json.Unmarshal([]byte(trimmed), &msg); err != nil || msg.Type == "" {
+		return nil, false
+	}
+
+	switch msg.Type {
+	case "system":
+		if msg.Subtype == "init" {
+			evs = append(evs, &models.AgentEvent{Type: models.AgentEventInit, Content: msg.Model})
+		}
+	case "assistant":
+		evs = p.parseAssistant(&msg)
+	case "user":
+		evs = p.parseToolResults(&msg)
+	case "result":
+		ev := &models.AgentEvent{
+			Type:    models.AgentEventResult,
+			Content: msg.Result,
+			IsError: msg.IsError,
+			Usage:   msg.Usage.tokens(),
+		}
+		p.result = ev
+		evs = append(evs, ev)
+	}
+
+	p.events = append(p.events, evs...)
+	return evs, true
+}
+
+func (p *StreamParser) parseAssistant(msg *streamMessage) []*models.AgentEvent {
+	// Claude repeats a message's usage on every content block it streams, so
+	// count each message once
+	usage := models.TokenUsage{}
+	if msg.Message.ID == "" || !p.seenMessages[msg.Message.ID] {
+		usage = msg.Message.Usage.tokens()
+		p.usage.Add(usage)
+		p.seenMessages[msg.Message.ID] = true
+	}
+
+	var evs []*models.AgentEvent
+	for _, block := range msg.Message.Content {
+		switch block.Type {
+		case "text":
+			if strings.TrimSpace(block.Text) == "" {
+				continue
+			}
+			p.lastMessage = block.Text
+			evs = append(evs, &models.AgentEvent{Type: models.AgentEventMessage, Content: block.Text})
+		case "tool_use":
+			p.toolNames[block.ID] = block.Name
+			evs = append(evs, &models.AgentEvent{Type: models.AgentEventToolCall, Tool: block.Name, Content: string(block.Input)})
+		}
+	}
+	if len(evs) > 0 {
+		evs[0].Usage = usage
+	}
+	return evs
+}
+
+func (p *StreamParser) parseToolResults(msg *streamMessage) []*models.AgentEvent {
+	var evs []*models.AgentEvent
+	for _, block := range msg.Message.Content {
+		if block.Type != "tool_result" {
+			continue
+		}
+		evs = append(evs, &models.AgentEvent{
+			Type:    models.AgentEventToolResult,
+			Tool:    p.toolNames[block.ToolUseID],
+			Content: toolResultText(block.Content),
+			IsError: block.IsError,
+		})
+	}
+	return evs
+}
+
+// toolResultText flattens a tool result, which is either a string or a list
+// of content blocks
+func toolResultText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text
+	}
+	var blocks []contentBlock
+	if err := json.Unmarshal(raw, &blocks); err == nil {
+		var parts []string
+		for _, b := range blocks {
+			if b.Type == "text" {
+				parts = append(parts, b.Text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return string(raw)
+}
+
+// Events returns every event parsed so far
+func (p *StreamParser) Events() []*models.AgentEvent {
+	return p.events
+}
+
+// FinalMessage returns the text the agent finished with: the session result
+// if claude reported one, otherwise its last assistant message
+func (p *StreamParser) FinalMessage() string {
+	if p.result != nil && p.result.Content != "" {
+		return p.result.Content
+	}
+	return p.lastMessage
+}
+
+// Usage returns the tokens used: the session total if claude reported one,
+// otherwise the sum over assistant messages
+func (p *StreamParser) Usage() models.TokenUsage {
+	if p.result != nil && p.result.Usage.Total() > 0 {
+		return p.result.Usage
+	}
+	return p.usage
+}
+
+// RenderEvent formats an event as human-readable log lines
+func RenderEvent(ev *models.AgentEvent) []string {
+	switch ev.Type {
+	case models.AgentEventInit:
+		if ev.Content != "" {
+			return []string{fmt.Sprintf("Session started (model %s)", ev.Content)}
+		}
+		return []string{"Session started"}
+	case models.AgentEventMessage:
+		return strings.Split(strings.TrimRight(ev.Content, "\n"), "\n")
+	case models.AgentEventToolCall:
+		return []string{fmt.Sprintf("> %s %s", ev.Tool, summarize(ev.Content))}
+	case models.AgentEventToolResult:
+		marker := "<"
+		if ev.IsError {
+			marker = "< error:"
+		}
+		return []string{strings.TrimSpace(fmt.Sprintf("%s %s %s", marker, ev.Tool, summarize(ev.Content)))}
+	case models.AgentEventResult:
+		status := "Finished"
+		if ev.IsError {
+			status = "Finished with error"
+		}
+		return []string{fmt.Sprintf("%s (%d input / %d output tokens)", status, ev.Usage.InputTokens, ev.Usage.OutputTokens)}
+	}
+	return nil
+}
+
+// summarize collapses text to a single line of at most maxToolSummary runes
+func summarize(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > maxToolSummary {
+		return string(r[:maxToolSummary]) + "..."
+	}
+	return text
+}
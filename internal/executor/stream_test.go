@@ -0,0 +1,136 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// claudeStream is a trimmed transcript of claude --output-format stream-json
+var claudeStream = []string{
+	`{"type":"system","subtype":"init","session_id":"s1","model":"qwen3-coder:70b","tools":["Bash","Edit"]}`,
+	`{"type":"assistant","message":{"id":"m1","content":[{"type":"text","text":"Running the tests. Iteration: 5"}],"usage":{"input_tokens":100,"output_tokens":10}}}`,
+	`{"type":"assistant","message":{"id":"m1","content":[{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test ./..."}}],"usage":{"input_tokens":100,"output_tokens":10}}}`,
+	`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","content":"FAIL auth_test.go\nexit status 1","is_error":true}]}}`,
+	`{"type":"assistant","message":{"id":"m2","content":[{"type":"text","text":"Tests pass now. I will not print <promise>COMPLETE</promise> until asked."}],"usage":{"input_tokens":200,"output_tokens":20}}}`,
+	`{"type":"assistant","message":{"id":"m3","content":[{"type":"text","text":"All done."}],"usage":{"input_tokens":50,"output_tokens":5}}}`,
+	`{"type":"result","subtype":"success","is_error":false,"num_turns":3,"result":"All done.","usage":{"input_tokens":350,"output_tokens":35,"cache_read_input_tokens":1000}}`,
+}
+
+func parseAll(p *StreamParser, lines []string) {
+	for _, line := range lines {
+		p.Parse(line)
+	}
+}
+
+func TestStreamParser_Events(t *testing.T) {
+	p := NewStreamParser()
+	parseAll(p, claudeStream)
+
+	evs := p.Events()
+	require.Len(t, evs, 7)
+
+	assert.Equal(t, models.AgentEventInit, evs[0].Type)
+	assert.Equal(t, "qwen3-coder:70b", evs[0].Content)
+
+	assert.Equal(t, models.AgentEventMessage, evs[1].Type)
+	assert.Equal(t, int64(100), evs[1].Usage.InputTokens)
+
+	assert.Equal(t, models.AgentEventToolCall, evs[2].Type)
+	assert.Equal(t, "Bash", evs[2].Tool)
+	assert.JSONEq(t, `{"command":"go test ./..."}`, evs[2].Content)
+	assert.Zero(t, evs[2].Usage.Total(), "usage of a repeated message is counted once")
+
+	assert.Equal(t, models.AgentEventToolResult, evs[3].Type)
+	assert.Equal(t, "Bash", evs[3].Tool)
+	assert.True(t, evs[3].IsError)
+	assert.Contains(t, evs[3].Content, "FAIL")
+
+	assert.Equal(t, models.AgentEventResult, evs[6].Type)
+	assert.Equal(t, "All done.", evs[6].Content)
+}
+
+func TestStreamParser_FinalMessageAndUsage(t *testing.T) {
+	p := NewStreamParser()
+	parseAll(p, claudeStream)
+
+	// A promise mentioned mid-run does not count; only the final message does
+	assert.Equal(t, "All done.", p.FinalMessage())
+	assert.False(t, IsComplete(p.FinalMessage()))
+
+	assert.Equal(t, models.TokenUsage{InputTokens: 350, OutputTokens: 35, CacheReadTokens: 1000}, p.Usage())
+}
+
+func TestStreamParser_NoResult(t *testing.T) {
+	// claude was killed before reporting a result
+	p := NewStreamParser()
+	parseAll(p, claudeStream[:5])
+
+	assert.Contains(t, p.FinalMessage(), "Tests pass now")
+	assert.Equal(t, models.TokenUsage{InputTokens: 300, OutputTokens: 30}, p.Usage())
+}
+
+func TestStreamParser_NonJSON(t *testing.T) {
+	p := NewStreamParser()
+
+	for _, line := range []string{"", "Error: connection refused", "{not json", `{"no":"type"}`} {
+		evs, ok := p.Parse(line)
+		assert.False(t, ok, line)
+		assert.Empty(t, evs)
+	}
+}
+
+func TestStreamParser_ToolResultBlocks(t *testing.T) {
+	p := NewStreamParser()
+	evs, ok := p.Parse(`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"x","content":[{"type":"text","text":"line one"},{"type":"text","text":"line two"}]}]}}`)
+	require.True(t, ok)
+	require.Len(t, evs, 1)
+	assert.Equal(t, "line one\nline two", evs[0].Content)
+}
+
+func TestRenderEvent(t *testing.T) {
+	assert.Equal(t, []string{"first", "second"}, RenderEvent(&models.AgentEvent{Type: models.AgentEventMessage, Content: "first\nsecond\n"}))
+	assert.Equal(t, []string{`> Bash {"command": "ls"}`}, RenderEvent(&models.AgentEvent{Type: models.AgentEventToolCall, Tool: "Bash", Content: "{\"command\":\n \"ls\"}"}))
+	assert.Equal(t, []string{"< error: Bash boom"}, RenderEvent(&models.AgentEvent{Type: models.AgentEventToolResult, Tool: "Bash", Content: "boom", IsError: true}))
+
+	long := RenderEvent(&models.AgentEvent{Type: models.AgentEventToolResult, Tool: "Read", Content: strings.Repeat("x", 500)})
+	assert.Less(t, len(long[0]), 220)
+}
+
+func TestClaudeExecutor_Execute_StreamJSON(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake claude is a shell script")
+	}
+
+	binDir := t.TempDir()
+	transcript := filepath.Join(binDir, "transcript.jsonl")
+	final := `{"type":"result","subtype":"success","is_error":false,"result":"Everything passes.\n<promise>COMPLETE</promise>","usage":{"input_tokens":10,"output_tokens":2}}`
+	require.NoError(t, os.WriteFile(transcript, []byte(strings.Join(append(claudeStream[:4], final), "\n")+"\n"), 0o644))
+	script := "#!/bin/sh\ncat >/dev/null\necho 'warning: something on stderr' >&2\ncat " + transcript + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "claude"), []byte(script), 0o755))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	var lines []string
+	result, err := NewClaudeExecutor(models.DefaultServerConfig()).Execute(context.Background(), t.TempDir(), "prompt", nil, func(line string) {
+		lines = append(lines, line)
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, result.Error)
+	assert.True(t, result.Completed)
+	assert.Len(t, result.Events, 5)
+	assert.Equal(t, int64(10), result.Usage.InputTokens)
+
+	// Events are logged readably, and non-JSON lines verbatim
+	assert.Contains(t, lines, "warning: something on stderr")
+	assert.Contains(t, lines, "Running the tests. Iteration: 5")
+	assert.Contains(t, lines, `> Bash {"command":"go test ./..."}`)
+	assert.NotContains(t, strings.Join(lines, "\n"), `"type":"assistant"`)
+}
@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// maxVerifyOutput caps how much verify output is kept for the next prompt
+// and the PR body; the full output is still written to the job log
+const maxVerifyOutput = 8 * 1024
+
+// Verify runs command through the shell in workDir and reports whether it
+// passed. A command that runs and fails is a failed Verification, not an
+// error; the error return is reserved for failing to start it.
+func Verify(ctx context.Context, workDir, command string, env map[string]string, onOutput OutputCallback) (*models.Verification, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = workDir
+	cmd.Env = buildEnv(nil, env)
+
+	start := time.Now()
+	output, exitErr, err := runProcess(ctx, cmd, DefaultKillGrace, onOutput)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Verification{
+		Command:  command,
+		Passed:   exitErr == nil,
+		ExitCode: ExitCode(exitErr),
+		Output:   tail(output, maxVerifyOutput),
+		Duration: time.Since(start),
+	}, nil
+}
+
+// VerificationFeedback appends a failed verification to the job's prompt so
+// the next iteration knows why its completion was rejected
+func VerificationFeedback(prompt string, v *models.Verification) string {
+	return fmt.Sprintf("%s\n\n## Verification failed\n\n"+
+		"The last iteration reported the task complete, but the verification command `%s` "+
+		"exited with code %d. Fix the problems below before reporting completion again.\n\n```\n%s\n```\n",
+		strings.TrimRight(prompt, "\n"), v.Command, v.ExitCode, strings.TrimRight(v.Output, "\n"))
+}
+
+// tail returns the last max bytes of s, starting on a line boundary
+func tail(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	s = s[len(s)-max:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return "...\n" + s
+}
@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"context"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("verify commands run through sh")
+	}
+
+	v, err := Verify(context.Background(), t.TempDir(), "echo ok", nil, nil)
+	require.NoError(t, err)
+	assert.True(t, v.Passed)
+	assert.Equal(t, 0, v.ExitCode)
+	assert.Equal(t, "ok\n", v.Output)
+
+	var lines []string
+	v, err = Verify(context.Background(), t.TempDir(), "echo $GREETING; echo broken >&2; exit 2", map[string]string{"GREETING": "hi"}, func(line string) {
+		lines = append(lines, line)
+	})
+	require.NoError(t, err)
+	assert.False(t, v.Passed)
+	assert.Equal(t, 2, v.ExitCode)
+	assert.ElementsMatch(t, []string{"hi", "broken"}, lines)
+}
+
+func TestVerify_ScrubsEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("verify commands run through sh")
+	}
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "server-credential")
+	t.Setenv("LC_ALL", "C")
+
+	v, err := Verify(context.Background(), t.TempDir(), "echo \"[$AWS_SECRET_ACCESS_KEY] [$LC_ALL] [$TOKEN]\"", map[string]string{"TOKEN": "job"}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "[] [C] [job]\n", v.Output)
+}
+
+func TestVerificationFeedback(t *testing.T) {
+	prompt := VerificationFeedback("Fix the tests\n", &models.Verification{Command: "make test", ExitCode: 2, Output: "FAIL\n"})
+
+	assert.True(t, strings.HasPrefix(prompt, "Fix the tests\n\n## Verification failed"))
+	assert.Contains(t, prompt, "`make test`")
+	assert.Contains(t, prompt, "exited with code 2")
+	assert.Contains(t, prompt, "```\nFAIL\n```")
+}
+
+func TestTail(t *testing.T) {
+	assert.Equal(t, "short", tail("short", 100))
+	assert.Equal(t, "...\nthree\n", tail("one\ntwo\nthree\n", 8))
+}
@@ -5,7 +5,11 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
 )
 
 // GH wraps the GitHub CLI
@@ -31,6 +35,7 @@ func (g *GH) IsAuthenticated() bool {
 // Clone clones a repository using gh
 func (g *GH) Clone(ctx context.Context, repoURL, branch, dest string) error {
 	cmd := exec.CommandContext(ctx, "gh", "repo", "clone", repoURL, dest, "--", "--branch", branch)
+	cmd.Env = HostEnv()
 
 	var stderr bytes.Buffer
 	cmd.Stderr = &stderr
@@ -51,6 +56,7 @@ func (g *GH) CreatePR(ctx context.Context, dir, baseBranch, headBranch, title, b
 		"--body", body,
 	)
 	cmd.Dir = dir
+	cmd.Env = workspaceEnv(dir)
 
 	output, err := cmd.Output()
 	if err != nil {
@@ -65,6 +71,7 @@ func (g *GH) CreatePR(ctx context.Context, dir, baseBranch, headBranch, title, b
 func (g *GH) GetPRURL(ctx context.Context, dir, branch string) (string, error) {
 	cmd := exec.CommandContext(ctx, "gh", "pr", "view", branch, "--json", "url", "-q", ".url")
 	cmd.Dir = dir
+	cmd.Env = workspaceEnv(dir)
 
 	output, err := cmd.Output()
 	if err != nil {
@@ -74,16 +81,36 @@ func (g *GH) GetPRURL(ctx context.Context, dir, branch string) (string, error) {
 	return strings.TrimSpace(string(output)), nil
 }
 
-// BuildPRBody generates the PR description
-func BuildPRBody(iterations int, success bool, specPath string, details map[string]string) string {
+// workspaceEnv is HostEnv for running gh in the repository rooted at dir,
+// which gh's own git commands are pointed at like workspaceArgs does
+func workspaceEnv(dir string) []string {
+	return append(HostEnv(), "GIT_DIR="+filepath.Join(dir, ".git"), "GIT_WORK_TREE="+dir)
+}
+
+// BuildPRBody generates the PR description. verification, when the job has
+// a verify command, is the last result of running it.
+func BuildPRBody(iterations int, success bool, specPath string, details map[string]string, verification *models.Verification) string {
 	var sb strings.Builder
 
 	sb.WriteString("## Summary\n\n")
 
-	if success {
-		sb.WriteString(fmt.Sprintf("Completed in %d iterations. All tests passing.\n\n", iterations))
-	} else {
-		sb.WriteString(fmt.Sprintf("Reached max iterations (%d) without completing. Tests may still be failing.\n\n", iterations))
+	switch {
+	case success && verification != nil:
+		sb.WriteString(fmt.Sprintf("Completed in %d iterations. Verification passed.\n\n", iterations))
+	case success:
+		sb.WriteString(fmt.Sprintf("Completed in %d iterations. No verify command was configured, so the result has not been checked.\n\n", iterations))
+	default:
+		sb.WriteString(fmt.Sprintf("Stopped after %d iterations without completing.\n\n", iterations))
+	}
+
+	if verification != nil {
+		sb.WriteString("## Verification\n\n")
+		if verification.Passed {
+			sb.WriteString(fmt.Sprintf("`%s` passed in %s.\n\n", verification.Command, verification.Duration.Round(time.Second)))
+		} else {
+			sb.WriteString(fmt.Sprintf("`%s` failed with exit code %d:\n\n", verification.Command, verification.ExitCode))
+			sb.WriteString("```\n" + strings.TrimRight(verification.Output, "\n") + "\n```\n\n")
+		}
 	}
 
 	if specPath != "" {
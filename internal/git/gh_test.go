@@ -3,6 +3,7 @@ package git
 import (
 	"testing"
 
+	"github.com/ryan/ralph-o-matic/internal/models"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -27,7 +28,7 @@ func TestGH_IsAuthenticated(t *testing.T) {
 }
 
 func TestGH_BuildPRBody(t *testing.T) {
-	body := BuildPRBody(8, true, "docs/plans/design.md", nil)
+	body := BuildPRBody(8, true, "docs/plans/design.md", nil, nil)
 
 	assert.Contains(t, body, "8 iterations")
 	assert.Contains(t, body, "docs/plans/design.md")
@@ -37,9 +38,24 @@ func TestGH_BuildPRBody(t *testing.T) {
 func TestGH_BuildPRBody_Failed(t *testing.T) {
 	body := BuildPRBody(50, false, "docs/plans/design.md", map[string]string{
 		"remaining_issues": "3 tests failing",
-	})
+	}, nil)
 
 	assert.Contains(t, body, "50")
 	assert.Contains(t, body, "without completing")
 	assert.Contains(t, body, "3 tests failing")
 }
+
+func TestGH_BuildPRBody_Verification(t *testing.T) {
+	passed := BuildPRBody(3, true, "", nil, &models.Verification{Command: "go test ./...", Passed: true})
+	assert.Contains(t, passed, "Verification passed")
+	assert.Contains(t, passed, "`go test ./...` passed")
+
+	failed := BuildPRBody(50, false, "", nil, &models.Verification{Command: "go test ./...", ExitCode: 1, Output: "--- FAIL: TestAuth\n"})
+	assert.Contains(t, failed, "failed with exit code 1")
+	assert.Contains(t, failed, "--- FAIL: TestAuth")
+	assert.NotContains(t, failed, "passing")
+
+	unchecked := BuildPRBody(3, true, "", nil, nil)
+	assert.Contains(t, unchecked, "has not been checked")
+	assert.NotContains(t, unchecked, "All tests passing")
+}
@@ -4,11 +4,46 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
-// Git wraps git command execution
+// hostConfig overrides the repository settings that make git run programs
+// from the workspace. Agents can write to the workspace, and the server runs
+// git there as itself, so hooks, fsmonitor, diff and ssh commands planted in
+// its config are ignored. Filters and textconv drivers named in
+// .gitattributes only run when config defines them, which agents cannot
+// reach: workspace commands name the root .git explicitly, and the sandbox
+// mounts it read-only.
+var hostConfig = [][2]string{
+	{"core.hooksPath", os.DevNull},
+	{"core.fsmonitor", "false"},
+	{"core.sshCommand", "ssh"},
+	{"core.pager", "cat"},
+	{"core.attributesFile", os.DevNull},
+	{"diff.external", os.DevNull},
+}
+
+// HostEnv returns the environment to run git and gh in a workspace with: the
+// server's own, with hostConfig added to any config it already passes to git
+func HostEnv() []string {
+	env := os.Environ()
+	n, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	for _, kv := range hostConfig {
+		env = append(env,
+			fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", n, kv[0]),
+			fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", n, kv[1]))
+		n++
+	}
+	return append(env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", n))
+}
+
+// Git wraps git command execution. The dir passed to its methods is the root
+// of a repository, whose .git is used no matter what lies below it.
 type Git struct{}
 
 // New creates a new Git wrapper
@@ -67,6 +102,16 @@ func (g *Git) GetCurrentBranch(ctx context.Context, dir string) (string, error)
 	return strings.TrimSpace(output), nil
 }
 
+// UserEmail returns the configured user.email, which identifies who
+// submitted a job when the server does not authenticate
+func (g *Git) UserEmail(ctx context.Context, dir string) (string, error) {
+	output, err := g.runOutput(ctx, dir, "config", "user.email")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
+}
+
 // HasUncommittedChanges checks if there are uncommitted changes
 func (g *Git) HasUncommittedChanges(ctx context.Context, dir string) (bool, error) {
 	output, err := g.runOutput(ctx, dir, "status", "--porcelain")
@@ -81,13 +126,65 @@ func (g *Git) AddAll(ctx context.Context, dir string) error {
 	return g.run(ctx, dir, "add", "-A")
 }
 
+// DiffStat summarizes the changes made by a commit
+type DiffStat struct {
+	FilesChanged int
+	Insertions   int
+	Deletions    int
+}
+
+var shortStatPattern = regexp.MustCompile(`(\d+) (file|insertion|deletion)`)
+
+// ShortStat returns the diffstat of a commit
+func (g *Git) ShortStat(ctx context.Context, dir, rev string) (DiffStat, error) {
+	output, err := g.runOutput(ctx, dir, "show", "--no-ext-diff", "--no-textconv", "--shortstat", "--format=", rev)
+	if err != nil {
+		return DiffStat{}, err
+	}
+	return ParseShortStat(output), nil
+}
+
+// Patch returns the diff a commit introduced, without its header, so the
+// same change made in two commits yields the same text
+func (g *Git) Patch(ctx context.Context, dir, rev string) (string, error) {
+	return g.runOutput(ctx, dir, "show", "--no-ext-diff", "--no-textconv", "--format=", "--no-color", rev)
+}
+
+// ParseShortStat parses git's --shortstat summary, e.g.
+// "3 files changed, 10 insertions(+), 2 deletions(-)"
+func ParseShortStat(output string) DiffStat {
+	var stat DiffStat
+	for _, m := range shortStatPattern.FindAllStringSubmatch(output, -1) {
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "file":
+			stat.FilesChanged = n
+		case "insertion":
+			stat.Insertions = n
+		case "deletion":
+			stat.Deletions = n
+		}
+	}
+	return stat
+}
+
 // GetLog returns the git log
 func (g *Git) GetLog(ctx context.Context, dir string, limit int) (string, error) {
 	return g.runOutput(ctx, dir, "log", "--oneline", fmt.Sprintf("-n%d", limit))
 }
 
+// workspaceArgs names dir's own .git and work tree explicitly, so a .git or
+// gitdir file planted below dir is never discovered instead
+func workspaceArgs(dir string, args []string) []string {
+	if dir == "" {
+		return args
+	}
+	return append([]string{"--git-dir=" + filepath.Join(dir, ".git"), "--work-tree=" + dir}, args...)
+}
+
 func (g *Git) run(ctx context.Context, dir string, args ...string) error {
-	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd := exec.CommandContext(ctx, "git", workspaceArgs(dir, args)...)
+	cmd.Env = HostEnv()
 	if dir != "" {
 		cmd.Dir = dir
 	}
@@ -103,7 +200,8 @@ func (g *Git) run(ctx context.Context, dir string, args ...string) error {
 }
 
 func (g *Git) runOutput(ctx context.Context, dir string, args ...string) (string, error) {
-	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd := exec.CommandContext(ctx, "git", workspaceArgs(dir, args)...)
+	cmd.Env = HostEnv()
 	if dir != "" {
 		cmd.Dir = dir
 	}
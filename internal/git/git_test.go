@@ -4,7 +4,9 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -84,6 +86,124 @@ func TestGit_Commit(t *testing.T) {
 	assert.Len(t, hash, 7) // Short hash
 }
 
+func TestGit_IgnoresPlantedHooks(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks are shell scripts")
+	}
+
+	g := New()
+	tmpDir := t.TempDir()
+	_ = g.run(context.Background(), tmpDir, "init")
+	_ = g.run(context.Background(), tmpDir, "config", "user.email", "test@test.com")
+	_ = g.run(context.Background(), tmpDir, "config", "user.name", "Test")
+
+	// What an agent could leave behind in the workspace: a hook in the
+	// default location, and config pointing hooks and fsmonitor elsewhere
+	ran := filepath.Join(t.TempDir(), "ran")
+	hook := "#!/bin/sh\necho $0 >> " + ran + "\n"
+	hooks := filepath.Join(tmpDir, "planted")
+	require.NoError(t, os.MkdirAll(hooks, 0o755))
+	for _, path := range []string{filepath.Join(tmpDir, ".git", "hooks", "pre-commit"), filepath.Join(hooks, "pre-commit"), filepath.Join(hooks, "fsmonitor")} {
+		require.NoError(t, os.WriteFile(path, []byte(hook), 0o755))
+	}
+	config, err := os.OpenFile(filepath.Join(tmpDir, ".git", "config"), os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = config.WriteString("[core]\n\thooksPath = " + hooks + "\n\tfsmonitor = " + filepath.Join(hooks, "fsmonitor") + "\n")
+	require.NoError(t, err)
+	require.NoError(t, config.Close())
+
+	os.WriteFile(filepath.Join(tmpDir, "test.txt"), []byte("hello"), 0644)
+	require.NoError(t, g.AddAll(context.Background(), tmpDir))
+	hash, err := g.Commit(context.Background(), tmpDir, "Test commit")
+	require.NoError(t, err)
+	_, err = g.ShortStat(context.Background(), tmpDir, hash)
+	require.NoError(t, err)
+
+	assert.NoFileExists(t, ran, "no planted hook ran")
+}
+
+func TestGit_IgnoresPlantedCommands(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	g := New()
+	tmpDir := t.TempDir()
+	_ = g.run(context.Background(), tmpDir, "init")
+	_ = g.run(context.Background(), tmpDir, "config", "user.email", "test@test.com")
+	_ = g.run(context.Background(), tmpDir, "config", "user.name", "Test")
+	_ = g.run(context.Background(), tmpDir, "remote", "add", "origin", "git@ralph.invalid:user/repo.git")
+
+	// What an agent could leave behind: a repository of its own in its
+	// working_dir, and config in either one that runs a program on push or diff
+	ran := filepath.Join(t.TempDir(), "ran")
+	sub := filepath.Join(tmpDir, "services", "api")
+	require.NoError(t, os.MkdirAll(sub, 0o755))
+	script := filepath.Join(sub, "planted.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho $0 >> "+ran+"\nexit 1\n"), 0o755))
+	_ = g.run(context.Background(), "", "init", sub)
+	_ = g.run(context.Background(), "", "-C", sub, "remote", "add", "origin", "git@ralph.invalid:user/repo.git")
+	_ = g.run(context.Background(), "", "-C", sub, "-c", "user.email=test@test.com", "-c", "user.name=Test", "commit", "--allow-empty", "-m", "Planted")
+	for _, key := range []string{"core.sshCommand", "diff.external", "core.pager"} {
+		_ = g.run(context.Background(), tmpDir, "config", key, script)
+		_ = g.run(context.Background(), "", "-C", sub, "config", key, script)
+	}
+
+	os.WriteFile(filepath.Join(sub, "test.txt"), []byte("hello"), 0644)
+	require.NoError(t, g.AddAll(context.Background(), tmpDir))
+	hash, err := g.Commit(context.Background(), tmpDir, "Test commit")
+	require.NoError(t, err)
+	_, err = g.ShortStat(context.Background(), tmpDir, hash)
+	require.NoError(t, err)
+	_, err = g.Patch(context.Background(), tmpDir, hash)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.Error(t, g.Push(ctx, tmpDir, "master"))
+
+	assert.NoFileExists(t, ran, "no planted command ran")
+}
+
+func TestGit_ShortStat(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	g := New()
+	tmpDir := t.TempDir()
+
+	_ = g.run(context.Background(), tmpDir, "init")
+	_ = g.run(context.Background(), tmpDir, "config", "user.email", "test@test.com")
+	_ = g.run(context.Background(), tmpDir, "config", "user.name", "Test")
+
+	os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("one\ntwo\n"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "b.txt"), []byte("three\n"), 0644)
+	_ = g.run(context.Background(), tmpDir, "add", ".")
+	hash, err := g.Commit(context.Background(), tmpDir, "Test commit")
+	require.NoError(t, err)
+
+	stat, err := g.ShortStat(context.Background(), tmpDir, hash)
+	require.NoError(t, err)
+	assert.Equal(t, DiffStat{FilesChanged: 2, Insertions: 3}, stat)
+
+	patch, err := g.Patch(context.Background(), tmpDir, hash)
+	require.NoError(t, err)
+	assert.Contains(t, patch, "+three")
+	assert.NotContains(t, patch, "Test commit")
+}
+
+func TestParseShortStat(t *testing.T) {
+	assert.Equal(t, DiffStat{FilesChanged: 3, Insertions: 10, Deletions: 2},
+		ParseShortStat(" 3 files changed, 10 insertions(+), 2 deletions(-)\n"))
+	assert.Equal(t, DiffStat{FilesChanged: 1, Deletions: 1},
+		ParseShortStat(" 1 file changed, 1 deletion(-)\n"))
+	assert.Equal(t, DiffStat{}, ParseShortStat(""))
+}
+
 func TestGit_GetCurrentBranch(t *testing.T) {
 	if testing.Short() {
 		t.Skip("skipping integration test")
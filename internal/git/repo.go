@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
 )
 
-// RepoManager handles repository operations for jobs
+// RepoManager handles repository operations for jobs. The workDir its
+// methods take is the root of a job's workspace, not its working_dir.
 type RepoManager struct {
 	workspaceDir string
 	git          *Git
@@ -28,6 +31,12 @@ func (rm *RepoManager) WorkspacePath(jobID int64) string {
 	return filepath.Join(rm.workspaceDir, fmt.Sprintf("job-%d", jobID))
 }
 
+// HasWorkspace returns true if the job's workspace holds a cloned repository
+func (rm *RepoManager) HasWorkspace(jobID int64) bool {
+	info, err := os.Stat(filepath.Join(rm.WorkspacePath(jobID), ".git"))
+	return err == nil && info.IsDir()
+}
+
 // ResultBranch returns the result branch name for a source branch
 func (rm *RepoManager) ResultBranch(sourceBranch string) string {
 	return "ralph/" + sourceBranch + "-result"
@@ -83,8 +92,19 @@ func (rm *RepoManager) Commit(ctx context.Context, workDir, message string) (str
 	return hash, nil
 }
 
-// PushAndCreatePR pushes the branch and creates a PR
-func (rm *RepoManager) PushAndCreatePR(ctx context.Context, workDir, baseBranch string, iterations int, success bool, specPath string) (string, error) {
+// DiffStat returns the diffstat of a commit in a workspace
+func (rm *RepoManager) DiffStat(ctx context.Context, workDir, hash string) (DiffStat, error) {
+	return rm.git.ShortStat(ctx, workDir, hash)
+}
+
+// Patch returns the diff a commit in a workspace introduced
+func (rm *RepoManager) Patch(ctx context.Context, workDir, hash string) (string, error) {
+	return rm.git.Patch(ctx, workDir, hash)
+}
+
+// PushAndCreatePR pushes the branch and creates a PR. reason says why an
+// unsuccessful job stopped.
+func (rm *RepoManager) PushAndCreatePR(ctx context.Context, workDir, baseBranch string, iterations int, success bool, reason, specPath string, verification *models.Verification) (string, error) {
 	resultBranch := rm.ResultBranch(baseBranch)
 
 	// Push the branch
@@ -94,7 +114,11 @@ func (rm *RepoManager) PushAndCreatePR(ctx context.Context, workDir, baseBranch
 
 	// Create PR
 	title := BuildPRTitle(baseBranch, success)
-	body := BuildPRBody(iterations, success, specPath, nil)
+	var details map[string]string
+	if reason != "" {
+		details = map[string]string{"Stopped": reason}
+	}
+	body := BuildPRBody(iterations, success, specPath, details, verification)
 
 	prURL, err := rm.gh.CreatePR(ctx, workDir, baseBranch, resultBranch, title, body)
 	if err != nil {
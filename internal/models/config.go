@@ -3,12 +3,13 @@ package models
 import (
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 )
 
 // ModelPlacement describes which model to use and where to run it
 type ModelPlacement struct {
 	Name     string  `json:"name"`
-	Device   string  `json:"device"`    // "gpu", "cpu", or "auto"
+	Device   string  `json:"device"` // "gpu", "cpu", or "auto"
 	MemoryGB float64 `json:"memory_gb"`
 }
 
@@ -26,17 +27,168 @@ func (mp *ModelPlacement) Validate() error {
 	return nil
 }
 
-// OllamaConfig holds connection settings for the Ollama server
+// OllamaEndpoint is one Ollama server in a pool that jobs are spread across
+type OllamaEndpoint struct {
+	Name     string   `json:"name,omitempty"`
+	Host     string   `json:"host"`
+	Capacity int      `json:"capacity,omitempty"` // jobs it runs at once; 0 means 1
+	Tags     []string `json:"tags,omitempty"`     // e.g. "gpu", "a100"; jobs can require them
+}
+
+// Slots returns how many jobs the endpoint runs at once
+func (e *OllamaEndpoint) Slots() int {
+	if e.Capacity <= 0 {
+		return 1
+	}
+	return e.Capacity
+}
+
+// HasTags reports whether the endpoint carries every one of tags
+func (e *OllamaEndpoint) HasTags(tags []string) bool {
+	for _, want := range tags {
+		found := false
+		for _, have := range e.Tags {
+			if have == want {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// OllamaConfig holds connection settings for the Ollama server. When
+// Endpoints is set, jobs are routed across them and Host is unused.
 type OllamaConfig struct {
-	Host     string `json:"host"`
-	IsRemote bool   `json:"is_remote"`
+	Host      string           `json:"host"`
+	IsRemote  bool             `json:"is_remote"`
+	Endpoints []OllamaEndpoint `json:"endpoints,omitempty"`
 }
 
-// Validate checks that host is set
+// Validate checks that a host or a pool of endpoints is set
 func (oc *OllamaConfig) Validate() error {
-	if oc.Host == "" {
+	if oc.Host == "" && len(oc.Endpoints) == 0 {
 		return fmt.Errorf("ollama host is required")
 	}
+	seen := make(map[string]bool)
+	for i, e := range oc.Endpoints {
+		if e.Host == "" {
+			return fmt.Errorf("endpoints[%d]: host is required", i)
+		}
+		if seen[e.Host] {
+			return fmt.Errorf("endpoints[%d]: duplicate host %s", i, e.Host)
+		}
+		seen[e.Host] = true
+		if e.Capacity < 0 {
+			return fmt.Errorf("endpoints[%d]: capacity cannot be negative", i)
+		}
+	}
+	return nil
+}
+
+// Hosts returns every Ollama host the server runs jobs against: the pool's
+// endpoints, or the single host when there is no pool
+func (oc *OllamaConfig) Hosts() []string {
+	if len(oc.Endpoints) == 0 {
+		return []string{oc.Host}
+	}
+	hosts := make([]string, len(oc.Endpoints))
+	for i, e := range oc.Endpoints {
+		hosts[i] = e.Host
+	}
+	return hosts
+}
+
+// Scheduling policies
+const (
+	PolicyPriority  = "priority"   // highest priority first, then queue position
+	PolicyFairShare = "fair_share" // the owner or repository using the least of its share first
+)
+
+// What fair-share scheduling divides the server between
+const (
+	FairShareByOwner = "owner"
+	FairShareByRepo  = "repo"
+)
+
+// SchedulingConfig selects how the scheduler picks the next queued job
+type SchedulingConfig struct {
+	Policy       string             `json:"policy"`                  // PolicyPriority or PolicyFairShare; empty is priority
+	FairShareBy  string             `json:"fair_share_by,omitempty"` // FairShareByOwner or FairShareByRepo; empty is owner
+	Weights      map[string]float64 `json:"weights,omitempty"`       // share of each owner or repository; others get 1
+	AgingMinutes int                `json:"aging_minutes,omitempty"` // a queued job gains a priority level per this many minutes waited; 0 disables
+}
+
+// Validate checks the policy and its settings are known values
+func (sc *SchedulingConfig) Validate() error {
+	switch sc.Policy {
+	case "", PolicyPriority, PolicyFairShare:
+	default:
+		return fmt.Errorf("policy must be %s or %s; got %q", PolicyPriority, PolicyFairShare, sc.Policy)
+	}
+	switch sc.FairShareBy {
+	case "", FairShareByOwner, FairShareByRepo:
+	default:
+		return fmt.Errorf("fair_share_by must be %s or %s; got %q", FairShareByOwner, FairShareByRepo, sc.FairShareBy)
+	}
+	for name, w := range sc.Weights {
+		if w <= 0 {
+			return fmt.Errorf("weights[%s] must be positive", name)
+		}
+	}
+	if sc.AgingMinutes < 0 {
+		return fmt.Errorf("aging_minutes cannot be negative")
+	}
+	return nil
+}
+
+// Sandbox runtimes
+const (
+	SandboxBwrap  = "bwrap"  // bubblewrap namespaces on the host's own filesystem
+	SandboxPodman = "podman" // a container from SandboxConfig.Image
+	SandboxDocker = "docker"
+)
+
+// SandboxConfig confines the agent and verify command of every job to its
+// workspace, with network access only to Ollama and the job's git remote
+type SandboxConfig struct {
+	Runtime  string   `json:"runtime"`             // SandboxBwrap, SandboxPodman or SandboxDocker; empty runs jobs unconfined
+	Image    string   `json:"image,omitempty"`     // image providing the agents, for podman and docker
+	CPUs     float64  `json:"cpus,omitempty"`      // CPU cores each job may use; 0 is unlimited
+	MemoryGB float64  `json:"memory_gb,omitempty"` // memory each job may use; 0 is unlimited
+	Mounts   []string `json:"mounts,omitempty"`    // extra host paths mounted read-only, such as where the agents are installed
+}
+
+// Enabled reports whether jobs run in a sandbox
+func (sc *SandboxConfig) Enabled() bool {
+	return sc.Runtime != ""
+}
+
+// Validate checks the runtime is known and has what it needs
+func (sc *SandboxConfig) Validate() error {
+	switch sc.Runtime {
+	case "", SandboxBwrap:
+	case SandboxPodman, SandboxDocker:
+		if sc.Image == "" {
+			return fmt.Errorf("image is required with %s", sc.Runtime)
+		}
+	default:
+		return fmt.Errorf("runtime must be %s, %s or %s; got %q", SandboxBwrap, SandboxPodman, SandboxDocker, sc.Runtime)
+	}
+	if sc.CPUs < 0 {
+		return fmt.Errorf("cpus cannot be negative")
+	}
+	if sc.MemoryGB < 0 {
+		return fmt.Errorf("memory_gb cannot be negative")
+	}
+	for _, m := range sc.Mounts {
+		if !filepath.IsAbs(m) {
+			return fmt.Errorf("mount %q must be an absolute path", m)
+		}
+	}
 	return nil
 }
 
@@ -50,8 +202,16 @@ type ServerConfig struct {
 	SmallModel ModelPlacement `json:"small_model"`
 
 	// Execution
-	DefaultMaxIterations int `json:"default_max_iterations"`
-	ConcurrentJobs       int `json:"concurrent_jobs"`
+	Executor             string `json:"executor"` // default agent backend for jobs that don't pick one
+	DefaultMaxIterations int    `json:"default_max_iterations"`
+	ConcurrentJobs       int    `json:"concurrent_jobs"`
+	MaxJobsPerUser       int    `json:"max_jobs_per_user"` // active jobs each submitter may have; 0 is unlimited
+
+	// Order in which queued jobs start
+	Scheduling SchedulingConfig `json:"scheduling"`
+
+	// Isolation of the commands jobs run
+	Sandbox SandboxConfig `json:"sandbox"`
 
 	// Storage
 	WorkspaceDir     string `json:"workspace_dir"`
@@ -69,8 +229,10 @@ func DefaultServerConfig() *ServerConfig {
 		Ollama:               OllamaConfig{Host: "http://localhost:11434", IsRemote: false},
 		LargeModel:           ModelPlacement{Name: "qwen3-coder:70b", Device: "cpu", MemoryGB: 42},
 		SmallModel:           ModelPlacement{Name: "qwen2.5-coder:7b", Device: "gpu", MemoryGB: 5},
+		Executor:             "claude",
 		DefaultMaxIterations: 50,
 		ConcurrentJobs:       1,
+		Scheduling:           SchedulingConfig{Policy: PolicyPriority},
 		JobRetentionDays:     30,
 		MaxClaudeRetries:     3,
 		MaxGitRetries:        3,
@@ -95,6 +257,15 @@ func (c *ServerConfig) Validate() error {
 	if c.ConcurrentJobs <= 0 {
 		return fmt.Errorf("concurrent_jobs must be positive")
 	}
+	if err := c.Scheduling.Validate(); err != nil {
+		return fmt.Errorf("scheduling: %w", err)
+	}
+	if err := c.Sandbox.Validate(); err != nil {
+		return fmt.Errorf("sandbox: %w", err)
+	}
+	if c.MaxJobsPerUser < 0 {
+		return fmt.Errorf("max_jobs_per_user cannot be negative")
+	}
 	if c.JobRetentionDays < 0 {
 		return fmt.Errorf("job_retention_days cannot be negative")
 	}
@@ -112,6 +283,9 @@ func (c *ServerConfig) Merge(updates *ServerConfig) *ServerConfig {
 	if updates.Ollama.IsRemote {
 		result.Ollama.IsRemote = updates.Ollama.IsRemote
 	}
+	if updates.Ollama.Endpoints != nil {
+		result.Ollama.Endpoints = updates.Ollama.Endpoints
+	}
 
 	// LargeModel: merge individual fields
 	if updates.LargeModel.Name != "" {
@@ -135,12 +309,49 @@ func (c *ServerConfig) Merge(updates *ServerConfig) *ServerConfig {
 		result.SmallModel.MemoryGB = updates.SmallModel.MemoryGB
 	}
 
+	if updates.Executor != "" {
+		result.Executor = updates.Executor
+	}
 	if updates.DefaultMaxIterations > 0 {
 		result.DefaultMaxIterations = updates.DefaultMaxIterations
 	}
 	if updates.ConcurrentJobs > 0 {
 		result.ConcurrentJobs = updates.ConcurrentJobs
 	}
+	if updates.MaxJobsPerUser > 0 {
+		result.MaxJobsPerUser = updates.MaxJobsPerUser
+	}
+
+	// Scheduling: merge individual fields
+	if updates.Scheduling.Policy != "" {
+		result.Scheduling.Policy = updates.Scheduling.Policy
+	}
+	if updates.Scheduling.FairShareBy != "" {
+		result.Scheduling.FairShareBy = updates.Scheduling.FairShareBy
+	}
+	if updates.Scheduling.Weights != nil {
+		result.Scheduling.Weights = updates.Scheduling.Weights
+	}
+	if updates.Scheduling.AgingMinutes > 0 {
+		result.Scheduling.AgingMinutes = updates.Scheduling.AgingMinutes
+	}
+
+	// Sandbox: merge individual fields
+	if updates.Sandbox.Runtime != "" {
+		result.Sandbox.Runtime = updates.Sandbox.Runtime
+	}
+	if updates.Sandbox.Image != "" {
+		result.Sandbox.Image = updates.Sandbox.Image
+	}
+	if updates.Sandbox.CPUs > 0 {
+		result.Sandbox.CPUs = updates.Sandbox.CPUs
+	}
+	if updates.Sandbox.MemoryGB > 0 {
+		result.Sandbox.MemoryGB = updates.Sandbox.MemoryGB
+	}
+	if updates.Sandbox.Mounts != nil {
+		result.Sandbox.Mounts = updates.Sandbox.Mounts
+	}
 	if updates.WorkspaceDir != "" {
 		result.WorkspaceDir = updates.WorkspaceDir
 	}
@@ -183,6 +394,45 @@ func (c *ServerConfig) MergeJSON(raw json.RawMessage) (*ServerConfig, error) {
 			if _, ok := ollamaMap["is_remote"]; ok {
 				result.Ollama.IsRemote = updates.Ollama.IsRemote
 			}
+			if _, ok := ollamaMap["endpoints"]; ok {
+				result.Ollama.Endpoints = updates.Ollama.Endpoints
+			}
+		}
+	}
+
+	// 0 lifts the per-user quota
+	if _, ok := rawMap["max_jobs_per_user"]; ok {
+		result.MaxJobsPerUser = updates.MaxJobsPerUser
+	}
+
+	if schedRaw, ok := rawMap["scheduling"]; ok {
+		var schedMap map[string]json.RawMessage
+		if err := json.Unmarshal(schedRaw, &schedMap); err == nil {
+			if _, ok := schedMap["weights"]; ok {
+				result.Scheduling.Weights = updates.Scheduling.Weights
+			}
+			if _, ok := schedMap["aging_minutes"]; ok {
+				result.Scheduling.AgingMinutes = updates.Scheduling.AgingMinutes
+			}
+		}
+	}
+
+	if sandboxRaw, ok := rawMap["sandbox"]; ok {
+		var sandboxMap map[string]json.RawMessage
+		if err := json.Unmarshal(sandboxRaw, &sandboxMap); err == nil {
+			// An empty runtime turns the sandbox off
+			if _, ok := sandboxMap["runtime"]; ok {
+				result.Sandbox.Runtime = updates.Sandbox.Runtime
+			}
+			if _, ok := sandboxMap["cpus"]; ok {
+				result.Sandbox.CPUs = updates.Sandbox.CPUs
+			}
+			if _, ok := sandboxMap["memory_gb"]; ok {
+				result.Sandbox.MemoryGB = updates.Sandbox.MemoryGB
+			}
+			if _, ok := sandboxMap["mounts"]; ok {
+				result.Sandbox.Mounts = updates.Sandbox.Mounts
+			}
 		}
 	}
 
@@ -45,6 +45,15 @@ func TestModelPlacement_Validate(t *testing.T) {
 	})
 }
 
+func TestOllamaConfig_Hosts(t *testing.T) {
+	oc := OllamaConfig{Host: "http://localhost:11434"}
+	assert.Equal(t, []string{"http://localhost:11434"}, oc.Hosts())
+
+	// A pool replaces the single host
+	oc.Endpoints = []OllamaEndpoint{{Host: "http://gpu-1:11434"}, {Host: "http://gpu-2:11434"}}
+	assert.Equal(t, []string{"http://gpu-1:11434", "http://gpu-2:11434"}, oc.Hosts())
+}
+
 func TestOllamaConfig_Validate(t *testing.T) {
 	t.Run("valid passes", func(t *testing.T) {
 		oc := OllamaConfig{Host: "http://localhost:11434", IsRemote: false}
@@ -115,6 +124,25 @@ func TestServerConfig_Validate(t *testing.T) {
 		assert.Error(t, cfg.Validate())
 	})
 
+	t.Run("endpoint pool replaces the host", func(t *testing.T) {
+		cfg := validConfig()
+		cfg.Ollama.Host = ""
+		cfg.Ollama.Endpoints = []OllamaEndpoint{{Host: "http://gpu-1:11434"}, {Host: "http://gpu-2:11434", Capacity: 2}}
+		assert.NoError(t, cfg.Validate())
+	})
+
+	t.Run("invalid endpoints fail", func(t *testing.T) {
+		for _, endpoints := range [][]OllamaEndpoint{
+			{{Host: ""}},
+			{{Host: "http://gpu-1:11434"}, {Host: "http://gpu-1:11434"}},
+			{{Host: "http://gpu-1:11434", Capacity: -1}},
+		} {
+			cfg := validConfig()
+			cfg.Ollama.Endpoints = endpoints
+			assert.Error(t, cfg.Validate())
+		}
+	})
+
 	t.Run("zero iterations fails", func(t *testing.T) {
 		cfg := validConfig()
 		cfg.DefaultMaxIterations = 0
@@ -165,7 +193,120 @@ func TestServerConfig_Merge(t *testing.T) {
 		assert.Equal(t, base.SmallModel, merged.SmallModel)
 		assert.Equal(t, base.Ollama, merged.Ollama)
 		assert.Equal(t, base.DefaultMaxIterations, merged.DefaultMaxIterations)
+		assert.Equal(t, "claude", merged.Executor)
 	})
+
+	t.Run("merge updates executor", func(t *testing.T) {
+		base := DefaultServerConfig()
+		merged := base.Merge(&ServerConfig{Executor: "aider"})
+		assert.Equal(t, "aider", merged.Executor)
+	})
+}
+
+func TestServerConfig_MergeJSON_Endpoints(t *testing.T) {
+	base := DefaultServerConfig()
+
+	merged, err := base.MergeJSON([]byte(`{"ollama": {"endpoints": [{"host": "http://gpu-1:11434", "tags": ["gpu"]}]}}`))
+	require.NoError(t, err)
+	require.Len(t, merged.Ollama.Endpoints, 1)
+	assert.Equal(t, []string{"gpu"}, merged.Ollama.Endpoints[0].Tags)
+	assert.Equal(t, base.Ollama.Host, merged.Ollama.Host)
+
+	// Other updates leave the pool alone; an empty list clears it
+	merged, err = merged.MergeJSON([]byte(`{"ollama": {"host": "http://other:11434"}}`))
+	require.NoError(t, err)
+	assert.Len(t, merged.Ollama.Endpoints, 1)
+	merged, err = merged.MergeJSON([]byte(`{"ollama": {"endpoints": []}}`))
+	require.NoError(t, err)
+	assert.Empty(t, merged.Ollama.Endpoints)
+}
+
+func TestServerConfig_MergeJSON_MaxJobsPerUser(t *testing.T) {
+	base := DefaultServerConfig()
+	assert.Zero(t, base.MaxJobsPerUser, "unlimited by default")
+
+	merged, err := base.MergeJSON([]byte(`{"max_jobs_per_user": 3}`))
+	require.NoError(t, err)
+	assert.Equal(t, 3, merged.MaxJobsPerUser)
+
+	// 0 lifts the quota again
+	merged, err = merged.MergeJSON([]byte(`{"max_jobs_per_user": 0}`))
+	require.NoError(t, err)
+	assert.Zero(t, merged.MaxJobsPerUser)
+
+	merged.MaxJobsPerUser = -1
+	assert.Error(t, merged.Validate())
+}
+
+func TestServerConfig_MergeJSON_Scheduling(t *testing.T) {
+	base := DefaultServerConfig()
+	assert.Equal(t, PolicyPriority, base.Scheduling.Policy)
+
+	merged, err := base.MergeJSON([]byte(`{"scheduling": {"policy": "fair_share", "weights": {"alice": 2}, "aging_minutes": 30}}`))
+	require.NoError(t, err)
+	require.NoError(t, merged.Validate())
+	assert.Equal(t, PolicyFairShare, merged.Scheduling.Policy)
+	assert.Equal(t, map[string]float64{"alice": 2}, merged.Scheduling.Weights)
+	assert.Equal(t, 30, merged.Scheduling.AgingMinutes)
+
+	// Aging can be turned off without restating the policy
+	merged, err = merged.MergeJSON([]byte(`{"scheduling": {"aging_minutes": 0}}`))
+	require.NoError(t, err)
+	assert.Equal(t, PolicyFairShare, merged.Scheduling.Policy)
+	assert.Zero(t, merged.Scheduling.AgingMinutes)
+
+	for _, sc := range []SchedulingConfig{
+		{Policy: "lottery"},
+		{Policy: PolicyFairShare, FairShareBy: "team"},
+		{Policy: PolicyFairShare, Weights: map[string]float64{"alice": 0}},
+		{AgingMinutes: -1},
+	} {
+		cfg := DefaultServerConfig()
+		cfg.Scheduling = sc
+		assert.Error(t, cfg.Validate(), "%+v", sc)
+	}
+}
+
+func TestServerConfig_MergeJSON_Sandbox(t *testing.T) {
+	base := DefaultServerConfig()
+	assert.False(t, base.Sandbox.Enabled())
+
+	merged, err := base.MergeJSON([]byte(`{"sandbox": {"runtime": "podman", "image": "ralph-agents", "cpus": 2, "memory_gb": 8}}`))
+	require.NoError(t, err)
+	require.NoError(t, merged.Validate())
+	assert.True(t, merged.Sandbox.Enabled())
+	assert.Equal(t, SandboxConfig{Runtime: SandboxPodman, Image: "ralph-agents", CPUs: 2, MemoryGB: 8}, merged.Sandbox)
+
+	// Limits and the sandbox itself can be turned off again
+	merged, err = merged.MergeJSON([]byte(`{"sandbox": {"memory_gb": 0}}`))
+	require.NoError(t, err)
+	assert.Zero(t, merged.Sandbox.MemoryGB)
+	assert.Equal(t, 2.0, merged.Sandbox.CPUs)
+	merged, err = merged.MergeJSON([]byte(`{"sandbox": {"runtime": ""}}`))
+	require.NoError(t, err)
+	assert.False(t, merged.Sandbox.Enabled())
+
+	for _, sc := range []SandboxConfig{
+		{Runtime: "firejail"},
+		{Runtime: SandboxDocker},
+		{Runtime: SandboxBwrap, MemoryGB: -1},
+		{Runtime: SandboxBwrap, Mounts: []string{"relative/path"}},
+	} {
+		cfg := DefaultServerConfig()
+		cfg.Sandbox = sc
+		assert.Error(t, cfg.Validate(), "%+v", sc)
+	}
+}
+
+func TestOllamaEndpoint(t *testing.T) {
+	e := OllamaEndpoint{Host: "http://gpu-1:11434", Tags: []string{"gpu", "a100"}}
+	assert.Equal(t, 1, e.Slots())
+	e.Capacity = 3
+	assert.Equal(t, 3, e.Slots())
+
+	assert.True(t, e.HasTags(nil))
+	assert.True(t, e.HasTags([]string{"a100"}))
+	assert.False(t, e.HasTags([]string{"gpu", "h100"}))
 }
 
 func TestServerConfig_JSON(t *testing.T) {
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// AgentEventType identifies what an agent did during an iteration
+type AgentEventType string
+
+const (
+	AgentEventInit       AgentEventType = "init"        // session started
+	AgentEventMessage    AgentEventType = "message"     // assistant text
+	AgentEventToolCall   AgentEventType = "tool_call"   // assistant invoked a tool
+	AgentEventToolResult AgentEventType = "tool_result" // output returned by a tool
+	AgentEventResult     AgentEventType = "result"      // session finished
+)
+
+// TokenUsage counts the tokens consumed by a model
+type TokenUsage struct {
+	InputTokens         int64 `json:"input_tokens"`
+	OutputTokens        int64 `json:"output_tokens"`
+	CacheReadTokens     int64 `json:"cache_read_tokens,omitempty"`
+	CacheCreationTokens int64 `json:"cache_creation_tokens,omitempty"`
+}
+
+// Total returns all tokens counted, input and output
+func (u TokenUsage) Total() int64 {
+	return u.InputTokens + u.OutputTokens + u.CacheReadTokens + u.CacheCreationTokens
+}
+
+// Add accumulates other into u
+func (u *TokenUsage) Add(other TokenUsage) {
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheReadTokens += other.CacheReadTokens
+	u.CacheCreationTokens += other.CacheCreationTokens
+}
+
+// AgentEvent is one structured step of an agent's run: a message, a tool
+// call or its result, or the session's start and end
+type AgentEvent struct {
+	ID        int64          `json:"id,omitempty"`
+	JobID     int64          `json:"job_id,omitempty"`
+	Iteration int            `json:"iteration"`
+	Type      AgentEventType `json:"type"`
+	Tool      string         `json:"tool,omitempty"`    // tool name for tool calls and results
+	Content   string         `json:"content,omitempty"` // message text, tool input JSON or tool output
+	IsError   bool           `json:"is_error,omitempty"`
+	Usage     TokenUsage     `json:"usage"`
+	Timestamp time.Time      `json:"timestamp"`
+}
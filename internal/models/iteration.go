@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Iteration records one pass of the ralph loop over a job: what the agent
+// did, what it cost, and the commit it left behind
+type Iteration struct {
+	JobID      int64      `json:"job_id"`
+	Number     int        `json:"iteration"`
+	Model      string     `json:"model,omitempty"` // large model the agent ran with
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	// Outcome
+	ExitCode     int    `json:"exit_code"`
+	Completed    bool   `json:"completed"`               // the agent claimed completion
+	VerifyPassed *bool  `json:"verify_passed,omitempty"` // set when a verify command ran
+	Error        string `json:"error,omitempty"`
+
+	// Changes committed at the end of the iteration
+	CommitHash   string `json:"commit_hash,omitempty"`
+	FilesChanged int    `json:"files_changed"`
+	Insertions   int    `json:"insertions"`
+	Deletions    int    `json:"deletions"`
+
+	Usage TokenUsage `json:"usage"`
+
+	// Fingerprint used to spot a loop that has stopped making progress
+	DiffHash     string   `json:"diff_hash,omitempty"` // empty when nothing was committed
+	OutputHash   string   `json:"output_hash,omitempty"`
+	FailingTests []string `json:"failing_tests,omitempty"`
+}
+
+// NewIteration starts the record for iteration number of a job
+func NewIteration(jobID int64, number int) *Iteration {
+	return &Iteration{JobID: jobID, Number: number, StartedAt: time.Now()}
+}
+
+// Duration returns how long the iteration ran, or has been running
+func (it *Iteration) Duration() time.Duration {
+	end := time.Now()
+	if it.FinishedAt != nil {
+		end = *it.FinishedAt
+	}
+	return end.Sub(it.StartedAt)
+}
+
+// Finish marks the iteration as done
+func (it *Iteration) Finish() {
+	now := time.Now()
+	it.FinishedAt = &now
+}
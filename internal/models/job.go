@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"net/url"
 	"time"
 )
 
@@ -11,6 +12,7 @@ type Job struct {
 	Status   JobStatus `json:"status"`
 	Priority Priority  `json:"priority"`
 	Position int       `json:"position"`
+	Owner    string    `json:"owner,omitempty"` // who submitted the job
 
 	// Repository info
 	RepoURL      string `json:"repo_url"`
@@ -22,6 +24,27 @@ type Job struct {
 	Prompt        string            `json:"prompt"`
 	MaxIterations int               `json:"max_iterations"`
 	Env           map[string]string `json:"env,omitempty"`
+	Secrets       []string          `json:"secrets,omitempty"`        // names of stored secrets passed to the job as env vars
+	Executor      string            `json:"executor,omitempty"`       // agent backend; empty uses the server default
+	VerifyCommand string            `json:"verify_command,omitempty"` // must pass before a claimed completion is accepted
+	StallWindow   int               `json:"stall_window,omitempty"`   // iterations compared for stalls; 0 uses DefaultStallWindow
+	StallPolicy   StallPolicy       `json:"stall_policy,omitempty"`   // what to do when stalled; empty means hint
+	ModelPlan     ModelPlan         `json:"model_plan,omitempty"`     // models to escalate through; empty uses the server's large model
+
+	// Overrides of the server config; empty uses the server's setting
+	LargeModel string `json:"large_model,omitempty"`
+	SmallModel string `json:"small_model,omitempty"`
+	OllamaHost string `json:"ollama_host,omitempty"`
+
+	// Endpoint routing: tags an endpoint of the server's pool must carry to
+	// run the job, and the endpoint the scheduler last placed it on
+	EndpointTags []string `json:"endpoint_tags,omitempty"`
+	Endpoint     string   `json:"endpoint,omitempty"`
+
+	// Budgets; zero means unlimited
+	MaxDuration         time.Duration `json:"max_duration,omitempty"`          // total running time across iterations
+	PerIterationTimeout time.Duration `json:"per_iteration_timeout,omitempty"` // longest a single agent run may take
+	MaxTokens           int64         `json:"max_tokens,omitempty"`            // total tokens across iterations
 
 	// Progress tracking
 	Iteration  int `json:"iteration"`
@@ -36,6 +59,13 @@ type Job struct {
 	// Results
 	PRURL string `json:"pr_url,omitempty"`
 	Error string `json:"error,omitempty"`
+
+	// Why a queued job has not started yet, e.g. waiting for memory
+	WaitReason string `json:"wait_reason,omitempty"`
+
+	// Lease held by the server process running the job
+	LeaseOwner  string     `json:"lease_owner,omitempty"`
+	HeartbeatAt *time.Time `json:"heartbeat_at,omitempty"`
 }
 
 // NewJob creates a new job with default values
@@ -76,9 +106,72 @@ func (j *Job) Validate() error {
 	if !j.Priority.Valid() {
 		return fmt.Errorf("invalid priority: %q", j.Priority)
 	}
+	if !j.StallPolicy.Valid() {
+		return fmt.Errorf("invalid stall_policy: %q", j.StallPolicy)
+	}
+	if j.StallWindow < 0 || j.StallWindow == 1 {
+		return fmt.Errorf("stall_window must be at least 2")
+	}
+	if j.MaxDuration < 0 || j.PerIterationTimeout < 0 || j.MaxTokens < 0 {
+		return fmt.Errorf("budgets must not be negative")
+	}
+	if err := j.ModelPlan.Validate(); err != nil {
+		return err
+	}
+	if j.LargeModel != "" && len(j.ModelPlan) > 0 {
+		return fmt.Errorf("large_model and model_plan cannot both be set")
+	}
+	if j.OllamaHost != "" {
+		if u, err := url.Parse(j.OllamaHost); err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("ollama_host must be an http or https URL; got %q", j.OllamaHost)
+		}
+	}
+	for _, name := range j.Secrets {
+		if err := ValidateSecretName(name); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// Config returns the server config with the job's model and Ollama host
+// overrides applied. Without a host override the job runs against the
+// endpoint it was placed on, if any. cfg itself is returned when the job has
+// neither.
+func (j *Job) Config(cfg *ServerConfig) *ServerConfig {
+	host := j.OllamaHost
+	if host == "" {
+		host = j.Endpoint
+	}
+	if j.LargeModel == "" && j.SmallModel == "" && host == "" {
+		return cfg
+	}
+	merged := *cfg
+	if j.LargeModel != "" {
+		merged.LargeModel = ModelPlacement{Name: j.LargeModel}
+	}
+	if j.SmallModel != "" {
+		merged.SmallModel = ModelPlacement{Name: j.SmallModel}
+	}
+	if host != "" {
+		merged.Ollama.Host = host
+	}
+	return &merged
+}
+
+// Models returns the names of every model the job may run with under cfg
+func (j *Job) Models(cfg *ServerConfig) []string {
+	merged := j.Config(cfg)
+	names := []string{merged.LargeModel.Name, merged.SmallModel.Name}
+	if len(j.ModelPlan) > 0 {
+		names = names[1:]
+		for _, stage := range j.ModelPlan {
+			names = append(names, stage.Name)
+		}
+	}
+	return names
+}
+
 // TransitionTo attempts to change the job status
 func (j *Job) TransitionTo(target JobStatus) error {
 	if !j.Status.CanTransitionTo(target) {
@@ -98,10 +191,36 @@ func (j *Job) TransitionTo(target JobStatus) error {
 		j.CompletedAt = &now
 	}
 
+	// Only queued jobs wait
+	j.WaitReason = ""
 	j.Status = target
 	return nil
 }
 
+// Requeue returns a running or preparing job to the queue after the process
+// running it died. This is a recovery path, not a normal transition: callers
+// use it only for jobs whose lease has been found dead.
+func (j *Job) Requeue() error {
+	if j.Status != StatusRunning && j.Status != StatusPreparing {
+		return fmt.Errorf("cannot requeue job in status %s", j.Status)
+	}
+
+	j.Status = StatusQueued
+	j.RetryCount++
+	j.LeaseOwner = ""
+	j.HeartbeatAt = nil
+	return nil
+}
+
+// LeaseExpired returns true if the job's last heartbeat is older than ttl
+// (or it never had one)
+func (j *Job) LeaseExpired(ttl time.Duration, now time.Time) bool {
+	if j.HeartbeatAt == nil {
+		return true
+	}
+	return now.Sub(*j.HeartbeatAt) > ttl
+}
+
 // IncrementIteration increases the iteration counter
 func (j *Job) IncrementIteration() {
 	j.Iteration++
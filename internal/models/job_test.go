@@ -85,6 +85,40 @@ func TestJob_Validate(t *testing.T) {
 		assert.Error(t, job.Validate())
 	})
 
+	t.Run("unknown stall_policy fails", func(t *testing.T) {
+		job := validJob()
+		job.StallPolicy = "retry"
+		assert.Error(t, job.Validate())
+	})
+
+	t.Run("stall_window of one fails", func(t *testing.T) {
+		job := validJob()
+		job.StallWindow = 1
+		assert.Error(t, job.Validate())
+	})
+
+	t.Run("negative budget fails", func(t *testing.T) {
+		job := validJob()
+		job.MaxTokens = -1
+		assert.Error(t, job.Validate())
+	})
+
+	t.Run("large_model with model_plan fails", func(t *testing.T) {
+		job := validJob()
+		job.LargeModel = "qwen3-coder:30b"
+		job.ModelPlan = ModelPlan{{ModelPlacement: ModelPlacement{Name: "qwen3-coder:70b"}}}
+		assert.Error(t, job.Validate())
+	})
+
+	t.Run("ollama_host without scheme fails", func(t *testing.T) {
+		job := validJob()
+		job.OllamaHost = "gpu-box:11434"
+		assert.Error(t, job.Validate())
+
+		job.OllamaHost = "http://gpu-box:11434"
+		assert.NoError(t, job.Validate())
+	})
+
 	t.Run("negative max_iterations fails", func(t *testing.T) {
 		job := validJob()
 		job.MaxIterations = -1
@@ -131,6 +165,39 @@ func TestJob_TransitionTo(t *testing.T) {
 	})
 }
 
+func TestJob_Requeue(t *testing.T) {
+	job := NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	assert.Error(t, job.Requeue()) // Only running jobs can be requeued
+
+	require.NoError(t, job.TransitionTo(StatusRunning))
+	now := time.Now()
+	job.LeaseOwner = "host:1"
+	job.HeartbeatAt = &now
+
+	require.NoError(t, job.Requeue())
+	assert.Equal(t, StatusQueued, job.Status)
+	assert.Equal(t, 1, job.RetryCount)
+	assert.Empty(t, job.LeaseOwner)
+	assert.Nil(t, job.HeartbeatAt)
+
+	// A job that died while preparing goes back too
+	require.NoError(t, job.TransitionTo(StatusPreparing))
+	require.NoError(t, job.Requeue())
+	assert.Equal(t, StatusQueued, job.Status)
+	assert.Equal(t, 2, job.RetryCount)
+}
+
+func TestJob_LeaseExpired(t *testing.T) {
+	job := NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	now := time.Now()
+	assert.True(t, job.LeaseExpired(time.Minute, now))
+
+	beat := now.Add(-30 * time.Second)
+	job.HeartbeatAt = &beat
+	assert.False(t, job.LeaseExpired(time.Minute, now))
+	assert.True(t, job.LeaseExpired(10*time.Second, now))
+}
+
 func TestJob_IncrementIteration(t *testing.T) {
 	job := NewJob("git@github.com:user/repo.git", "main", "test", 3)
 	_ = job.TransitionTo(StatusRunning)
@@ -169,6 +236,43 @@ func TestJob_Progress(t *testing.T) {
 	assert.Equal(t, 1.0, job.Progress())
 }
 
+func TestJob_Config(t *testing.T) {
+	cfg := DefaultServerConfig()
+	job := NewJob("git@github.com:user/repo.git", "main", "test", 10)
+
+	// Without overrides the server config is used as is
+	assert.Same(t, cfg, job.Config(cfg))
+
+	job.LargeModel = "qwen3-coder:30b"
+	job.OllamaHost = "http://gpu-box:11434"
+	merged := job.Config(cfg)
+	assert.Equal(t, ModelPlacement{Name: "qwen3-coder:30b"}, merged.LargeModel)
+	assert.Equal(t, cfg.SmallModel, merged.SmallModel)
+	assert.Equal(t, "http://gpu-box:11434", merged.Ollama.Host)
+
+	// A placed job runs against its endpoint unless pinned to a host
+	job.OllamaHost = ""
+	job.Endpoint = "http://gpu-2:11434"
+	assert.Equal(t, "http://gpu-2:11434", job.Config(cfg).Ollama.Host)
+
+	// The server config is not modified
+	assert.Equal(t, "qwen3-coder:70b", cfg.LargeModel.Name)
+	assert.Equal(t, "http://localhost:11434", cfg.Ollama.Host)
+}
+
+func TestJob_Models(t *testing.T) {
+	cfg := DefaultServerConfig()
+	job := NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	assert.Equal(t, []string{"qwen3-coder:70b", "qwen2.5-coder:7b"}, job.Models(cfg))
+
+	job.SmallModel = "qwen2.5-coder:1.5b"
+	job.ModelPlan = ModelPlan{
+		{ModelPlacement: ModelPlacement{Name: "qwen3-coder:30b"}, Iterations: 5},
+		{ModelPlacement: ModelPlacement{Name: "qwen3-coder:480b"}},
+	}
+	assert.Equal(t, []string{"qwen2.5-coder:1.5b", "qwen3-coder:30b", "qwen3-coder:480b"}, job.Models(cfg))
+}
+
 func TestJob_JSON(t *testing.T) {
 	job := NewJob("git@github.com:user/repo.git", "feature/test", "Run tests", 50)
 	job.ID = 42
@@ -0,0 +1,46 @@
+package models
+
+import "fmt"
+
+// ModelStage is one step of a job's model plan: the large model to run and
+// when to move on to the next stage
+type ModelStage struct {
+	ModelPlacement
+
+	// Switch conditions; the job moves on as soon as any is met
+	Iterations int  `json:"iterations,omitempty"`  // after this many iterations on the stage
+	OnFailures int  `json:"on_failures,omitempty"` // after this many agent errors in a row
+	OnStall    bool `json:"on_stall,omitempty"`    // when the loop stalls
+}
+
+// Exhausted reports whether a job that has run iterations on this stage, the
+// last failures of which errored, should move on
+func (s *ModelStage) Exhausted(iterations, failures int) bool {
+	if s.Iterations > 0 && iterations >= s.Iterations {
+		return true
+	}
+	return s.OnFailures > 0 && failures >= s.OnFailures
+}
+
+// ModelPlan is an ordered list of models a job escalates through, e.g. a
+// fast model for the first iterations and a larger one if it has not
+// finished by then. The last stage runs until the job ends.
+type ModelPlan []ModelStage
+
+// Validate checks every stage has a valid model and that each stage but the
+// last can be left
+func (p ModelPlan) Validate() error {
+	for i := range p {
+		stage := &p[i]
+		if err := stage.ModelPlacement.Validate(); err != nil {
+			return fmt.Errorf("model_plan[%d]: %w", i, err)
+		}
+		if stage.Iterations < 0 || stage.OnFailures < 0 {
+			return fmt.Errorf("model_plan[%d]: switch conditions must not be negative", i)
+		}
+		if i < len(p)-1 && stage.Iterations == 0 && stage.OnFailures == 0 && !stage.OnStall {
+			return fmt.Errorf("model_plan[%d]: stage needs a switch condition to reach the next one", i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModelStage_Exhausted(t *testing.T) {
+	stage := ModelStage{Iterations: 10, OnFailures: 2}
+
+	assert.False(t, stage.Exhausted(9, 1))
+	assert.True(t, stage.Exhausted(10, 0))
+	assert.True(t, stage.Exhausted(3, 2))
+	assert.False(t, (&ModelStage{}).Exhausted(100, 100))
+}
+
+func TestModelPlan_Validate(t *testing.T) {
+	small := ModelPlacement{Name: "qwen2.5-coder:14b"}
+	large := ModelPlacement{Name: "qwen3-coder:70b", Device: "cpu"}
+
+	assert.NoError(t, ModelPlan(nil).Validate())
+	assert.NoError(t, ModelPlan{{ModelPlacement: small, Iterations: 10}, {ModelPlacement: large}}.Validate())
+	assert.NoError(t, ModelPlan{{ModelPlacement: small, OnStall: true}, {ModelPlacement: large}}.Validate())
+
+	// A stage that can never be left makes the rest unreachable
+	assert.Error(t, ModelPlan{{ModelPlacement: small}, {ModelPlacement: large}}.Validate())
+	assert.Error(t, ModelPlan{{ModelPlacement: ModelPlacement{Device: "gpu"}}}.Validate())
+	assert.Error(t, ModelPlan{{ModelPlacement: small, Iterations: -1}}.Validate())
+}
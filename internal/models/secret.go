@@ -0,0 +1,93 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Redacted stands in for a secret value in logs and API responses
+const Redacted = "[REDACTED]"
+
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Secret is a value stored encrypted on the server, which jobs that name it
+// receive as an environment variable of the same name. Its value is never
+// returned.
+type Secret struct {
+	Name      string    `json:"name"`
+	Users     []string  `json:"users,omitempty"` // token names that may use it besides admins
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Allows reports whether jobs submitted by user may use the secret. Admins
+// may use any secret and are not checked here.
+func (s *Secret) Allows(user string) bool {
+	for _, u := range s.Users {
+		if u == user {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateSecretName checks a secret name can be used as an environment
+// variable
+func ValidateSecretName(name string) error {
+	if !secretNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q: use letters, digits and underscores, not starting with a digit", name)
+	}
+	return nil
+}
+
+// Redactor hides secret values in text
+type Redactor struct {
+	replacer *strings.Replacer
+}
+
+// NewRedactor returns a redactor for values. Empty values are ignored.
+func NewRedactor(values []string) *Redactor {
+	// Longer values first, so one containing another is hidden whole
+	sorted := append([]string(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	var pairs []string
+	for _, v := range sorted {
+		if v != "" {
+			pairs = append(pairs, v, Redacted)
+		}
+	}
+	if len(pairs) == 0 {
+		return &Redactor{}
+	}
+	return &Redactor{replacer: strings.NewReplacer(pairs...)}
+}
+
+// Redact returns s with every secret value replaced. A nil redactor returns
+// s unchanged.
+func (r *Redactor) Redact(s string) string {
+	if r == nil || r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// RedactJob returns a copy of job with secret values hidden in its env and
+// error, or job itself if there are none to hide
+func (r *Redactor) RedactJob(job *Job) *Job {
+	if r == nil || r.replacer == nil {
+		return job
+	}
+	redacted := *job
+	if job.Env != nil {
+		redacted.Env = make(map[string]string, len(job.Env))
+		for k, v := range job.Env {
+			redacted.Env[k] = r.Redact(v)
+		}
+	}
+	redacted.Error = r.Redact(job.Error)
+	return &redacted
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSecretName(t *testing.T) {
+	for _, name := range []string{"NPM_TOKEN", "_x", "gh2"} {
+		assert.NoError(t, ValidateSecretName(name), name)
+	}
+	for _, name := range []string{"", "2FA", "NPM-TOKEN", "A B", "A=B"} {
+		assert.Error(t, ValidateSecretName(name), name)
+	}
+}
+
+func TestRedactor(t *testing.T) {
+	r := NewRedactor([]string{"abc", "abc123", ""})
+	assert.Equal(t, "key="+Redacted+" and "+Redacted, r.Redact("key=abc123 and abc"))
+	assert.Equal(t, "nothing here", r.Redact("nothing here"))
+
+	var none *Redactor
+	assert.Equal(t, "abc", none.Redact("abc"))
+	assert.Equal(t, "abc", NewRedactor(nil).Redact("abc"))
+
+	job := &Job{Env: map[string]string{"URL": "https://abc123@host"}, Error: "bad abc"}
+	redacted := r.RedactJob(job)
+	assert.Equal(t, "https://"+Redacted+"@host", redacted.Env["URL"])
+	assert.Equal(t, "bad "+Redacted, redacted.Error)
+	assert.Equal(t, "https://abc123@host", job.Env["URL"], "the original is not changed")
+	assert.Same(t, job, none.RedactJob(job))
+}
@@ -0,0 +1,43 @@
+package models
+
+// DefaultStallWindow is how many consecutive iterations are compared when a
+// job does not set its own window
+const DefaultStallWindow = 4
+
+// StallPolicy decides what happens when a job stops making progress
+type StallPolicy string
+
+const (
+	StallPolicyOff      StallPolicy = "off"      // never check for stalls
+	StallPolicyHint     StallPolicy = "hint"     // tell the agent it is stuck, then fail if it stays stuck
+	StallPolicyFail     StallPolicy = "fail"     // fail the job straight away
+	StallPolicyEscalate StallPolicy = "escalate" // move to the next model in the plan, or pause the job for a human once none is left
+)
+
+// Valid returns true if the policy is a known value. The empty policy is
+// valid and means StallPolicyHint.
+func (p StallPolicy) Valid() bool {
+	switch p {
+	case "", StallPolicyOff, StallPolicyHint, StallPolicyFail, StallPolicyEscalate:
+		return true
+	default:
+		return false
+	}
+}
+
+// StallKind names the pattern that marked a job as stalled
+type StallKind string
+
+const (
+	StallNoProgress      StallKind = "no_progress"      // nothing changed
+	StallRepeatedFailure StallKind = "repeated_failure" // the same tests kept failing
+	StallRepeatedOutput  StallKind = "repeated_output"  // the agent kept saying the same thing
+	StallOscillation     StallKind = "oscillation"      // changes were made and undone in a cycle
+)
+
+// Stall describes how a job's recent iterations stopped making progress
+type Stall struct {
+	Kind   StallKind `json:"kind"`
+	Window int       `json:"window"` // iterations the pattern spans
+	Reason string    `json:"reason"`
+}
@@ -10,6 +10,7 @@ type JobStatus string
 
 const (
 	StatusQueued    JobStatus = "queued"
+	StatusPreparing JobStatus = "preparing" // checking and pulling models before running
 	StatusRunning   JobStatus = "running"
 	StatusPaused    JobStatus = "paused"
 	StatusCompleted JobStatus = "completed"
@@ -20,7 +21,7 @@ const (
 // Valid returns true if the status is a known value
 func (s JobStatus) Valid() bool {
 	switch s {
-	case StatusQueued, StatusRunning, StatusPaused, StatusCompleted, StatusFailed, StatusCancelled:
+	case StatusQueued, StatusPreparing, StatusRunning, StatusPaused, StatusCompleted, StatusFailed, StatusCancelled:
 		return true
 	default:
 		return false
@@ -41,12 +42,15 @@ func (s JobStatus) IsTerminal() bool {
 func (s JobStatus) CanTransitionTo(target JobStatus) bool {
 	switch s {
 	case StatusQueued:
-		return target == StatusRunning || target == StatusCancelled
+		return target == StatusPreparing || target == StatusRunning || target == StatusCancelled
+	case StatusPreparing:
+		return target == StatusRunning || target == StatusPaused ||
+			target == StatusFailed || target == StatusCancelled
 	case StatusRunning:
 		return target == StatusPaused || target == StatusCompleted ||
 			target == StatusFailed || target == StatusCancelled
 	case StatusPaused:
-		return target == StatusRunning || target == StatusCancelled
+		return target == StatusQueued || target == StatusRunning || target == StatusCancelled
 	case StatusCompleted, StatusFailed, StatusCancelled:
 		return false // Terminal states cannot transition
 	default:
@@ -15,6 +15,7 @@ func TestJobStatus_Valid(t *testing.T) {
 		want   bool
 	}{
 		{"queued", StatusQueued, true},
+		{"preparing", StatusPreparing, true},
 		{"running", StatusRunning, true},
 		{"paused", StatusPaused, true},
 		{"completed", StatusCompleted, true},
@@ -33,7 +34,7 @@ func TestJobStatus_Valid(t *testing.T) {
 
 func TestJobStatus_IsTerminal(t *testing.T) {
 	terminals := []JobStatus{StatusCompleted, StatusFailed, StatusCancelled}
-	nonTerminals := []JobStatus{StatusQueued, StatusRunning, StatusPaused}
+	nonTerminals := []JobStatus{StatusQueued, StatusPreparing, StatusRunning, StatusPaused}
 
 	for _, s := range terminals {
 		assert.True(t, s.IsTerminal(), "%s should be terminal", s)
@@ -50,10 +51,18 @@ func TestJobStatus_CanTransitionTo(t *testing.T) {
 		allowed bool
 	}{
 		// From queued
+		{StatusQueued, StatusPreparing, true},
 		{StatusQueued, StatusRunning, true},
 		{StatusQueued, StatusCancelled, true},
 		{StatusQueued, StatusPaused, false},
 		{StatusQueued, StatusCompleted, false},
+		// From preparing
+		{StatusPreparing, StatusRunning, true},
+		{StatusPreparing, StatusPaused, true},
+		{StatusPreparing, StatusFailed, true},
+		{StatusPreparing, StatusCancelled, true},
+		{StatusPreparing, StatusCompleted, false},
+		{StatusPreparing, StatusQueued, false},
 		// From running
 		{StatusRunning, StatusPaused, true},
 		{StatusRunning, StatusCompleted, true},
@@ -63,7 +72,7 @@ func TestJobStatus_CanTransitionTo(t *testing.T) {
 		// From paused
 		{StatusPaused, StatusRunning, true},
 		{StatusPaused, StatusCancelled, true},
-		{StatusPaused, StatusQueued, false},
+		{StatusPaused, StatusQueued, true},
 		{StatusPaused, StatusCompleted, false},
 		// From terminal states
 		{StatusCompleted, StatusRunning, false},
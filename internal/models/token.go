@@ -0,0 +1,76 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scope is a permission an API token grants on top of reading
+type Scope string
+
+const (
+	ScopeSubmit  Scope = "submit"  // submit jobs
+	ScopeControl Scope = "control" // pause, resume, cancel, reorder and edit jobs
+	ScopeAdmin   Scope = "admin"   // change the server config, models and tokens; implies every scope
+)
+
+// Valid returns true if the scope is a known value
+func (s Scope) Valid() bool {
+	switch s {
+	case ScopeSubmit, ScopeControl, ScopeAdmin:
+		return true
+	default:
+		return false
+	}
+}
+
+// ParseScopes parses a comma-separated list of scopes
+func ParseScopes(s string) ([]Scope, error) {
+	var scopes []Scope
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		scope := Scope(strings.ToLower(part))
+		if !scope.Valid() {
+			return nil, fmt.Errorf("invalid scope: %q", part)
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
+
+// Token is an API token. Every token can read; its scopes grant the rest.
+// The secret itself is only known when the token is created.
+type Token struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"` // who the token belongs to
+	Scopes     []Scope    `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Validate checks the token has a name and known scopes
+func (t *Token) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	for _, s := range t.Scopes {
+		if !s.Valid() {
+			return fmt.Errorf("invalid scope: %q", s)
+		}
+	}
+	return nil
+}
+
+// HasScope reports whether the token grants scope
+func (t *Token) HasScope(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes("submit, Control")
+	require.NoError(t, err)
+	assert.Equal(t, []Scope{ScopeSubmit, ScopeControl}, scopes)
+
+	scopes, err = ParseScopes("")
+	require.NoError(t, err)
+	assert.Empty(t, scopes)
+
+	_, err = ParseScopes("submit,root")
+	assert.Error(t, err)
+}
+
+func TestToken_HasScope(t *testing.T) {
+	submit := &Token{Name: "ci", Scopes: []Scope{ScopeSubmit}}
+	assert.True(t, submit.HasScope(ScopeSubmit))
+	assert.False(t, submit.HasScope(ScopeControl))
+
+	admin := &Token{Name: "root", Scopes: []Scope{ScopeAdmin}}
+	assert.True(t, admin.HasScope(ScopeSubmit))
+	assert.True(t, admin.HasScope(ScopeControl))
+
+	reader := &Token{Name: "viewer"}
+	assert.False(t, reader.HasScope(ScopeSubmit))
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Verification is the outcome of running a job's verify command
+type Verification struct {
+	Command  string        `json:"command"`
+	Passed   bool          `json:"passed"`
+	ExitCode int           `json:"exit_code"`
+	Output   string        `json:"output,omitempty"` // tail of combined stdout and stderr
+	Duration time.Duration `json:"duration"`
+}
@@ -0,0 +1,199 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// ErrWaitingForMemory is returned by Admission.Admit for a job whose models
+// do not fit in the memory left on the machine
+var ErrWaitingForMemory = errors.New("waiting for memory")
+
+// Admission holds jobs back until the machine the server runs on has the
+// memory to load their models. Each admitted job reserves the memory_gb of
+// every model it may run with, from its placement or the model catalog, until
+// Release. A model already reserved by another job is loaded once by Ollama
+// and shared, so it costs nothing more. With a Monitor attached, models the
+// local Ollama reports loaded that no job reserved, such as ones loaded by
+// hand or still held after their job ended, take their memory too. Only jobs
+// whose Ollama host is this machine are counted; the hardware of other hosts
+// is not known.
+type Admission struct {
+	mu       sync.Mutex
+	cfg      *models.ServerConfig
+	catalog  *Catalog
+	monitor  *Monitor
+	memoryGB float64                      // memory models can be loaded into
+	admitted map[int64]map[string]float64 // memory of each admitted job's models, by normalized name
+}
+
+// NewAdmission creates admission control for the hardware hw. catalog may
+// be nil, in which case only the memory_gb of the configured placements is
+// known and other models count as free.
+func NewAdmission(cfg *models.ServerConfig, hw *HardwareInfo, catalog *Catalog) *Admission {
+	return &Admission{
+		cfg:      cfg,
+		catalog:  catalog,
+		memoryGB: hw.ModelMemoryGB(),
+		admitted: make(map[int64]map[string]float64),
+	}
+}
+
+// SetConfig replaces the config that jobs' models are resolved against. Jobs
+// already admitted keep their reservation.
+func (a *Admission) SetConfig(cfg *models.ServerConfig) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cfg = cfg
+}
+
+// SetMonitor makes admission count the models the local Ollama has loaded
+// beyond those reserved
+func (a *Admission) SetMonitor(m *Monitor) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.monitor = m
+}
+
+// Admit reserves memory for job's models, or returns an error wrapping
+// ErrWaitingForMemory that says how much is missing. While no other job is
+// admitted every job is, so one whose models are larger than the machine
+// still gets to run.
+func (a *Admission) Admit(job *models.Job) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.admitted[job.ID]; ok {
+		return nil
+	}
+	merged := job.Config(a.cfg)
+	if !isLocalHost(merged.Ollama.Host) {
+		return nil
+	}
+
+	loaded := make(map[string]bool)
+	var usedGB float64
+	for _, reserved := range a.admitted {
+		for name, gb := range reserved {
+			if !loaded[name] {
+				loaded[name] = true
+				usedGB += gb
+			}
+		}
+	}
+	for name, gb := range a.unreserved(loaded) {
+		loaded[name] = true
+		usedGB += gb
+	}
+
+	wanted := a.modelMemory(job, merged)
+	var needGB float64
+	for name, gb := range wanted {
+		if !loaded[name] {
+			needGB += gb
+		}
+	}
+
+	if freeGB := a.memoryGB - usedGB; len(a.admitted) > 0 && needGB > freeGB {
+		return fmt.Errorf("%w: need %sGB, %sGB free", ErrWaitingForMemory, formatGB(needGB), formatGB(math.Max(freeGB, 0)))
+	}
+
+	a.admitted[job.ID] = wanted
+	return nil
+}
+
+// Release frees the memory reserved for job, if any
+func (a *Admission) Release(job *models.Job) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.admitted, job.ID)
+}
+
+// unreserved returns the memory of the models the monitor last saw loaded on
+// this machine that are not in reserved, by normalized name
+func (a *Admission) unreserved(reserved map[string]bool) map[string]float64 {
+	if a.monitor == nil {
+		return nil
+	}
+	extra := make(map[string]float64)
+	for _, host := range a.monitor.Snapshot() {
+		if host.Error != "" || !isLocalHost(host.Host) {
+			continue
+		}
+		for _, m := range host.Models {
+			if name := normalizeModel(m.Name); !reserved[name] {
+				extra[name] = float64(m.Size) / (1024 * 1024 * 1024)
+			}
+		}
+	}
+	return extra
+}
+
+// modelMemory returns the memory of every model job may run with, by
+// normalized name. A job with a model plan reserves every stage: Ollama keeps
+// the previous stage's model loaded for a while after the job moves on.
+func (a *Admission) modelMemory(job *models.Job, merged *models.ServerConfig) map[string]float64 {
+	placements := []models.ModelPlacement{merged.SmallModel}
+	if len(job.ModelPlan) > 0 {
+		for _, stage := range job.ModelPlan {
+			placements = append(placements, stage.ModelPlacement)
+		}
+	} else {
+		placements = append(placements, merged.LargeModel)
+	}
+
+	memory := make(map[string]float64, len(placements))
+	for _, p := range placements {
+		memory[normalizeModel(p.Name)] = a.placementMemoryGB(p)
+	}
+	return memory
+}
+
+// placementMemoryGB returns the memory a model needs: its placement's
+// memory_gb if set, otherwise the catalog's, otherwise that of the server's
+// placement of the same model. Unknown models count as free.
+func (a *Admission) placementMemoryGB(p models.ModelPlacement) float64 {
+	if p.MemoryGB > 0 {
+		return p.MemoryGB
+	}
+	name := normalizeModel(p.Name)
+	if a.catalog != nil {
+		for _, m := range a.catalog.Models {
+			if normalizeModel(m.Name) == name {
+				return m.MemoryGB
+			}
+		}
+	}
+	for _, configured := range []models.ModelPlacement{a.cfg.LargeModel, a.cfg.SmallModel} {
+		if normalizeModel(configured.Name) == name {
+			return configured.MemoryGB
+		}
+	}
+	return 0
+}
+
+// isLocalHost reports whether an Ollama host URL points at this machine
+func isLocalHost(host string) bool {
+	u, err := url.Parse(host)
+	if err != nil {
+		return false
+	}
+	hostname := u.Hostname()
+	if hostname == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(hostname)
+	return ip != nil && ip.IsLoopback()
+}
+
+// formatGB formats a memory size in GB to at most one decimal
+func formatGB(gb float64) string {
+	return strconv.FormatFloat(math.Round(gb*10)/10, 'f', -1, 64)
+}
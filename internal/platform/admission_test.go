@@ -0,0 +1,123 @@
+package platform
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+func newTestAdmission(t *testing.T, ramGB float64) *Admission {
+	t.Helper()
+	catalog, err := LoadEmbeddedCatalog()
+	require.NoError(t, err)
+	return NewAdmission(models.DefaultServerConfig(), &HardwareInfo{SystemRAMGB: ramGB}, catalog)
+}
+
+func admissionJob(id int64) *models.Job {
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	job.ID = id
+	return job
+}
+
+func TestAdmission_SharesLoadedModels(t *testing.T) {
+	a := newTestAdmission(t, 64)
+
+	// The default models take 42GB + 5GB; a second job on them shares them
+	require.NoError(t, a.Admit(admissionJob(1)))
+	require.NoError(t, a.Admit(admissionJob(2)))
+
+	other := admissionJob(3)
+	other.LargeModel = "qwen2.5-coder:32b"
+	err := a.Admit(other)
+	require.ErrorIs(t, err, ErrWaitingForMemory)
+	assert.Equal(t, "waiting for memory: need 20GB, 17GB free", err.Error())
+
+	// The reservation holds until every job on the models is released
+	a.Release(admissionJob(1))
+	assert.ErrorIs(t, a.Admit(other), ErrWaitingForMemory)
+	a.Release(admissionJob(2))
+	assert.NoError(t, a.Admit(other))
+}
+
+func TestAdmission_ModelPlanReservesEveryStage(t *testing.T) {
+	a := newTestAdmission(t, 64)
+
+	small := admissionJob(1)
+	small.LargeModel = "qwen2.5-coder:14b"
+	require.NoError(t, a.Admit(small))
+
+	plan := admissionJob(2)
+	plan.ModelPlan = models.ModelPlan{
+		{ModelPlacement: models.ModelPlacement{Name: "qwen2.5-coder:32b"}, Iterations: 5},
+		{ModelPlacement: models.ModelPlacement{Name: "qwen3-coder:70b"}},
+	}
+	err := a.Admit(plan)
+	require.ErrorIs(t, err, ErrWaitingForMemory)
+	assert.Equal(t, "waiting for memory: need 62GB, 49GB free", err.Error())
+}
+
+func TestAdmission_CountsUnreservedLoadedModels(t *testing.T) {
+	a := newTestAdmission(t, 64)
+	require.NoError(t, a.Admit(admissionJob(1)))
+
+	// Ollama also holds the default large model, shared with job 1, and a
+	// 10GB model no job reserved
+	ps := &fakePS{}
+	ps.set(
+		RunningModel{Name: "qwen3-coder:70b", Size: 42 << 30},
+		RunningModel{Name: "llama3", Size: 10 << 30},
+	)
+	server := httptest.NewServer(ps)
+	defer server.Close()
+	cfg := models.DefaultServerConfig()
+	cfg.Ollama.Host = server.URL
+	monitor := NewMonitor(cfg)
+	monitor.Poll(context.Background())
+	a.SetMonitor(monitor)
+
+	other := admissionJob(2)
+	other.LargeModel = "qwen2.5-coder:14b"
+	err := a.Admit(other)
+	require.ErrorIs(t, err, ErrWaitingForMemory)
+	assert.Equal(t, "waiting for memory: need 10GB, 7GB free", err.Error())
+
+	// A job on the model already loaded needs nothing more
+	loaded := admissionJob(3)
+	loaded.LargeModel = "llama3"
+	assert.NoError(t, a.Admit(loaded))
+
+	// Once Ollama unloads it the memory is free again
+	ps.set(RunningModel{Name: "qwen3-coder:70b", Size: 42 << 30})
+	monitor.Poll(context.Background())
+	a.Release(loaded)
+	assert.NoError(t, a.Admit(other))
+}
+
+func TestAdmission_AdmitsFirstJobAndRemoteHosts(t *testing.T) {
+	a := newTestAdmission(t, 16)
+
+	// Nothing else is running, so even a job too large for the machine starts
+	require.NoError(t, a.Admit(admissionJob(1)))
+
+	// The memory of another machine is not ours to count
+	remote := admissionJob(2)
+	remote.OllamaHost = "http://gpu-box:11434"
+	assert.NoError(t, a.Admit(remote))
+
+	local := admissionJob(3)
+	local.LargeModel = "qwen2.5-coder:14b"
+	assert.ErrorIs(t, a.Admit(local), ErrWaitingForMemory)
+}
+
+func TestIsLocalHost(t *testing.T) {
+	assert.True(t, isLocalHost("http://localhost:11434"))
+	assert.True(t, isLocalHost("http://127.0.0.1:11434"))
+	assert.True(t, isLocalHost("http://[::1]:11434"))
+	assert.False(t, isLocalHost("http://gpu-box:11434"))
+	assert.False(t, isLocalHost("http://192.168.1.20:11434"))
+}
@@ -10,17 +10,17 @@ import (
 
 // GPUInfo describes a detected GPU
 type GPUInfo struct {
-	Type   string  // "nvidia", "amd", "apple"
-	Name   string  // e.g. "RTX 4090"
-	VRAMGB float64 // video memory in GB
+	Type   string  `json:"type"`    // "nvidia", "amd", "apple"
+	Name   string  `json:"name"`    // e.g. "RTX 4090"
+	VRAMGB float64 `json:"vram_gb"` // video memory in GB
 }
 
 // HardwareInfo describes the detected system hardware
 type HardwareInfo struct {
-	OS          string    // "darwin", "linux", "windows"
-	Arch        string    // "amd64", "arm64"
-	SystemRAMGB float64   // total system RAM in GB
-	GPUs        []GPUInfo // detected GPUs
+	OS          string    `json:"os"`            // "darwin", "linux", "windows"
+	Arch        string    `json:"arch"`          // "amd64", "arm64"
+	SystemRAMGB float64   `json:"system_ram_gb"` // total system RAM in GB
+	GPUs        []GPUInfo `json:"gpus"`          // detected GPUs
 }
 
 // DetectHardware probes the system for RAM, GPU, and platform info
@@ -50,6 +50,15 @@ func (h *HardwareInfo) TotalGPUMemoryGB() float64 {
 	return total
 }
 
+// ModelMemoryGB returns the memory Ollama can load models into: system RAM
+// plus the best GPU's VRAM, counting Apple Silicon's unified memory once
+func (h *HardwareInfo) ModelMemoryGB() float64 {
+	if !h.HasGPU() || (len(h.GPUs) == 1 && h.GPUs[0].Type == "apple") {
+		return h.SystemRAMGB
+	}
+	return h.SystemRAMGB + h.BestGPU().VRAMGB
+}
+
 // HasGPU returns true if any GPU was detected
 func (h *HardwareInfo) HasGPU() bool {
 	return len(h.GPUs) > 0
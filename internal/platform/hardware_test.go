@@ -91,3 +91,23 @@ func TestHardwareInfo_BestGPU_NoGPU(t *testing.T) {
 	hw := &HardwareInfo{GPUs: nil}
 	assert.Nil(t, hw.BestGPU())
 }
+
+func TestHardwareInfo_ModelMemoryGB(t *testing.T) {
+	t.Run("discrete GPU", func(t *testing.T) {
+		hw := &HardwareInfo{SystemRAMGB: 64, GPUs: []GPUInfo{
+			{Type: "nvidia", VRAMGB: 8},
+			{Type: "nvidia", VRAMGB: 24},
+		}}
+		assert.Equal(t, 88.0, hw.ModelMemoryGB())
+	})
+
+	t.Run("unified memory", func(t *testing.T) {
+		hw := &HardwareInfo{SystemRAMGB: 64, GPUs: []GPUInfo{{Type: "apple", VRAMGB: 64}}}
+		assert.Equal(t, 64.0, hw.ModelMemoryGB())
+	})
+
+	t.Run("without GPU", func(t *testing.T) {
+		hw := &HardwareInfo{SystemRAMGB: 32}
+		assert.Equal(t, 32.0, hw.ModelMemoryGB())
+	})
+}
@@ -0,0 +1,127 @@
+package platform
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// HostActivity is what one Ollama host had loaded when last polled
+type HostActivity struct {
+	Host     string         `json:"host"`
+	Models   []RunningModel `json:"models"`
+	Error    string         `json:"error,omitempty"`
+	PolledAt time.Time      `json:"polled_at"`
+}
+
+// Monitor polls the Ollama hosts in the server config for the models they
+// have loaded, so slow jobs can be explained: a model partly offloaded to
+// the CPU, or unloaded between iterations and loaded again. Models being
+// unloaded or moving between CPU and GPU are logged as they happen.
+type Monitor struct {
+	mu       sync.Mutex
+	hosts    []string
+	activity map[string]*HostActivity // last poll of each host
+}
+
+// NewMonitor creates a monitor over the Ollama hosts in cfg. Nothing is
+// known about a host until the first Poll.
+func NewMonitor(cfg *models.ServerConfig) *Monitor {
+	m := &Monitor{activity: make(map[string]*HostActivity)}
+	m.SetConfig(cfg)
+	return m
+}
+
+// SetConfig replaces the hosts the monitor polls
+func (m *Monitor) SetConfig(cfg *models.ServerConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hosts = cfg.Ollama.Hosts()
+}
+
+// Poll asks every host which models it has loaded
+func (m *Monitor) Poll(ctx context.Context) {
+	m.mu.Lock()
+	hosts := append([]string(nil), m.hosts...)
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host string) {
+			defer wg.Done()
+			pollCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+			defer cancel()
+			running, err := NewOllamaClient(host).RunningModels(pollCtx)
+
+			current := &HostActivity{Host: host, Models: running, PolledAt: time.Now()}
+			if current.Models == nil {
+				current.Models = []RunningModel{}
+			}
+			if err != nil {
+				current.Error = err.Error()
+			}
+
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if previous := m.activity[host]; previous != nil && err == nil && previous.Error == "" {
+				logChanges(host, previous.Models, running)
+			}
+			m.activity[host] = current
+		}(host)
+	}
+	wg.Wait()
+}
+
+// Run polls the hosts every interval until ctx is cancelled
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Poll(ctx)
+		}
+	}
+}
+
+// Snapshot returns the last poll of each host, in config order. Hosts not
+// polled yet are left out.
+func (m *Monitor) Snapshot() []HostActivity {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := make([]HostActivity, 0, len(m.hosts))
+	for _, host := range m.hosts {
+		if a := m.activity[host]; a != nil {
+			snapshot = append(snapshot, *a)
+		}
+	}
+	return snapshot
+}
+
+// logChanges logs the models a host unloaded or moved between CPU and GPU
+// since its previous poll
+func logChanges(host string, previous, current []RunningModel) {
+	loaded := make(map[string]RunningModel, len(current))
+	for _, model := range current {
+		loaded[model.Name] = model
+	}
+
+	for _, before := range previous {
+		now, ok := loaded[before.Name]
+		if !ok {
+			log.Printf("Ollama at %s unloaded %s", host, before.Name)
+			continue
+		}
+		if now.GPUPercent() != before.GPUPercent() {
+			log.Printf("Ollama at %s moved %s from %s to %s", host, now.Name, before.Processor(), now.Processor())
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// fakePS serves /api/ps with whatever running models are set
+type fakePS struct {
+	mu      sync.Mutex
+	running []RunningModel
+}
+
+func (f *fakePS) set(running ...RunningModel) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.running = running
+}
+
+func (f *fakePS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.URL.Path != "/api/ps" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"models": f.running})
+}
+
+func TestRunningModel_Processor(t *testing.T) {
+	assert.Equal(t, "100% GPU", RunningModel{Size: 100, SizeVRAM: 100}.Processor())
+	assert.Equal(t, "100% CPU", RunningModel{Size: 100}.Processor())
+	assert.Equal(t, "38%/62% CPU/GPU", RunningModel{Size: 100, SizeVRAM: 62}.Processor())
+	assert.Equal(t, 0, RunningModel{}.GPUPercent())
+}
+
+func TestOllamaClient_RunningModels(t *testing.T) {
+	expires := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/ps", r.URL.Path)
+		w.Write([]byte(`{"models":[{"name":"qwen3-coder:70b","model":"qwen3-coder:70b","size":45000000000,"size_vram":20000000000,"expires_at":"2026-01-02T03:04:05Z"}]}`))
+	}))
+	defer server.Close()
+
+	running, err := NewOllamaClient(server.URL).RunningModels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, running, 1)
+	assert.Equal(t, "qwen3-coder:70b", running[0].Name)
+	assert.Equal(t, int64(20000000000), running[0].SizeVRAM)
+	assert.True(t, expires.Equal(running[0].ExpiresAt))
+	assert.Equal(t, "56%/44% CPU/GPU", running[0].Processor())
+}
+
+func TestMonitor_Poll(t *testing.T) {
+	ps := &fakePS{}
+	up := httptest.NewServer(ps)
+	defer up.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	cfg := models.DefaultServerConfig()
+	cfg.Ollama.Endpoints = []models.OllamaEndpoint{{Host: up.URL}, {Host: down.URL}}
+	monitor := NewMonitor(cfg)
+	assert.Empty(t, monitor.Snapshot(), "nothing is known before the first poll")
+
+	ps.set(RunningModel{Name: "qwen3-coder:70b", Size: 100, SizeVRAM: 100, ExpiresAt: time.Now().Add(5 * time.Minute)})
+	monitor.Poll(context.Background())
+
+	snapshot := monitor.Snapshot()
+	require.Len(t, snapshot, 2)
+	assert.Equal(t, up.URL, snapshot[0].Host)
+	require.Len(t, snapshot[0].Models, 1)
+	assert.Equal(t, "100% GPU", snapshot[0].Models[0].Processor())
+	assert.Empty(t, snapshot[0].Error)
+	assert.Equal(t, down.URL, snapshot[1].Host)
+	assert.NotEmpty(t, snapshot[1].Error)
+	assert.Empty(t, snapshot[1].Models)
+
+	// Unloaded models drop out of the next poll
+	ps.set()
+	monitor.Poll(context.Background())
+	assert.Empty(t, monitor.Snapshot()[0].Models)
+
+	// Hosts dropped from the config are no longer reported
+	cfg = models.DefaultServerConfig()
+	cfg.Ollama.Host = up.URL
+	monitor.SetConfig(cfg)
+	snapshot = monitor.Snapshot()
+	require.Len(t, snapshot, 1)
+	assert.Equal(t, up.URL, snapshot[0].Host)
+}
@@ -3,18 +3,23 @@ package platform
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 )
 
 // OllamaModel represents a model available on the Ollama server
 type OllamaModel struct {
-	Name   string  // model name/tag
-	SizeGB float64 // size on disk in GB
+	Name   string  `json:"name"`    // model name/tag
+	SizeGB float64 `json:"size_gb"` // size on disk in GB
 }
 
+// ErrModelNotFound is returned for a model the Ollama server does not have
+var ErrModelNotFound = errors.New("model not found")
+
 // OllamaClient communicates with the Ollama REST API
 type OllamaClient struct {
 	host       string
@@ -94,6 +99,61 @@ func (c *OllamaClient) ListModels(ctx context.Context) ([]OllamaModel, error) {
 	return models, nil
 }
 
+// RunningModel is a model Ollama has loaded into memory
+type RunningModel struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`       // bytes of memory the loaded model takes
+	SizeVRAM  int64     `json:"size_vram"`  // bytes of that held in GPU memory
+	ExpiresAt time.Time `json:"expires_at"` // when Ollama unloads it unless it is used again
+}
+
+// GPUPercent returns the share of the model held in GPU memory
+func (m RunningModel) GPUPercent() int {
+	if m.Size <= 0 {
+		return 0
+	}
+	return int(m.SizeVRAM * 100 / m.Size)
+}
+
+// Processor describes where the model runs the way "ollama ps" does:
+// "100% GPU", "100% CPU", or a split such as "38%/62% CPU/GPU"
+func (m RunningModel) Processor() string {
+	gpu := m.GPUPercent()
+	switch gpu {
+	case 100:
+		return "100% GPU"
+	case 0:
+		return "100% CPU"
+	}
+	return fmt.Sprintf("%d%%/%d%% CPU/GPU", 100-gpu, gpu)
+}
+
+// RunningModels returns the models Ollama currently has loaded
+func (c *OllamaClient) RunningModels(ctx context.Context) ([]RunningModel, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.host+"/api/ps", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running models on %s: %w", c.host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list running models: Ollama returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Models []RunningModel `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse running models: %w", err)
+	}
+	return result.Models, nil
+}
+
 // HasModel checks if a specific model is available on the server
 func (c *OllamaClient) HasModel(ctx context.Context, name string) (bool, error) {
 	body := fmt.Sprintf(`{"name":%q}`, name)
@@ -121,6 +181,21 @@ func (c *OllamaClient) HasModel(ctx context.Context, name string) (bool, error)
 
 // PullModel downloads a model from the Ollama registry
 func (c *OllamaClient) PullModel(ctx context.Context, name string) error {
+	return c.PullModelProgress(ctx, name, nil)
+}
+
+// PullProgress is one status update Ollama streams while pulling a model.
+// Total and Completed count bytes of the layer being downloaded, if any.
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
+// PullModelProgress downloads a model from the Ollama registry, calling
+// onProgress, if not nil, with each status update
+func (c *OllamaClient) PullModelProgress(ctx context.Context, name string, onProgress func(PullProgress)) error {
 	body := fmt.Sprintf(`{"name":%q}`, name)
 	req, err := http.NewRequestWithContext(ctx, "POST", c.host+"/api/pull", strings.NewReader(body))
 	if err != nil {
@@ -145,11 +220,130 @@ func (c *OllamaClient) PullModel(ctx context.Context, name string) error {
 		return fmt.Errorf("failed to pull model %s: HTTP %d", name, resp.StatusCode)
 	}
 
-	// Consume the full streaming NDJSON response so Ollama completes the pull.
-	// Each line is a JSON status object; we read until EOF.
-	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
-		return fmt.Errorf("failed to pull model %s: error reading response: %w", name, err)
+	// Progress streams as NDJSON until the pull finishes. Ollama reports a
+	// failure part way through as a line with an error rather than a status.
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var line struct {
+			PullProgress
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&line); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("failed to pull model %s: error reading response: %w", name, err)
+		}
+		if line.Error != "" {
+			return fmt.Errorf("failed to pull model %s: %s", name, line.Error)
+		}
+		if onProgress != nil {
+			onProgress(line.PullProgress)
+		}
 	}
+}
 
-	return nil
+// DeleteModel removes a model from the Ollama server
+func (c *OllamaClient) DeleteModel(ctx context.Context, name string) error {
+	body := fmt.Sprintf(`{"name":%q}`, name)
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.host+"/api/delete", strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create delete request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to delete model %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("failed to delete model %s: %w", name, ErrModelNotFound)
+	}
+
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(resp.Body).Decode(&errResp)
+	if errResp.Error != "" {
+		return fmt.Errorf("failed to delete model %s: %s", name, errResp.Error)
+	}
+	return fmt.Errorf("failed to delete model %s: HTTP %d", name, resp.StatusCode)
+}
+
+// ChatMessage is one turn of an Ollama chat conversation
+type ChatMessage struct {
+	Role    string `json:"role"` // "system", "user" or "assistant"
+	Content string `json:"content"`
+}
+
+// Chat sends a conversation to a model and streams the reply. onChunk is
+// called with each piece of content as it arrives; the full reply is
+// returned once the model is done.
+func (c *OllamaClient) Chat(ctx context.Context, model string, messages []ChatMessage, onChunk func(string)) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"model":    model,
+		"messages": messages,
+		"stream":   true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode chat request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.host+"/api/chat", strings.NewReader(string(body)))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to chat with %s: %w", model, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		if errResp.Error != "" {
+			return "", fmt.Errorf("failed to chat with %s: %s", model, errResp.Error)
+		}
+		return "", fmt.Errorf("failed to chat with %s: HTTP %d", model, resp.StatusCode)
+	}
+
+	// The reply streams as NDJSON, one partial message per line
+	var reply strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk struct {
+			Message ChatMessage `json:"message"`
+			Done    bool        `json:"done"`
+			Error   string      `json:"error"`
+		}
+		if err := decoder.Decode(&chunk); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return reply.String(), fmt.Errorf("failed to read chat response: %w", err)
+		}
+		if chunk.Error != "" {
+			return reply.String(), fmt.Errorf("failed to chat with %s: %s", model, chunk.Error)
+		}
+
+		reply.WriteString(chunk.Message.Content)
+		if onChunk != nil && chunk.Message.Content != "" {
+			onChunk(chunk.Message.Content)
+		}
+		if chunk.Done {
+			break
+		}
+	}
+
+	return reply.String(), nil
 }
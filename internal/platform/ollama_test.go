@@ -83,6 +83,64 @@ func TestOllamaClient_PullModel(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestOllamaClient_PullModelProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"pulling manifest"}
+{"status":"pulling abc123","digest":"sha256:abc123","total":200,"completed":50}
+{"status":"pulling abc123","digest":"sha256:abc123","total":200,"completed":200}
+{"status":"success"}
+`))
+	}))
+	defer server.Close()
+
+	var updates []PullProgress
+	client := NewOllamaClient(server.URL)
+	err := client.PullModelProgress(context.Background(), "test-model:7b", func(p PullProgress) {
+		updates = append(updates, p)
+	})
+	require.NoError(t, err)
+	require.Len(t, updates, 4)
+	assert.Equal(t, "pulling manifest", updates[0].Status)
+	assert.Equal(t, int64(200), updates[1].Total)
+	assert.Equal(t, int64(50), updates[1].Completed)
+	assert.Equal(t, "success", updates[3].Status)
+}
+
+func TestOllamaClient_PullModelProgress_StreamError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"pulling manifest"}
+{"error":"pull model manifest: file does not exist"}
+`))
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL)
+	err := client.PullModelProgress(context.Background(), "missing:7b", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "file does not exist")
+}
+
+func TestOllamaClient_DeleteModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/delete", r.URL.Path)
+		assert.Equal(t, "DELETE", r.Method)
+
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["name"] != "test-model:7b" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"model not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL)
+	require.NoError(t, client.DeleteModel(context.Background(), "test-model:7b"))
+
+	err := client.DeleteModel(context.Background(), "other:7b")
+	assert.ErrorIs(t, err, ErrModelNotFound)
+}
+
 func TestOllamaClient_PullModel_ServerError(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -239,7 +297,7 @@ func TestOllamaClient_PullModel_MalformedJSON(t *testing.T) {
 	defer server.Close()
 
 	client := NewOllamaClient(server.URL)
-	// PullModel with 200 but non-JSON body should succeed (it doesn't parse body on 200)
+	// The body of a 200 is the progress stream, so it has to be NDJSON
 	err := client.PullModel(context.Background(), "test-model")
-	assert.NoError(t, err)
+	assert.Error(t, err)
 }
@@ -0,0 +1,302 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// pingTimeout bounds a single health check of an endpoint
+const pingTimeout = 5 * time.Second
+
+// EndpointStatus is a snapshot of one endpoint in a Pool
+type EndpointStatus struct {
+	Name      string    `json:"name"`
+	Host      string    `json:"host"`
+	Tags      []string  `json:"tags,omitempty"`
+	Capacity  int       `json:"capacity"`
+	Active    int       `json:"active"`
+	Healthy   bool      `json:"healthy"`
+	Error     string    `json:"error,omitempty"`
+	Models    []string  `json:"models"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// endpoint is the pool's view of one configured Ollama server
+type endpoint struct {
+	models.OllamaEndpoint
+	client    *OllamaClient
+	healthy   bool
+	err       string
+	models    map[string]bool // installed models, by normalized name
+	active    int
+	checkedAt time.Time
+}
+
+// Pool spreads jobs across the Ollama endpoints in the server config. Each
+// job is placed on a healthy endpoint with a free slot, preferring one that
+// already has every model it needs and then the least loaded; a model that
+// is missing is pulled when the job starts. Without endpoints configured the
+// pool is disabled and jobs run against the single configured host.
+type Pool struct {
+	mu        sync.Mutex
+	cfg       *models.ServerConfig
+	endpoints []*endpoint
+	assigned  map[int64]*endpoint // endpoint each running job holds a slot on
+}
+
+// NewPool creates a pool over the endpoints in cfg. Endpoints count as down
+// until the first Check.
+func NewPool(cfg *models.ServerConfig) *Pool {
+	p := &Pool{assigned: make(map[int64]*endpoint)}
+	p.SetConfig(cfg)
+	return p
+}
+
+// SetConfig replaces the pool's endpoints. Endpoints that stay in the config
+// keep their health and the jobs placed on them.
+func (p *Pool) SetConfig(cfg *models.ServerConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	existing := make(map[string]*endpoint)
+	for _, e := range p.endpoints {
+		existing[e.Host] = e
+	}
+
+	p.cfg = cfg
+	p.endpoints = nil
+	for _, conf := range cfg.Ollama.Endpoints {
+		e, ok := existing[conf.Host]
+		if !ok {
+			e = &endpoint{client: NewOllamaClient(conf.Host)}
+		}
+		e.OllamaEndpoint = conf
+		p.endpoints = append(p.endpoints, e)
+	}
+}
+
+// Enabled reports whether the pool has endpoints to route jobs across
+func (p *Pool) Enabled() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.endpoints) > 0
+}
+
+// Check pings every endpoint and refreshes the models installed on it
+func (p *Pool) Check(ctx context.Context) {
+	p.mu.Lock()
+	endpoints := append([]*endpoint(nil), p.endpoints...)
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, e := range endpoints {
+		wg.Add(1)
+		go func(e *endpoint) {
+			defer wg.Done()
+			installed, err := probe(ctx, e.client)
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if e.healthy && err != nil {
+				log.Printf("Ollama endpoint %s is down: %v", e.Host, err)
+			} else if !e.healthy && err == nil && !e.checkedAt.IsZero() {
+				log.Printf("Ollama endpoint %s is back up", e.Host)
+			}
+			e.checkedAt = time.Now()
+			e.healthy = err == nil
+			e.err = ""
+			if err != nil {
+				e.err = err.Error()
+				return
+			}
+			e.models = installed
+		}(e)
+	}
+	wg.Wait()
+}
+
+// Run checks the endpoints every interval until ctx is cancelled
+func (p *Pool) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Check(ctx)
+		}
+	}
+}
+
+// Assign places job on an endpoint, recording its host in job.Endpoint and
+// taking one of the endpoint's slots until Release. It returns false when no
+// endpoint can take the job right now. Jobs pinned to a host with
+// ollama_host, and all jobs while the pool is disabled, are not placed.
+func (p *Pool) Assign(job *models.Job) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.endpoints) == 0 || job.OllamaHost != "" {
+		job.Endpoint = ""
+		return true
+	}
+	if e, ok := p.assigned[job.ID]; ok {
+		job.Endpoint = e.Host
+		return true
+	}
+
+	needed := job.Models(p.cfg)
+	var best *endpoint
+	for _, e := range p.endpoints {
+		if !e.healthy || e.active >= e.Slots() || !e.HasTags(job.EndpointTags) {
+			continue
+		}
+		if best == nil || better(e, best, needed) {
+			best = e
+		}
+	}
+	if best == nil {
+		return false
+	}
+
+	best.active++
+	p.assigned[job.ID] = best
+	job.Endpoint = best.Host
+	return true
+}
+
+// Release frees the slot job holds, if any
+func (p *Pool) Release(job *models.Job) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if e, ok := p.assigned[job.ID]; ok {
+		e.active--
+		delete(p.assigned, job.ID)
+	}
+}
+
+// Healthy pings the endpoint at host and reports whether it answered. Hosts
+// outside the pool are not the pool's to judge and always count as healthy.
+func (p *Pool) Healthy(ctx context.Context, host string) bool {
+	p.mu.Lock()
+	var e *endpoint
+	for _, candidate := range p.endpoints {
+		if candidate.Host == host {
+			e = candidate
+		}
+	}
+	p.mu.Unlock()
+	if e == nil {
+		return true
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	err := e.client.Ping(pingCtx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e.checkedAt = time.Now()
+	e.healthy = err == nil
+	e.err = ""
+	if err != nil {
+		e.err = err.Error()
+	}
+	return e.healthy
+}
+
+// Status returns a snapshot of every endpoint in the pool
+func (p *Pool) Status() []EndpointStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	statuses := make([]EndpointStatus, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		names := make([]string, 0, len(e.models))
+		for name := range e.models {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		name := e.Name
+		if name == "" {
+			name = e.Host
+		}
+		statuses = append(statuses, EndpointStatus{
+			Name:      name,
+			Host:      e.Host,
+			Tags:      e.Tags,
+			Capacity:  e.Slots(),
+			Active:    e.active,
+			Healthy:   e.healthy,
+			Error:     e.err,
+			Models:    names,
+			CheckedAt: e.checkedAt,
+		})
+	}
+	return statuses
+}
+
+// hasModels reports whether every one of names is installed on the endpoint
+func (e *endpoint) hasModels(names []string) bool {
+	for _, name := range names {
+		if !e.models[normalizeModel(name)] {
+			return false
+		}
+	}
+	return true
+}
+
+// better reports whether e is a better place than current for a job needing
+// the models in needed: one that has them saves a pull, and among equals the
+// less loaded wins
+func better(e, current *endpoint, needed []string) bool {
+	if has := e.hasModels(needed); has != current.hasModels(needed) {
+		return has
+	}
+	return load(e) < load(current)
+}
+
+// load is the fraction of an endpoint's slots in use
+func load(e *endpoint) float64 {
+	return float64(e.active) / float64(e.Slots())
+}
+
+// probe checks that an endpoint answers and lists its installed models
+func probe(ctx context.Context, client *OllamaClient) (map[string]bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	if err := client.Ping(ctx); err != nil {
+		return nil, err
+	}
+	list, err := client.ListModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("endpoint answered but listing models failed: %w", err)
+	}
+
+	installed := make(map[string]bool, len(list))
+	for _, m := range list {
+		installed[normalizeModel(m.Name)] = true
+	}
+	return installed, nil
+}
+
+// normalizeModel spells out the implicit ":latest" tag Ollama gives model
+// names without one, so "llama3" and "llama3:latest" compare equal
+func normalizeModel(name string) string {
+	if !strings.Contains(name, ":") {
+		return name + ":latest"
+	}
+	return name
+}
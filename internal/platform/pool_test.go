@@ -0,0 +1,189 @@
+package platform
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// fakeOllama serves /api/tags with the given installed models
+func fakeOllama(t *testing.T, installed ...string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var list []map[string]interface{}
+		for _, name := range installed {
+			list = append(list, map[string]interface{}{"name": name, "size": 1})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"models": list})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func poolConfig(endpoints ...models.OllamaEndpoint) *models.ServerConfig {
+	cfg := models.DefaultServerConfig()
+	cfg.LargeModel.Name = "qwen3-coder:70b"
+	cfg.SmallModel.Name = "qwen2.5-coder:7b"
+	cfg.Ollama.Endpoints = endpoints
+	return cfg
+}
+
+func poolJob(id int64) *models.Job {
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	job.ID = id
+	return job
+}
+
+func TestPool_Disabled(t *testing.T) {
+	pool := NewPool(models.DefaultServerConfig())
+	assert.False(t, pool.Enabled())
+
+	job := poolJob(1)
+	job.Endpoint = "http://stale:11434"
+	assert.True(t, pool.Assign(job))
+	assert.Empty(t, job.Endpoint)
+	assert.True(t, pool.Healthy(context.Background(), "http://stale:11434"))
+	assert.Empty(t, pool.Status())
+}
+
+func TestPool_AssignLeastLoaded(t *testing.T) {
+	a := fakeOllama(t, "qwen3-coder:70b", "qwen2.5-coder:7b")
+	b := fakeOllama(t, "qwen3-coder:70b", "qwen2.5-coder:7b")
+	pool := NewPool(poolConfig(
+		models.OllamaEndpoint{Name: "a", Host: a.URL, Capacity: 2},
+		models.OllamaEndpoint{Name: "b", Host: b.URL},
+	))
+
+	// Endpoints are down until checked
+	assert.False(t, pool.Assign(poolJob(1)))
+	pool.Check(context.Background())
+
+	first, second, third := poolJob(1), poolJob(2), poolJob(3)
+	require.True(t, pool.Assign(first))
+	assert.Equal(t, a.URL, first.Endpoint)
+	require.True(t, pool.Assign(second))
+	assert.Equal(t, b.URL, second.Endpoint, "b is idle while a is half full")
+	require.True(t, pool.Assign(third))
+	assert.Equal(t, a.URL, third.Endpoint)
+
+	// Every slot is taken
+	assert.False(t, pool.Assign(poolJob(4)))
+
+	pool.Release(second)
+	fourth := poolJob(4)
+	require.True(t, pool.Assign(fourth))
+	assert.Equal(t, b.URL, fourth.Endpoint)
+
+	// Assigning a job that already holds a slot keeps it where it is
+	require.True(t, pool.Assign(first))
+	assert.Equal(t, a.URL, first.Endpoint)
+}
+
+func TestPool_AssignPrefersModelsAndNeedsTags(t *testing.T) {
+	small := fakeOllama(t, "qwen2.5-coder:7b", "qwen3-coder:30b")
+	big := fakeOllama(t, "qwen2.5-coder:7b", "qwen3-coder:70b", "llama3")
+	pool := NewPool(poolConfig(
+		models.OllamaEndpoint{Host: small.URL, Capacity: 4},
+		models.OllamaEndpoint{Host: big.URL, Capacity: 4, Tags: []string{"gpu", "a100"}},
+	))
+	pool.Check(context.Background())
+
+	job := poolJob(1)
+	require.True(t, pool.Assign(job))
+	assert.Equal(t, big.URL, job.Endpoint, "only big has the default large model")
+	pool.Release(job)
+
+	job = poolJob(2)
+	job.LargeModel = "qwen3-coder:30b"
+	require.True(t, pool.Assign(job))
+	assert.Equal(t, small.URL, job.Endpoint)
+
+	// The only tagged endpoint lacks the model, so the job goes there to pull it
+	job = poolJob(3)
+	job.LargeModel = "qwen3-coder:30b"
+	job.EndpointTags = []string{"gpu"}
+	require.True(t, pool.Assign(job))
+	assert.Equal(t, big.URL, job.Endpoint)
+
+	job = poolJob(6)
+	job.EndpointTags = []string{"cpu"}
+	assert.False(t, pool.Assign(job), "no endpoint has the tag")
+
+	// Names without a tag match Ollama's implicit :latest
+	job = poolJob(4)
+	job.LargeModel = "llama3"
+	require.True(t, pool.Assign(job))
+	assert.Equal(t, big.URL, job.Endpoint)
+
+	// A model no endpoint has does not hold the job back
+	job = poolJob(7)
+	job.LargeModel = "mistral"
+	require.True(t, pool.Assign(job))
+	assert.Equal(t, small.URL, job.Endpoint, "small is the less loaded")
+
+	// Jobs pinned to a host bypass the pool
+	job = poolJob(5)
+	job.OllamaHost = "http://elsewhere:11434"
+	assert.True(t, pool.Assign(job))
+	assert.Empty(t, job.Endpoint)
+}
+
+func TestPool_EndpointGoesDown(t *testing.T) {
+	a := fakeOllama(t, "qwen3-coder:70b", "qwen2.5-coder:7b")
+	b := fakeOllama(t, "qwen3-coder:70b", "qwen2.5-coder:7b")
+	pool := NewPool(poolConfig(
+		models.OllamaEndpoint{Host: a.URL},
+		models.OllamaEndpoint{Host: b.URL},
+	))
+	pool.Check(context.Background())
+
+	job := poolJob(1)
+	require.True(t, pool.Assign(job))
+	require.Equal(t, a.URL, job.Endpoint)
+
+	a.Close()
+	assert.False(t, pool.Healthy(context.Background(), a.URL))
+	assert.True(t, pool.Healthy(context.Background(), b.URL))
+
+	// The job moves to the endpoint that is still up
+	pool.Release(job)
+	require.True(t, pool.Assign(job))
+	assert.Equal(t, b.URL, job.Endpoint)
+
+	statuses := pool.Status()
+	require.Len(t, statuses, 2)
+	assert.False(t, statuses[0].Healthy)
+	assert.NotEmpty(t, statuses[0].Error)
+	assert.True(t, statuses[1].Healthy)
+	assert.Equal(t, 1, statuses[1].Active)
+	assert.Equal(t, []string{"qwen2.5-coder:7b", "qwen3-coder:70b"}, statuses[1].Models)
+}
+
+func TestPool_SetConfigKeepsState(t *testing.T) {
+	a := fakeOllama(t, "qwen3-coder:70b", "qwen2.5-coder:7b")
+	b := fakeOllama(t, "qwen3-coder:70b", "qwen2.5-coder:7b")
+	pool := NewPool(poolConfig(models.OllamaEndpoint{Host: a.URL}))
+	pool.Check(context.Background())
+
+	job := poolJob(1)
+	require.True(t, pool.Assign(job))
+
+	pool.SetConfig(poolConfig(
+		models.OllamaEndpoint{Host: a.URL, Capacity: 2},
+		models.OllamaEndpoint{Host: b.URL},
+	))
+
+	statuses := pool.Status()
+	require.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Healthy)
+	assert.Equal(t, 1, statuses[0].Active)
+	assert.Equal(t, 2, statuses[0].Capacity)
+	assert.False(t, statuses[1].Healthy, "new endpoints are down until checked")
+}
@@ -35,14 +35,7 @@ func SelectModels(catalog *Catalog, hw *HardwareInfo) ([]ModelConfig, error) {
 			}
 
 			totalMemory := large.MemoryGB + small.MemoryGB
-			availableMemory := hw.SystemRAMGB
-			if hw.HasGPU() {
-				availableMemory += hw.BestGPU().VRAMGB
-				// For Apple Silicon, don't double-count
-				if len(hw.GPUs) == 1 && hw.GPUs[0].Type == "apple" {
-					availableMemory = hw.SystemRAMGB
-				}
-			}
+			availableMemory := hw.ModelMemoryGB()
 			tightFit := totalMemory > (availableMemory * 0.9)
 
 			configs = append(configs, ModelConfig{
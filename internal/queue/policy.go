@@ -0,0 +1,129 @@
+package queue
+
+import (
+	"sort"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// Policy decides the order in which queued jobs start
+type Policy interface {
+	// Order returns queued, which is sorted by priority and position, in the
+	// order to start the jobs. active holds the jobs already preparing or
+	// running.
+	Order(queued, active []*models.Job, now time.Time) []*models.Job
+}
+
+// NewPolicy returns the policy cfg selects
+func NewPolicy(cfg models.SchedulingConfig) Policy {
+	aging := time.Duration(cfg.AgingMinutes) * time.Minute
+	if cfg.Policy == models.PolicyFairShare {
+		return &FairShare{By: cfg.FairShareBy, Weights: cfg.Weights, Aging: aging}
+	}
+	return &StrictPriority{Aging: aging}
+}
+
+// StrictPriority starts the highest priority job first, and the one earliest
+// in the queue among equals. With Aging set, a job gains a priority level for
+// every Aging it has waited, so low priority jobs cannot starve.
+type StrictPriority struct {
+	Aging time.Duration
+}
+
+// Order implements Policy
+func (p *StrictPriority) Order(queued, active []*models.Job, now time.Time) []*models.Job {
+	ordered := append([]*models.Job(nil), queued...)
+	sortByPriority(ordered, p.Aging, now)
+	return ordered
+}
+
+// FairShare divides the server between owners, or repositories, in
+// proportion to their weight: the next job is that of the owner running the
+// fewest jobs for their weight, so one person's twenty jobs take turns with
+// everyone else's. Within an owner jobs go by priority, aged as in
+// StrictPriority.
+type FairShare struct {
+	By      string             // models.FairShareByOwner or models.FairShareByRepo
+	Weights map[string]float64 // share of each owner or repository; others get 1
+	Aging   time.Duration
+}
+
+// Order implements Policy
+func (p *FairShare) Order(queued, active []*models.Job, now time.Time) []*models.Job {
+	ordered := append([]*models.Job(nil), queued...)
+	sortByPriority(ordered, p.Aging, now)
+
+	running := make(map[string]int)
+	for _, job := range active {
+		running[p.group(job)]++
+	}
+
+	// Each group's jobs in priority order, and the order groups first appear
+	// in, which breaks ties towards the group with the best job
+	pending := make(map[string][]*models.Job)
+	var groups []string
+	for _, job := range ordered {
+		g := p.group(job)
+		if _, ok := pending[g]; !ok {
+			groups = append(groups, g)
+		}
+		pending[g] = append(pending[g], job)
+	}
+
+	// Hand out jobs one at a time to the group furthest below its share, as
+	// if each job started before the next is picked
+	result := make([]*models.Job, 0, len(ordered))
+	for len(result) < len(ordered) {
+		var next string
+		best := -1.0
+		for _, g := range groups {
+			if len(pending[g]) == 0 {
+				continue
+			}
+			if usage := float64(running[g]) / p.weight(g); best < 0 || usage < best {
+				next, best = g, usage
+			}
+		}
+		result = append(result, pending[next][0])
+		pending[next] = pending[next][1:]
+		running[next]++
+	}
+	return result
+}
+
+// group returns the owner or repository a job is accounted to
+func (p *FairShare) group(job *models.Job) string {
+	if p.By == models.FairShareByRepo {
+		return job.RepoURL
+	}
+	return job.Owner
+}
+
+func (p *FairShare) weight(group string) float64 {
+	if w, ok := p.Weights[group]; ok && w > 0 {
+		return w
+	}
+	return 1
+}
+
+// sortByPriority sorts jobs by aged priority, then queue position
+func sortByPriority(jobs []*models.Job, aging time.Duration, now time.Time) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		wi, wj := agedWeight(jobs[i], aging, now), agedWeight(jobs[j], aging, now)
+		if wi != wj {
+			return wi > wj
+		}
+		return jobs[i].Position < jobs[j].Position
+	})
+}
+
+// agedWeight returns a job's priority weight raised by a level for every
+// aging it has waited, up to high
+func agedWeight(job *models.Job, aging time.Duration, now time.Time) int {
+	weight := job.Priority.Weight()
+	if aging > 0 {
+		weight += int(now.Sub(job.CreatedAt) / aging)
+	}
+	return min(weight, models.PriorityHigh.Weight())
+}
@@ -0,0 +1,99 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var policyNow = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// policyJob returns a queued job at position that has waited for age
+func policyJob(id int64, owner, repo string, priority models.Priority, position int, age time.Duration) *models.Job {
+	return &models.Job{
+		ID:        id,
+		Owner:     owner,
+		RepoURL:   repo,
+		Priority:  priority,
+		Position:  position,
+		CreatedAt: policyNow.Add(-age),
+	}
+}
+
+func jobIDs(jobs []*models.Job) []int64 {
+	ids := make([]int64, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	return ids
+}
+
+func TestNewPolicy(t *testing.T) {
+	assert.Equal(t, &StrictPriority{}, NewPolicy(models.SchedulingConfig{}))
+	assert.Equal(t, &StrictPriority{Aging: time.Hour}, NewPolicy(models.SchedulingConfig{Policy: models.PolicyPriority, AgingMinutes: 60}))
+	assert.Equal(t,
+		&FairShare{By: models.FairShareByRepo, Weights: map[string]float64{"a": 2}},
+		NewPolicy(models.SchedulingConfig{Policy: models.PolicyFairShare, FairShareBy: models.FairShareByRepo, Weights: map[string]float64{"a": 2}}))
+}
+
+func TestStrictPriority(t *testing.T) {
+	queued := []*models.Job{
+		policyJob(1, "", "", models.PriorityLow, 1, 3*time.Hour),
+		policyJob(2, "", "", models.PriorityNormal, 3, time.Minute),
+		policyJob(3, "", "", models.PriorityHigh, 4, time.Minute),
+		policyJob(4, "", "", models.PriorityNormal, 2, time.Minute),
+	}
+
+	assert.Equal(t, []int64{3, 4, 2, 1}, jobIDs((&StrictPriority{}).Order(queued, nil, policyNow)))
+
+	// After three hours at an hour per level the low job counts as high, and
+	// is ahead of the real one by position
+	assert.Equal(t, []int64{1, 3, 4, 2}, jobIDs((&StrictPriority{Aging: time.Hour}).Order(queued, nil, policyNow)))
+}
+
+func TestFairShare_ByOwner(t *testing.T) {
+	queued := []*models.Job{
+		policyJob(1, "alice", "", models.PriorityNormal, 1, 0),
+		policyJob(2, "alice", "", models.PriorityNormal, 2, 0),
+		policyJob(3, "alice", "", models.PriorityNormal, 3, 0),
+		policyJob(4, "bob", "", models.PriorityNormal, 4, 0),
+		policyJob(5, "carol", "", models.PriorityNormal, 5, 0),
+	}
+	policy := &FairShare{}
+
+	assert.Equal(t, []int64{1, 4, 5, 2, 3}, jobIDs(policy.Order(queued, nil, policyNow)))
+
+	// alice already running one job puts her behind the others
+	active := []*models.Job{policyJob(9, "alice", "", models.PriorityNormal, 0, 0)}
+	assert.Equal(t, []int64{4, 5, 1, 2, 3}, jobIDs(policy.Order(queued, active, policyNow)))
+
+}
+
+func TestFairShare_Weights(t *testing.T) {
+	queued := []*models.Job{
+		policyJob(1, "alice", "", models.PriorityNormal, 1, 0),
+		policyJob(2, "alice", "", models.PriorityNormal, 2, 0),
+		policyJob(3, "alice", "", models.PriorityNormal, 3, 0),
+		policyJob(4, "bob", "", models.PriorityNormal, 4, 0),
+		policyJob(5, "bob", "", models.PriorityNormal, 5, 0),
+	}
+	policy := &FairShare{}
+	assert.Equal(t, []int64{1, 4, 2, 5, 3}, jobIDs(policy.Order(queued, nil, policyNow)))
+
+	// With twice bob's weight alice gets two jobs to his one
+	policy.Weights = map[string]float64{"alice": 2}
+	assert.Equal(t, []int64{1, 4, 2, 3, 5}, jobIDs(policy.Order(queued, nil, policyNow)))
+}
+
+func TestFairShare_ByRepo(t *testing.T) {
+	queued := []*models.Job{
+		policyJob(1, "alice", "repo-a", models.PriorityHigh, 1, 0),
+		policyJob(2, "bob", "repo-a", models.PriorityHigh, 2, 0),
+		policyJob(3, "alice", "repo-b", models.PriorityLow, 3, 0),
+	}
+
+	// Priority orders jobs within a repository, not across them
+	assert.Equal(t, []int64{1, 3, 2}, jobIDs((&FairShare{By: models.FairShareByRepo}).Order(queued, nil, policyNow)))
+}
@@ -1,18 +1,28 @@
 package queue
 
 import (
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/events"
 	"github.com/ryan/ralph-o-matic/internal/models"
 )
 
+// ErrQuotaExceeded is returned by Enqueue for a job whose owner already has
+// as many active jobs as the per-user quota allows
+var ErrQuotaExceeded = errors.New("queue quota exceeded")
+
 // Queue manages job scheduling and state transitions
 type Queue struct {
-	db      *db.DB
-	jobRepo *db.JobRepo
-	mu      sync.RWMutex
+	db          *db.DB
+	jobRepo     *db.JobRepo
+	mu          sync.RWMutex
+	maxPerOwner int    // active jobs each owner may have; 0 is unlimited
+	policy      Policy // order queued jobs start in
 }
 
 // New creates a new queue backed by the database
@@ -20,10 +30,27 @@ func New(database *db.DB) *Queue {
 	return &Queue{
 		db:      database,
 		jobRepo: db.NewJobRepo(database),
+		policy:  &StrictPriority{},
 	}
 }
 
-// Enqueue adds a new job to the queue
+// SetPolicy changes the order in which queued jobs start
+func (q *Queue) SetPolicy(policy Policy) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.policy = policy
+}
+
+// SetQuota limits how many queued, preparing, running or paused jobs each
+// owner may have; 0 lifts the limit. Jobs without an owner are not limited.
+func (q *Queue) SetQuota(maxPerOwner int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.maxPerOwner = maxPerOwner
+}
+
+// Enqueue adds a new job to the queue, or returns an error wrapping
+// ErrQuotaExceeded if its owner is at their quota
 func (q *Queue) Enqueue(job *models.Job) error {
 	if err := job.Validate(); err != nil {
 		return fmt.Errorf("invalid job: %w", err)
@@ -32,12 +59,34 @@ func (q *Queue) Enqueue(job *models.Job) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
+	if q.maxPerOwner > 0 && job.Owner != "" {
+		active, err := q.jobRepo.CountActive(job.Owner)
+		if err != nil {
+			return err
+		}
+		if active >= q.maxPerOwner {
+			return fmt.Errorf("%w: %s already has %d active jobs (limit %d)", ErrQuotaExceeded, job.Owner, active, q.maxPerOwner)
+		}
+	}
+
 	job.Status = models.StatusQueued
-	return q.jobRepo.Create(job)
+	if err := q.jobRepo.Create(job); err != nil {
+		return err
+	}
+
+	q.publish(job)
+	return nil
 }
 
-// Dequeue returns the next job to process (highest priority, lowest position)
+// Dequeue returns the next job to process, the first in the policy's order
 func (q *Queue) Dequeue() (*models.Job, error) {
+	return q.DequeueFunc(nil, models.StatusRunning)
+}
+
+// DequeueFunc returns the first job in the policy's order that accept takes,
+// skipping the ones it turns down, and moves it to status: running, or preparing if
+// it still has to be made ready. A nil accept takes any job.
+func (q *Queue) DequeueFunc(accept func(*models.Job) bool, status models.JobStatus) (*models.Job, error) {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
@@ -45,13 +94,25 @@ func (q *Queue) Dequeue() (*models.Job, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to list queued jobs: %w", err)
 	}
+	active, _, err := q.jobRepo.List(db.ListOptions{
+		Statuses: []models.JobStatus{models.StatusPreparing, models.StatusRunning},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list active jobs: %w", err)
+	}
 
-	if len(jobs) == 0 {
+	var job *models.Job
+	for _, candidate := range q.policy.Order(jobs, active, time.Now()) {
+		if accept == nil || accept(candidate) {
+			job = candidate
+			break
+		}
+	}
+	if job == nil {
 		return nil, nil
 	}
 
-	job := jobs[0]
-	if err := job.TransitionTo(models.StatusRunning); err != nil {
+	if err := job.TransitionTo(status); err != nil {
 		return nil, fmt.Errorf("failed to transition job: %w", err)
 	}
 
@@ -59,22 +120,53 @@ func (q *Queue) Dequeue() (*models.Job, error) {
 		return nil, fmt.Errorf("failed to update job: %w", err)
 	}
 
+	q.publish(job)
 	return job, nil
 }
 
+// SetWaitReason records why a queued job has not started yet; an empty
+// reason clears it
+func (q *Queue) SetWaitReason(job *models.Job, reason string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.jobRepo.UpdateWaitReason(job.ID, reason); err != nil {
+		return err
+	}
+
+	job.WaitReason = reason
+	q.publish(job)
+	return nil
+}
+
+// Start moves a job that finished preparing to running
+func (q *Queue) Start(job *models.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if job.Status != models.StatusPreparing {
+		return fmt.Errorf("cannot start job: job is not preparing (status: %s)", job.Status)
+	}
+
+	if err := q.transition(job, models.StatusRunning); err != nil {
+		return fmt.Errorf("cannot start job: %w", err)
+	}
+	return nil
+}
+
 // Pause pauses a running job, preserving its iteration count
 func (q *Queue) Pause(job *models.Job) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	if err := job.TransitionTo(models.StatusPaused); err != nil {
+	if err := q.transition(job, models.StatusPaused); err != nil {
 		return fmt.Errorf("cannot pause job: %w", err)
 	}
-
-	return q.jobRepo.Update(job)
+	return nil
 }
 
-// Resume resumes a paused job
+// Resume returns a paused job to the queue, preserving its iteration count,
+// so that it runs again from its workspace once a worker picks it up
 func (q *Queue) Resume(job *models.Job) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
@@ -83,11 +175,33 @@ func (q *Queue) Resume(job *models.Job) error {
 		return fmt.Errorf("cannot resume job: job is not paused (status: %s)", job.Status)
 	}
 
-	if err := job.TransitionTo(models.StatusRunning); err != nil {
+	if err := q.transition(job, models.StatusQueued); err != nil {
 		return fmt.Errorf("cannot resume job: %w", err)
 	}
+	return nil
+}
+
+// Requeue returns a preparing or running job that the server interrupted
+// while shutting down to the queue, preserving its iteration count, so that
+// it runs again from its workspace once the server is back
+func (q *Queue) Requeue(job *models.Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	from := job.Status
+	if from != models.StatusPreparing && from != models.StatusRunning {
+		return fmt.Errorf("cannot requeue job: job is not running (status: %s)", from)
+	}
 
-	return q.jobRepo.Update(job)
+	job.Status = models.StatusQueued
+	job.WaitReason = ""
+	if err := q.jobRepo.UpdateStatus(job, from); err != nil {
+		job.Status = from
+		return fmt.Errorf("cannot requeue job: %w", err)
+	}
+
+	q.publish(job)
+	return nil
 }
 
 // Complete marks a job as successfully completed
@@ -95,11 +209,10 @@ func (q *Queue) Complete(job *models.Job) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	if err := job.TransitionTo(models.StatusCompleted); err != nil {
+	if err := q.transition(job, models.StatusCompleted); err != nil {
 		return fmt.Errorf("cannot complete job: %w", err)
 	}
-
-	return q.jobRepo.Update(job)
+	return nil
 }
 
 // Fail marks a job as failed with an error message
@@ -108,11 +221,10 @@ func (q *Queue) Fail(job *models.Job, errMsg string) error {
 	defer q.mu.Unlock()
 
 	job.Error = errMsg
-	if err := job.TransitionTo(models.StatusFailed); err != nil {
+	if err := q.transition(job, models.StatusFailed); err != nil {
 		return fmt.Errorf("cannot fail job: %w", err)
 	}
-
-	return q.jobRepo.Update(job)
+	return nil
 }
 
 // Cancel cancels a job (can be called from any non-terminal state)
@@ -120,11 +232,10 @@ func (q *Queue) Cancel(job *models.Job) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	if err := job.TransitionTo(models.StatusCancelled); err != nil {
+	if err := q.transition(job, models.StatusCancelled); err != nil {
 		return fmt.Errorf("cannot cancel job: %w", err)
 	}
-
-	return q.jobRepo.Update(job)
+	return nil
 }
 
 // Reorder changes the order of queued jobs
@@ -135,6 +246,25 @@ func (q *Queue) Reorder(jobIDs []int64) error {
 	return q.jobRepo.UpdatePositions(jobIDs)
 }
 
+// ReorderAmong changes the order of the given jobs among the positions they
+// already hold, leaving every other job where it is
+func (q *Queue) ReorderAmong(jobIDs []int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	positions := make([]int, 0, len(jobIDs))
+	for _, id := range jobIDs {
+		job, err := q.jobRepo.Get(id)
+		if err != nil {
+			return fmt.Errorf("job %d: %w", id, err)
+		}
+		positions = append(positions, job.Position)
+	}
+	sort.Ints(positions)
+
+	return q.jobRepo.SetPositions(jobIDs, positions)
+}
+
 // Size returns the number of queued jobs
 func (q *Queue) Size() int {
 	q.mu.RLock()
@@ -187,5 +317,80 @@ func (q *Queue) Update(job *models.Job) error {
 	q.mu.Lock()
 	defer q.mu.Unlock()
 
-	return q.jobRepo.Update(job)
+	return q.save(job)
+}
+
+// Edit saves the priority and max_iterations of a job that has not
+// finished, after checking the job is still valid with them, and reloads the
+// rest of it
+func (q *Queue) Edit(job *models.Job) error {
+	if err := job.Validate(); err != nil {
+		return fmt.Errorf("invalid job: %w", err)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.jobRepo.UpdateSettings(job); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return fmt.Errorf("cannot edit job %d: it has finished", job.ID)
+		}
+		return err
+	}
+	current, err := q.jobRepo.Get(job.ID)
+	if err != nil {
+		return err
+	}
+	*job = *current
+
+	q.publish(job)
+	return nil
+}
+
+// transition moves job to target, saving only the status change and only
+// if the job is still in the status job was read with, so edits and
+// transitions made through another copy of it are kept. If the status had
+// changed, job is reloaded and the error wraps db.ErrStatusChanged. Callers
+// must hold q.mu.
+func (q *Queue) transition(job *models.Job, target models.JobStatus) error {
+	from := job.Status
+	if err := job.TransitionTo(target); err != nil {
+		return err
+	}
+
+	if err := q.jobRepo.UpdateStatus(job, from); err != nil {
+		if !errors.Is(err, db.ErrStatusChanged) {
+			return err
+		}
+		current, getErr := q.jobRepo.Get(job.ID)
+		if getErr != nil {
+			return getErr
+		}
+		*job = *current
+		return fmt.Errorf("%w: it is %s now", err, job.Status)
+	}
+
+	q.publish(job)
+	return nil
+}
+
+// save persists a job and announces its new state on the event bus.
+// Callers must hold q.mu.
+func (q *Queue) save(job *models.Job) error {
+	if err := q.jobRepo.Update(job); err != nil {
+		return err
+	}
+
+	q.publish(job)
+	return nil
+}
+
+// publish sends a snapshot of the job to event bus subscribers
+func (q *Queue) publish(job *models.Job) {
+	snapshot := *job
+	q.db.Events().Publish(events.Event{
+		Type:  events.JobStatus,
+		JobID: job.ID,
+		Data:  &snapshot,
+	})
 }
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/events"
 	"github.com/ryan/ralph-o-matic/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -39,6 +40,38 @@ func TestQueue_Enqueue_InvalidJob(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestQueue_Enqueue_Quota(t *testing.T) {
+	q, _ := newTestQueue(t)
+	q.SetQuota(2)
+
+	newOwnedJob := func(owner string) *models.Job {
+		job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+		job.Owner = owner
+		return job
+	}
+
+	require.NoError(t, q.Enqueue(newOwnedJob("alice")))
+	first, err := q.Dequeue()
+	require.NoError(t, err)
+	require.NoError(t, q.Enqueue(newOwnedJob("alice")))
+
+	// Running and queued jobs both count
+	err = q.Enqueue(newOwnedJob("alice"))
+	require.ErrorIs(t, err, ErrQuotaExceeded)
+	assert.Contains(t, err.Error(), "alice already has 2 active jobs (limit 2)")
+
+	// Other owners and jobs without one have their own allowance
+	assert.NoError(t, q.Enqueue(newOwnedJob("bob")))
+	assert.NoError(t, q.Enqueue(newOwnedJob("")))
+
+	// A finished job frees its slot
+	require.NoError(t, q.Complete(first))
+	assert.NoError(t, q.Enqueue(newOwnedJob("alice")))
+
+	q.SetQuota(0)
+	assert.NoError(t, q.Enqueue(newOwnedJob("alice")))
+}
+
 func TestQueue_Dequeue(t *testing.T) {
 	q, _ := newTestQueue(t)
 
@@ -84,6 +117,44 @@ func TestQueue_Dequeue_Priority(t *testing.T) {
 	assert.Equal(t, highJob.ID, dequeued.ID)
 }
 
+func TestQueue_Dequeue_Policy(t *testing.T) {
+	q, _ := newTestQueue(t)
+	q.SetPolicy(&FairShare{})
+
+	for _, owner := range []string{"alice", "alice", "bob"} {
+		job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+		job.Owner = owner
+		require.NoError(t, q.Enqueue(job))
+	}
+
+	// bob goes next while alice's first job runs, although queued after her
+	first, err := q.Dequeue()
+	require.NoError(t, err)
+	assert.Equal(t, "alice", first.Owner)
+	second, err := q.Dequeue()
+	require.NoError(t, err)
+	assert.Equal(t, "bob", second.Owner)
+}
+
+func TestQueue_Start(t *testing.T) {
+	q, _ := newTestQueue(t)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	dequeued, err := q.DequeueFunc(nil, models.StatusPreparing)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusPreparing, dequeued.Status)
+	assert.Nil(t, dequeued.StartedAt, "preparing does not count as running")
+
+	require.NoError(t, q.Start(dequeued))
+	assert.Equal(t, models.StatusRunning, dequeued.Status)
+	assert.NotNil(t, dequeued.StartedAt)
+
+	// Only preparing jobs can be started
+	assert.Error(t, q.Start(dequeued))
+}
+
 func TestQueue_Pause(t *testing.T) {
 	q, _ := newTestQueue(t)
 
@@ -111,6 +182,28 @@ func TestQueue_Pause_NotRunning(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestQueue_KeepsTransitionFromOtherCopy(t *testing.T) {
+	q, _ := newTestQueue(t)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+	dequeued, err := q.Dequeue()
+	require.NoError(t, err)
+
+	// Cancelled through the API while the worker's copy still says running
+	other, err := q.Get(job.ID)
+	require.NoError(t, err)
+	require.NoError(t, q.Cancel(other))
+
+	err = q.Complete(dequeued)
+	assert.ErrorIs(t, err, db.ErrStatusChanged)
+	assert.Equal(t, models.StatusCancelled, dequeued.Status)
+
+	current, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, current.Status)
+}
+
 func TestQueue_Resume(t *testing.T) {
 	q, _ := newTestQueue(t)
 
@@ -124,7 +217,7 @@ func TestQueue_Resume(t *testing.T) {
 	err := q.Resume(dequeued)
 	require.NoError(t, err)
 
-	assert.Equal(t, models.StatusRunning, dequeued.Status)
+	assert.Equal(t, models.StatusQueued, dequeued.Status)
 	assert.Equal(t, 5, dequeued.Iteration) // Still preserved
 }
 
@@ -138,6 +231,28 @@ func TestQueue_Resume_NotPaused(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestQueue_Edit(t *testing.T) {
+	q, _ := newTestQueue(t)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	// The scheduler starts the job while an edit is under way
+	running, err := q.Dequeue()
+	require.NoError(t, err)
+	running.Iteration = 3
+	require.NoError(t, q.Update(running))
+
+	job.MaxIterations = 20
+	require.NoError(t, q.Edit(job))
+	assert.Equal(t, models.StatusRunning, job.Status, "the edit keeps the newer status")
+	assert.Equal(t, 3, job.Iteration)
+	assert.Equal(t, 20, job.MaxIterations)
+
+	job.MaxIterations = -1
+	assert.Error(t, q.Edit(job))
+}
+
 func TestQueue_Complete(t *testing.T) {
 	q, _ := newTestQueue(t)
 
@@ -261,3 +376,25 @@ func TestQueue_GetPaused(t *testing.T) {
 	paused := q.GetPaused()
 	assert.Len(t, paused, 1)
 }
+
+func TestQueue_PublishesStatusChanges(t *testing.T) {
+	q, database := newTestQueue(t)
+
+	ch, unsub := database.Events().Subscribe(0)
+	defer unsub()
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+	dequeued, err := q.Dequeue()
+	require.NoError(t, err)
+	require.NoError(t, q.Cancel(dequeued))
+
+	var statuses []models.JobStatus
+	for i := 0; i < 3; i++ {
+		e := <-ch
+		assert.Equal(t, events.JobStatus, e.Type)
+		assert.Equal(t, job.ID, e.JobID)
+		statuses = append(statuses, e.Data.(*models.Job).Status)
+	}
+	assert.Equal(t, []models.JobStatus{models.StatusQueued, models.StatusRunning, models.StatusCancelled}, statuses)
+}
@@ -0,0 +1,182 @@
+package queue
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// DefaultLeaseTTL is how long a running job's lease stays valid without a
+// heartbeat before another process may treat the job as orphaned
+const DefaultLeaseTTL = 2 * time.Minute
+
+// heartbeatInterval is how often the scheduler refreshes leases it holds
+const heartbeatInterval = DefaultLeaseTTL / 4
+
+// WorkspaceInspector locates the workspaces that jobs left behind.
+// git.RepoManager implements it.
+type WorkspaceInspector interface {
+	WorkspacePath(jobID int64) string
+	HasWorkspace(jobID int64) bool
+}
+
+// RecoveryOptions configures the startup recovery pass
+type RecoveryOptions struct {
+	Owner      string             // lease owner ID of this process
+	LeaseTTL   time.Duration      // heartbeat age after which a lease is dead
+	MaxRetries int                // restarts a job may survive before failing
+	Workspaces WorkspaceInspector // used to decide whether a job can resume
+}
+
+// RecoveryResult summarizes what the recovery pass did
+type RecoveryResult struct {
+	Requeued []int64
+	Failed   []int64
+	Skipped  []int64 // running jobs still held by a live lease
+}
+
+// DefaultLeaseOwner identifies this process as "hostname:pid"
+func DefaultLeaseOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+// Recover reconciles jobs left preparing or running by a server that died. Each
+// orphaned job is requeued (counting against MaxRetries) so it resumes from
+// its workspace, or failed with an explanation when it cannot continue.
+func (q *Queue) Recover(opts RecoveryOptions) (*RecoveryResult, error) {
+	if opts.LeaseTTL <= 0 {
+		opts.LeaseTTL = DefaultLeaseTTL
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	running, _, err := q.jobRepo.List(db.ListOptions{
+		Statuses: []models.JobStatus{models.StatusPreparing, models.StatusRunning},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running jobs: %w", err)
+	}
+
+	logRepo := db.NewLogRepo(q.db)
+	result := &RecoveryResult{}
+	now := time.Now()
+
+	for _, job := range running {
+		if !isOrphaned(job, opts, now) {
+			result.Skipped = append(result.Skipped, job.ID)
+			continue
+		}
+
+		staleOwner := job.LeaseOwner
+		reason, restart := recoverJob(job, opts)
+		if job.Status == models.StatusFailed {
+			result.Failed = append(result.Failed, job.ID)
+		} else {
+			result.Requeued = append(result.Requeued, job.ID)
+		}
+
+		save := q.jobRepo.Update
+		if restart {
+			save = q.jobRepo.Restart
+		}
+		if err := save(job); err != nil {
+			return nil, fmt.Errorf("failed to update job %d: %w", job.ID, err)
+		}
+		if err := q.jobRepo.ReleaseLease(job.ID, staleOwner); err != nil {
+			return nil, err
+		}
+		q.publish(job)
+
+		log.Printf("Recovered job %d: %s", job.ID, reason)
+		_ = logRepo.Append(job.ID, job.Iteration, "Recovery: "+reason)
+	}
+
+	return result, nil
+}
+
+// isOrphaned reports whether a running job's owner is gone: its lease
+// expired, it never had one, or it belonged to an earlier process on this host
+func isOrphaned(job *models.Job, opts RecoveryOptions, now time.Time) bool {
+	if job.LeaseOwner == "" || job.LeaseExpired(opts.LeaseTTL, now) {
+		return true
+	}
+	if job.LeaseOwner == opts.Owner {
+		return false
+	}
+	return sameHost(job.LeaseOwner, opts.Owner)
+}
+
+func sameHost(a, b string) bool {
+	hostOf := func(owner string) string {
+		if i := strings.LastIndex(owner, ":"); i >= 0 {
+			return owner[:i]
+		}
+		return owner
+	}
+	return hostOf(a) == hostOf(b)
+}
+
+// recoverJob decides the fate of an orphaned job, mutating it in place, and
+// returns a human-readable explanation and whether the job starts over
+func recoverJob(job *models.Job, opts RecoveryOptions) (string, bool) {
+	if job.RetryCount >= opts.MaxRetries {
+		job.Error = fmt.Sprintf("server restarted while job was running; giving up after %d restart(s)", job.RetryCount)
+		_ = job.TransitionTo(models.StatusFailed)
+		return job.Error, false
+	}
+
+	if opts.Workspaces == nil {
+		_ = job.Requeue()
+		return fmt.Sprintf("requeued at iteration %d (restart %d of %d)", job.Iteration, job.RetryCount, opts.MaxRetries), false
+	}
+
+	path := opts.Workspaces.WorkspacePath(job.ID)
+	if opts.Workspaces.HasWorkspace(job.ID) {
+		_ = job.Requeue()
+		return fmt.Sprintf("requeued to resume from workspace %s at iteration %d (restart %d of %d)",
+			path, job.Iteration, job.RetryCount, opts.MaxRetries), false
+	}
+	if dirExists(path) {
+		job.Error = fmt.Sprintf("server restarted while job was running and workspace %s is not a git repository", path)
+		_ = job.TransitionTo(models.StatusFailed)
+		return job.Error, false
+	}
+
+	// Workspace is gone: its iterations are lost, so start over and forget
+	// their records
+	lost := job.Iteration
+	job.Iteration = 0
+	_ = job.Requeue()
+	return fmt.Sprintf("requeued from scratch; workspace missing, %d iteration(s) lost (restart %d of %d)",
+		lost, job.RetryCount, opts.MaxRetries), true
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// AcquireLease records that owner is running the job
+func (q *Queue) AcquireLease(jobID int64, owner string) error {
+	return q.jobRepo.AcquireLease(jobID, owner)
+}
+
+// Heartbeat refreshes owner's lease on a running job
+func (q *Queue) Heartbeat(jobID int64, owner string) error {
+	return q.jobRepo.Heartbeat(jobID, owner)
+}
+
+// ReleaseLease clears owner's lease on a job
+func (q *Queue) ReleaseLease(jobID int64, owner string) error {
+	return q.jobRepo.ReleaseLease(jobID, owner)
+}
@@ -0,0 +1,154 @@
+package queue
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/db"
+	"github.com/ryan/ralph-o-matic/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWorkspaces struct {
+	dir string
+}
+
+func (f *fakeWorkspaces) WorkspacePath(jobID int64) string {
+	return filepath.Join(f.dir, fmt.Sprintf("job-%d", jobID))
+}
+
+func (f *fakeWorkspaces) HasWorkspace(jobID int64) bool {
+	_, err := os.Stat(filepath.Join(f.WorkspacePath(jobID), ".git"))
+	return err == nil
+}
+
+// startRunningJob enqueues and dequeues a job, leasing it to owner
+func startRunningJob(t *testing.T, q *Queue, owner string, iteration int) *models.Job {
+	t.Helper()
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+	running, err := q.Dequeue()
+	require.NoError(t, err)
+	require.Equal(t, job.ID, running.ID)
+
+	running.Iteration = iteration
+	require.NoError(t, q.Update(running))
+	if owner != "" {
+		require.NoError(t, q.AcquireLease(running.ID, owner))
+	}
+	return running
+}
+
+func TestQueue_Recover_RequeuesWithWorkspace(t *testing.T) {
+	q, _ := newTestQueue(t)
+	ws := &fakeWorkspaces{dir: t.TempDir()}
+
+	job := startRunningJob(t, q, "host-a:100", 4)
+	require.NoError(t, os.MkdirAll(filepath.Join(ws.WorkspacePath(job.ID), ".git"), 0o755))
+
+	result, err := q.Recover(RecoveryOptions{Owner: "host-a:200", MaxRetries: 3, Workspaces: ws})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{job.ID}, result.Requeued)
+
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusQueued, updated.Status)
+	assert.Equal(t, 1, updated.RetryCount)
+	assert.Equal(t, 4, updated.Iteration) // Resumes where it left off
+	assert.Empty(t, updated.LeaseOwner)
+}
+
+func TestQueue_Recover_MissingWorkspaceStartsOver(t *testing.T) {
+	q, database := newTestQueue(t)
+	ws := &fakeWorkspaces{dir: t.TempDir()}
+
+	job := startRunningJob(t, q, "", 4)
+	iterations := db.NewIterationRepo(database)
+	for n := 1; n <= 4; n++ {
+		require.NoError(t, iterations.Save(models.NewIteration(job.ID, n)))
+	}
+
+	result, err := q.Recover(RecoveryOptions{Owner: "host-a:200", MaxRetries: 3, Workspaces: ws})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{job.ID}, result.Requeued)
+
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusQueued, updated.Status)
+	assert.Equal(t, 0, updated.Iteration)
+
+	// The lost iterations' records go with them, so the new run's are not mixed in
+	recorded, err := iterations.ListForJob(job.ID)
+	require.NoError(t, err)
+	assert.Empty(t, recorded)
+}
+
+func TestQueue_Recover_CorruptWorkspaceFails(t *testing.T) {
+	q, _ := newTestQueue(t)
+	ws := &fakeWorkspaces{dir: t.TempDir()}
+
+	job := startRunningJob(t, q, "", 2)
+	require.NoError(t, os.MkdirAll(ws.WorkspacePath(job.ID), 0o755))
+
+	result, err := q.Recover(RecoveryOptions{Owner: "host-a:200", MaxRetries: 3, Workspaces: ws})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{job.ID}, result.Failed)
+
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusFailed, updated.Status)
+	assert.Contains(t, updated.Error, "not a git repository")
+}
+
+func TestQueue_Recover_RetryLimit(t *testing.T) {
+	q, _ := newTestQueue(t)
+
+	job := startRunningJob(t, q, "", 2)
+	job.RetryCount = 3
+	require.NoError(t, q.Update(job))
+
+	result, err := q.Recover(RecoveryOptions{Owner: "host-a:200", MaxRetries: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{job.ID}, result.Failed)
+
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusFailed, updated.Status)
+	assert.Contains(t, updated.Error, "giving up after 3 restart(s)")
+}
+
+func TestQueue_Recover_SkipsLiveLeaseOnOtherHost(t *testing.T) {
+	q, _ := newTestQueue(t)
+
+	job := startRunningJob(t, q, "host-b:100", 2)
+
+	result, err := q.Recover(RecoveryOptions{Owner: "host-a:200", LeaseTTL: time.Minute, MaxRetries: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{job.ID}, result.Skipped)
+	assert.Empty(t, result.Requeued)
+
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusRunning, updated.Status)
+}
+
+func TestQueue_Recover_ExpiredLeaseOnOtherHost(t *testing.T) {
+	q, database := newTestQueue(t)
+
+	job := startRunningJob(t, q, "host-b:100", 2)
+	_, err := database.Conn().Exec("UPDATE jobs SET heartbeat_at = ? WHERE id = ?", time.Now().Add(-time.Hour), job.ID)
+	require.NoError(t, err)
+
+	result, err := q.Recover(RecoveryOptions{Owner: "host-a:200", LeaseTTL: time.Minute, MaxRetries: 3})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{job.ID}, result.Requeued)
+
+	logs, err := db.NewLogRepo(database).GetForJob(job.ID)
+	require.NoError(t, err)
+	require.NotEmpty(t, logs)
+	assert.Contains(t, logs[len(logs)-1].Message, "Recovery: requeued")
+}
@@ -2,36 +2,131 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
+	"github.com/ryan/ralph-o-matic/internal/db"
 	"github.com/ryan/ralph-o-matic/internal/models"
 )
 
 // JobHandler is called for each job to be processed
 type JobHandler func(ctx context.Context, job *models.Job) error
 
-// Scheduler manages job execution
+// Causes attached to a job's context when the scheduler stops it. Handlers
+// inspect them with context.Cause to decide how to wind down.
+var (
+	ErrJobPaused    = errors.New("job paused")
+	ErrJobCancelled = errors.New("job cancelled")
+	ErrShutdown     = errors.New("scheduler shutting down")
+)
+
+// ErrEndpointLost is the cause of a job stopping because the Ollama endpoint
+// it ran against went down. The scheduler keeps the job running and moves it
+// to another endpoint.
+var ErrEndpointLost = errors.New("ollama endpoint lost")
+
+// ErrAgentFailed matches the errors AgentError marks
+var ErrAgentFailed = errors.New("agent or ollama call failed")
+
+// maxEndpointMoves is how many times a job moves off a lost endpoint before
+// the scheduler gives up on it and fails it
+const maxEndpointMoves = 3
+
+// AgentError marks err as the failure of a call to the agent or Ollama. If
+// the job's endpoint is down by the time a handler returns it, the scheduler
+// blames the endpoint and moves the job instead of failing it. A nil err
+// stays nil.
+func AgentError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &agentError{err}
+}
+
+type agentError struct{ err error }
+
+func (e *agentError) Error() string   { return e.err.Error() }
+func (e *agentError) Unwrap() []error { return []error{e.err, ErrAgentFailed} }
+
+// Router places jobs on the Ollama endpoint they run against
+type Router interface {
+	// Assign reserves an endpoint for job and records it in job.Endpoint,
+	// or returns false if no endpoint can take the job right now
+	Assign(job *models.Job) bool
+	// Release frees the endpoint reserved for job
+	Release(job *models.Job)
+	// Healthy checks whether the endpoint at host still answers
+	Healthy(ctx context.Context, host string) bool
+}
+
+// Admitter decides whether the machine a job runs on has room for it
+type Admitter interface {
+	// Admit reserves what job needs to run, or returns why it has to wait
+	Admit(job *models.Job) error
+	// Release frees what was reserved for job
+	Release(job *models.Job)
+}
+
+// waitingForEndpoint is the wait reason of a job no endpoint can take
+const waitingForEndpoint = "waiting for an Ollama endpoint with its models and a free slot"
+
+// ErrNeedsAttention is returned by a handler that cannot make progress on its
+// own. The scheduler pauses the job, keeping its workspace, so that someone
+// can look at it and resume it.
+var ErrNeedsAttention = errors.New("job needs attention")
+
+// Scheduler manages job execution with a pool of workers
 type Scheduler struct {
-	queue   *Queue
-	handler JobHandler
-	signal  chan struct{}
-	running bool
-	mu      sync.RWMutex
+	queue       *Queue
+	handler     JobHandler
+	preparer    JobHandler // makes jobs ready to run; nil runs them as they are
+	signal      chan struct{}
+	running     bool
+	owner       string // lease owner ID of this process
+	concurrency int
+	active      int
+	jobs        map[int64]context.CancelCauseFunc // running jobs by ID
+	interrupted map[int64]error                   // causes of jobs stopped before their cancel func was registered
+	resumed     []*models.Job                     // jobs moved off a lost endpoint, awaiting a worker
+	front       map[int64]bool                    // queued jobs resumed by hand, dequeued ahead of the rest
+	router      Router                            // places jobs on Ollama endpoints; nil runs them anywhere
+	admitter    Admitter                          // holds jobs back until they fit; nil admits every job
+	endpoints   map[int64]string                  // endpoint each running job was placed on
+	moves       map[int64]int                     // times each job has moved off a lost endpoint
+	mu          sync.RWMutex
+
+	// Job contexts derive from jobsCtx rather than the Start context so that
+	// stopping the dispatch loop lets in-flight jobs drain until Shutdown
+	// decides to cancel them.
+	jobsCtx    context.Context
+	cancelJobs context.CancelCauseFunc
+	workers    sync.WaitGroup
 }
 
-// NewScheduler creates a new scheduler
+// NewScheduler creates a new scheduler that runs one job at a time
 func NewScheduler(queue *Queue, handler JobHandler) *Scheduler {
+	jobsCtx, cancelJobs := context.WithCancelCause(context.Background())
 	return &Scheduler{
-		queue:   queue,
-		handler: handler,
-		signal:  make(chan struct{}, 1),
+		queue:       queue,
+		handler:     handler,
+		signal:      make(chan struct{}, 1),
+		owner:       DefaultLeaseOwner(),
+		concurrency: 1,
+		jobs:        make(map[int64]context.CancelCauseFunc),
+		interrupted: make(map[int64]error),
+		front:       make(map[int64]bool),
+		endpoints:   make(map[int64]string),
+		moves:       make(map[int64]int),
+		jobsCtx:     jobsCtx,
+		cancelJobs:  cancelJobs,
 	}
 }
 
-// Start begins processing jobs until context is cancelled
+// Start dispatches jobs to workers until ctx is cancelled. Jobs already
+// running when ctx is cancelled keep going; call Shutdown to wait for them.
 func (s *Scheduler) Start(ctx context.Context) {
 	s.mu.Lock()
 	s.running = true
@@ -44,23 +139,115 @@ func (s *Scheduler) Start(ctx context.Context) {
 	}()
 
 	// Process any pending jobs immediately on startup
-	s.processNext(ctx)
+	s.dispatch()
 
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
+	watchDone := make(chan struct{})
+	defer func() { <-watchDone }()
+	go func() {
+		defer close(watchDone)
+		s.watchEndpoints(ctx)
+	}()
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-s.signal:
-			s.processNext(ctx)
+			s.dispatch()
 		case <-ticker.C:
-			s.processNext(ctx)
+			s.dispatch()
 		}
 	}
 }
 
+// SetRouter makes the scheduler place each job on an Ollama endpoint before
+// running it. Jobs no endpoint can take stay queued.
+func (s *Scheduler) SetRouter(r Router) {
+	s.mu.Lock()
+	s.router = r
+	s.mu.Unlock()
+}
+
+// SetAdmitter makes the scheduler start a job only once a admits it, on top
+// of the concurrency limit. Jobs it turns down stay queued with its reason.
+func (s *Scheduler) SetAdmitter(a Admitter) {
+	s.mu.Lock()
+	s.admitter = a
+	s.mu.Unlock()
+}
+
+// SetPreparer makes the scheduler run p on each job before its handler, for
+// example to pull the models it needs. Jobs taken from the queue wait in
+// preparing until p returns; if p fails, so does the job.
+func (s *Scheduler) SetPreparer(p JobHandler) {
+	s.mu.Lock()
+	s.preparer = p
+	s.mu.Unlock()
+}
+
+// watchEndpoints checks the endpoints of running jobs every heartbeat and
+// stops the jobs on any that went down so that they can move elsewhere
+func (s *Scheduler) watchEndpoints(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkEndpoints(ctx)
+		}
+	}
+}
+
+// checkEndpoints stops the running jobs whose endpoint no longer answers
+func (s *Scheduler) checkEndpoints(ctx context.Context) {
+	s.mu.RLock()
+	router := s.router
+	byHost := make(map[string][]int64)
+	for id, host := range s.endpoints {
+		byHost[host] = append(byHost[host], id)
+	}
+	s.mu.RUnlock()
+	if router == nil {
+		return
+	}
+
+	for host, ids := range byHost {
+		if router.Healthy(ctx, host) {
+			continue
+		}
+		for _, id := range ids {
+			s.interrupt(id, fmt.Errorf("%w: %s", ErrEndpointLost, host))
+		}
+	}
+}
+
+// Shutdown waits for running jobs to finish. If ctx expires first, the
+// remaining jobs are cancelled and Shutdown waits for their handlers to
+// return; they go back to the queue to resume on the next start.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		log.Printf("Drain timed out, cancelling %d running job(s)", s.ActiveJobs())
+		s.cancelJobs(ErrShutdown)
+		<-done
+		return ctx.Err()
+	}
+}
+
 // Signal notifies the scheduler that new work is available
 func (s *Scheduler) Signal() {
 	select {
@@ -77,25 +264,314 @@ func (s *Scheduler) IsRunning() bool {
 	return s.running
 }
 
-func (s *Scheduler) processNext(ctx context.Context) {
-	job, err := s.queue.Dequeue()
-	if err != nil {
-		log.Printf("Error dequeuing job: %v", err)
-		return
+// Owner returns the lease owner ID this scheduler records on jobs it runs
+func (s *Scheduler) Owner() string {
+	return s.owner
+}
+
+// SetConcurrency changes the maximum number of jobs run in parallel.
+// Lowering it never interrupts running jobs; it only delays new ones.
+func (s *Scheduler) SetConcurrency(n int) {
+	if n <= 0 {
+		n = 1
+	}
+
+	s.mu.Lock()
+	changed := s.concurrency != n
+	s.concurrency = n
+	s.mu.Unlock()
+
+	if changed {
+		log.Printf("Scheduler concurrency set to %d", n)
+		s.Signal()
+	}
+}
+
+// Concurrency returns the maximum number of jobs run in parallel
+func (s *Scheduler) Concurrency() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.concurrency
+}
+
+// ActiveJobs returns the number of jobs currently being handled
+func (s *Scheduler) ActiveJobs() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active
+}
+
+// dispatch starts workers for queued jobs until the pool is full
+func (s *Scheduler) dispatch() {
+	for {
+		if s.jobsCtx.Err() != nil {
+			return // Shutting down
+		}
+
+		s.mu.Lock()
+		if s.active >= s.concurrency {
+			s.mu.Unlock()
+			return
+		}
+		s.active++
+		s.mu.Unlock()
+
+		job, err := s.next()
+		if err != nil || job == nil {
+			s.release()
+			if err != nil {
+				log.Printf("Error dequeuing job: %v", err)
+			}
+			return
+		}
+
+		s.workers.Add(1)
+		go func() {
+			defer s.workers.Done()
+			defer s.release()
+			s.process(job)
+		}()
+	}
+}
+
+// next returns the next job to run: jobs moving endpoints first, then
+// resumed jobs, then the rest of the queue. Queued jobs that cannot start yet
+// are given the reason why.
+func (s *Scheduler) next() (*models.Job, error) {
+	s.mu.Lock()
+	for i := 0; i < len(s.resumed); i++ {
+		job := s.resumed[i]
+		// A job moving endpoints waits until its previous worker has let go
+		// of it
+		if _, busy := s.jobs[job.ID]; busy {
+			continue
+		}
+		// Pause and Cancel stop it moving, but a job paused or cancelled
+		// before its worker gave it up is only caught here
+		current, err := s.queue.Get(job.ID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			continue
+		}
+		if err != nil || current.Status != models.StatusRunning {
+			s.resumed = append(s.resumed[:i], s.resumed[i+1:]...)
+			delete(s.moves, job.ID)
+			i--
+			continue
+		}
+		job = current
+		if reason := reserve(job, s.router, s.admitter); reason != "" {
+			continue
+		}
+		s.resumed = append(s.resumed[:i], s.resumed[i+1:]...)
+		s.jobs[job.ID] = nil // reserve until process registers its cancel func
+		s.mu.Unlock()
+		return job, nil
+	}
+	router, admitter := s.router, s.admitter
+	status := models.StatusRunning
+	if s.preparer != nil {
+		status = models.StatusPreparing
+	}
+	busy := make(map[int64]bool, len(s.jobs))
+	for id := range s.jobs {
+		busy[id] = true
+	}
+	front := make(map[int64]bool, len(s.front))
+	for id := range s.front {
+		front[id] = true
+	}
+	s.mu.Unlock()
+
+	var waiting []*models.Job
+	var reserved *models.Job
+	reasons := make(map[int64]string)
+	tried := make(map[int64]bool)
+	accept := func(job *models.Job) bool {
+		// A job resumed while its previous run is still winding down waits
+		// until that worker has let go of it
+		if busy[job.ID] || tried[job.ID] {
+			return false
+		}
+		tried[job.ID] = true
+		reason := reserve(job, router, admitter)
+		if reason == "" {
+			reserved = job
+			return true
+		}
+		if reason != job.WaitReason {
+			waiting = append(waiting, job)
+			reasons[job.ID] = reason
+		}
+		return false
+	}
+	var job *models.Job
+	var err error
+	if len(front) > 0 {
+		job, err = s.queue.DequeueFunc(func(job *models.Job) bool {
+			return front[job.ID] && accept(job)
+		}, status)
+	}
+	if err == nil && job == nil {
+		job, err = s.queue.DequeueFunc(accept, status)
 	}
 
+	for _, w := range waiting {
+		if err := s.queue.SetWaitReason(w, reasons[w.ID]); err != nil {
+			log.Printf("Failed to record why job %d waits: %v", w.ID, err)
+		}
+	}
+	if err != nil {
+		// The job was reserved but could not be moved out of the queue, so
+		// nothing will run it and release what it holds
+		if reserved != nil {
+			unreserve(reserved, router, admitter)
+		}
+		return nil, err
+	}
 	if job == nil {
-		return // No jobs available
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	s.jobs[job.ID] = nil
+	delete(s.front, job.ID)
+	s.mu.Unlock()
+	return job, nil
+}
+
+// reserve places job on an endpoint and admits it, or returns why it cannot
+// start yet. Either of router and admitter may be nil.
+func reserve(job *models.Job, router Router, admitter Admitter) string {
+	if router != nil && !router.Assign(job) {
+		return waitingForEndpoint
 	}
+	if admitter != nil {
+		if err := admitter.Admit(job); err != nil {
+			if router != nil {
+				router.Release(job)
+			}
+			return err.Error()
+		}
+	}
+	return ""
+}
+
+// unreserve releases what reserve took for job
+func unreserve(job *models.Job, router Router, admitter Admitter) {
+	if router != nil {
+		router.Release(job)
+	}
+	if admitter != nil {
+		admitter.Release(job)
+	}
+}
 
+func (s *Scheduler) release() {
+	s.mu.Lock()
+	s.active--
+	s.mu.Unlock()
+}
+
+func (s *Scheduler) process(job *models.Job) {
 	log.Printf("Processing job %d: %s", job.ID, job.Branch)
 
-	// Create a context for this job that can be cancelled
-	jobCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	// Create a context for this job that PauseJob/CancelJob can cancel
+	jobCtx, cancel := context.WithCancelCause(s.jobsCtx)
+	s.mu.Lock()
+	s.jobs[job.ID] = cancel
+	if cause, ok := s.interrupted[job.ID]; ok {
+		delete(s.interrupted, job.ID)
+		cancel(cause)
+	}
+	router := s.router
+	admitter := s.admitter
+	preparer := s.preparer
+	if job.Endpoint != "" {
+		s.endpoints[job.ID] = job.Endpoint
+	}
+	s.mu.Unlock()
+	if job.Endpoint != "" {
+		log.Printf("Job %d placed on %s", job.ID, job.Endpoint)
+	}
+
+	if err := s.queue.AcquireLease(job.ID, s.owner); err != nil {
+		log.Printf("Failed to acquire lease on job %d: %v", job.ID, err)
+	}
+	stopHeartbeat := s.heartbeat(job.ID, cancel)
+
+	moving := false
+	defer func() {
+		stopHeartbeat()
+		cancel(nil)
+		if err := s.queue.ReleaseLease(job.ID, s.owner); err != nil {
+			log.Printf("Failed to release lease on job %d: %v", job.ID, err)
+		}
+
+		// Free the endpoint before the job can be picked up again, so a
+		// job moving elsewhere does not release its new reservation
+		if router != nil {
+			router.Release(job)
+		}
+		if admitter != nil {
+			admitter.Release(job)
+		}
+		s.mu.Lock()
+		delete(s.jobs, job.ID)
+		delete(s.endpoints, job.ID)
+		if !moving {
+			delete(s.moves, job.ID)
+		}
+		s.mu.Unlock()
+
+		// Signal that a worker slot is free
+		s.Signal()
+	}()
 
 	// Run the handler
-	if err := s.handler(jobCtx, job); err != nil {
+	if err := s.run(jobCtx, job, preparer); err != nil {
+		if cause := context.Cause(jobCtx); errors.Is(cause, ErrJobPaused) || errors.Is(cause, ErrJobCancelled) ||
+			errors.Is(cause, db.ErrLeaseLost) {
+			// Status was already updated by PauseJob/CancelJob, or belongs
+			// to whichever process now holds the lease
+			log.Printf("Job %d stopped: %v", job.ID, cause)
+			return
+		}
+
+		if s.jobsCtx.Err() != nil {
+			// Interrupted by shutdown: keep progress so the job resumes
+			// when the server starts again
+			log.Printf("Job %d interrupted by shutdown: %v", job.ID, err)
+			if err := s.queue.Requeue(job); err != nil {
+				log.Printf("Failed to requeue interrupted job: %v", err)
+			}
+			return
+		}
+
+		if endpoint := job.Endpoint; endpoint != "" && router != nil && s.lostEndpoint(jobCtx, err, router, endpoint) {
+			s.mu.Lock()
+			moved := s.moves[job.ID]
+			if moved < maxEndpointMoves {
+				// The job did not fail, its endpoint did: run it again, from
+				// the same workspace, once another endpoint can take it
+				log.Printf("Job %d lost endpoint %s, moving it: %v", job.ID, endpoint, err)
+				s.moves[job.ID]++
+				s.resumed = append(s.resumed, job)
+				moving = true
+				s.mu.Unlock()
+				return
+			}
+			s.mu.Unlock()
+			err = fmt.Errorf("lost its endpoint %d times, last %s: %w", moved+1, endpoint, err)
+		}
+
+		if errors.Is(err, ErrNeedsAttention) {
+			log.Printf("Job %d paused: %v", job.ID, err)
+			if err := s.queue.Pause(job); err != nil {
+				log.Printf("Failed to pause job: %v", err)
+			}
+			return
+		}
+
 		log.Printf("Job %d failed: %v", job.ID, err)
 		if err := s.queue.Fail(job, err.Error()); err != nil {
 			log.Printf("Failed to mark job as failed: %v", err)
@@ -109,42 +585,175 @@ func (s *Scheduler) processNext(ctx context.Context) {
 			log.Printf("Failed to mark job as completed: %v", err)
 		}
 	}
+}
+
+// lostEndpoint reports whether a job stopped with err because its endpoint
+// went down: the scheduler stopped it for that, or a call to the agent or
+// Ollama failed and the endpoint is not healthy now
+func (s *Scheduler) lostEndpoint(ctx context.Context, err error, router Router, endpoint string) bool {
+	if errors.Is(context.Cause(ctx), ErrEndpointLost) || errors.Is(err, ErrEndpointLost) {
+		return true
+	}
+	return errors.Is(err, ErrAgentFailed) && !router.Healthy(s.jobsCtx, endpoint)
+}
+
+// run prepares job, moving it from preparing to running once it is ready,
+// then hands it to the handler
+func (s *Scheduler) run(ctx context.Context, job *models.Job, preparer JobHandler) error {
+	if preparer != nil {
+		if err := preparer(ctx, job); err != nil {
+			return err
+		}
+	}
+
+	if job.Status == models.StatusPreparing {
+		// Paused or cancelled while preparing: the job is no longer ours to start
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		if err := s.queue.Start(job); err != nil {
+			return err
+		}
+	}
+
+	return s.handler(ctx, job)
+}
+
+// Pause pauses a job and stops its running subprocess. The handler commits
+// partial work so a later Resume continues from the same workspace.
+func (s *Scheduler) Pause(job *models.Job) error {
+	if err := s.queue.Pause(job); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.dropResumed(job.ID)
+	s.mu.Unlock()
+	s.interrupt(job.ID, ErrJobPaused)
+	return nil
+}
+
+// Resume returns a paused job to the queue, ahead of the jobs that have not
+// run yet, and wakes a worker for it
+func (s *Scheduler) Resume(job *models.Job) error {
+	if err := s.queue.Resume(job); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.front[job.ID] = true
+	s.mu.Unlock()
 
-	// Signal that we might have more work
 	s.Signal()
+	return nil
+}
+
+// Cancel cancels a job, stopping its subprocess if it is running
+func (s *Scheduler) Cancel(job *models.Job) error {
+	if err := s.queue.Cancel(job); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.front, job.ID)
+	s.dropResumed(job.ID)
+	s.mu.Unlock()
+	s.interrupt(job.ID, ErrJobCancelled)
+	return nil
+}
+
+// dropResumed stops a job waiting to move off a lost endpoint from moving.
+// Callers must hold s.mu.
+func (s *Scheduler) dropResumed(jobID int64) {
+	for i, job := range s.resumed {
+		if job.ID == jobID {
+			s.resumed = append(s.resumed[:i], s.resumed[i+1:]...)
+			delete(s.moves, jobID)
+			return
+		}
+	}
+}
+
+// IsJobRunning returns true if a worker is currently handling the job
+func (s *Scheduler) IsJobRunning(jobID int64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.jobs[jobID]
+	return ok
+}
+
+// interrupt cancels a running job's context with the given cause. A job
+// handed to a worker that has not registered its cancel func yet is
+// cancelled as soon as it does.
+func (s *Scheduler) interrupt(jobID int64, cause error) {
+	s.mu.Lock()
+	cancel, ok := s.jobs[jobID]
+	if ok && cancel == nil {
+		s.interrupted[jobID] = cause
+	}
+	s.mu.Unlock()
+
+	if cancel != nil {
+		log.Printf("Stopping job %d: %v", jobID, cause)
+		cancel(cause)
+	}
+}
+
+// heartbeat refreshes the job's lease until the returned stop func is called.
+// If another process has taken the lease, the job is cancelled.
+func (s *Scheduler) heartbeat(jobID int64, cancel context.CancelCauseFunc) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				err := s.queue.Heartbeat(jobID, s.owner)
+				if errors.Is(err, db.ErrLeaseLost) {
+					log.Printf("Lost lease on job %d, stopping it", jobID)
+					cancel(err)
+					return
+				}
+				if err != nil {
+					log.Printf("Heartbeat failed for job %d: %v", jobID, err)
+				}
+			}
+		}
+	}()
+
+	return func() { close(done) }
 }
 
 // PauseJob pauses a specific running job
-func (s *Scheduler) PauseJob(jobID int64) error {
+func (s *Scheduler) PauseJob(jobID int64) (*models.Job, error) {
 	job, err := s.queue.Get(jobID)
 	if err != nil {
-		return fmt.Errorf("failed to get job: %w", err)
+		return nil, fmt.Errorf("failed to get job: %w", err)
 	}
 
-	return s.queue.Pause(job)
+	return job, s.Pause(job)
 }
 
 // ResumeJob resumes a specific paused job
-func (s *Scheduler) ResumeJob(jobID int64) error {
+func (s *Scheduler) ResumeJob(jobID int64) (*models.Job, error) {
 	job, err := s.queue.Get(jobID)
 	if err != nil {
-		return fmt.Errorf("failed to get job: %w", err)
+		return nil, fmt.Errorf("failed to get job: %w", err)
 	}
 
-	if err := s.queue.Resume(job); err != nil {
-		return err
-	}
-
-	s.Signal()
-	return nil
+	return job, s.Resume(job)
 }
 
 // CancelJob cancels a specific job
-func (s *Scheduler) CancelJob(jobID int64) error {
+func (s *Scheduler) CancelJob(jobID int64) (*models.Job, error) {
 	job, err := s.queue.Get(jobID)
 	if err != nil {
-		return fmt.Errorf("failed to get job: %w", err)
+		return nil, fmt.Errorf("failed to get job: %w", err)
 	}
 
-	return s.queue.Cancel(job)
+	return job, s.Cancel(job)
 }
@@ -3,6 +3,7 @@ package queue
 import (
 	"context"
 	"fmt"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -111,6 +112,82 @@ func TestScheduler_HandlerError(t *testing.T) {
 	assert.Equal(t, models.StatusFailed, updated.Status)
 }
 
+func TestScheduler_HandlerNeedsAttention(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	handler := func(ctx context.Context, j *models.Job) error {
+		return fmt.Errorf("%w: stalled", ErrNeedsAttention)
+	}
+
+	s := NewScheduler(q, handler)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	go s.Start(ctx)
+
+	time.Sleep(200 * time.Millisecond)
+
+	// Job should be paused rather than failed
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusPaused, updated.Status)
+}
+
+func TestScheduler_KeepsEditsToRunningJob(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	handler := func(ctx context.Context, j *models.Job) error {
+		close(started)
+		<-finish
+		return nil
+	}
+
+	s := NewScheduler(q, handler)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	go s.Start(ctx)
+	<-started
+
+	// Edit the job through a copy of its own, as the API does
+	edited, err := q.Get(job.ID)
+	require.NoError(t, err)
+	edited.Priority = models.PriorityHigh
+	edited.MaxIterations = 25
+	require.NoError(t, q.Edit(edited))
+	close(finish)
+
+	require.Eventually(t, func() bool {
+		updated, err := q.Get(job.ID)
+		return err == nil && updated.Status == models.StatusCompleted
+	}, time.Second, 10*time.Millisecond)
+
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PriorityHigh, updated.Priority)
+	assert.Equal(t, 25, updated.MaxIterations)
+}
+
 func TestScheduler_JobSignal(t *testing.T) {
 	database, err := db.New(":memory:")
 	require.NoError(t, err)
@@ -142,3 +219,862 @@ func TestScheduler_JobSignal(t *testing.T) {
 
 	assert.Equal(t, int32(1), atomic.LoadInt32(&processed))
 }
+
+func TestScheduler_Concurrency(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+
+	for i := 0; i < 3; i++ {
+		job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+		require.NoError(t, q.Enqueue(job))
+	}
+
+	var current, peak int32
+	entered := make(chan struct{}, 3)
+	release := make(chan struct{})
+	handler := func(ctx context.Context, j *models.Job) error {
+		n := atomic.AddInt32(&current, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		entered <- struct{}{}
+		<-release
+		atomic.AddInt32(&current, -1)
+		return nil
+	}
+
+	s := NewScheduler(q, handler)
+	s.SetConcurrency(2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.Start(ctx)
+
+	// waitEntered blocks until n more handlers are running
+	waitEntered := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			select {
+			case <-entered:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("handler %d of %d never started", i+1, n)
+			}
+		}
+	}
+
+	waitEntered(2)
+	assert.Equal(t, 1, q.Size())
+	select {
+	case <-entered:
+		t.Fatal("a third job started above the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Raising the limit starts the remaining job without waiting for the others
+	s.SetConcurrency(3)
+	waitEntered(1)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&peak))
+
+	close(release)
+	require.Eventually(t, func() bool { return s.ActiveJobs() == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestScheduler_ShutdownDrains(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	started := make(chan struct{})
+	handler := func(ctx context.Context, j *models.Job) error {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		return nil
+	}
+
+	s := NewScheduler(q, handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.Start(ctx)
+	<-started
+	cancel()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelShutdown()
+	require.NoError(t, s.Shutdown(shutdownCtx))
+
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, updated.Status)
+}
+
+func TestScheduler_ShutdownTimeoutRequeuesJob(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	started := make(chan struct{})
+	handler := func(ctx context.Context, j *models.Job) error {
+		require.NoError(t, q.jobRepo.UpdateIteration(j.ID, 3))
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	s := NewScheduler(q, handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go s.Start(ctx)
+	<-started
+	cancel()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShutdown()
+	assert.ErrorIs(t, s.Shutdown(shutdownCtx), context.DeadlineExceeded)
+
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusQueued, updated.Status)
+	assert.Equal(t, 3, updated.Iteration)
+
+	// After a restart the job picks up where it left off
+	_, err = q.Recover(RecoveryOptions{Owner: "restarted:1"})
+	require.NoError(t, err)
+	resumed := make(chan int, 1)
+	restarted := NewScheduler(q, func(ctx context.Context, j *models.Job) error {
+		resumed <- j.Iteration
+		return nil
+	})
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go restarted.Start(ctx)
+
+	select {
+	case iteration := <-resumed:
+		assert.Equal(t, 3, iteration)
+	case <-ctx.Done():
+		t.Fatal("requeued job never ran after the restart")
+	}
+	require.Eventually(t, func() bool {
+		updated, err := q.Get(job.ID)
+		return err == nil && updated.Status == models.StatusCompleted
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestScheduler_PauseAndResumeRunningJob(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	runs := make(chan error, 2)
+	var calls int32
+	handler := func(ctx context.Context, j *models.Job) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			runs <- context.Cause(ctx)
+			return ctx.Err()
+		}
+		runs <- nil
+		return nil
+	}
+
+	s := NewScheduler(q, handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.Start(ctx)
+
+	require.Eventually(t, func() bool { return s.IsJobRunning(job.ID) }, time.Second, 10*time.Millisecond)
+
+	paused, err := s.PauseJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusPaused, paused.Status)
+	assert.ErrorIs(t, <-runs, ErrJobPaused)
+
+	require.Eventually(t, func() bool { return !s.IsJobRunning(job.ID) }, time.Second, 10*time.Millisecond)
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusPaused, updated.Status)
+
+	_, err = s.ResumeJob(job.ID)
+	require.NoError(t, err)
+
+	select {
+	case err := <-runs:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("resumed job was not picked up")
+	}
+
+	require.Eventually(t, func() bool {
+		updated, _ := q.Get(job.ID)
+		return updated.Status == models.StatusCompleted
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestScheduler_ResumedJobWaitsQueuedAheadOfOthers(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+
+	first := models.NewJob("git@github.com:user/repo.git", "first", "test", 10)
+	second := models.NewJob("git@github.com:user/repo.git", "second", "test", 10)
+	require.NoError(t, q.Enqueue(first))
+	require.NoError(t, q.Enqueue(second))
+
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	var firstRuns int32
+	handler := func(ctx context.Context, j *models.Job) error {
+		started <- j.Branch
+		if j.Branch == "first" && atomic.AddInt32(&firstRuns, 1) == 1 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		if j.Branch == "second" {
+			<-release
+		}
+		return nil
+	}
+
+	s := NewScheduler(q, handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.Start(ctx)
+
+	waitStarted := func() string {
+		t.Helper()
+		select {
+		case branch := <-started:
+			return branch
+		case <-time.After(5 * time.Second):
+			t.Fatal("no job started")
+			return ""
+		}
+	}
+
+	require.Equal(t, "first", waitStarted())
+	_, err = s.PauseJob(first.ID)
+	require.NoError(t, err)
+	require.Equal(t, "second", waitStarted())
+
+	// The only worker is busy, so the resumed job waits in the queue
+	resumed, err := s.ResumeJob(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusQueued, resumed.Status)
+	updated, err := q.Get(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusQueued, updated.Status)
+
+	third := models.NewJob("git@github.com:user/repo.git", "third", "test", 10)
+	third.Priority = models.PriorityHigh
+	require.NoError(t, q.Enqueue(third))
+
+	close(release)
+	assert.Equal(t, "first", waitStarted(), "a resumed job goes ahead of jobs that have not run")
+	assert.Equal(t, "third", waitStarted())
+}
+
+func TestScheduler_CancelRunningJob(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	stopped := make(chan error, 1)
+	handler := func(ctx context.Context, j *models.Job) error {
+		<-ctx.Done()
+		stopped <- context.Cause(ctx)
+		return fmt.Errorf("claude killed: %w", ctx.Err())
+	}
+
+	s := NewScheduler(q, handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go s.Start(ctx)
+
+	require.Eventually(t, func() bool { return s.IsJobRunning(job.ID) }, time.Second, 10*time.Millisecond)
+
+	_, err = s.CancelJob(job.ID)
+	require.NoError(t, err)
+	assert.ErrorIs(t, <-stopped, ErrJobCancelled)
+
+	require.Eventually(t, func() bool { return !s.IsJobRunning(job.ID) }, time.Second, 10*time.Millisecond)
+
+	// The handler's error must not turn the cancellation into a failure
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, updated.Status)
+	assert.Empty(t, updated.Error)
+}
+
+// fakeRouter places jobs on the first of its hosts that is up and not full
+type fakeRouter struct {
+	mu       sync.Mutex
+	hosts    []string
+	down     map[string]bool
+	active   map[string]int
+	assigned map[int64]string
+}
+
+func newFakeRouter(hosts ...string) *fakeRouter {
+	return &fakeRouter{
+		hosts:    hosts,
+		down:     make(map[string]bool),
+		active:   make(map[string]int),
+		assigned: make(map[int64]string),
+	}
+}
+
+func (r *fakeRouter) Assign(job *models.Job) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, host := range r.hosts {
+		if !r.down[host] && r.active[host] == 0 {
+			r.active[host]++
+			r.assigned[job.ID] = host
+			job.Endpoint = host
+			return true
+		}
+	}
+	return false
+}
+
+func (r *fakeRouter) Release(job *models.Job) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if host, ok := r.assigned[job.ID]; ok {
+		r.active[host]--
+		delete(r.assigned, job.ID)
+	}
+}
+
+func (r *fakeRouter) Healthy(ctx context.Context, host string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return !r.down[host]
+}
+
+func (r *fakeRouter) setDown(host string, down bool) {
+	r.mu.Lock()
+	r.down[host] = down
+	r.mu.Unlock()
+}
+
+func TestScheduler_RouterHoldsJobsWithoutEndpoint(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	ran := make(chan string, 1)
+	s := NewScheduler(q, func(ctx context.Context, j *models.Job) error {
+		ran <- j.Endpoint
+		return nil
+	})
+	router := newFakeRouter("http://a:11434")
+	router.setDown("http://a:11434", true)
+	s.SetRouter(router)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go s.Start(ctx)
+
+	// No endpoint is up, so the job waits in the queue
+	time.Sleep(100 * time.Millisecond)
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusQueued, updated.Status)
+
+	router.setDown("http://a:11434", false)
+	s.Signal()
+
+	select {
+	case endpoint := <-ran:
+		assert.Equal(t, "http://a:11434", endpoint)
+	case <-ctx.Done():
+		t.Fatal("job never ran")
+	}
+}
+
+func TestScheduler_DequeueErrorReleasesReservation(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	// Starting the job fails after it has been placed and admitted
+	_, err = database.Conn().Exec(`CREATE TRIGGER fail_start BEFORE UPDATE OF status ON jobs
+		BEGIN SELECT RAISE(FAIL, 'disk full'); END`)
+	require.NoError(t, err)
+
+	s := NewScheduler(q, func(ctx context.Context, j *models.Job) error { return nil })
+	router := newFakeRouter("http://a:11434")
+	admitter := &fakeAdmitter{admitted: make(map[int64]bool)}
+	s.SetRouter(router)
+	s.SetAdmitter(admitter)
+
+	_, err = s.next()
+	require.Error(t, err)
+	assert.Empty(t, router.assigned, "the endpoint slot is free again")
+	assert.Zero(t, router.active["http://a:11434"])
+	assert.Empty(t, admitter.admitted, "the memory is free again")
+}
+
+func TestScheduler_FailsOverWhenEndpointDies(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	router := newFakeRouter("http://a:11434", "http://b:11434")
+	var runs []string
+	var mu sync.Mutex
+	started := make(chan struct{}, 2)
+	s := NewScheduler(q, func(ctx context.Context, j *models.Job) error {
+		mu.Lock()
+		runs = append(runs, j.Endpoint)
+		mu.Unlock()
+		started <- struct{}{}
+
+		if j.Endpoint == "http://a:11434" {
+			// Runs until the scheduler notices its endpoint is gone
+			<-ctx.Done()
+			return context.Cause(ctx)
+		}
+		return nil
+	})
+	s.SetRouter(router)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go s.Start(ctx)
+
+	<-started
+	router.setDown("http://a:11434", true)
+	s.checkEndpoints(ctx)
+
+	require.Eventually(t, func() bool {
+		updated, err := q.Get(job.ID)
+		return err == nil && updated.Status == models.StatusCompleted
+	}, time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"http://a:11434", "http://b:11434"}, runs)
+}
+
+func TestScheduler_FailsOverOnHandlerErrorFromDeadEndpoint(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	router := newFakeRouter("http://a:11434", "http://b:11434")
+	s := NewScheduler(q, func(ctx context.Context, j *models.Job) error {
+		if j.Endpoint == "http://a:11434" {
+			// The agent fails because Ollama went away under it
+			router.setDown("http://a:11434", true)
+			return AgentError(fmt.Errorf("claude execution failed: connection refused"))
+		}
+		return nil
+	})
+	s.SetRouter(router)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go s.Start(ctx)
+
+	require.Eventually(t, func() bool {
+		updated, err := q.Get(job.ID)
+		return err == nil && updated.Status == models.StatusCompleted
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestScheduler_FailsOnUnrelatedErrorFromDeadEndpoint(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	router := newFakeRouter("http://a:11434", "http://b:11434")
+	var runs int32
+	s := NewScheduler(q, func(ctx context.Context, j *models.Job) error {
+		atomic.AddInt32(&runs, 1)
+		// The endpoint goes away, but that is not why the job stopped
+		router.setDown(j.Endpoint, true)
+		return fmt.Errorf("failed to setup workspace: repository not found")
+	})
+	s.SetRouter(router)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go s.Start(ctx)
+
+	require.Eventually(t, func() bool {
+		updated, err := q.Get(job.ID)
+		return err == nil && updated.Status == models.StatusFailed
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs))
+}
+
+func TestScheduler_FailsJobThatKeepsLosingEndpoints(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	router := newFakeRouter("http://a:11434", "http://b:11434")
+	var runs int32
+	s := NewScheduler(q, func(ctx context.Context, j *models.Job) error {
+		atomic.AddInt32(&runs, 1)
+		return fmt.Errorf("%w: %s", ErrEndpointLost, j.Endpoint)
+	})
+	s.SetRouter(router)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go s.Start(ctx)
+
+	require.Eventually(t, func() bool {
+		updated, err := q.Get(job.ID)
+		return err == nil && updated.Status == models.StatusFailed
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(maxEndpointMoves+1), atomic.LoadInt32(&runs))
+
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Contains(t, updated.Error, "lost its endpoint 4 times")
+	s.mu.RLock()
+	assert.Empty(t, s.moves)
+	s.mu.RUnlock()
+}
+
+func TestScheduler_CancelStopsJobMovingEndpoints(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	router := newFakeRouter("http://a:11434")
+	var runs int32
+	s := NewScheduler(q, func(ctx context.Context, j *models.Job) error {
+		atomic.AddInt32(&runs, 1)
+		router.setDown(j.Endpoint, true)
+		return fmt.Errorf("%w: %s", ErrEndpointLost, j.Endpoint)
+	})
+	s.SetRouter(router)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go s.Start(ctx)
+
+	// The only endpoint is down, so the job waits to move
+	require.Eventually(t, func() bool {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return len(s.resumed) == 1 && len(s.jobs) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = s.CancelJob(job.ID)
+	require.NoError(t, err)
+
+	router.setDown("http://a:11434", false)
+	s.Signal()
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&runs), "a cancelled job does not run again")
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, updated.Status)
+}
+
+func TestScheduler_SkipsMovingJobPausedElsewhere(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+	moving, err := q.Dequeue()
+	require.NoError(t, err)
+
+	s := NewScheduler(q, func(ctx context.Context, j *models.Job) error { return nil })
+	s.SetRouter(newFakeRouter("http://a:11434"))
+	s.resumed = append(s.resumed, moving)
+
+	// Paused through a copy of its own, as another server process would
+	other, err := q.Get(job.ID)
+	require.NoError(t, err)
+	require.NoError(t, q.Pause(other))
+
+	next, err := s.next()
+	require.NoError(t, err)
+	assert.Nil(t, next)
+	assert.Empty(t, s.resumed)
+}
+
+func TestScheduler_PreparerHoldsJobInPreparing(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	preparing := make(chan struct{})
+	ready := make(chan struct{})
+	handled := make(chan models.JobStatus, 1)
+	s := NewScheduler(q, func(ctx context.Context, j *models.Job) error {
+		handled <- j.Status
+		return nil
+	})
+	s.SetPreparer(func(ctx context.Context, j *models.Job) error {
+		close(preparing)
+		<-ready
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go s.Start(ctx)
+
+	<-preparing
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusPreparing, updated.Status)
+
+	close(ready)
+	select {
+	case status := <-handled:
+		assert.Equal(t, models.StatusRunning, status)
+	case <-ctx.Done():
+		t.Fatal("job was not handled after preparing")
+	}
+
+	require.Eventually(t, func() bool {
+		updated, _ := q.Get(job.ID)
+		return updated.Status == models.StatusCompleted
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestScheduler_PreparerErrorFailsJob(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	var handled int32
+	s := NewScheduler(q, func(ctx context.Context, j *models.Job) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+	s.SetPreparer(func(ctx context.Context, j *models.Job) error {
+		return fmt.Errorf("failed to pull model qwen3-coder:70b: file does not exist")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go s.Start(ctx)
+
+	require.Eventually(t, func() bool {
+		updated, _ := q.Get(job.ID)
+		return updated.Status == models.StatusFailed
+	}, time.Second, 10*time.Millisecond)
+
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Contains(t, updated.Error, "file does not exist")
+	assert.Nil(t, updated.StartedAt)
+	assert.Zero(t, atomic.LoadInt32(&handled), "the handler never runs")
+}
+
+func TestScheduler_CancelWhilePreparing(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+
+	job := models.NewJob("git@github.com:user/repo.git", "main", "test", 10)
+	require.NoError(t, q.Enqueue(job))
+
+	preparing := make(chan struct{})
+	var handled int32
+	s := NewScheduler(q, func(ctx context.Context, j *models.Job) error {
+		atomic.AddInt32(&handled, 1)
+		return nil
+	})
+	s.SetPreparer(func(ctx context.Context, j *models.Job) error {
+		close(preparing)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go s.Start(ctx)
+
+	<-preparing
+	require.Eventually(t, func() bool { return s.IsJobRunning(job.ID) }, time.Second, 10*time.Millisecond)
+	_, err = s.CancelJob(job.ID)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return !s.IsJobRunning(job.ID) }, time.Second, 10*time.Millisecond)
+	updated, err := q.Get(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, updated.Status)
+	assert.Zero(t, atomic.LoadInt32(&handled))
+}
+
+// fakeAdmitter admits one job at a time
+type fakeAdmitter struct {
+	mu       sync.Mutex
+	admitted map[int64]bool
+}
+
+func (a *fakeAdmitter) Admit(job *models.Job) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.admitted) > 0 && !a.admitted[job.ID] {
+		return fmt.Errorf("waiting for memory: need 42GB, 18GB free")
+	}
+	a.admitted[job.ID] = true
+	return nil
+}
+
+func (a *fakeAdmitter) Release(job *models.Job) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.admitted, job.ID)
+}
+
+func TestScheduler_AdmitterHoldsJobsWithReason(t *testing.T) {
+	database, err := db.New(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, database.Migrate())
+	defer database.Close()
+
+	q := New(database)
+	first := models.NewJob("git@github.com:user/repo.git", "first", "test", 10)
+	require.NoError(t, q.Enqueue(first))
+	second := models.NewJob("git@github.com:user/repo.git", "second", "test", 10)
+	require.NoError(t, q.Enqueue(second))
+
+	release := make(chan struct{})
+	ran := make(chan int64, 2)
+	s := NewScheduler(q, func(ctx context.Context, j *models.Job) error {
+		ran <- j.ID
+		if j.ID == first.ID {
+			<-release
+		}
+		return nil
+	})
+	s.SetConcurrency(2)
+	s.SetAdmitter(&fakeAdmitter{admitted: make(map[int64]bool)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	go s.Start(ctx)
+
+	require.Equal(t, first.ID, <-ran)
+
+	// A worker is free, but the second job does not fit next to the first
+	require.Eventually(t, func() bool {
+		updated, _ := q.Get(second.ID)
+		return updated.WaitReason != ""
+	}, time.Second, 10*time.Millisecond)
+	updated, err := q.Get(second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusQueued, updated.Status)
+	assert.Equal(t, "waiting for memory: need 42GB, 18GB free", updated.WaitReason)
+
+	close(release)
+	select {
+	case id := <-ran:
+		assert.Equal(t, second.ID, id)
+	case <-ctx.Done():
+		t.Fatal("second job never ran")
+	}
+
+	updated, err = q.Get(second.ID)
+	require.NoError(t, err)
+	assert.Empty(t, updated.WaitReason, "a started job no longer waits")
+}
@@ -0,0 +1,124 @@
+package sandbox
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// forwardFlag collects -forward listen=socket pairs
+type forwardFlag [][2]string
+
+func (f *forwardFlag) String() string {
+	return fmt.Sprint(*f)
+}
+
+func (f *forwardFlag) Set(value string) error {
+	listen, socket, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("want address=socket, got %q", value)
+	}
+	*f = append(*f, [2]string{listen, socket})
+	return nil
+}
+
+// RunHelper is the helper's main function, run inside a sandbox with the
+// arguments after HelperArg. It forwards each -forward address to its
+// socket, runs the command after --, passing on termination signals, and
+// returns its exit code.
+func RunHelper(args []string) int {
+	fs := flag.NewFlagSet(HelperArg, flag.ContinueOnError)
+	var forwards forwardFlag
+	fs.Var(&forwards, "forward", "`address=socket` to forward connections from")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	command := fs.Args()
+	if len(command) == 0 {
+		fmt.Fprintln(os.Stderr, "sandbox: no command to run")
+		return 2
+	}
+
+	for _, f := range forwards {
+		l, err := net.Listen("tcp", f[0])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+			return 1
+		}
+		go forward(l, "unix", f[1])
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	// In a container the helper is PID 1, which no signal reaches unless it
+	// handles it
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+		return 127
+	}
+	go func() {
+		for sig := range signals {
+			_ = cmd.Process.Signal(sig)
+		}
+	}()
+
+	var exitErr *exec.ExitError
+	if err := cmd.Wait(); errors.As(err, &exitErr) {
+		if code := exitErr.ExitCode(); code >= 0 {
+			return code
+		}
+		return 1
+	} else if err != nil {
+		return 1
+	}
+	return 0
+}
+
+// forward connects everything l accepts to address until l is closed
+func forward(l net.Listener, network, address string) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			upstream, err := net.DialTimeout(network, address, dialTimeout)
+			if err != nil {
+				return
+			}
+			defer upstream.Close()
+			pipe(conn, upstream)
+		}()
+	}
+}
+
+// pipe copies between a and b in both directions until both are done,
+// passing on each side's end of input to the other
+func pipe(a, b net.Conn) {
+	done := make(chan struct{})
+	go func() {
+		io.Copy(a, b)
+		closeWrite(a)
+		close(done)
+	}()
+	io.Copy(b, a)
+	closeWrite(b)
+	<-done
+}
+
+func closeWrite(c net.Conn) {
+	if cw, ok := c.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+}
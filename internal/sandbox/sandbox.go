@@ -0,0 +1,434 @@
+// Package sandbox confines the commands a job runs, so that whatever a model
+// has an agent do can only touch the job's workspace and reach the job's
+// Ollama host and git remote.
+//
+// Each command runs in a bubblewrap sandbox or a rootless container with a
+// network namespace of its own, which has no route out. The server binary
+// runs inside as a helper that listens on loopback addresses and forwards
+// connections over unix sockets to the server, which completes them only to
+// the destinations the job is allowed.
+package sandbox
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// HelperArg is the first argument with which the server binary runs as the
+// forwarding helper inside a sandbox
+const HelperArg = "sandbox-helper"
+
+// Where a sandbox sees the helper binary and the forwarding sockets
+const (
+	helperPath = "/run/ralph-helper"
+	socketDir  = "/run/ralph"
+)
+
+// dialTimeout bounds connecting to an allowed destination
+const dialTimeout = 10 * time.Second
+
+// Sandbox runs commands confined by a runtime
+type Sandbox struct {
+	cfg    models.SandboxConfig
+	helper string // the server binary
+}
+
+// New checks the runtime cfg names is installed and returns a sandbox using
+// it
+func New(cfg models.SandboxConfig) (*Sandbox, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := exec.LookPath(cfg.Runtime); err != nil {
+		return nil, fmt.Errorf("sandbox runtime %s is not installed: %w", cfg.Runtime, err)
+	}
+	if cfg.Runtime == models.SandboxBwrap && (cfg.CPUs > 0 || cfg.MemoryGB > 0) {
+		if _, err := exec.LookPath("systemd-run"); err != nil {
+			return nil, fmt.Errorf("cpu and memory limits with bwrap need systemd-run: %w", err)
+		}
+	}
+
+	helper, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the server binary: %w", err)
+	}
+	return &Sandbox{cfg: cfg, helper: helper}, nil
+}
+
+// route is a destination a sandbox may connect to
+type route struct {
+	host, port string // as the job names it
+	local      string // the loopback address it is reached at inside the sandbox
+	socket     string // file name of its socket in the session's socket directory
+}
+
+// Session confines the commands of one job run, forwarding connections from
+// them to the destinations it was opened with for as long as it is open
+type Session struct {
+	sb        *Sandbox
+	name      string
+	dir       string // holds the sockets, hosts file and env files
+	repo      string // root of the repository commands run in, if set
+	routes    []route
+	listeners []net.Listener
+
+	mu   sync.Mutex
+	runs int // commands wrapped, which number their containers
+}
+
+// Open starts a session named name whose commands may connect to the hosts
+// of the given URLs: http(s) URLs such as the Ollama host, and git remotes
+// in URL or scp-like form. Local paths need no network and are skipped.
+func (s *Sandbox) Open(name string, allow ...string) (*Session, error) {
+	dir, err := os.MkdirTemp("", "ralph-sandbox-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sandbox directory: %w", err)
+	}
+	session := &Session{sb: s, name: name, dir: dir}
+	if err := session.listen(allow); err != nil {
+		session.Close()
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hosts"), []byte(session.hosts()), 0o644); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("failed to write hosts file: %w", err)
+	}
+	return session, nil
+}
+
+// listen opens a socket forwarding to each destination
+func (s *Session) listen(allow []string) error {
+	if err := os.Mkdir(filepath.Join(s.dir, "sockets"), 0o700); err != nil {
+		return fmt.Errorf("failed to create socket directory: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, raw := range allow {
+		host, port, err := destination(raw)
+		if err != nil {
+			return err
+		}
+		target := net.JoinHostPort(host, port)
+		if host == "" || seen[target] {
+			continue
+		}
+		seen[target] = true
+
+		r := route{
+			host:   host,
+			port:   port,
+			local:  net.JoinHostPort(fmt.Sprintf("127.0.0.%d", len(s.routes)+2), port),
+			socket: fmt.Sprintf("%d.sock", len(s.routes)),
+		}
+		l, err := net.Listen("unix", filepath.Join(s.dir, "sockets", r.socket))
+		if err != nil {
+			return fmt.Errorf("failed to listen for %s: %w", target, err)
+		}
+		s.listeners = append(s.listeners, l)
+		s.routes = append(s.routes, r)
+		go forward(l, "tcp", target)
+	}
+	return nil
+}
+
+// hosts returns the sandbox's /etc/hosts, which resolves the allowed host
+// names to the addresses they are forwarded from
+func (s *Session) hosts() string {
+	var b strings.Builder
+	b.WriteString("127.0.0.1 localhost\n::1 localhost\n")
+	for _, r := range s.routes {
+		if r.host != "localhost" && net.ParseIP(r.host) == nil {
+			ip, _, _ := net.SplitHostPort(r.local)
+			fmt.Fprintf(&b, "%s %s\n", ip, r.host)
+		}
+	}
+	return b.String()
+}
+
+// Config returns cfg with its Ollama host replaced by the address the
+// sandbox reaches it at. Host names resolve inside the sandbox too, but IP
+// addresses other than loopback ones cannot be forwarded as they are. A nil
+// session returns cfg unchanged.
+func (s *Session) Config(cfg *models.ServerConfig) *models.ServerConfig {
+	if s == nil {
+		return cfg
+	}
+	u, err := url.Parse(cfg.Ollama.Host)
+	if err != nil {
+		return cfg
+	}
+	for _, r := range s.routes {
+		if r.host == u.Hostname() && r.port == urlPort(u) {
+			merged := *cfg
+			u.Host = r.local
+			merged.Ollama.Host = u.String()
+			return &merged
+		}
+	}
+	return cfg
+}
+
+// SetRepository names the root of the repository the session's commands run
+// in, whose .git is mounted read-only even when only a subdirectory of it is
+// the working directory
+func (s *Session) SetRepository(root string) {
+	s.repo = root
+}
+
+// Wrap rewrites cmd to run in the sandbox, with its working directory as the
+// only writable mount and mounts, such as files its arguments name, mounted
+// read-only. The returned function cleans up after cmd has exited.
+func (s *Session) Wrap(cmd *exec.Cmd, mounts ...string) (func(), error) {
+	if cmd.Dir == "" {
+		return nil, fmt.Errorf("sandboxed commands need a working directory")
+	}
+	dir, err := filepath.Abs(cmd.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	// The server's home is not mounted; give the command a scratch one
+	cmd.Env = append(cmd.Env, "HOME=/tmp")
+
+	// The command is looked up again on the sandbox's PATH, and need not
+	// exist outside it at all
+	inner := []string{helperPath, HelperArg}
+	for _, r := range s.routes {
+		inner = append(inner, "-forward", r.local+"="+socketDir+"/"+r.socket)
+	}
+	inner = append(inner, "--")
+	inner = append(inner, cmd.Args...)
+
+	mounts = append(append([]string(nil), s.sb.cfg.Mounts...), mounts...)
+	var args []string
+	cleanup := func() {}
+	switch s.sb.cfg.Runtime {
+	case models.SandboxBwrap:
+		args = s.bwrapArgs(dir, mounts, inner)
+	default:
+		s.mu.Lock()
+		s.runs++
+		container := fmt.Sprintf("%s-%d", s.name, s.runs)
+		s.mu.Unlock()
+
+		envFile := filepath.Join(s.dir, container+".env")
+		file, inherit := containerEnv(cmd.Env)
+		if err := os.WriteFile(envFile, []byte(file), 0o600); err != nil {
+			return nil, fmt.Errorf("failed to write env file: %w", err)
+		}
+		args = s.containerArgs(container, envFile, inherit, dir, mounts, inner)
+
+		// The container outlives its CLI if that is killed
+		runtime := s.sb.cfg.Runtime
+		cleanup = func() {
+			_ = exec.Command(runtime, "rm", "-f", container).Run()
+			os.Remove(envFile)
+		}
+	}
+
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		return nil, err
+	}
+	cmd.Path, cmd.Args, cmd.Err = path, args, nil
+	return cleanup, nil
+}
+
+// bwrapArgs runs inner in bubblewrap on a read-only view of the host's
+// system directories, wrapped in a systemd scope when it has limits
+func (s *Session) bwrapArgs(dir string, mounts, inner []string) []string {
+	var args []string
+	if limits := s.systemdLimits(); len(limits) > 0 {
+		args = append([]string{"systemd-run", "--user", "--scope", "--quiet", "--collect"}, limits...)
+	}
+	args = append(args, "bwrap", "--unshare-all", "--die-with-parent", "--ro-bind", "/usr", "/usr")
+	for _, p := range []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64"} {
+		if target, err := os.Readlink(p); err == nil {
+			args = append(args, "--symlink", target, p)
+		} else {
+			args = append(args, "--ro-bind-try", p, p)
+		}
+	}
+	args = append(args,
+		"--ro-bind", "/etc", "/etc",
+		"--ro-bind", filepath.Join(s.dir, "hosts"), "/etc/hosts",
+		"--proc", "/proc",
+		"--dev", "/dev",
+		"--tmpfs", "/tmp",
+	)
+	for _, m := range mounts {
+		args = append(args, "--ro-bind-try", m, m)
+	}
+	args = append(args,
+		"--bind", filepath.Join(s.dir, "sockets"), socketDir,
+		"--ro-bind", s.sb.helper, helperPath,
+		"--bind", dir, dir,
+	)
+	for _, p := range s.readOnly(dir) {
+		args = append(args, "--ro-bind", p, p)
+	}
+	args = append(args, "--chdir", dir, "--")
+	return append(args, inner...)
+}
+
+// systemdLimits returns the systemd-run properties of the CPU and memory
+// limits
+func (s *Session) systemdLimits() []string {
+	var props []string
+	if s.sb.cfg.CPUs > 0 {
+		props = append(props, "-p", fmt.Sprintf("CPUQuota=%.0f%%", s.sb.cfg.CPUs*100))
+	}
+	if s.sb.cfg.MemoryGB > 0 {
+		props = append(props, "-p", fmt.Sprintf("MemoryMax=%.0fM", s.sb.cfg.MemoryGB*1024))
+	}
+	return props
+}
+
+// containerArgs runs inner in a container of the configured image as the
+// server's user, so that files it writes in the workspace stay the server's.
+// The variables named in inherit are passed on from the runtime CLI's own
+// environment.
+func (s *Session) containerArgs(name, envFile string, inherit []string, dir string, mounts, inner []string) []string {
+	args := []string{s.sb.cfg.Runtime, "run", "--rm", "--interactive",
+		"--name", name,
+		"--network", "none",
+		"--env-file", envFile,
+	}
+	for _, name := range inherit {
+		args = append(args, "--env", name)
+	}
+	if s.sb.cfg.Runtime == models.SandboxPodman {
+		args = append(args, "--userns", "keep-id")
+	} else {
+		args = append(args, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+	if s.sb.cfg.CPUs > 0 {
+		args = append(args, "--cpus", fmt.Sprintf("%g", s.sb.cfg.CPUs))
+	}
+	if s.sb.cfg.MemoryGB > 0 {
+		args = append(args, "--memory", fmt.Sprintf("%.0fm", s.sb.cfg.MemoryGB*1024))
+	}
+	for _, m := range mounts {
+		args = append(args, "--volume", m+":"+m+":ro")
+	}
+	args = append(args,
+		"--volume", filepath.Join(s.dir, "hosts")+":/etc/hosts:ro",
+		"--volume", filepath.Join(s.dir, "sockets")+":"+socketDir,
+		"--volume", s.sb.helper+":"+helperPath+":ro",
+		"--volume", dir+":"+dir,
+	)
+	for _, p := range s.readOnly(dir) {
+		args = append(args, "--volume", p+":"+p+":ro")
+	}
+	args = append(args,
+		"--workdir", dir,
+		"--entrypoint", helperPath,
+		s.sb.cfg.Image,
+	)
+	return append(args, inner[1:]...)
+}
+
+// readOnly returns the paths mounted read-only alongside the writable dir:
+// the repository's .git, since the server runs git on it outside the sandbox
+// afterwards and a planted hook or config setting would run there. The
+// repository is dir itself unless SetRepository named another root.
+func (s *Session) readOnly(dir string) []string {
+	root := dir
+	if s.repo != "" {
+		root = s.repo
+	}
+	gitDir := filepath.Join(root, ".git")
+	if _, err := os.Stat(gitDir); err != nil {
+		return nil
+	}
+	return []string{gitDir}
+}
+
+// containerEnv renders env as an env file, leaving out the server's PATH,
+// which is no use in the image. Values an env file cannot hold, such as
+// multi-line keys, are returned by name in inherit instead, for the runtime
+// to take from its own environment. Later entries win, as with exec.Cmd.
+func containerEnv(env []string) (file string, inherit []string) {
+	values := make(map[string]string)
+	var names []string
+	for _, kv := range env {
+		name, value, _ := strings.Cut(kv, "=")
+		if name == "PATH" {
+			continue
+		}
+		if _, ok := values[name]; !ok {
+			names = append(names, name)
+		}
+		values[name] = value
+	}
+
+	var b strings.Builder
+	for _, name := range names {
+		if strings.ContainsAny(values[name], "\n\r") {
+			inherit = append(inherit, name)
+			continue
+		}
+		fmt.Fprintf(&b, "%s=%s\n", name, values[name])
+	}
+	return b.String(), inherit
+}
+
+// Close stops forwarding and removes the session's files
+func (s *Session) Close() error {
+	for _, l := range s.listeners {
+		l.Close()
+	}
+	return os.RemoveAll(s.dir)
+}
+
+// destination returns the host and port a URL or git remote connects to,
+// or an empty host for a local path
+func destination(raw string) (host, port string, err error) {
+	if !strings.Contains(raw, "://") {
+		// scp-like git remote: [user@]host:path
+		before, _, ok := strings.Cut(raw, ":")
+		if !ok || strings.Contains(before, "/") {
+			return "", "", nil
+		}
+		if _, h, ok := strings.Cut(before, "@"); ok {
+			before = h
+		}
+		return before, "22", nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid sandbox destination %q: %w", raw, err)
+	}
+	if u.Scheme == "file" {
+		return "", "", nil
+	}
+	return u.Hostname(), urlPort(u), nil
+}
+
+// urlPort returns the port of u, or its scheme's default
+func urlPort(u *url.URL) string {
+	if p := u.Port(); p != "" {
+		return p
+	}
+	switch u.Scheme {
+	case "https":
+		return "443"
+	case "ssh", "git+ssh":
+		return "22"
+	case "git":
+		return "9418"
+	}
+	return "80"
+}
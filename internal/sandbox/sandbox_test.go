@@ -0,0 +1,187 @@
+package sandbox
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ryan/ralph-o-matic/internal/models"
+)
+
+// openTestSession opens a session without checking a runtime is installed
+func openTestSession(t *testing.T, cfg models.SandboxConfig, allow ...string) *Session {
+	t.Helper()
+	sb := &Sandbox{cfg: cfg, helper: "/opt/ralph/ralph-o-matic-server"}
+	session, err := sb.Open("ralph-job-7", allow...)
+	require.NoError(t, err)
+	t.Cleanup(func() { session.Close() })
+	return session
+}
+
+// echoServer returns the address of a TCP server that echoes lines back
+func echoServer(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					conn.Write([]byte(scanner.Text() + "\n"))
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func roundTrip(t *testing.T, network, address string) string {
+	t.Helper()
+	conn, err := net.Dial(network, address)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("ping\n"))
+	require.NoError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	return line
+}
+
+func TestDestination(t *testing.T) {
+	tests := []struct {
+		raw, host, port string
+	}{
+		{"http://localhost:11434", "localhost", "11434"},
+		{"https://github.com/user/repo.git", "github.com", "443"},
+		{"git@github.com:user/repo.git", "github.com", "22"},
+		{"ssh://git@git.example.com:2222/repo.git", "git.example.com", "2222"},
+		{"/srv/git/repo.git", "", ""},
+		{"file:///srv/git/repo.git", "", ""},
+	}
+	for _, tt := range tests {
+		host, port, err := destination(tt.raw)
+		require.NoError(t, err, tt.raw)
+		assert.Equal(t, tt.host, host, tt.raw)
+		assert.Equal(t, tt.port, port, tt.raw)
+	}
+}
+
+func TestSession_Routes(t *testing.T) {
+	session := openTestSession(t, models.SandboxConfig{Runtime: models.SandboxBwrap},
+		"http://localhost:11434", "git@github.com:user/repo.git", "http://localhost:11434", "/srv/repo.git")
+
+	require.Len(t, session.routes, 2, "duplicates and local paths need no route")
+	assert.Equal(t, "127.0.0.2:11434", session.routes[0].local)
+	assert.Equal(t, "127.0.0.3:22", session.routes[1].local)
+
+	hosts, err := os.ReadFile(filepath.Join(session.dir, "hosts"))
+	require.NoError(t, err)
+	assert.Contains(t, string(hosts), "127.0.0.3 github.com\n")
+	assert.NotContains(t, string(hosts), "127.0.0.2", "Ollama is reached through the rewritten config")
+
+	cfg := models.DefaultServerConfig()
+	assert.Equal(t, "http://127.0.0.2:11434", session.Config(cfg).Ollama.Host)
+	assert.Equal(t, "http://localhost:11434", cfg.Ollama.Host, "the original is not changed")
+
+	var none *Session
+	assert.Same(t, cfg, none.Config(cfg))
+
+	dir := session.dir
+	require.NoError(t, session.Close())
+	assert.NoDirExists(t, dir)
+}
+
+func TestSession_Forwards(t *testing.T) {
+	target := echoServer(t)
+	session := openTestSession(t, models.SandboxConfig{Runtime: models.SandboxBwrap}, "http://"+target)
+	socket := filepath.Join(session.dir, "sockets", session.routes[0].socket)
+
+	// The server side completes connections to the allowed destination
+	assert.Equal(t, "ping\n", roundTrip(t, "unix", socket))
+
+	// and the helper's listeners pass them on from inside the sandbox
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go forward(l, "unix", socket)
+	assert.Equal(t, "ping\n", roundTrip(t, "tcp", l.Addr().String()))
+}
+
+func TestSession_BwrapArgs(t *testing.T) {
+	session := openTestSession(t, models.SandboxConfig{Runtime: models.SandboxBwrap, CPUs: 2, MemoryGB: 8, Mounts: []string{"/opt/agents"}},
+		"http://localhost:11434")
+
+	args := session.bwrapArgs("/work/job-7", []string{"/opt/agents"}, []string{helperPath, HelperArg, "--", "claude"})
+	line := strings.Join(args, " ")
+	assert.True(t, strings.HasPrefix(line, "systemd-run --user --scope --quiet --collect -p CPUQuota=200% -p MemoryMax=8192M bwrap --unshare-all"), line)
+	assert.Contains(t, line, "--ro-bind-try /opt/agents /opt/agents")
+	assert.Contains(t, line, "--ro-bind "+session.dir+"/hosts /etc/hosts")
+	assert.Contains(t, line, "--ro-bind /opt/ralph/ralph-o-matic-server "+helperPath)
+	assert.Contains(t, line, "--bind /work/job-7 /work/job-7 --chdir /work/job-7 -- "+helperPath+" "+HelperArg+" -- claude")
+}
+
+func TestSession_GitReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(dir, ".git"), 0o755))
+	gitDir := filepath.Join(dir, ".git")
+	inner := []string{helperPath, HelperArg, "--", "claude"}
+
+	session := openTestSession(t, models.SandboxConfig{Runtime: models.SandboxBwrap})
+	line := strings.Join(session.bwrapArgs(dir, nil, inner), " ")
+	assert.Contains(t, line, "--bind "+dir+" "+dir+" --ro-bind "+gitDir+" "+gitDir+" --chdir "+dir)
+
+	session = openTestSession(t, models.SandboxConfig{Runtime: models.SandboxPodman, Image: "ralph-agents"})
+	line = strings.Join(session.containerArgs("ralph-job-7-1", "/tmp/env", nil, dir, nil, inner), " ")
+	assert.Contains(t, line, "--volume "+dir+":"+dir+" --volume "+gitDir+":"+gitDir+":ro --workdir "+dir)
+}
+
+func TestSession_GitReadOnlyInSubdirectory(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.Mkdir(filepath.Join(root, ".git"), 0o755))
+	gitDir := filepath.Join(root, ".git")
+	dir := filepath.Join(root, "services", "api")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0o755))
+
+	session := openTestSession(t, models.SandboxConfig{Runtime: models.SandboxBwrap})
+	session.SetRepository(root)
+	line := strings.Join(session.bwrapArgs(dir, nil, []string{helperPath, HelperArg, "--", "claude"}), " ")
+	assert.Contains(t, line, "--bind "+dir+" "+dir+" --ro-bind "+gitDir+" "+gitDir+" --chdir "+dir)
+}
+
+func TestSession_ContainerArgs(t *testing.T) {
+	session := openTestSession(t, models.SandboxConfig{Runtime: models.SandboxDocker, Image: "ralph-agents", CPUs: 1.5, MemoryGB: 4},
+		"http://localhost:11434")
+
+	args := session.containerArgs("ralph-job-7-1", "/tmp/env", []string{"DEPLOY_KEY"}, "/work/job-7", nil, []string{helperPath, HelperArg, "--", "claude"})
+	line := strings.Join(args, " ")
+	assert.True(t, strings.HasPrefix(line, "docker run --rm --interactive --name ralph-job-7-1 --network none --env-file /tmp/env --env DEPLOY_KEY --user "), line)
+	assert.Contains(t, line, "--cpus 1.5 --memory 4096m")
+	assert.Contains(t, line, "--volume "+session.dir+"/sockets:"+socketDir)
+	assert.True(t, strings.HasSuffix(line, "--workdir /work/job-7 --entrypoint "+helperPath+" ralph-agents "+HelperArg+" -- claude"), line)
+}
+
+func TestContainerEnv(t *testing.T) {
+	env, inherit := containerEnv([]string{"PATH=/usr/bin", "HOME=/home/ralph", "KEY=a", "MULTI=a\nb", "HOME=/tmp"})
+	assert.Equal(t, "HOME=/tmp\nKEY=a\n", env)
+	assert.Equal(t, []string{"MULTI"}, inherit, "multi-line values are passed on by name")
+}
+
+func TestRunHelper(t *testing.T) {
+	assert.Equal(t, 3, RunHelper([]string{"--", "sh", "-c", "exit 3"}))
+	assert.Equal(t, 0, RunHelper([]string{"-forward", "127.0.0.1:0=/nonexistent.sock", "--", "true"}))
+	assert.Equal(t, 2, RunHelper(nil))
+	assert.Equal(t, 127, RunHelper([]string{"--", "ralph-no-such-command"}))
+}